@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// a11yOutput is set with -a11y to print the results as plain linear text
+// for screen readers: no colors, emoji, progress line or padding, and the
+// status of each repository as a sentence.
+var a11yOutput bool
+
+// a11yMarkers are the words read instead of the emoji that lead results.
+var a11yMarkers = map[string]string{
+	"✅️": "ok",
+	"❌":  "failed",
+	"⚠️": "warning",
+	"⏭️": "skipped",
+	"⏩":  "unchanged",
+	"⛔":  "not run",
+	"➕":  "missing",
+	"➖":  "removed",
+	"🧹":  "cleaned",
+	"🩺":  "unhealthy",
+}
+
+// a11yResult matches the emoji and repository that lead a result line,
+// which may be indented.
+var a11yResult = regexp.MustCompile(`(?m)^([ \t]*)(\S+) ([^:\n]+):`)
+
+// trailingSpace matches the padding at the end of lines.
+var trailingSpace = regexp.MustCompile(`(?m)[ \t]+$`)
+
+// a11yLine rewrites results such as "❌ api: error" as "repo api, failed:
+// error", without the padding at the end of the lines.
+func a11yLine(result string) string {
+	result = trailingSpace.ReplaceAllString(result, "")
+	return a11yResult.ReplaceAllStringFunc(result, func(m string) string {
+		parts := a11yResult.FindStringSubmatch(m)
+		word, ok := a11yMarkers[parts[2]]
+		if !ok {
+			return m
+		}
+		return fmt.Sprintf("%srepo %s, %s:", parts[1], parts[3], word)
+	})
+}
+
+// padding returns the width to pad a column of a table to, or 0 with -a11y
+// as the padding would be read out.
+func padding(width int) int {
+	if a11yOutput {
+		return 0
+	}
+	return width
+}
+
+// formatStatusA11y renders the status of the repository as a sentence, such
+// as "repo api: branch main, clean, up to date".
+func formatStatusA11y(relPath string, st repoStatus) string {
+	parts := []string{"branch " + st.Branch}
+	if st.Branch != st.DefaultBranch && st.DefaultBranch != "" {
+		parts[0] += ", not the default branch " + st.DefaultBranch
+	}
+	if st.Dirty {
+		parts = append(parts, "uncommitted changes")
+	} else {
+		parts = append(parts, "clean")
+	}
+
+	switch {
+	case st.Upstream == upstreamGone:
+		parts = append(parts, "upstream gone")
+	case st.Upstream == upstreamNone && st.VCS == "" && st.Bridge != bridgeSVN:
+		parts = append(parts, "no upstream")
+	case st.Ahead > 0 && st.Behind > 0:
+		parts = append(parts, fmt.Sprintf("%d ahead and %d behind", st.Ahead, st.Behind))
+	case st.Ahead > 0:
+		parts = append(parts, fmt.Sprintf("%d ahead", st.Ahead))
+	case st.Behind > 0:
+		parts = append(parts, fmt.Sprintf("%d behind", st.Behind))
+	case st.Sync == AheadRemote:
+		parts = append(parts, "ahead")
+	case st.Sync == BehindRemote:
+		parts = append(parts, "behind")
+	default:
+		parts = append(parts, "up to date")
+	}
+
+	if len(st.Branches) > 0 {
+		parts = append(parts, "other branches "+strings.Join(st.Branches, ", "))
+	}
+	if st.VCS != "" {
+		parts = append(parts, st.VCS+" repository")
+	}
+	if st.Bridge != "" {
+		parts = append(parts, st.Bridge+" bridge")
+	}
+	if st.StaleRemoteRefs > 0 {
+		parts = append(parts, fmt.Sprintf("%d stale remote branches", st.StaleRemoteRefs))
+	}
+	if len(st.UnpushedNotes) > 0 {
+		parts = append(parts, fmt.Sprintf("%d unpushed notes refs", len(st.UnpushedNotes)))
+	}
+	if st.Ticket != "" {
+		parts = append(parts, "ticket "+st.Ticket)
+	}
+	for _, name := range sortedKeys(st.Annotations) {
+		parts = append(parts, name+" "+st.Annotations[name])
+	}
+	return fmt.Sprintf("repo %s: %s", relPath, strings.Join(parts, ", "))
+}
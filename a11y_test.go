@@ -0,0 +1,44 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestA11yLine(t *testing.T) {
+	tests := []struct {
+		result string
+		want   string
+	}{
+		{"❌ api: exit code 1", "repo api, failed: exit code 1"},
+		{"✅️ libs/common: done   ", "repo libs/common, ok: done"},
+		{"  ❌ api:\n    fatal: not a git repository", "  repo api, failed:\n    fatal: not a git repository"},
+		{"Alice Example:", "Alice Example:"},
+		{"warning, git push -f is forced with -f and would run in 2 repositories:", "warning, git push -f is forced with -f and would run in 2 repositories:"},
+	}
+	for _, tt := range tests {
+		if got := a11yLine(tt.result); got != tt.want {
+			t.Errorf("a11yLine(%q) = %q, want %q", tt.result, got, tt.want)
+		}
+	}
+}
+
+func TestA11yTablesHaveNoPadding(t *testing.T) {
+	if args := os.Getenv("GITS_TEST_MAIN_ARGS"); args != "" {
+		os.Args = append([]string{"gits"}, strings.Split(args, "\n")...)
+		main()
+		return
+	}
+
+	repo := initTestRepo(t)
+	testWorkspace(t, repo)
+	for _, sub := range []string{"health", "overview", "describe"} {
+		out := runGits(t, "TestA11yTablesHaveNoPadding", "-root", repo, "-no-lock", "-a11y", sub)
+		for _, line := range strings.Split(strings.TrimRight(out, "\n"), "\n") {
+			if strings.Contains(line, "\033") || strings.Contains(strings.TrimLeft(line, " "), "  ") || strings.HasSuffix(line, " ") {
+				t.Errorf("%s printed %q with -a11y", sub, line)
+			}
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// statusConfig configures the -status summary.
+type statusConfig struct {
+	// Annotations are run in each repository and their output appended to
+	// its status
+	Annotations []annotationConfig `yaml:"annotations"`
+	// AnnotationTimeout is how long each annotation may take, e.g. 5s
+	// (default: 2s)
+	AnnotationTimeout string `yaml:"annotation_timeout"`
+	// Columns are the columns of -status when -columns is not given, such
+	// as [repo, branch, dirty, lastcommit]
+	Columns []string `yaml:"columns"`
+	// Widths are fixed widths of columns by name, longer cells are cut
+	// down to them
+	Widths map[string]int `yaml:"widths"`
+}
+
+// annotationConfig is a command whose short output annotates the status of
+// each repository, such as the deployed version or the ticket of the branch.
+type annotationConfig struct {
+	// Name is the key of the annotation in JSON output
+	Name string `yaml:"name"`
+	// Command is run in the repository with the same placeholders and
+	// GITS_* environment variables as the commands gits runs
+	Command []string `yaml:"command"`
+}
+
+// defaultAnnotationTimeout is how long an annotation may take unless
+// annotation_timeout is set.
+const defaultAnnotationTimeout = 2 * time.Second
+
+// maxAnnotationLength is how many characters of an annotation are shown, so
+// a chatty command cannot take over the status line.
+const maxAnnotationLength = 40
+
+func (c statusConfig) validate() error {
+	if c.AnnotationTimeout != "" {
+		if _, err := parseAge(c.AnnotationTimeout); err != nil {
+			return fmt.Errorf("status annotation_timeout: %v", err)
+		}
+	}
+	names := make(map[string]bool)
+	for _, a := range c.Annotations {
+		if a.Name == "" || len(a.Command) == 0 {
+			return fmt.Errorf("status annotations need a name and a command")
+		}
+		if names[a.Name] {
+			return fmt.Errorf("status annotation `%s` is defined twice", a.Name)
+		}
+		names[a.Name] = true
+	}
+	return nil
+}
+
+// annotationTimeout returns how long each annotation may take.
+func (c statusConfig) annotationTimeout() time.Duration {
+	if d, _ := parseAge(c.AnnotationTimeout); d > 0 {
+		return d
+	}
+	return defaultAnnotationTimeout
+}
+
+// annotate runs the annotations in the repository and returns the first
+// line of the output of each, by name. Annotations that fail, time out or
+// print nothing are left out, as the status is still worth showing.
+func (c statusConfig) annotate(root string, path string) map[string]string {
+	if len(c.Annotations) == 0 {
+		return nil
+	}
+	info := newRepoInfo(root, path)
+	annotations := make(map[string]string)
+	for _, a := range c.Annotations {
+		ctx, cancel := context.WithTimeout(context.Background(), c.annotationTimeout())
+		command := info.expand(a.Command)
+		cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+		cmd.Dir = path
+		cmd.Env = append(os.Environ(), info.environ()...)
+		var out bytes.Buffer
+		cmd.Stdout = &out
+		// children of a shell that timed out could hold the output open
+		cmd.WaitDelay = 100 * time.Millisecond
+		err := cmd.Run()
+		cancel()
+		line, _, _ := strings.Cut(strings.TrimSpace(out.String()), "\n")
+		if err != nil || line == "" {
+			continue
+		}
+		if runes := []rune(line); len(runes) > maxAnnotationLength {
+			line = string(runes[:maxAnnotationLength-1]) + "…"
+		}
+		annotations[a.Name] = line
+	}
+	return annotations
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+func init() {
+	registerSubcommand(&subcommand{
+		name:    "asof",
+		summary: "check out the repositories as they were at a time, `gits asof -restore` returns them",
+		run:     runAsOf,
+	})
+}
+
+// asOfState records where the repositories were before `gits asof`, by
+// absolute path, for `gits asof -restore`.
+type asOfState struct {
+	Time time.Time `json:"time"`
+	// Branch is the temporary branch checked out, if not detached
+	Branch    string            `json:"branch,omitempty"`
+	Positions map[string]string `json:"positions"`
+}
+
+func runAsOf(ws *workspace, args []string) int {
+	fs := newSubcommandFlags("asof", " <time>")
+	restore := fs.Bool("restore", false, "check out the branches or commits the repositories were on before")
+	branch := fs.String("branch", "", "check out a temporary branch of this name instead of a detached HEAD")
+	fs.Parse(args)
+
+	var state asOfState
+	if err := readState("asof.json", &state); err != nil {
+		fmt.Println("Error reading asof state:", err)
+		return 1
+	}
+
+	release, ok := ws.lock("asof")
+	if !ok {
+		return 1
+	}
+	defer release()
+
+	if *restore {
+		return restoreAsOf(ws, state)
+	}
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 1
+	}
+	if len(state.Positions) > 0 {
+		fmt.Printf("The repositories are already checked out as of %s, use -restore first\n", state.Time.Format("2006-01-02 15:04"))
+		return 1
+	}
+	at, err := parseTime(fs.Arg(0))
+	if err != nil {
+		fmt.Println("Invalid time:", err)
+		return 1
+	}
+
+	state = asOfState{Time: at, Branch: *branch, Positions: make(map[string]string)}
+	var mu sync.Mutex
+	results, exitCode := forEachRepo(ws.repos, ws.parallel, func(repo string) (string, int) {
+		relPath := relativePath(ws.root, repo)
+		if changed, err := hasTrackedChanges(repo); err != nil || changed {
+			return fmt.Sprintf("\033[1m❌ %s:\033[0m has uncommitted changes", relPath), 1
+		}
+		position, err := getHeadPosition(repo)
+		if err != nil {
+			return fmt.Sprintf("\033[1m❌ %s:\033[0m %v", relPath, err), 1
+		}
+
+		// the local default branch, or the remote's if it is not checked out
+		defaultBranch, err := getDefaultBranch(repo)
+		if err != nil {
+			defaultBranch = "main"
+		}
+		rev := defaultBranch
+		if _, err := resolveCommit(repo, rev); err != nil {
+			rev = "origin/" + defaultBranch
+		}
+		commit, err := getCommitBefore(repo, rev, at)
+		if err != nil {
+			return fmt.Sprintf("\033[1m⏭️ %s:\033[0m %v", relPath, err), 0
+		}
+
+		if *branch != "" {
+			if out, err := exec.Command("git", "-C", repo, "checkout", "--quiet", "-b", *branch, commit).CombinedOutput(); err != nil {
+				return fmt.Sprintf("\033[1m❌ %s:\033[0m %s", relPath, strings.TrimSpace(string(out))), 1
+			}
+		} else if err := checkoutDetached(repo, commit); err != nil {
+			return fmt.Sprintf("\033[1m❌ %s:\033[0m %v", relPath, err), 1
+		}
+		mu.Lock()
+		state.Positions[repo] = position
+		mu.Unlock()
+		return "", 0
+	})
+	printResults(results)
+
+	if err := writeState("asof.json", state); err != nil {
+		fmt.Println("Error saving asof state:", err)
+		return 1
+	}
+	fmt.Printf("Checked out %d repositories as of %s, use `gits asof -restore` to return\n", len(state.Positions), at.Format("2006-01-02 15:04"))
+	return exitCode
+}
+
+// restoreAsOf checks out the recorded positions again, keeping those that
+// fail in the state to retry.
+func restoreAsOf(ws *workspace, state asOfState) int {
+	if len(state.Positions) == 0 {
+		fmt.Println("Nothing to restore")
+		return 0
+	}
+
+	var repos []string
+	for path := range state.Positions {
+		repos = append(repos, path)
+	}
+	sortNames(repos)
+
+	var mu sync.Mutex
+	results, exitCode := forEachRepo(repos, ws.parallel, func(repo string) (string, int) {
+		relPath := relativePath(ws.root, repo)
+		if err := checkoutPosition(repo, state.Positions[repo]); err != nil {
+			return fmt.Sprintf("\033[1m❌ %s:\033[0m %v", relPath, err), 1
+		}
+		if state.Branch != "" {
+			if out, err := exec.Command("git", "-C", repo, "branch", "-D", state.Branch).CombinedOutput(); err != nil {
+				return fmt.Sprintf("\033[1m⚠️ %s:\033[0m %s", relPath, strings.TrimSpace(string(out))), 1
+			}
+		}
+		mu.Lock()
+		delete(state.Positions, repo)
+		mu.Unlock()
+		return "", 0
+	})
+	printResults(results)
+
+	if err := writeState("asof.json", state); err != nil {
+		fmt.Println("Error saving asof state:", err)
+		return 1
+	}
+	return exitCode
+}
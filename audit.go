@@ -0,0 +1,41 @@
+package main
+
+import "fmt"
+
+func init() {
+	registerSubcommand(&subcommand{
+		name:    "audit",
+		summary: "run an audit across the repositories, see `gits audit -help`",
+		run:     runAudit,
+	})
+}
+
+// audits are the checks available as `gits audit <name>`.
+var audits = map[string]*subcommand{}
+
+func registerAudit(s *subcommand) {
+	audits[s.name] = s
+}
+
+func runAudit(ws *workspace, args []string) int {
+	if len(args) == 0 || args[0] == "-help" || args[0] == "--help" || args[0] == "-h" {
+		fmt.Println("Usage: gits [options] audit <audit> [audit options]")
+		printAudits()
+		if len(args) == 0 {
+			return 1
+		}
+		return 0
+	}
+
+	a, ok := audits[args[0]]
+	if !ok {
+		fmt.Printf("Unknown audit `%s`\n", args[0])
+		printAudits()
+		return 1
+	}
+	return a.run(ws, args[1:])
+}
+
+func printAudits() {
+	printCommandList("Audits:", audits)
+}
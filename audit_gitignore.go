@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+func init() {
+	registerAudit(&subcommand{
+		name:    "gitignore",
+		summary: "find repositories whose .gitignore lacks the patterns of a template, and optionally commit them",
+		run:     runAuditGitignore,
+	})
+}
+
+func runAuditGitignore(ws *workspace, args []string) int {
+	fs := newSubcommandFlags("audit gitignore", "")
+	templatePath := fs.String("template", "", "ignore file with the patterns every repository must have")
+	fix := fs.Bool("fix", false, "commit the missing patterns, appended to .gitignore, on a branch")
+	branch := fs.String("branch", "gitignore", "with -fix, branch to commit on, replaced if it exists")
+	message := fs.String("message", "Add required ignore patterns", "with -fix, commit message")
+	fs.Parse(args)
+
+	if *templatePath == "" {
+		fmt.Println("-template is required")
+		fs.Usage()
+		return 1
+	}
+	data, err := os.ReadFile(*templatePath)
+	if err != nil {
+		fmt.Println("Error reading template:", err)
+		return 1
+	}
+	required := ignorePatterns(string(data))
+	if len(required) == 0 {
+		fmt.Printf("No patterns in %s\n", *templatePath)
+		return 1
+	}
+
+	if *fix {
+		release, ok := ws.lock("audit gitignore")
+		if !ok {
+			return 1
+		}
+		defer release()
+	}
+
+	policy, err := ws.commitPolicy()
+	if err != nil {
+		fmt.Println("Error reading manifest:", err)
+		return 1
+	}
+
+	results, exitCode := forEachRepo(ws.repos, ws.parallel, func(repo string) (string, int) {
+		relPath := relativePath(ws.root, repo)
+		current, _, err := getFileAt(repo, "HEAD", ".gitignore")
+		if err != nil {
+			return fmt.Sprintf("\033[1m❌ %s:\033[0m %v", relPath, err), 1
+		}
+		missing := missingIgnorePatterns(current, required)
+		if len(missing) == 0 {
+			return "", 0
+		}
+		if !*fix {
+			return fmt.Sprintf("\033[1m⚠️ %s:\033[0m .gitignore is missing %d patterns\n  %s", relPath, len(missing), strings.Join(missing, "\n  ")), 1
+		}
+
+		// an earlier run may have already committed them
+		if committed, ok, _ := getFileAt(repo, *branch, ".gitignore"); ok && len(missingIgnorePatterns(committed, required)) == 0 && isAncestor(repo, "HEAD", *branch) {
+			return fmt.Sprintf("\033[1m⚠️ %s:\033[0m the missing patterns are already committed on %s", relPath, *branch), 0
+		}
+		if b, err := getCurrentBranch(repo); err == nil && b == *branch {
+			return fmt.Sprintf("\033[1m❌ %s:\033[0m %s is checked out", relPath, *branch), 1
+		}
+		content := current
+		if content != "" && !strings.HasSuffix(content, "\n") {
+			content += "\n"
+		}
+		content += strings.Join(missing, "\n") + "\n"
+		if err := commitFileOnBranch(repo, *branch, ".gitignore", content, *message, policy); err != nil {
+			return fmt.Sprintf("\033[1m❌ %s:\033[0m %v", relPath, err), 1
+		}
+		return fmt.Sprintf("\033[1m✅️ %s:\033[0m committed %d patterns on %s", relPath, len(missing), *branch), 0
+	})
+	printResults(results)
+	return exitCode
+}
+
+// ignorePatterns returns the patterns of an ignore file, without the blank
+// lines and comments.
+func ignorePatterns(content string) []string {
+	var patterns []string
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			patterns = append(patterns, line)
+		}
+	}
+	return patterns
+}
+
+// missingIgnorePatterns returns the required patterns the ignore file does
+// not have.
+func missingIgnorePatterns(content string, required []string) []string {
+	present := ignorePatterns(content)
+	var missing []string
+	for _, p := range required {
+		if !slices.Contains(present, p) {
+			missing = append(missing, p)
+		}
+	}
+	return missing
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+func init() {
+	registerAudit(&subcommand{
+		name:    "identity",
+		summary: "find repositories configured or recently committed to with the wrong email",
+		run:     runAuditIdentity,
+	})
+}
+
+func runAuditIdentity(ws *workspace, args []string) int {
+	fs := newSubcommandFlags("audit identity", "")
+	pattern := fs.String("email-pattern", "", "regular expression the email must match, e.g. '@example\\.com$'")
+	since := fs.String("since", "30d", "how far back to check commits")
+	fix := fs.Bool("fix", false, "set the local user.email of repositories whose configured email does not match")
+	email := fs.String("email", "", "the email to set with -fix")
+	fs.Parse(args)
+
+	if *pattern == "" {
+		fmt.Println("-email-pattern is required")
+		fs.Usage()
+		return 1
+	}
+	re, err := regexp.Compile(*pattern)
+	if err != nil {
+		fmt.Println("Invalid -email-pattern:", err)
+		return 1
+	}
+	if *fix && !re.MatchString(*email) {
+		fmt.Printf("-fix requires an -email that matches `%s`\n", *pattern)
+		return 1
+	}
+	age, err := parseAge(*since)
+	if err != nil {
+		fmt.Println("Invalid -since:", err)
+		return 1
+	}
+
+	if *fix {
+		release, ok := ws.lock("audit identity -fix")
+		if !ok {
+			return 1
+		}
+		defer release()
+	}
+
+	results, exitCode := forEachRepo(ws.repos, ws.parallel, func(repo string) (string, int) {
+		return auditIdentityRepo(repo, ws.root, re, time.Now().Add(-age), *fix, *email)
+	})
+	printResults(results)
+	return exitCode
+}
+
+func auditIdentityRepo(repo string, root string, re *regexp.Regexp, cutoff time.Time, fix bool, email string) (string, int) {
+	relPath := relativePath(root, repo)
+
+	configured, err := getConfigValue(repo, "user.email")
+	if err != nil {
+		return fmt.Sprintf("\033[1m❌ %s:\033[0m %v", relPath, err), 1
+	}
+	name, _ := getConfigValue(repo, "user.name")
+
+	var findings []string
+	exitCode := 0
+	if !re.MatchString(configured) {
+		if fix {
+			if err := setLocalConfigValue(repo, "user.email", email); err != nil {
+				findings = append(findings, fmt.Sprintf("  user.email `%s` could not be fixed: %v", configured, err))
+				exitCode = 1
+			} else {
+				findings = append(findings, fmt.Sprintf("  user.email `%s` \033[32mfixed\033[0m to `%s`", configured, email))
+			}
+		} else {
+			findings = append(findings, fmt.Sprintf("  user.email `\033[31m%s\033[0m` does not match", configured))
+			exitCode = 1
+		}
+	}
+
+	// Only our own commits are checked: those with our name or configured email
+	commits, err := getCommits(repo, "--branches", "--since="+cutoff.Format(time.RFC3339))
+	if err == nil {
+		for _, c := range commits {
+			if c.authorName != name && c.authorEmail != configured {
+				continue
+			}
+			if !re.MatchString(c.authorEmail) {
+				findings = append(findings, fmt.Sprintf("  %.7s committed as \033[31m%s\033[0m: %s", c.hash, c.authorEmail, c.subject))
+				exitCode = 1
+			}
+		}
+	}
+
+	if len(findings) == 0 {
+		return "", 0
+	}
+	return fmt.Sprintf("\033[1m⚠️ %s:\033[0m\n%s", relPath, strings.Join(findings, "\n")), exitCode
+}
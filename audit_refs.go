@@ -0,0 +1,183 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+func init() {
+	registerAudit(&subcommand{
+		name:    "refs",
+		summary: "check origin/HEAD, upstreams and push remotes still match the remote, such as after a server migration",
+		run:     runAuditRefs,
+	})
+}
+
+// refProblem is ref configuration that does not match the remote, with how
+// to fix it if it can be.
+type refProblem struct {
+	description string
+	fix         func() error
+}
+
+func runAuditRefs(ws *workspace, args []string) int {
+	fs := newSubcommandFlags("audit refs", "")
+	fix := fs.Bool("fix", false, "fix the problems that can be fixed")
+	fs.Parse(args)
+
+	if *fix {
+		release, ok := ws.lock("audit refs")
+		if !ok {
+			return 1
+		}
+		defer release()
+	}
+
+	results, exitCode := forEachRepo(ws.repos, ws.parallel, func(repo string) (string, int) {
+		relPath := relativePath(ws.root, repo)
+		remotes, err := getRemotes(repo)
+		if err != nil {
+			return fmt.Sprintf("\033[1m❌ %s:\033[0m %v", relPath, err), 1
+		}
+		if !slices.Contains(remotes, "origin") {
+			return fmt.Sprintf("\033[1m⏭️ %s:\033[0m no origin remote", relPath), 0
+		}
+		problems, err := findRefProblems(repo, remotes)
+		if err != nil {
+			return fmt.Sprintf("\033[1m❌ %s:\033[0m %v", relPath, err), 1
+		}
+		if len(problems) == 0 {
+			return "", 0
+		}
+
+		var lines []string
+		remaining := 0
+		for _, p := range problems {
+			switch {
+			case !*fix || p.fix == nil:
+				lines = append(lines, p.description)
+				remaining++
+			default:
+				if err := p.fix(); err != nil {
+					lines = append(lines, fmt.Sprintf("%s, fixing it failed: %v", p.description, err))
+					remaining++
+				} else {
+					lines = append(lines, p.description+", fixed")
+				}
+			}
+		}
+		if remaining == 0 {
+			return fmt.Sprintf("\033[1m✅️ %s:\033[0m\n  %s", relPath, strings.Join(lines, "\n  ")), 0
+		}
+		return fmt.Sprintf("\033[1m⚠️ %s:\033[0m\n  %s", relPath, strings.Join(lines, "\n  ")), 1
+	})
+	printResults(results)
+	return exitCode
+}
+
+// findRefProblems compares the ref configuration of the repository with what
+// the origin remote has.
+func findRefProblems(repo string, remotes []string) ([]refProblem, error) {
+	defaultBranch, branches, err := getRemoteHeads(repo, "origin")
+	if err != nil {
+		return nil, err
+	}
+	var problems []refProblem
+
+	// origin/HEAD is what the default branch was when it was last set
+	oldDefault := strings.TrimPrefix(getSymbolicRef(repo, "refs/remotes/origin/HEAD"), "refs/remotes/origin/")
+	if defaultBranch != "" && oldDefault != defaultBranch {
+		current := "unset"
+		if oldDefault != "" {
+			current = "origin/" + oldDefault
+		}
+		problems = append(problems, refProblem{
+			description: fmt.Sprintf("origin/HEAD is %s but the default branch of origin is %s", current, defaultBranch),
+			fix: func() error {
+				if _, err := runGit(repo, "fetch", "-q", "origin", defaultBranch); err != nil {
+					return err
+				}
+				_, err := runGit(repo, "remote", "set-head", "origin", defaultBranch)
+				return err
+			},
+		})
+	}
+
+	upstreams, err := getBranchUpstreams(repo)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for name := range upstreams {
+		names = append(names, name)
+	}
+	sortNames(names)
+	for _, name := range names {
+		up := upstreams[name]
+		switch {
+		case !slices.Contains(remotes, up.remote):
+			problems = append(problems, refProblem{
+				description: fmt.Sprintf("%s tracks a branch of %s, which is not a remote", name, up.remote),
+				fix: func() error {
+					_, err := runGit(repo, "branch", "--unset-upstream", name)
+					return err
+				},
+			})
+		case up.remote != "origin" || slices.Contains(branches, up.branch):
+		case up.branch == oldDefault && defaultBranch != "":
+			// the default branch was renamed
+			problems = append(problems, refProblem{
+				description: fmt.Sprintf("%s tracks origin/%s, the old default branch, instead of origin/%s", name, up.branch, defaultBranch),
+				fix: func() error {
+					if _, err := runGit(repo, "fetch", "-q", "origin", defaultBranch); err != nil {
+						return err
+					}
+					_, err := runGit(repo, "branch", "--set-upstream-to", "origin/"+defaultBranch, name)
+					return err
+				},
+			})
+		default:
+			problems = append(problems, refProblem{description: fmt.Sprintf("%s tracks origin/%s, which no longer exists", name, up.branch)})
+		}
+	}
+
+	// git config fails when none are set
+	pushRemotes, _ := runGit(repo, "config", "--get-regexp", `^(remote\.pushdefault|branch\..*\.pushremote)$`)
+	for _, line := range strings.Split(pushRemotes, "\n") {
+		key, value, ok := strings.Cut(line, " ")
+		if !ok || slices.Contains(remotes, value) {
+			continue
+		}
+		problems = append(problems, refProblem{
+			description: fmt.Sprintf("%s is %s, which is not a remote", key, value),
+			fix: func() error {
+				_, err := runGit(repo, "config", "--unset", key)
+				return err
+			},
+		})
+	}
+
+	for _, remote := range remotes {
+		fetchURL, err := getConfigValue(repo, "remote."+remote+".url")
+		if err != nil {
+			return nil, err
+		}
+		pushURL, err := getConfigValue(repo, "remote."+remote+".pushurl")
+		if err != nil {
+			return nil, err
+		}
+		fetchRepo, fetchOK := parseRemoteURL(fetchURL)
+		pushRepo, pushOK := parseRemoteURL(pushURL)
+		if fetchOK && pushOK && fetchRepo.host != pushRepo.host {
+			problems = append(problems, refProblem{
+				description: fmt.Sprintf("%s fetches from %s but pushes to %s", remote, fetchRepo.host, pushRepo.host),
+				fix: func() error {
+					_, err := runGit(repo, "config", "--unset", "remote."+remote+".pushurl")
+					return err
+				},
+			})
+		}
+	}
+	return problems, nil
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+func init() {
+	registerAudit(&subcommand{
+		name:    "rewrites",
+		summary: "find force pushes and rewritten history on protected branches",
+		run:     runAuditRewrites,
+	})
+}
+
+func runAuditRewrites(ws *workspace, args []string) int {
+	fs := newSubcommandFlags("audit rewrites", "")
+	since := fs.String("since", "30d", "how far back to scan the reflogs, e.g. 30d, 2w or 12h")
+	branches := fs.String("branches", "", "comma separated protected branch patterns (default: the default branch)")
+	fs.Parse(args)
+
+	age, err := parseAge(*since)
+	if err != nil {
+		fmt.Println("Invalid -since:", err)
+		return 1
+	}
+	cutoff := time.Now().Add(-age)
+
+	var patterns []string
+	if *branches != "" {
+		patterns = strings.Split(*branches, ",")
+	}
+
+	results, exitCode := forEachRepo(ws.repos, ws.parallel, func(repo string) (string, int) {
+		return auditRewritesRepo(repo, ws.root, patterns, cutoff)
+	})
+	printResults(results)
+	return exitCode
+}
+
+// protectedBranchName returns the branch name of a local or remote-tracking
+// ref, or false for refs that are not branches.
+func protectedBranchName(ref string) (string, bool) {
+	if name, ok := strings.CutPrefix(ref, "refs/heads/"); ok {
+		return name, true
+	}
+	if name, ok := strings.CutPrefix(ref, "refs/remotes/"); ok {
+		_, name, ok = strings.Cut(name, "/")
+		return name, ok && name != "HEAD"
+	}
+	return "", false
+}
+
+func auditRewritesRepo(repo string, root string, patterns []string, cutoff time.Time) (string, int) {
+	relPath := relativePath(root, repo)
+
+	if len(patterns) == 0 {
+		defaultBranch, err := getDefaultBranch(repo)
+		if err != nil {
+			defaultBranch = "main"
+		}
+		patterns = []string{defaultBranch}
+	}
+
+	refs, err := getRefs(repo, "refs/heads", "refs/remotes")
+	if err != nil {
+		return fmt.Sprintf("\033[1m❌ %s:\033[0m %v", relPath, err), 1
+	}
+
+	var findings []string
+	for _, ref := range refs {
+		name, ok := protectedBranchName(ref)
+		if !ok || !matchesAnyGlob(patterns, name) {
+			continue
+		}
+
+		entries, err := getReflog(repo, ref)
+		if err != nil {
+			continue
+		}
+		for i := 0; i+1 < len(entries); i++ {
+			newer, older := entries[i], entries[i+1]
+			if newer.when.Before(cutoff) {
+				break
+			}
+			if strings.Trim(older.hash, "0") == "" {
+				continue
+			}
+			if strings.Contains(newer.message, "forced-update") || !isAncestor(repo, older.hash, newer.hash) {
+				findings = append(findings, fmt.Sprintf("  %s %s \033[31m%.7s → %.7s\033[0m %s",
+					newer.when.Format("2006-01-02 15:04"), strings.TrimPrefix(ref, "refs/"), older.hash, newer.hash, newer.message))
+			}
+		}
+	}
+
+	if len(findings) == 0 {
+		return "", 0
+	}
+	return fmt.Sprintf("\033[1m⚠️ %s:\033[0m\n%s", relPath, strings.Join(findings, "\n")), 1
+}
@@ -0,0 +1,245 @@
+//go:build !noforge
+
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+func init() {
+	registerAudit(&subcommand{
+		name:    "settings",
+		summary: "compare the forge settings and default branch protection of the repositories with a policy",
+		run:     runAuditSettings,
+	})
+}
+
+// settingsPolicy is the expected forge settings, unset fields are not checked.
+type settingsPolicy struct {
+	Visibility          *string           `yaml:"visibility"`
+	DefaultBranch       *string           `yaml:"default_branch"`
+	AllowMergeCommit    *bool             `yaml:"allow_merge_commit"`
+	AllowSquashMerge    *bool             `yaml:"allow_squash_merge"`
+	AllowRebaseMerge    *bool             `yaml:"allow_rebase_merge"`
+	DeleteBranchOnMerge *bool             `yaml:"delete_branch_on_merge"`
+	Protection          *protectionPolicy `yaml:"protection"`
+}
+
+type protectionPolicy struct {
+	// Required is whether the default branch must be protected at all
+	Required *bool `yaml:"required"`
+	// RequiredReviews is the least number of approvals
+	RequiredReviews     *int  `yaml:"required_reviews"`
+	RequireStatusChecks *bool `yaml:"require_status_checks"`
+	EnforceAdmins       *bool `yaml:"enforce_admins"`
+	AllowForcePushes    *bool `yaml:"allow_force_pushes"`
+}
+
+// repoSettings are the settings of a forge repository, with nil for those
+// the forge does not have.
+type repoSettings struct {
+	visibility          string
+	defaultBranch       string
+	allowMergeCommit    *bool
+	allowSquashMerge    *bool
+	allowRebaseMerge    *bool
+	deleteBranchOnMerge *bool
+	// protection is nil if the default branch is not protected
+	protection *branchProtection
+}
+
+type branchProtection struct {
+	requiredReviews     int
+	requireStatusChecks bool
+	enforceAdmins       *bool
+	allowForcePushes    bool
+}
+
+func runAuditSettings(ws *workspace, args []string) int {
+	fs := newSubcommandFlags("audit settings", "")
+	policyPath := fs.String("policy", "", "YAML file with the expected settings")
+	fs.Parse(args)
+
+	if *policyPath == "" {
+		fmt.Println("-policy is required")
+		fs.Usage()
+		return 1
+	}
+	data, err := os.ReadFile(*policyPath)
+	if err != nil {
+		fmt.Println("Error reading policy:", err)
+		return 1
+	}
+	var policy settingsPolicy
+	if err := yaml.Unmarshal(data, &policy); err != nil {
+		fmt.Printf("Error reading policy: %s: %v\n", *policyPath, err)
+		return 1
+	}
+
+	results, exitCode := forEachRepo(ws.repos, ws.parallel, func(repo string) (string, int) {
+		relPath := relativePath(ws.root, repo)
+		client, fr, err := forgeClientFor(ws, repo)
+		if err != nil {
+			return fmt.Sprintf("\033[1m⏭️ %s:\033[0m %v", relPath, err), 0
+		}
+		settings, err := client.repoSettings(fr)
+		if err != nil {
+			return fmt.Sprintf("\033[1m❌ %s:\033[0m %s: %v", relPath, fr, err), 1
+		}
+
+		drift := policy.drift(settings)
+		if len(drift) == 0 {
+			return "", 0
+		}
+		return fmt.Sprintf("\033[1m⚠️ %s:\033[0m %s\n  %s", relPath, fr, strings.Join(drift, "\n  ")), 1
+	})
+	printResults(results)
+	return exitCode
+}
+
+// drift describes each way the settings differ from the policy.
+func (p *settingsPolicy) drift(s repoSettings) []string {
+	var drift []string
+	differs := func(name string, actual any, expected any) {
+		drift = append(drift, fmt.Sprintf("%s is %v, policy is %v", name, actual, expected))
+	}
+	checkBool := func(name string, actual *bool, expected *bool) {
+		if actual != nil && expected != nil && *actual != *expected {
+			differs(name, *actual, *expected)
+		}
+	}
+
+	if p.Visibility != nil && s.visibility != *p.Visibility {
+		differs("visibility", s.visibility, *p.Visibility)
+	}
+	if p.DefaultBranch != nil && s.defaultBranch != *p.DefaultBranch {
+		differs("default_branch", s.defaultBranch, *p.DefaultBranch)
+	}
+	checkBool("allow_merge_commit", s.allowMergeCommit, p.AllowMergeCommit)
+	checkBool("allow_squash_merge", s.allowSquashMerge, p.AllowSquashMerge)
+	checkBool("allow_rebase_merge", s.allowRebaseMerge, p.AllowRebaseMerge)
+	checkBool("delete_branch_on_merge", s.deleteBranchOnMerge, p.DeleteBranchOnMerge)
+
+	pp := p.Protection
+	if pp == nil {
+		return drift
+	}
+	if s.protection == nil {
+		if pp.Required == nil || *pp.Required {
+			drift = append(drift, fmt.Sprintf("default branch %s is not protected", s.defaultBranch))
+		}
+		return drift
+	}
+	if pp.RequiredReviews != nil && s.protection.requiredReviews < *pp.RequiredReviews {
+		differs("protection.required_reviews", s.protection.requiredReviews, fmt.Sprintf("at least %d", *pp.RequiredReviews))
+	}
+	checkBool("protection.require_status_checks", &s.protection.requireStatusChecks, pp.RequireStatusChecks)
+	checkBool("protection.enforce_admins", s.protection.enforceAdmins, pp.EnforceAdmins)
+	checkBool("protection.allow_force_pushes", &s.protection.allowForcePushes, pp.AllowForcePushes)
+	return drift
+}
+
+// repoSettings reads the settings of the repository and the protection of
+// its default branch.
+func (c *forgeClient) repoSettings(r forgeRepo) (repoSettings, error) {
+	if c.kind == forgeGitLab {
+		return c.gitlabSettings(r)
+	}
+
+	var repo struct {
+		Visibility          string `json:"visibility"`
+		DefaultBranch       string `json:"default_branch"`
+		AllowMergeCommit    *bool  `json:"allow_merge_commit"`
+		AllowSquashMerge    *bool  `json:"allow_squash_merge"`
+		AllowRebaseMerge    *bool  `json:"allow_rebase_merge"`
+		DeleteBranchOnMerge *bool  `json:"delete_branch_on_merge"`
+	}
+	if err := c.get(c.repoPath(r), &repo); err != nil {
+		return repoSettings{}, err
+	}
+	s := repoSettings{
+		visibility:          repo.Visibility,
+		defaultBranch:       repo.DefaultBranch,
+		allowMergeCommit:    repo.AllowMergeCommit,
+		allowSquashMerge:    repo.AllowSquashMerge,
+		allowRebaseMerge:    repo.AllowRebaseMerge,
+		deleteBranchOnMerge: repo.DeleteBranchOnMerge,
+	}
+
+	var protection struct {
+		RequiredPullRequestReviews *struct {
+			RequiredApprovingReviewCount int `json:"required_approving_review_count"`
+		} `json:"required_pull_request_reviews"`
+		RequiredStatusChecks *struct{} `json:"required_status_checks"`
+		EnforceAdmins        struct {
+			Enabled bool `json:"enabled"`
+		} `json:"enforce_admins"`
+		AllowForcePushes struct {
+			Enabled bool `json:"enabled"`
+		} `json:"allow_force_pushes"`
+	}
+	err := c.get(c.repoPath(r)+"/branches/"+repo.DefaultBranch+"/protection", &protection)
+	if isNotFound(err) {
+		return s, nil
+	} else if err != nil {
+		return s, err
+	}
+	s.protection = &branchProtection{
+		requireStatusChecks: protection.RequiredStatusChecks != nil,
+		enforceAdmins:       &protection.EnforceAdmins.Enabled,
+		allowForcePushes:    protection.AllowForcePushes.Enabled,
+	}
+	if reviews := protection.RequiredPullRequestReviews; reviews != nil {
+		s.protection.requiredReviews = reviews.RequiredApprovingReviewCount
+	}
+	return s, nil
+}
+
+// gitlabSettings maps the GitLab project settings onto GitHub's.
+func (c *forgeClient) gitlabSettings(r forgeRepo) (repoSettings, error) {
+	var project struct {
+		Visibility                       string `json:"visibility"`
+		DefaultBranch                    string `json:"default_branch"`
+		MergeMethod                      string `json:"merge_method"`
+		SquashOption                     string `json:"squash_option"`
+		RemoveSourceBranchAfterMerge     bool   `json:"remove_source_branch_after_merge"`
+		OnlyAllowMergeIfPipelineSucceeds bool   `json:"only_allow_merge_if_pipeline_succeeds"`
+		ApprovalsBeforeMerge             int    `json:"approvals_before_merge"`
+	}
+	if err := c.get(c.repoPath(r), &project); err != nil {
+		return repoSettings{}, err
+	}
+
+	mergeCommit := project.MergeMethod == "merge"
+	squash := project.SquashOption != "never"
+	rebase := project.MergeMethod != "merge"
+	s := repoSettings{
+		visibility:          project.Visibility,
+		defaultBranch:       project.DefaultBranch,
+		allowMergeCommit:    &mergeCommit,
+		allowSquashMerge:    &squash,
+		allowRebaseMerge:    &rebase,
+		deleteBranchOnMerge: &project.RemoveSourceBranchAfterMerge,
+	}
+
+	var protected struct {
+		AllowForcePush bool `json:"allow_force_push"`
+	}
+	err := c.get(c.repoPath(r)+"/protected_branches/"+url.PathEscape(project.DefaultBranch), &protected)
+	if isNotFound(err) {
+		return s, nil
+	} else if err != nil {
+		return s, err
+	}
+	s.protection = &branchProtection{
+		requiredReviews:     project.ApprovalsBeforeMerge,
+		requireStatusChecks: project.OnlyAllowMergeIfPipelineSucceeds,
+		allowForcePushes:    protected.AllowForcePush,
+	}
+	return s, nil
+}
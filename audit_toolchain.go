@@ -0,0 +1,220 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+func init() {
+	registerAudit(&subcommand{
+		name:    "toolchain",
+		summary: "compare the toolchain versions the repositories pin, such as in go.mod, .nvmrc and .tool-versions, with those of the workspace, and optionally commit them",
+		run:     runAuditToolchain,
+	})
+}
+
+// toolchainPin is the version of a toolchain a file of a repository pins.
+type toolchainPin struct {
+	tool    string
+	file    string
+	version string
+}
+
+// versionFiles are the files that hold nothing but the version of a
+// toolchain.
+var versionFiles = []struct{ tool, file string }{
+	{"node", ".nvmrc"},
+	{"node", ".node-version"},
+	{"python", ".python-version"},
+	{"ruby", ".ruby-version"},
+}
+
+// asdfNames are the toolchains whose asdf plugin, as named in
+// .tool-versions, has another name.
+var asdfNames = map[string]string{"golang": "go", "nodejs": "node"}
+
+// goModVersion matches the toolchain and go directives of go.mod.
+var goModVersion = regexp.MustCompile(`(?m)^(toolchain go|go )(\S+)`)
+
+func runAuditToolchain(ws *workspace, args []string) int {
+	fs := newSubcommandFlags("audit toolchain", "")
+	var pins []string
+	fs.Var((*stringList)(&pins), "pin", "version a toolchain must have, such as go=1.22, instead of that of toolchains in the workspace manifest (repeatable)")
+	fix := fs.Bool("fix", false, "commit the versions of the workspace on a branch")
+	branch := fs.String("branch", "toolchain", "with -fix, branch to commit on, replaced if it exists")
+	message := fs.String("message", "Pin the toolchain versions of the workspace", "with -fix, commit message")
+	fs.Parse(args)
+
+	m, err := findManifest(ws.root)
+	if err != nil {
+		fmt.Println("Error reading manifest:", err)
+		return 1
+	}
+	want := make(map[string]string)
+	if m != nil {
+		for tool, version := range m.Toolchains {
+			want[tool] = version
+		}
+	}
+	for _, pin := range pins {
+		tool, version, ok := strings.Cut(pin, "=")
+		if !ok || tool == "" || version == "" {
+			fmt.Printf("Invalid -pin %s: must be a toolchain and its version such as go=1.22\n", pin)
+			return 1
+		}
+		want[tool] = version
+	}
+	if len(want) == 0 {
+		fmt.Println("No toolchain versions, set toolchains in the workspace manifest or use -pin")
+		return 1
+	}
+
+	if *fix {
+		release, ok := ws.lock("audit toolchain")
+		if !ok {
+			return 1
+		}
+		defer release()
+	}
+	policy, err := ws.commitPolicy()
+	if err != nil {
+		fmt.Println("Error reading manifest:", err)
+		return 1
+	}
+
+	results, exitCode := forEachRepo(ws.repos, ws.parallel, func(repo string) (string, int) {
+		relPath := relativePath(ws.root, repo)
+		files, pins, err := readToolchainPins(repo, "HEAD")
+		if err != nil {
+			return fmt.Sprintf("\033[1m❌ %s:\033[0m %v", relPath, err), 1
+		}
+		drift := toolchainDrift(pins, want)
+		if len(drift) == 0 {
+			return "", 0
+		}
+		var lines []string
+		for _, p := range drift {
+			lines = append(lines, fmt.Sprintf("%s %s in %s, want %s", p.tool, p.version, p.file, want[p.tool]))
+		}
+		if !*fix {
+			return fmt.Sprintf("\033[1m⚠️ %s:\033[0m %d toolchains differ from the workspace\n  %s", relPath, len(drift), strings.Join(lines, "\n  ")), 1
+		}
+
+		// an earlier run may have already committed them
+		if _, committed, err := readToolchainPins(repo, *branch); err == nil && isAncestor(repo, "HEAD", *branch) && len(toolchainDrift(committed, want)) == 0 {
+			return fmt.Sprintf("\033[1m⚠️ %s:\033[0m the versions are already committed on %s", relPath, *branch), 0
+		}
+		if b, err := getCurrentBranch(repo); err == nil && b == *branch {
+			return fmt.Sprintf("\033[1m❌ %s:\033[0m %s is checked out", relPath, *branch), 1
+		}
+		changed := make(map[string]string)
+		for _, p := range drift {
+			content, ok := changed[p.file]
+			if !ok {
+				content = files[p.file]
+			}
+			changed[p.file] = setToolchainPin(content, p, want[p.tool])
+		}
+		if err := commitFilesOnBranch(repo, *branch, changed, *message, policy); err != nil {
+			return fmt.Sprintf("\033[1m❌ %s:\033[0m %v", relPath, err), 1
+		}
+		return fmt.Sprintf("\033[1m✅️ %s:\033[0m committed %d versions on %s\n  %s", relPath, len(drift), *branch, strings.Join(lines, "\n  ")), 0
+	})
+	printResults(results)
+	return exitCode
+}
+
+// readToolchainPins returns the files pinning toolchain versions at the
+// revision, by path, and the versions they pin.
+func readToolchainPins(repo string, rev string) (map[string]string, []toolchainPin, error) {
+	files := make(map[string]string)
+	var pins []toolchainPin
+	read := func(file string) (string, bool, error) {
+		content, ok, err := getFileAt(repo, rev, file)
+		if ok {
+			files[file] = content
+		}
+		return content, ok, err
+	}
+
+	if content, ok, err := read("go.mod"); err != nil {
+		return nil, nil, err
+	} else if ok {
+		// the toolchain directive, where there is one, is what builds use
+		var version string
+		for _, match := range goModVersion.FindAllStringSubmatch(content, -1) {
+			if match[1] == "toolchain go" || version == "" {
+				version = match[2]
+			}
+		}
+		if version != "" {
+			pins = append(pins, toolchainPin{"go", "go.mod", version})
+		}
+	}
+	for _, f := range versionFiles {
+		if content, ok, err := read(f.file); err != nil {
+			return nil, nil, err
+		} else if ok && strings.TrimSpace(content) != "" {
+			pins = append(pins, toolchainPin{f.tool, f.file, strings.TrimPrefix(strings.TrimSpace(content), "v")})
+		}
+	}
+	if content, ok, err := read(".tool-versions"); err != nil {
+		return nil, nil, err
+	} else if ok {
+		for _, line := range strings.Split(content, "\n") {
+			fields := strings.Fields(line)
+			if len(fields) < 2 || strings.HasPrefix(fields[0], "#") {
+				continue
+			}
+			tool := fields[0]
+			if name, ok := asdfNames[tool]; ok {
+				tool = name
+			}
+			pins = append(pins, toolchainPin{tool, ".tool-versions", fields[1]})
+		}
+	}
+	return files, pins, nil
+}
+
+// toolchainDrift returns the pins of the toolchains of the workspace that
+// differ from its versions. A version such as 1.22 is met by the patch
+// releases 1.22.x too.
+func toolchainDrift(pins []toolchainPin, want map[string]string) []toolchainPin {
+	var drift []toolchainPin
+	for _, p := range pins {
+		w, ok := want[p.tool]
+		if !ok || p.version == w || strings.HasPrefix(p.version, w+".") {
+			continue
+		}
+		drift = append(drift, p)
+	}
+	return drift
+}
+
+// setToolchainPin returns the content of the file of the pin with the
+// version of the toolchain replaced.
+func setToolchainPin(content string, p toolchainPin, version string) string {
+	switch p.file {
+	case "go.mod":
+		prefix := "go "
+		if strings.Contains(content, "\ntoolchain go") || strings.HasPrefix(content, "toolchain go") {
+			prefix = "toolchain go"
+		}
+		return regexp.MustCompile(`(?m)^`+regexp.QuoteMeta(prefix)+`\S+`).ReplaceAllString(content, prefix+version)
+	case ".tool-versions":
+		lines := strings.Split(content, "\n")
+		for i, line := range lines {
+			fields := strings.Fields(line)
+			if len(fields) >= 2 && fields[1] == p.version && (fields[0] == p.tool || asdfNames[fields[0]] == p.tool) {
+				k := strings.Index(line, fields[0]) + len(fields[0])
+				lines[i] = line[:k] + strings.Replace(line[k:], p.version, version, 1)
+			}
+		}
+		return strings.Join(lines, "\n")
+	}
+	if strings.HasPrefix(strings.TrimSpace(content), "v") {
+		version = "v" + version
+	}
+	return version + "\n"
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func init() {
+	registerAudit(&subcommand{
+		name:    "unpublished",
+		summary: "find local branches and stashes with commits on no remote, work that only exists on this machine",
+		run:     runAuditUnpublished,
+	})
+}
+
+func runAuditUnpublished(ws *workspace, args []string) int {
+	fs := newSubcommandFlags("audit unpublished", "")
+	fetch := fs.Bool("fetch", false, "fetch all the remotes first, so the remote-tracking branches are up to date")
+	fs.Parse(args)
+
+	results, exitCode := forEachRepo(ws.repos, ws.parallel, func(repo string) (string, int) {
+		relPath := relativePath(ws.root, repo)
+		if *fetch {
+			if _, err := runGit(repo, "fetch", "-q", "--all"); err != nil {
+				return fmt.Sprintf("\033[1m❌ %s:\033[0m %v", relPath, err), 1
+			}
+		}
+		lines, err := findUnpublished(repo)
+		if err != nil {
+			return fmt.Sprintf("\033[1m❌ %s:\033[0m %v", relPath, err), 1
+		}
+		if len(lines) == 0 {
+			return "", 0
+		}
+		return fmt.Sprintf("\033[1m⚠️ %s:\033[0m\n  %s", relPath, strings.Join(lines, "\n  ")), 1
+	})
+	printResults(results)
+	return exitCode
+}
+
+// findUnpublished describes the local branches with commits that no
+// remote-tracking branch has, and the stashes.
+func findUnpublished(repo string) ([]string, error) {
+	out, err := runGit(repo, "for-each-ref", "--format=%(committerdate:unix) %(refname:short)", "refs/heads")
+	if err != nil {
+		return nil, err
+	}
+	remoteRefs, err := getRefs(repo, "refs/remotes")
+	if err != nil {
+		return nil, err
+	}
+
+	var lines []string
+	for _, line := range strings.Split(out, "\n") {
+		date, branch, ok := strings.Cut(line, " ")
+		if !ok {
+			continue
+		}
+		count, err := runGit(repo, "rev-list", "--count", "refs/heads/"+branch, "--not", "--remotes")
+		if err != nil {
+			return nil, err
+		}
+		if count == "0" {
+			continue
+		}
+		description := fmt.Sprintf("%s: %s commits on no remote", branch, count)
+		if !hasRemoteBranch(remoteRefs, branch) {
+			description = fmt.Sprintf("%s: never pushed, %s commits", branch, count)
+		}
+		if unix, err := strconv.ParseInt(date, 10, 64); err == nil {
+			description += ", last committed " + formatAge(time.Since(time.Unix(unix, 0))) + " ago"
+		}
+		lines = append(lines, description)
+	}
+
+	// git stash list fails without any commits
+	if stashes, _ := runGit(repo, "stash", "list"); stashes != "" {
+		lines = append(lines, fmt.Sprintf("%d stashes", strings.Count(stashes, "\n")+1))
+	}
+	return lines, nil
+}
+
+// hasRemoteBranch reports whether any remote has a remote-tracking branch of the name.
+func hasRemoteBranch(remoteRefs []string, branch string) bool {
+	for _, ref := range remoteRefs {
+		// refs/remotes/<remote>/<branch>
+		if _, name, ok := strings.Cut(strings.TrimPrefix(ref, "refs/remotes/"), "/"); ok && name == branch {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"slices"
+	"strings"
+	"time"
+)
+
+func init() {
+	registerSubcommand(&subcommand{
+		name:    "bisect",
+		summary: "find the repository and commit that broke a test across several repositories",
+		run:     runBisect,
+	})
+}
+
+// bisectStep is a commit in the interleaved history of the repositories.
+type bisectStep struct {
+	repo string
+	commitInfo
+}
+
+func runBisect(ws *workspace, args []string) int {
+	fs := newSubcommandFlags("bisect", "")
+	var repoNames []string
+	fs.Var((*stringList)(&repoNames), "repos", "comma separated paths of the repositories to step through, relative to the workspace root (may be repeated)")
+	test := fs.String("test", "", "shell command, run in the workspace root, that exits 0 if the workspace is good")
+	goodAt := fs.String("good", "", "time the test passed, e.g. 2024-06-01 or 14d")
+	verbose := fs.Bool("v", false, "show the output of the test")
+	fs.Parse(args)
+
+	if *test == "" || *goodAt == "" || len(repoNames) == 0 {
+		fmt.Println("-repos, -test and -good are required")
+		fs.Usage()
+		return 1
+	}
+	good, err := parseTime(*goodAt)
+	if err != nil {
+		fmt.Println("Invalid -good:", err)
+		return 1
+	}
+
+	var repos []string
+	for _, names := range repoNames {
+		for _, name := range strings.Split(names, ",") {
+			i := slices.IndexFunc(ws.repos, func(r string) bool { return relativePath(ws.root, r) == strings.TrimSpace(name) })
+			if i < 0 {
+				fmt.Printf("No repository `%s` in the workspace\n", name)
+				return 1
+			}
+			repos = append(repos, ws.repos[i])
+		}
+	}
+
+	release, ok := ws.lock("bisect")
+	if !ok {
+		return 1
+	}
+	defer release()
+
+	// Each repository starts at its last commit before the good time, and the
+	// commits since are merged into one timeline by time
+	baseline := make(map[string]string)
+	var timeline []bisectStep
+	for _, repo := range repos {
+		relPath := relativePath(ws.root, repo)
+		if changed, err := hasTrackedChanges(repo); err != nil || changed {
+			fmt.Printf("%s has uncommitted changes, commit or stash them first\n", relPath)
+			return 1
+		}
+		if baseline[repo], err = getCommitBefore(repo, "HEAD", good); err != nil {
+			fmt.Printf("Error finding the good commit of %s: %v\n", relPath, err)
+			return 1
+		}
+		commits, err := getCommits(repo, "--first-parent", baseline[repo]+"..HEAD")
+		if err != nil {
+			fmt.Printf("Error reading the history of %s: %s\n", relPath, errorReason(err))
+			return 1
+		}
+		slices.Reverse(commits)
+		for _, c := range commits {
+			timeline = append(timeline, bisectStep{repo: repo, commitInfo: c})
+		}
+	}
+	slices.SortStableFunc(timeline, func(a, b bisectStep) int { return a.when.Compare(b.when) })
+
+	// Whatever happens the repositories go back to where they were
+	positions := make(map[string]string)
+	for _, repo := range repos {
+		if positions[repo], err = getHeadPosition(repo); err != nil {
+			fmt.Printf("Error reading HEAD of %s: %v\n", relativePath(ws.root, repo), err)
+			return 1
+		}
+	}
+	defer func() {
+		for _, repo := range repos {
+			if err := checkoutPosition(repo, positions[repo]); err != nil {
+				fmt.Printf("Error restoring %s to %s: %v\n", relativePath(ws.root, repo), positions[repo], err)
+			}
+		}
+	}()
+
+	// passes checks out the workspace with the first n commits of the
+	// timeline and runs the test
+	passes := func(n int) (bool, error) {
+		for _, repo := range repos {
+			commit := baseline[repo]
+			for _, s := range timeline[:n] {
+				if s.repo == repo {
+					commit = s.hash
+				}
+			}
+			if err := checkoutDetached(repo, commit); err != nil {
+				return false, fmt.Errorf("%s: %v", relativePath(ws.root, repo), err)
+			}
+		}
+
+		start := time.Now()
+		cmd := exec.Command("sh", "-c", *test)
+		cmd.Dir = ws.root
+		cmd.Env = ws.cmdOpts.env.build(nil)
+		out, err := cmd.CombinedOutput()
+		if _, failed := err.(*exec.ExitError); err != nil && !failed {
+			return false, err
+		}
+		state := "\033[32mgood\033[0m"
+		if err != nil {
+			state = "\033[31mbad\033[0m"
+		}
+		step := "good time"
+		if n > 0 {
+			s := timeline[n-1]
+			step = fmt.Sprintf("%s %s %s", relativePath(ws.root, s.repo), s.hash[:10], s.when.Format("2006-01-02 15:04"))
+		}
+		fmt.Printf("%s: %s \033[2m(%s)\033[0m\n", step, state, time.Since(start).Round(time.Second))
+		if *verbose && len(out) > 0 {
+			fmt.Println("  " + strings.ReplaceAll(strings.TrimSpace(string(out)), "\n", "\n  "))
+		}
+		return err == nil, nil
+	}
+
+	fmt.Printf("Bisecting %d commits across %d repositories\n", len(timeline), len(repos))
+	if ok, err := passes(len(timeline)); err != nil {
+		fmt.Println("Error:", err)
+		return 1
+	} else if ok {
+		fmt.Println("The test passes with every commit, nothing to bisect")
+		return 1
+	}
+	if ok, err := passes(0); err != nil {
+		fmt.Println("Error:", err)
+		return 1
+	} else if !ok {
+		fmt.Println("The test already fails at the good time")
+		return 1
+	}
+
+	// passes(lo) and !passes(hi)
+	lo, hi := 0, len(timeline)
+	for hi-lo > 1 {
+		mid := (lo + hi) / 2
+		ok, err := passes(mid)
+		if err != nil {
+			fmt.Println("Error:", err)
+			return 1
+		}
+		if ok {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+
+	first := timeline[hi-1]
+	fmt.Printf("\033[1m%s %s is the first bad commit\033[0m\n", relativePath(ws.root, first.repo), first.hash)
+	fmt.Printf("  %s <%s> %s\n  %s\n", first.authorName, first.authorEmail, first.when.Format("2006-01-02 15:04"), first.subject)
+	return 0
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"os/exec"
+	"path"
+	"slices"
+	"strings"
+)
+
+func init() {
+	registerSubcommand(&subcommand{
+		name:    "blame-gaps",
+		summary: "find the files whose recent commits are all by people who have left, the knowledge at risk in each repository",
+		run:     runBlameGaps,
+	})
+}
+
+// blameGapsHotspots is how many directories with the most such files are
+// shown for each repository.
+const blameGapsHotspots = 5
+
+// blameGap is a directory of files only recently changed by departed authors.
+type blameGap struct {
+	dir   string
+	files []string
+	// authors are the departed authors of the files
+	authors []string
+}
+
+func runBlameGaps(ws *workspace, args []string) int {
+	fs := newSubcommandFlags("blame-gaps", "")
+	departed := slices.Clone(ws.config.DepartedAuthors)
+	fs.Var((*stringList)(&departed), "departed", "email or name, or glob of them such as '*@old-vendor.com', of an author who has left, along with departed_authors of the configuration (repeatable)")
+	depth := fs.Int("depth", 3, "how many of the latest commits of each file count")
+	since := fs.String("since", "", "only count commits after this date, as for git log --since, such as 2y")
+	verbose := fs.Bool("v", false, "list the files, not only the directories with the most of them")
+	fs.Parse(args)
+
+	if len(departed) == 0 {
+		fmt.Println("No departed authors, set departed_authors in the configuration or use -departed")
+		return 1
+	}
+	if *depth < 1 {
+		fmt.Println("Invalid -depth: must be at least 1")
+		return 1
+	}
+	isDeparted := func(email string, name string) bool {
+		for _, d := range departed {
+			d = strings.ToLower(d)
+			if matchGlob(d, strings.ToLower(email)) || matchGlob(d, strings.ToLower(name)) {
+				return true
+			}
+		}
+		return false
+	}
+
+	results, exitCode := forEachRepo(ws.repos, ws.parallel, func(repo string) (string, int) {
+		relPath := relativePath(ws.root, repo)
+		gaps, total, err := findBlameGaps(repo, *depth, *since, isDeparted)
+		if err != nil {
+			return fmt.Sprintf("\033[1m❌ %s:\033[0m %v", relPath, err), 1
+		}
+		count := 0
+		for _, g := range gaps {
+			count += len(g.files)
+		}
+		if count == 0 {
+			return "", 0
+		}
+
+		var lines []string
+		for i, g := range gaps {
+			if i == blameGapsHotspots && !*verbose {
+				lines = append(lines, fmt.Sprintf("and %d more directories, -v lists them", len(gaps)-i))
+				break
+			}
+			lines = append(lines, fmt.Sprintf("%s %d files, by %s", g.dir, len(g.files), strings.Join(g.authors, ", ")))
+			if *verbose {
+				for _, f := range g.files {
+					lines = append(lines, "  "+f)
+				}
+			}
+		}
+		return fmt.Sprintf("\033[1m⚠️ %s:\033[0m %d of %d files only changed by departed authors\n  %s", relPath, count, total, strings.Join(lines, "\n  ")), 1
+	})
+	printResults(results)
+	return exitCode
+}
+
+// findBlameGaps returns the directories of the files of HEAD whose latest
+// commits, up to depth of them, are all by departed authors, those with the
+// most files first, and how many files HEAD has.
+func findBlameGaps(repo string, depth int, since string, isDeparted func(email string, name string) bool) ([]blameGap, int, error) {
+	out, err := runGit(repo, "ls-files", "-z")
+	if err != nil {
+		return nil, 0, err
+	}
+	tracked := make(map[string]bool)
+	for _, f := range strings.Split(out, "\x00") {
+		if f != "" {
+			tracked[f] = true
+		}
+	}
+
+	args := []string{"-C", repo, "-c", "core.quotePath=false", "log", "--no-merges", "--format=\x1e%aE\x1f%aN", "--name-only"}
+	if since != "" {
+		args = append(args, "--since="+since)
+	}
+	log, err := exec.Command("git", args...).Output()
+	if err != nil {
+		return nil, 0, fmt.Errorf("git log: %s", errorReason(err))
+	}
+
+	// the log is newest first, so the first commits seen of a file are its latest
+	seen := make(map[string]int)
+	onlyDeparted := make(map[string]bool)
+	authors := make(map[string]map[string]bool)
+	for _, record := range strings.Split(string(log), "\x1e") {
+		header, files, _ := strings.Cut(record, "\n")
+		email, name, ok := strings.Cut(header, "\x1f")
+		if !ok {
+			continue
+		}
+		departed := isDeparted(email, name)
+		for _, f := range strings.Split(files, "\n") {
+			if f == "" || !tracked[f] || seen[f] >= depth {
+				continue
+			}
+			if seen[f] == 0 {
+				onlyDeparted[f] = true
+				authors[f] = make(map[string]bool)
+			}
+			seen[f]++
+			onlyDeparted[f] = onlyDeparted[f] && departed
+			authors[f][cmp.Or(email, name)] = true
+		}
+	}
+
+	byDir := make(map[string]*blameGap)
+	for f, only := range onlyDeparted {
+		if !only {
+			continue
+		}
+		dir := path.Dir(f) + "/"
+		if dir == "./" {
+			dir = "/"
+		}
+		g, ok := byDir[dir]
+		if !ok {
+			g = &blameGap{dir: dir}
+			byDir[dir] = g
+		}
+		g.files = append(g.files, f)
+		for a := range authors[f] {
+			if !slices.Contains(g.authors, a) {
+				g.authors = append(g.authors, a)
+			}
+		}
+	}
+	gaps := make([]blameGap, 0, len(byDir))
+	for _, g := range byDir {
+		sortNames(g.files)
+		sortNames(g.authors)
+		gaps = append(gaps, *g)
+	}
+	slices.SortFunc(gaps, func(a, b blameGap) int {
+		if c := cmp.Compare(len(b.files), len(a.files)); c != 0 {
+			return c
+		}
+		return compareNames(a.dir, b.dir)
+	})
+	return gaps, len(tracked), nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Kinds of bridge repositories, git repositories kept in sync with another
+// version control system through git itself.
+const (
+	// bridgeSVN is a git-svn clone of a Subversion repository
+	bridgeSVN = "svn"
+	// bridgeHg is a clone of a Mercurial repository through the hg::
+	// remote helper of git-remote-hg or git-cinnabar
+	bridgeHg = "hg"
+	// bridgeNone is an ordinary git repository, for -bridge
+	bridgeNone = "none"
+)
+
+// detectBridge returns the kind of bridge the repository is, or "" for an
+// ordinary git repository. It reads the git config file rather than running
+// git, as it is done for every repository with -status.
+func detectBridge(path string) string {
+	_, common, ok := findGitDir(path)
+	if !ok {
+		// a bare repository is its own git directory
+		common = path
+	}
+	f, err := os.Open(filepath.Join(common, "config"))
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case strings.HasPrefix(line, "[svn-remote "):
+			return bridgeSVN
+		case strings.HasPrefix(line, "[cinnabar]"):
+			return bridgeHg
+		case strings.HasPrefix(line, "url"):
+			if _, url, ok := strings.Cut(line, "="); ok && strings.HasPrefix(strings.TrimSpace(url), "hg::") {
+				return bridgeHg
+			}
+		}
+	}
+	return ""
+}
+
+// svnRebaseRepo brings a git-svn repository up to date with git svn rebase,
+// the git-svn equivalent of pulling, which needs a clean worktree. The
+// commits made since are sent with git svn dcommit rather than pushed.
+func svnRebaseRepo(path string, relPath string) (string, int) {
+	changed, err := hasTrackedChanges(path)
+	if err != nil {
+		return fmt.Sprintf("\033[1m❌ %s:\033[0m %v", relPath, err), 1
+	}
+	if changed {
+		return fmt.Sprintf("\033[1m⏭️ %s:\033[0m has uncommitted changes, git svn rebase needs a clean worktree", relPath), 0
+	}
+	before, _ := resolveCommit(path, "HEAD")
+	if _, err := runGit(path, "svn", "rebase", "-q"); err != nil {
+		return fmt.Sprintf("\033[1m❌ %s:\033[0m %v", relPath, err), 1
+	}
+	after, _ := resolveCommit(path, "HEAD")
+	if before == after {
+		return fmt.Sprintf("\033[1m✅️ %s:\033[0m up to date with svn", relPath), 0
+	}
+	return fmt.Sprintf("\033[1m✅️ %s:\033[0m rebased onto svn %s..%s", relPath, commitLink(path, before), commitLink(path, after)), 0
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+func init() {
+	registerSubcommand(&subcommand{
+		name:    "bundle",
+		summary: "move repository updates between machines without network access, see `gits bundle create|apply`",
+		run:     runBundle,
+
+		standalone: true,
+	})
+}
+
+// bundleIndexName is the index written next to the bundles.
+const bundleIndexName = "bundles.json"
+
+// bundleIndex describes a set of bundles, and is the snapshot that
+// incremental bundles are created since.
+type bundleIndex struct {
+	SchemaVersion int       `json:"schema_version"`
+	Created       time.Time `json:"created"`
+	// Repos are by path relative to the workspace root
+	Repos map[string]*bundledRepo `json:"repos"`
+}
+
+type bundledRepo struct {
+	// Bundle is the bundle file relative to the index, empty if nothing changed since the snapshot
+	Bundle string `json:"bundle,omitempty"`
+	// Incremental bundles need the repository to already have the previous bundle's commits
+	Incremental bool `json:"incremental,omitempty"`
+	// URL is the origin remote, which clones made from the bundle are set up with
+	URL string `json:"url,omitempty"`
+	// Refs are the branch and tag tips in the repository when the bundle was created
+	Refs map[string]string `json:"refs"`
+}
+
+func readBundleIndex(path string) (*bundleIndex, error) {
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		path = filepath.Join(path, bundleIndexName)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var index bundleIndex
+	if err := json.Unmarshal(data, &index); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return &index, nil
+}
+
+func runBundle(ws *workspace, args []string) int {
+	if len(args) > 0 {
+		switch args[0] {
+		case "create":
+			return runBundleCreate(ws, args[1:])
+		case "apply":
+			return runBundleApply(ws, args[1:])
+		}
+	}
+	fmt.Println("Usage: gits [options] bundle create [-since <snapshot>] <dir>")
+	fmt.Println("       gits [options] bundle apply [-remote <name>] <dir>")
+	return 1
+}
+
+func runBundleCreate(ws *workspace, args []string) int {
+	fs := newSubcommandFlags("bundle create", " <dir>")
+	since := fs.String("since", "", "bundles.json, or the directory of it, of a previous run to only bundle what changed since (default: full bundles)")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 1
+	}
+	dir := fs.Arg(0)
+
+	var previous *bundleIndex
+	if *since != "" {
+		var err error
+		previous, err = readBundleIndex(*since)
+		if err != nil {
+			fmt.Println("Error reading snapshot:", err)
+			return 1
+		}
+	}
+
+	repos, err := ws.rediscover()
+	if err != nil {
+		fmt.Println("Error walking the path:", err)
+		return 1
+	}
+
+	index := &bundleIndex{SchemaVersion: schemaVersion, Created: time.Now().UTC(), Repos: make(map[string]*bundledRepo)}
+	var mu sync.Mutex
+
+	results, exitCode := forEachRepo(repos, ws.parallel, func(path string) (string, int) {
+		relPath := relativePath(ws.root, path)
+		var prev *bundledRepo
+		if previous != nil {
+			prev = previous.Repos[relPath]
+		}
+
+		entry, result, code := createBundle(path, relPath, dir, prev)
+		if entry != nil {
+			mu.Lock()
+			index.Repos[relPath] = entry
+			mu.Unlock()
+		}
+		return result, code
+	})
+	printResults(results)
+
+	data, _ := json.MarshalIndent(index, "", "  ")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		fmt.Println("Error writing index:", err)
+		return 1
+	}
+	if err := os.WriteFile(filepath.Join(dir, bundleIndexName), append(data, '\n'), 0o644); err != nil {
+		fmt.Println("Error writing index:", err)
+		return 1
+	}
+	return exitCode
+}
+
+// createBundle bundles the branches and tags of the repository, leaving out
+// the commits that the previous bundle had.
+func createBundle(path string, relPath string, dir string, prev *bundledRepo) (*bundledRepo, string, int) {
+	refs, err := getRefTips(path, "refs/heads", "refs/tags")
+	if err != nil {
+		return nil, fmt.Sprintf("\033[1m❌ %s:\033[0m %v", relPath, errorReason(err)), 1
+	}
+	if len(refs) == 0 {
+		return nil, fmt.Sprintf("\033[1m⏭️ %s:\033[0m no branches to bundle", relPath), 0
+	}
+	url, _ := getConfigValue(path, "remote.origin.url")
+	entry := &bundledRepo{URL: url, Refs: refs}
+
+	if prev != nil && maps.Equal(prev.Refs, refs) {
+		return entry, "", 0
+	}
+
+	revs := []string{"--branches", "--tags"}
+	if prev != nil {
+		for _, hash := range prev.Refs {
+			// commits the repository no longer has, say after a rewrite, can't be left out
+			if commit, err := resolveCommit(path, hash); err == nil {
+				revs = append(revs, "^"+commit)
+				entry.Incremental = true
+			}
+		}
+	}
+
+	entry.Bundle = filepath.ToSlash(relPath) + ".bundle"
+	file := filepath.Join(dir, filepath.FromSlash(entry.Bundle))
+	if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+		return nil, fmt.Sprintf("\033[1m❌ %s:\033[0m %v", relPath, err), 1
+	}
+
+	args := append([]string{"-C", path, "bundle", "create", "-q", file}, revs...)
+	if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+		if strings.Contains(string(out), "empty bundle") {
+			// only refs were deleted, there are no new commits
+			entry.Bundle = ""
+			return entry, "", 0
+		}
+		return nil, fmt.Sprintf("\033[1m❌ %s:\033[0m\n  %s", relPath, strings.ReplaceAll(strings.TrimSpace(string(out)), "\n", "\n  ")), 1
+	}
+
+	kind := "full"
+	if entry.Incremental {
+		kind = "incremental"
+	}
+	return entry, fmt.Sprintf("\033[1m✅️ %s:\033[0m %s bundle of %d refs", relPath, kind, len(refs)), 0
+}
+
+func runBundleApply(ws *workspace, args []string) int {
+	fs := newSubcommandFlags("bundle apply", " <dir>")
+	remote := fs.String("remote", "origin", "remote whose tracking branches are updated from the bundles")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 1
+	}
+	// clones made from the bundles keep the path as their remote if there is no URL
+	dir, err := filepath.Abs(fs.Arg(0))
+	if err != nil {
+		fmt.Println("Error reading bundles:", err)
+		return 1
+	}
+
+	index, err := readBundleIndex(dir)
+	if err != nil {
+		fmt.Println("Error reading bundles:", err)
+		return 1
+	}
+
+	release, ok := ws.lock("bundle apply")
+	if !ok {
+		return 1
+	}
+	defer release()
+
+	var paths []string
+	entries := make(map[string]*bundledRepo)
+	for relPath, entry := range index.Repos {
+		if entry.Bundle == "" {
+			continue
+		}
+		path := filepath.Join(ws.root, filepath.FromSlash(relPath))
+		paths = append(paths, path)
+		entries[path] = entry
+	}
+	sort.Strings(paths)
+
+	results, exitCode := forEachRepo(paths, ws.parallel, func(path string) (string, int) {
+		return applyBundle(path, relativePath(ws.root, path), filepath.Join(dir, filepath.FromSlash(entries[path].Bundle)), entries[path], *remote)
+	})
+	printResults(results)
+	return exitCode
+}
+
+// applyBundle fetches the bundle's branches into the remote's tracking
+// branches, or clones the repository from a full bundle if it is missing.
+func applyBundle(path string, relPath string, file string, entry *bundledRepo, remote string) (string, int) {
+	failed := func(out []byte) (string, int) {
+		return fmt.Sprintf("\033[1m❌ %s:\033[0m\n  %s", relPath, strings.ReplaceAll(strings.TrimSpace(string(out)), "\n", "\n  ")), 1
+	}
+
+	if _, err := os.Stat(path); err != nil {
+		if entry.Incremental {
+			return fmt.Sprintf("\033[1m❌ %s:\033[0m missing, and an incremental bundle can't be cloned", relPath), 1
+		}
+		if out, err := exec.Command("git", "clone", "--quiet", "--origin", remote, "--", file, path).CombinedOutput(); err != nil {
+			return failed(out)
+		}
+		if entry.URL != "" {
+			if out, err := exec.Command("git", "-C", path, "remote", "set-url", remote, entry.URL).CombinedOutput(); err != nil {
+				return failed(out)
+			}
+		}
+		return fmt.Sprintf("\033[1m✅️ %s:\033[0m cloned from bundle", relPath), 0
+	}
+
+	// verify reports the commits the repository lacks for an incremental bundle
+	if out, err := exec.Command("git", "-C", path, "bundle", "verify", "-q", file).CombinedOutput(); err != nil {
+		return failed(out)
+	}
+	out, err := exec.Command("git", "-C", path, "fetch", "--quiet", file,
+		"+refs/heads/*:refs/remotes/"+remote+"/*", "refs/tags/*:refs/tags/*").CombinedOutput()
+	if err != nil {
+		return failed(out)
+	}
+	return fmt.Sprintf("\033[1m✅️ %s:\033[0m fetched into %s", relPath, remote), 0
+}
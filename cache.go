@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// tempFileAge is how old a temporary file must be to be left behind by a
+// run rather than in use by one.
+const tempFileAge = 24 * time.Hour
+
+func runCacheDir(args []string) int {
+	newSubcommandFlags("cache dir", "").Parse(args)
+	cache, err := cacheDir()
+	if err != nil {
+		fmt.Println("Error finding the cache directory:", err)
+		return 1
+	}
+	state, err := stateDir()
+	if err != nil {
+		fmt.Println("Error finding the state directory:", err)
+		return 1
+	}
+	fmt.Println("cache:", cache)
+	fmt.Println("state:", state)
+	return 0
+}
+
+func runCacheClear(args []string) int {
+	newSubcommandFlags("cache clear", "").Parse(args)
+	dir, err := cacheDir()
+	if err != nil {
+		fmt.Println("Error finding the cache directory:", err)
+		return 1
+	}
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		fmt.Println("Nothing cached in", dir)
+		return 0
+	} else if err != nil {
+		fmt.Println("Error reading the cache:", err)
+		return 1
+	}
+
+	var files int
+	var size int64
+	exitCode := 0
+	for _, e := range entries {
+		path := filepath.Join(dir, e.Name())
+		switch e.Name() {
+		case "locks":
+			// removing the lock of a run would let another run in
+			continue
+		case "tmp":
+			// only what runs left behind, not what running ones use
+			filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+				if err != nil || p == path {
+					return nil
+				}
+				info, err := d.Info()
+				if err != nil || time.Since(info.ModTime()) < tempFileAge {
+					return nil
+				}
+				n, s := usage(p)
+				if err := os.RemoveAll(p); err != nil {
+					fmt.Println("Error:", err)
+					exitCode = 1
+				} else {
+					files, size = files+n, size+s
+				}
+				if d.IsDir() {
+					return fs.SkipDir
+				}
+				return nil
+			})
+			continue
+		}
+		n, s := usage(path)
+		if err := os.RemoveAll(path); err != nil {
+			fmt.Println("Error:", err)
+			exitCode = 1
+			continue
+		}
+		files, size = files+n, size+s
+	}
+	fmt.Printf("Removed %d files, %s, from %s\n", files, formatBytes(size), dir)
+	return exitCode
+}
+
+// usage returns the number and size of the files under path.
+func usage(path string) (int, int64) {
+	var files int
+	var size int64
+	filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			if info, err := d.Info(); err == nil {
+				files++
+				size += info.Size()
+			}
+		}
+		return nil
+	})
+	return files, size
+}
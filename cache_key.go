@@ -0,0 +1,91 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+func init() {
+	registerSubcommand(&subcommand{
+		name:    "cache-key",
+		summary: "print a build cache key for each repository, hashing HEAD and any uncommitted changes",
+		run:     runCacheKey,
+	})
+}
+
+// cacheKeys is the JSON output of `gits cache-key -json`.
+type cacheKeys struct {
+	SchemaVersion int `json:"schema_version"`
+	// Workspace is a key of all the repositories together
+	Workspace string     `json:"workspace"`
+	Repos     []cacheKey `json:"repos"`
+}
+
+type cacheKey struct {
+	Path string `json:"path"`
+	Key  string `json:"key"`
+	// Dirty is whether uncommitted changes went into the key
+	Dirty bool `json:"dirty"`
+}
+
+// envNameUnsafe are the characters that cannot be in an environment variable name.
+var envNameUnsafe = regexp.MustCompile(`[^A-Z0-9_]+`)
+
+func runCacheKey(ws *workspace, args []string) int {
+	fs := newSubcommandFlags("cache-key", "")
+	asJSON := fs.Bool("json", false, "print the keys as JSON")
+	asEnv := fs.Bool("env", false, "print the keys as shell variable assignments, GITS_CACHE_KEY_<PATH>=key, for eval")
+	fs.Parse(args)
+
+	keys := make([]cacheKey, len(ws.repos))
+	results, exitCode := forEachRepoWith(ws.repos, ws.parallel, false, func(repo string) (string, int) {
+		relPath := relativePath(ws.root, repo)
+		key, err := contentFingerprint(repo)
+		if err != nil {
+			return fmt.Sprintf("\033[1m❌ %s:\033[0m %v", relPath, err), 1
+		}
+		dirty, err := isDirty(repo)
+		if err != nil {
+			return fmt.Sprintf("\033[1m❌ %s:\033[0m %v", relPath, err), 1
+		}
+		keys[slices.Index(ws.repos, repo)] = cacheKey{Path: relPath, Key: key, Dirty: dirty}
+		return "", 0
+	})
+	printResults(results)
+	if exitCode != 0 {
+		return exitCode
+	}
+
+	h := sha256.New()
+	for _, k := range keys {
+		fmt.Fprintf(h, "%s %s\n", k.Path, k.Key)
+	}
+	workspaceKey := hex.EncodeToString(h.Sum(nil))
+
+	switch {
+	case *asJSON:
+		data, _ := json.MarshalIndent(cacheKeys{SchemaVersion: schemaVersion, Workspace: workspaceKey, Repos: keys}, "", "  ")
+		fmt.Println(string(data))
+	case *asEnv:
+		for _, k := range keys {
+			fmt.Printf("export GITS_CACHE_KEY_%s=%s\n", strings.Trim(envNameUnsafe.ReplaceAllString(strings.ToUpper(k.Path), "_"), "_"), k.Key)
+		}
+		fmt.Printf("export GITS_CACHE_KEY=%s\n", workspaceKey)
+	default:
+		width := max(longestRelPath(ws.root, ws.repos), len("(workspace)"))
+		for _, k := range keys {
+			dirty := ""
+			if k.Dirty {
+				dirty = " \033[2m(dirty)\033[0m"
+			}
+			fmt.Printf("%-*s %s%s\n", width, k.Path, k.Key, dirty)
+		}
+		fmt.Printf("%-*s %s\n", width, "(workspace)", workspaceKey)
+	}
+	return 0
+}
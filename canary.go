@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// pickCanaries splits the repositories into the canaries a command is run in
+// first and the rest. The spec is a number of repositories or a percentage of
+// them, which are picked at random, or a comma separated list of paths.
+func pickCanaries(spec string, root string, repos []string) ([]string, []string, error) {
+	var canaries []string
+	count, percent := strings.CutSuffix(spec, "%")
+	if n, err := strconv.Atoi(count); err == nil {
+		if n <= 0 || (percent && n > 100) {
+			return nil, nil, fmt.Errorf("%s is not a number of repositories or a percentage", spec)
+		}
+		if percent {
+			// at least one repository, rounding up
+			n = (len(repos)*n + 99) / 100
+		}
+		shuffled := slices.Clone(repos)
+		rand.Shuffle(len(shuffled), func(i, j int) { shuffled[i], shuffled[j] = shuffled[j], shuffled[i] })
+		canaries = shuffled[:min(n, len(shuffled))]
+	} else {
+		for _, p := range strings.Split(spec, ",") {
+			path := filepath.Join(root, strings.TrimSpace(p))
+			if !slices.Contains(repos, path) {
+				return nil, nil, fmt.Errorf("%s is not a matched repository", p)
+			}
+			canaries = append(canaries, path)
+		}
+	}
+
+	var rest []string
+	for _, repo := range repos {
+		if !slices.Contains(canaries, repo) {
+			rest = append(rest, repo)
+		}
+	}
+	return canaries, rest, nil
+}
+
+// confirm asks a yes or no question on the terminal, defaulting to no,
+// including when there is no input to answer it.
+func confirm(question string) bool {
+	fmt.Printf("%s [y/N] ", question)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
+	}
+	if answer == "" {
+		fmt.Println()
+	}
+	return false
+}
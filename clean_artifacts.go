@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+)
+
+func init() {
+	registerSubcommand(&subcommand{
+		name:    "clean-artifacts",
+		summary: "remove build output directories such as node_modules and target, showing their size first",
+		run:     runCleanArtifacts,
+	})
+}
+
+// defaultArtifacts are the build output directories removed unless the
+// profile or -dir name others.
+var defaultArtifacts = []string{"node_modules", "target", "dist", "build", "bin", "out", ".gradle", "__pycache__", ".venv"}
+
+// artifactDir is a build output directory found in a repository.
+type artifactDir struct {
+	path string
+	size int64
+}
+
+func runCleanArtifacts(ws *workspace, args []string) int {
+	fs := newSubcommandFlags("clean-artifacts", "")
+	var dirs []string
+	fs.Var((*stringList)(&dirs), "dir", "name of a build output directory to remove, instead of the defaults (may be repeated)")
+	dryRun := fs.Bool("dry-run", false, "only show what would be removed")
+	yes := fs.Bool("yes", false, "remove without asking for confirmation")
+	fs.Parse(args)
+
+	if len(dirs) == 0 && ws.profile != nil {
+		dirs = ws.profile.Artifacts
+	}
+	if len(dirs) == 0 {
+		dirs = defaultArtifacts
+	}
+
+	var mu sync.Mutex
+	found := make(map[string][]artifactDir)
+	results, exitCode := forEachRepo(ws.repos, ws.parallel, func(repo string) (string, int) {
+		relPath := relativePath(ws.root, repo)
+		artifacts, err := findArtifacts(repo, dirs)
+		if err != nil {
+			return fmt.Sprintf("\033[1m❌ %s:\033[0m %v", relPath, err), 1
+		}
+		if len(artifacts) == 0 {
+			return "", 0
+		}
+		mu.Lock()
+		found[repo] = artifacts
+		mu.Unlock()
+
+		var total int64
+		var lines []string
+		for _, a := range artifacts {
+			total += a.size
+			lines = append(lines, fmt.Sprintf("%-10s %s", formatBytes(a.size), relativePath(repo, a.path)))
+		}
+		return fmt.Sprintf("\033[1m🧹 %s:\033[0m %s\n  %s", relPath, formatBytes(total), strings.Join(lines, "\n  ")), 0
+	})
+	printResults(results)
+
+	var total int64
+	var count int
+	for _, artifacts := range found {
+		for _, a := range artifacts {
+			total += a.size
+			count++
+		}
+	}
+	if count == 0 {
+		fmt.Println("No build output directories found")
+		return exitCode
+	}
+	fmt.Printf("%s in %d directories of %d repositories\n", formatBytes(total), count, len(found))
+	if *dryRun || (!*yes && !confirm("Remove them?")) {
+		return exitCode
+	}
+
+	release, ok := ws.lock("clean-artifacts")
+	if !ok {
+		return 1
+	}
+	defer release()
+
+	var repos []string
+	for repo := range found {
+		repos = append(repos, repo)
+	}
+	sortNames(repos)
+	results, removeExitCode := forEachRepo(repos, ws.parallel, func(repo string) (string, int) {
+		for _, a := range found[repo] {
+			if err := os.RemoveAll(a.path); err != nil {
+				return fmt.Sprintf("\033[1m❌ %s:\033[0m %v", relativePath(ws.root, repo), err), 1
+			}
+		}
+		return "", 0
+	})
+	printResults(results)
+	if removeExitCode == 0 {
+		fmt.Printf("Removed %s\n", formatBytes(total))
+	}
+	return max(exitCode, removeExitCode)
+}
+
+// findArtifacts returns the directories of the repository with one of the
+// names that hold no tracked files, so are safe to remove.
+func findArtifacts(repo string, names []string) ([]artifactDir, error) {
+	tracked, err := getTrackedFiles(repo)
+	if err != nil {
+		return nil, err
+	}
+	var artifacts []artifactDir
+	err = filepath.WalkDir(repo, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return err
+		}
+		if path != repo && (d.Name() == ".git" || isGitRepo(path)) {
+			// nested repositories are matched on their own
+			return filepath.SkipDir
+		}
+		if path == repo || !slices.Contains(names, d.Name()) {
+			return nil
+		}
+		prefix := filepath.ToSlash(relativePath(repo, path)) + "/"
+		if slices.ContainsFunc(tracked, func(f string) bool { return strings.HasPrefix(f, prefix) }) {
+			// source, such as a bin directory of scripts
+			return nil
+		}
+		artifacts = append(artifacts, artifactDir{path: path, size: diskUsage(path)})
+		return filepath.SkipDir
+	})
+	return artifacts, err
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+func init() {
+	registerSubcommand(&subcommand{
+		name:    "clone",
+		summary: "clone the repositories of the workspace manifest that are missing",
+		run:     runClone,
+
+		standalone: true,
+	})
+}
+
+func runClone(ws *workspace, args []string) int {
+	fs := newSubcommandFlags("clone", "")
+	partial := fs.String("partial", "", "partial clone kind for every repository: blobless, treeless or none (default: from the manifest)")
+	refOption := fs.String("reference-dir", "", "borrow objects from the reference repositories maintained by `gits cache update` in this directory (default: reference_dir from the configuration)")
+	fs.Parse(args)
+
+	if _, ok := partialFilters[*partial]; *partial != "" && *partial != "none" && !ok {
+		fmt.Println("Invalid -partial: expected blobless, treeless or none")
+		return 1
+	}
+
+	m, err := findManifest(ws.root)
+	if err != nil {
+		fmt.Println("Error reading manifest:", err)
+		return 1
+	}
+	if m == nil {
+		fmt.Printf("No %s found in %s or above\n", manifestName, ws.root)
+		return 1
+	}
+
+	release, ok := ws.lock("clone")
+	if !ok {
+		return 1
+	}
+	defer release()
+
+	results, exitCode := cloneMissing(ws, m, *partial, referenceDir(ws, *refOption), nil)
+	if results == nil {
+		fmt.Println("All repositories are present")
+		return 0
+	}
+	printResults(results)
+	return exitCode
+}
+
+// cloneMissing clones the repositories of the manifest that are missing,
+// returning no results if none are. Each clone is a step of steps, if set.
+func cloneMissing(ws *workspace, m *manifest, partial string, refDir string, steps *stepMatrix) ([]string, int) {
+	var missing []string
+	repos := make(map[string]manifestRepo)
+	for _, r := range m.Repos {
+		path := m.absPath(r)
+		if _, err := os.Stat(path); err == nil || r.Retired != "" {
+			continue
+		}
+		if partial != "" {
+			r.Partial = partial
+		}
+		missing = append(missing, path)
+		repos[path] = r
+	}
+	if len(missing) == 0 {
+		return nil, 0
+	}
+	return forEachRepo(missing, ws.parallel, func(path string) (string, int) {
+		relPath := relativePath(ws.root, path)
+		return steps.action(relPath, "clone", func() (string, int) {
+			return cloneRepo(m, repos[path], relPath, refDir)
+		})
+	})
+}
+
+// cloneRepo clones the repository of the manifest, as a partial clone and
+// with a sparse checkout if it declares them. With a reference directory
+// the clone borrows the objects of the remote's reference repository, if
+// there is one.
+func cloneRepo(m *manifest, r manifestRepo, relPath string, refDir string) (string, int) {
+	if r.URL == "" {
+		return fmt.Sprintf("\033[1m❌ %s:\033[0m no url in the manifest", relPath), 1
+	}
+
+	path := m.absPath(r)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Sprintf("\033[1m❌ %s:\033[0m %v", relPath, err), 1
+	}
+
+	args := []string{"clone", "--quiet"}
+	var notes []string
+	if kind := m.partial(r); kind != "" {
+		args = append(args, "--filter="+partialFilters[kind])
+		notes = append(notes, kind)
+	}
+	if len(r.Sparse) > 0 {
+		args = append(args, "--sparse")
+	}
+	if r.Branch != "" {
+		args = append(args, "--branch", r.Branch)
+	}
+	if refDir != "" {
+		ref := referencePath(refDir, r.URL)
+		args = append(args, "--reference-if-able", ref)
+		if _, err := os.Stat(ref); err == nil {
+			notes = append(notes, "reference")
+		}
+	}
+	args = append(args, "--", r.URL, path)
+
+	if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+		return fmt.Sprintf("\033[1m❌ %s:\033[0m\n  %s", relPath, strings.ReplaceAll(strings.TrimSpace(string(out)), "\n", "\n  ")), 1
+	}
+
+	if len(r.Sparse) > 0 {
+		sparse := append([]string{"-C", path, "sparse-checkout", "set", "--cone", "--"}, r.Sparse...)
+		if out, err := exec.Command("git", sparse...).CombinedOutput(); err != nil {
+			return fmt.Sprintf("\033[1m❌ %s:\033[0m cloned, but setting the sparse checkout failed:\n  %s", relPath, strings.ReplaceAll(strings.TrimSpace(string(out)), "\n", "\n  ")), 1
+		}
+		notes = append(notes, "sparse: "+strings.Join(r.Sparse, ", "))
+	}
+
+	result := fmt.Sprintf("\033[1m✅️ %s:\033[0m cloned from %s", relPath, r.URL)
+	if len(notes) > 0 {
+		result += " (" + strings.Join(notes, ", ") + ")"
+	}
+	return result, 0
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v3"
+)
+
+func init() {
+	registerSubcommand(&subcommand{
+		name:    "codeowners",
+		summary: "check the CODEOWNERS files of the repositories or apply a shared one, see `gits codeowners lint|apply`",
+		run:     runCodeowners,
+	})
+}
+
+// codeownersPaths are where the forges look for the CODEOWNERS file, in
+// the order GitHub uses the first one found.
+var codeownersPaths = []string{".github/CODEOWNERS", "CODEOWNERS", "docs/CODEOWNERS", ".gitlab/CODEOWNERS"}
+
+// codeownersConfig is the shared ownership of `gits codeowners apply`.
+type codeownersConfig struct {
+	// Path is where the file is written if a repository has none yet
+	Path string `yaml:"path"`
+	// Template is a Go template of the repository path fields such as {{.Name}}
+	Template  string               `yaml:"template"`
+	Overrides []codeownersOverride `yaml:"overrides"`
+}
+
+// codeownersOverride appends rules for the matching repositories, which take
+// precedence over the template's as later rules win.
+type codeownersOverride struct {
+	// Repos is a glob of the repository paths relative to the workspace root
+	Repos string `yaml:"repos"`
+	Rules string `yaml:"rules"`
+}
+
+var (
+	codeownersUser  = regexp.MustCompile(`^@[\w.-]+(/[\w.-]+)?$`)
+	codeownersEmail = regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[^@\s]+$`)
+	// GitLab sections, ^[Name][approvals] @default-owners
+	codeownersSection = regexp.MustCompile(`^\^?\[[^\]]+\](\[\d+\])?`)
+)
+
+func runCodeowners(ws *workspace, args []string) int {
+	if len(args) > 0 {
+		switch args[0] {
+		case "lint":
+			return runCodeownersLint(ws, args[1:])
+		case "apply":
+			return runCodeownersApply(ws, args[1:])
+		}
+	}
+	fmt.Println("Usage: gits [options] codeowners lint|apply [codeowners options]")
+	return 1
+}
+
+func runCodeownersLint(ws *workspace, args []string) int {
+	fs := newSubcommandFlags("codeowners lint", "")
+	fs.Parse(args)
+
+	results, exitCode := forEachRepo(ws.repos, ws.parallel, func(repo string) (string, int) {
+		relPath := relativePath(ws.root, repo)
+		var problems []string
+		var used string
+		for _, p := range codeownersPaths {
+			data, err := os.ReadFile(filepath.Join(repo, p))
+			if os.IsNotExist(err) {
+				continue
+			} else if err != nil {
+				return fmt.Sprintf("\033[1m❌ %s:\033[0m %v", relPath, err), 1
+			}
+			if used != "" {
+				problems = append(problems, fmt.Sprintf("%s: ignored as %s is used", p, used))
+				continue
+			}
+			used = p
+			for _, problem := range lintCodeowners(string(data)) {
+				problems = append(problems, p+":"+problem)
+			}
+		}
+		if len(problems) == 0 {
+			return "", 0
+		}
+		return fmt.Sprintf("\033[1m⚠️ %s:\033[0m\n  %s", relPath, strings.Join(problems, "\n  ")), 1
+	})
+	printResults(results)
+	return exitCode
+}
+
+// lintCodeowners returns the problems with the CODEOWNERS content, each
+// prefixed with its line number.
+func lintCodeowners(content string) []string {
+	var problems []string
+	patterns := make(map[string]int)
+	for i, line := range strings.Split(content, "\n") {
+		n := i + 1
+		// a # starts a comment unless it is escaped
+		for j := 0; j < len(line); j++ {
+			if line[j] == '\\' {
+				j++
+			} else if line[j] == '#' {
+				line = line[:j]
+				break
+			}
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		var owners []string
+		if section := codeownersSection.FindString(line); section != "" {
+			owners = strings.Fields(line[len(section):])
+		} else {
+			fields := strings.Fields(line)
+			pattern := fields[0]
+			owners = fields[1:]
+			if strings.HasPrefix(pattern, "!") {
+				problems = append(problems, fmt.Sprintf("%d: negated pattern %s is not supported", n, pattern))
+			}
+			if prev, ok := patterns[pattern]; ok {
+				problems = append(problems, fmt.Sprintf("%d: %s is also on line %d, which this overrides", n, pattern, prev))
+			}
+			patterns[pattern] = n
+		}
+		for _, owner := range owners {
+			if !codeownersUser.MatchString(owner) && !codeownersEmail.MatchString(owner) {
+				problems = append(problems, fmt.Sprintf("%d: invalid owner %s, expected @user, @org/team or an email address", n, owner))
+			}
+		}
+	}
+	return problems
+}
+
+func runCodeownersApply(ws *workspace, args []string) int {
+	fs := newSubcommandFlags("codeowners apply", "")
+	configPath := fs.String("config", "", "YAML file with the template and overrides")
+	branch := fs.String("branch", "codeowners", "branch to commit the changes on, replaced if it exists")
+	message := fs.String("message", "Update CODEOWNERS", "commit message")
+	dryRun := fs.Bool("dry-run", false, "show the repositories that would change without committing")
+	fs.Parse(args)
+
+	if *configPath == "" {
+		fmt.Println("-config is required")
+		fs.Usage()
+		return 1
+	}
+	data, err := os.ReadFile(*configPath)
+	if err != nil {
+		fmt.Println("Error reading config:", err)
+		return 1
+	}
+	var config codeownersConfig
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		fmt.Printf("Error reading config: %s: %v\n", *configPath, err)
+		return 1
+	}
+	if config.Path == "" {
+		config.Path = codeownersPaths[0]
+	}
+	tmpl, err := template.New("codeowners").Parse(config.Template)
+	if err != nil {
+		fmt.Println("Invalid template:", err)
+		return 1
+	}
+
+	if !*dryRun {
+		release, ok := ws.lock("codeowners apply")
+		if !ok {
+			return 1
+		}
+		defer release()
+	}
+
+	policy, err := ws.commitPolicy()
+	if err != nil {
+		fmt.Println("Error reading manifest:", err)
+		return 1
+	}
+
+	results, exitCode := forEachRepo(ws.repos, ws.parallel, func(repo string) (string, int) {
+		info := newRepoInfo(ws.root, repo)
+
+		var out strings.Builder
+		if err := tmpl.Execute(&out, info); err != nil {
+			return fmt.Sprintf("\033[1m❌ %s:\033[0m %v", info.RelPath, err), 1
+		}
+		for _, o := range config.Overrides {
+			if matchGlob(o.Repos, info.RelPath) {
+				out.WriteString("\n" + strings.TrimRight(o.Rules, "\n") + "\n")
+			}
+		}
+		content := out.String()
+		if problems := lintCodeowners(content); len(problems) > 0 {
+			return fmt.Sprintf("\033[1m❌ %s:\033[0m invalid CODEOWNERS\n  %s", info.RelPath, strings.Join(problems, "\n  ")), 1
+		}
+
+		path := config.Path
+		var current string
+		for _, p := range codeownersPaths {
+			existing, ok, err := getFileAt(repo, "HEAD", p)
+			if err != nil {
+				return fmt.Sprintf("\033[1m❌ %s:\033[0m %v", info.RelPath, err), 1
+			} else if ok {
+				path, current = p, existing
+				break
+			}
+		}
+		if content == current {
+			return "", 0
+		}
+		// an earlier apply may have already committed it
+		if committed, ok, _ := getFileAt(repo, *branch, path); ok && committed == content && isAncestor(repo, "HEAD", *branch) {
+			return "", 0
+		}
+
+		if *dryRun {
+			return fmt.Sprintf("\033[1m%s:\033[0m would commit %s on %s", info.RelPath, path, *branch), 0
+		}
+		if current, err := getCurrentBranch(repo); err == nil && current == *branch {
+			return fmt.Sprintf("\033[1m❌ %s:\033[0m %s is checked out", info.RelPath, *branch), 1
+		}
+		if err := commitFileOnBranch(repo, *branch, path, content, *message, policy); err != nil {
+			return fmt.Sprintf("\033[1m❌ %s:\033[0m %v", info.RelPath, err), 1
+		}
+		return fmt.Sprintf("\033[1m✅️ %s:\033[0m committed %s on %s", info.RelPath, path, *branch), 0
+	})
+	printResults(results)
+	return exitCode
+}
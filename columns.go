@@ -0,0 +1,199 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// statusColumns are the columns of the status -columns can show, besides
+// the annotations of the configuration by name.
+var statusColumns = []string{"repo", "branch", "default", "dirty", "sync", "ahead", "behind", "upstream", "branches", "lastcommit", "ticket", "vcs"}
+
+// columnSeparator separates the cells of a row until the rows are aligned.
+const columnSeparator = "\x1f"
+
+// parseColumns parses a comma separated list of columns, such as
+// repo,branch,dirty.
+func parseColumns(spec string, annotations []annotationConfig) ([]string, error) {
+	var columns []string
+	for _, c := range strings.Split(spec, ",") {
+		c = strings.TrimSpace(c)
+		known := slices.Contains(statusColumns, c) || slices.ContainsFunc(annotations, func(a annotationConfig) bool { return a.Name == c })
+		if !known {
+			return nil, fmt.Errorf("unknown column `%s`, the columns are %s and the names of the status annotations", c, strings.Join(statusColumns, ", "))
+		}
+		columns = append(columns, c)
+	}
+	return columns, nil
+}
+
+// formatStatusColumns renders the columns of the status of the repository
+// as a row, its cells separated by columnSeparator for alignColumns, and
+// those with a width in the configuration cut or padded to it.
+func formatStatusColumns(path string, root string, st repoStatus, columns []string, widths map[string]int) string {
+	relPath := relativePath(root, path)
+	cells := make([]string, len(columns))
+	for i, c := range columns {
+		cells[i] = statusCell(path, relPath, st, c)
+	}
+	if a11yOutput {
+		var parts []string
+		for i, c := range columns {
+			if cell := ansiEscape.ReplaceAllString(cells[i], ""); cell != "" && c != "repo" {
+				parts = append(parts, c+" "+cell)
+			}
+		}
+		return fmt.Sprintf("repo %s: %s", relPath, strings.Join(parts, ", "))
+	}
+	for i, c := range columns {
+		if w, ok := widths[c]; ok && w > 0 {
+			cells[i] = padCell(fitCell(cells[i], w), w)
+		}
+	}
+	return strings.Join(cells, columnSeparator)
+}
+
+// statusCell renders the cell of the column for the status.
+func statusCell(path string, relPath string, st repoStatus, column string) string {
+	switch column {
+	case "repo":
+		return "\033[1m" + repoLink(path, relPath) + "\033[0m"
+	case "branch":
+		color := "\033[1;31m"
+		if st.Branch == st.DefaultBranch {
+			color = "\033[1;32m"
+		}
+		return color + hyperlink(st.TicketURL, st.Branch) + "\033[0m"
+	case "default":
+		return st.DefaultBranch
+	case "dirty":
+		if st.Dirty {
+			return "\033[31mdirty\033[0m"
+		}
+		return ""
+	case "sync":
+		switch st.Sync {
+		case BehindRemote:
+			return "behind"
+		case AheadRemote:
+			return "ahead"
+		}
+		return ""
+	case "ahead":
+		if st.Ahead > 0 {
+			return fmt.Sprintf("↑%d", st.Ahead)
+		}
+		return ""
+	case "behind":
+		if st.Behind > 0 {
+			return fmt.Sprintf("↓%d", st.Behind)
+		}
+		return ""
+	case "upstream":
+		switch st.Upstream {
+		case upstreamGone:
+			return "\033[31mgone\033[0m"
+		case upstreamNone:
+			if st.VCS == "" && st.Bridge != bridgeSVN && st.Branch != "HEAD" && !strings.HasPrefix(st.Branch, "!") {
+				return "\033[2mnone\033[0m"
+			}
+		}
+		return ""
+	case "branches":
+		return "\033[34m" + strings.Join(st.Branches, " ") + "\033[0m"
+	case "lastcommit":
+		out, err := runGit(path, "log", "-1", "--format=%ct", "HEAD")
+		if err != nil {
+			return ""
+		}
+		unix, err := strconv.ParseInt(out, 10, 64)
+		if err != nil {
+			return ""
+		}
+		return tr("%s ago", formatAge(time.Since(time.Unix(unix, 0))))
+	case "ticket":
+		return hyperlink(st.TicketURL, st.Ticket)
+	case "vcs":
+		if st.VCS == "" {
+			return "git"
+		}
+		return st.VCS
+	}
+	// the annotations of the configuration
+	return "\033[36m" + st.Annotations[column] + "\033[0m"
+}
+
+// cellWidth returns how many characters of the cell are shown.
+func cellWidth(cell string) int {
+	return utf8.RuneCountInString(ansiEscape.ReplaceAllString(cell, ""))
+}
+
+// padCell pads the cell with spaces to the width.
+func padCell(cell string, width int) string {
+	return cell + strings.Repeat(" ", max(0, width-cellWidth(cell)))
+}
+
+// fitCell cuts the cell down to the width, ending it with …, and drops its
+// colors if it has to.
+func fitCell(cell string, width int) string {
+	if cellWidth(cell) <= width {
+		return cell
+	}
+	runes := []rune(ansiEscape.ReplaceAllString(cell, ""))
+	return string(runes[:max(0, width-1)]) + "…"
+}
+
+// alignColumns pads the cells of the first line of the rows to the widest
+// of each column, separating them with two spaces, under a header of the
+// names of the columns cut down to the fixed widths. Results without cells,
+// such as skipped repositories, are left as they are.
+func alignColumns(results []string, columns []string, fixed map[string]int) []string {
+	header := make([]string, len(columns))
+	widths := make([]int, len(columns))
+	for i, c := range columns {
+		header[i] = c
+		if w, ok := fixed[c]; ok && w > 0 {
+			header[i] = fitCell(c, w)
+		}
+		widths[i] = cellWidth(header[i])
+	}
+	rows := 0
+	for _, result := range results {
+		row, _, _ := strings.Cut(result, "\n")
+		if !strings.Contains(row, columnSeparator) && len(columns) > 1 {
+			continue
+		}
+		rows++
+		for i, cell := range strings.Split(row, columnSeparator) {
+			widths[i] = max(widths[i], cellWidth(cell))
+		}
+	}
+	if rows == 0 {
+		return results
+	}
+
+	join := func(cells []string) string {
+		for j := range cells[:len(cells)-1] {
+			cells[j] = padCell(cells[j], widths[j])
+		}
+		return strings.TrimRight(strings.Join(cells, "  "), " ")
+	}
+	aligned := []string{"\033[2m" + join(header) + "\033[0m"}
+	for _, result := range results {
+		row, rest, hasRest := strings.Cut(result, "\n")
+		if !strings.Contains(row, columnSeparator) && len(columns) > 1 {
+			aligned = append(aligned, result)
+			continue
+		}
+		row = join(strings.Split(row, columnSeparator))
+		if hasRest {
+			row += "\n" + rest
+		}
+		aligned = append(aligned, row)
+	}
+	return aligned
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"crypto/rand"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// commitPolicy is how the commits gits makes itself are written, set under
+// commits in the workspace manifest, so that bulk commits meet the same
+// requirements as manual ones.
+type commitPolicy struct {
+	// Template wraps the message of each commit, where {message} is the
+	// message and the repository placeholders such as {name} are expanded
+	Template string `yaml:"template"`
+	// Trailers are added to each message, such as "Ticket: OPS-12"
+	Trailers []string `yaml:"trailers"`
+	// ChangeID adds a Gerrit Change-Id trailer unless there is one
+	ChangeID bool `yaml:"change_id"`
+	// Signoff adds a Signed-off-by trailer of the committer
+	Signoff bool `yaml:"signoff"`
+	// Sign signs the commits with -S, using SigningKey if set
+	Sign       bool   `yaml:"sign"`
+	SigningKey string `yaml:"signing_key"`
+
+	// root is the workspace root the placeholders are relative to
+	root string
+}
+
+// commitPolicy returns the commit policy of the workspace manifest, which
+// is empty without one.
+func (ws *workspace) commitPolicy() (*commitPolicy, error) {
+	m, err := findManifest(ws.root)
+	if err != nil {
+		return nil, err
+	}
+	p := &commitPolicy{}
+	if m != nil {
+		p = &m.Commits
+	}
+	p.root = ws.root
+	return p, nil
+}
+
+func (p *commitPolicy) validate() error {
+	if p.Template != "" && !strings.Contains(p.Template, "{message}") {
+		return fmt.Errorf("the commits template must contain {message}")
+	}
+	for _, t := range p.Trailers {
+		if key, _, ok := strings.Cut(t, ":"); !ok || strings.TrimSpace(key) == "" {
+			return fmt.Errorf("commit trailer `%s` is not `Key: value`", t)
+		}
+	}
+	return nil
+}
+
+// signArgs are the arguments of git commit, commit-tree or am that sign the
+// commits, if they are to be signed.
+func (p *commitPolicy) signArgs() []string {
+	if p == nil || !p.Sign {
+		return nil
+	}
+	return []string{"-S" + p.SigningKey}
+}
+
+// message applies the template and trailers to the message of a commit in
+// the repository.
+func (p *commitPolicy) message(repo string, message string) (string, error) {
+	if p == nil {
+		return message, nil
+	}
+	info := newRepoInfo(p.root, repo)
+	if p.Template != "" {
+		message = strings.ReplaceAll(info.expand([]string{p.Template})[0], "{message}", message)
+	}
+
+	args := []string{"interpret-trailers", "--if-exists", "addIfDifferent"}
+	for _, t := range info.expand(p.Trailers) {
+		args = append(args, "--trailer", t)
+	}
+	if p.ChangeID {
+		id := make([]byte, 20)
+		rand.Read(id)
+		args = append(args, "--if-exists", "doNothing", "--trailer", fmt.Sprintf("Change-Id: I%x", id), "--if-exists", "addIfDifferent")
+	}
+	if p.Signoff {
+		ident, err := runGit(repo, "var", "GIT_COMMITTER_IDENT")
+		if err != nil {
+			return "", err
+		}
+		// the identity is followed by the time and time zone
+		fields := strings.Fields(ident)
+		args = append(args, "--trailer", "Signed-off-by: "+strings.Join(fields[:max(0, len(fields)-2)], " "))
+	}
+	if len(args) == 3 {
+		return message, nil
+	}
+	cmd := exec.Command("git", append([]string{"-C", repo}, args...)...)
+	cmd.Stdin = strings.NewReader(strings.TrimRight(message, "\n") + "\n")
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git interpret-trailers: %s", errorReason(err))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// commit runs git commit in the repository with the message and the other
+// arguments, following the policy.
+func (p *commitPolicy) commit(repo string, message string, args ...string) error {
+	message, err := p.message(repo, message)
+	if err != nil {
+		return err
+	}
+	commitArgs := append([]string{"commit", "-q", "-m", message}, p.signArgs()...)
+	_, err = runGit(repo, append(commitArgs, args...)...)
+	return err
+}
+
+// amArgs are the arguments of git am applying patches following the
+// policy, as their messages are kept as they are apart from the sign-off.
+func (p *commitPolicy) amArgs() []string {
+	var args []string
+	if p != nil && p.Signoff {
+		args = append(args, "--signoff")
+	}
+	return append(args, p.signArgs()...)
+}
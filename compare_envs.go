@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+func init() {
+	registerSubcommand(&subcommand{
+		name:    "compare-envs",
+		summary: "show the commits that differ between two environment refs",
+		run:     runCompareEnvs,
+	})
+}
+
+func runCompareEnvs(ws *workspace, args []string) int {
+	fs := newSubcommandFlags("compare-envs", "")
+	refA := fs.String("ref-a", "", "the baseline environment ref, e.g. deploy/prod")
+	refB := fs.String("ref-b", "", "the environment ref to compare, e.g. deploy/staging")
+	both := fs.Bool("both", false, "also list the commits only in the baseline ref")
+	changed := fs.Bool("changed", false, "only show repositories where the refs differ")
+	fs.Parse(args)
+
+	if *refA == "" || *refB == "" {
+		fmt.Println("Both -ref-a and -ref-b are required")
+		fs.Usage()
+		return 1
+	}
+
+	results, exitCode := forEachRepo(ws.repos, ws.parallel, func(path string) (string, int) {
+		return compareEnvsRepo(path, ws.root, *refA, *refB, *both, *changed)
+	})
+
+	printResults(results)
+	return exitCode
+}
+
+func compareEnvsRepo(path string, root string, refA string, refB string, both bool, changed bool) (string, int) {
+	relPath := relativePath(root, path)
+
+	var missing []string
+	for _, ref := range []string{refA, refB} {
+		if _, err := resolveCommit(path, ref); err != nil {
+			missing = append(missing, ref)
+		}
+	}
+	if len(missing) > 0 {
+		if changed {
+			return "", 0
+		}
+		return fmt.Sprintf("\033[1m%s:\033[0m \033[33mmissing %s\033[0m", relPath, strings.Join(missing, ", ")), 0
+	}
+
+	onlyB, err := getCommitLog(path, refA+".."+refB)
+	if err != nil {
+		return fmt.Sprintf("\033[1m❌ %s:\033[0m %v", relPath, err), 1
+	}
+	onlyA, err := getCommitLog(path, refB+".."+refA)
+	if err != nil {
+		return fmt.Sprintf("\033[1m❌ %s:\033[0m %v", relPath, err), 1
+	}
+
+	if len(onlyA) == 0 && len(onlyB) == 0 {
+		if changed {
+			return "", 0
+		}
+		return fmt.Sprintf("\033[1m%s:\033[0m \033[32min sync\033[0m", relPath), 0
+	}
+
+	var result strings.Builder
+	fmt.Fprintf(&result, "\033[1m%s:\033[0m %d only in %s, %d only in %s", relPath, len(onlyB), refB, len(onlyA), refA)
+	for _, line := range onlyB {
+		fmt.Fprintf(&result, "\n  \033[32m+\033[0m %s", line)
+	}
+	if both {
+		for _, line := range onlyA {
+			fmt.Fprintf(&result, "\n  \033[31m-\033[0m %s", line)
+		}
+	}
+	return result.String(), 0
+}
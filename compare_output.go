@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// compareResults renders the output of the command in each repository as a
+// comparison: the lines most repositories print, then for each repository
+// that differs the lines it prints that most do not, prefixed with +, and
+// those it lacks, prefixed with -, so the odd one out stands out.
+// Results without output, such as skipped repositories, are kept as they are.
+func compareResults(results []string) []string {
+	type repoOutput struct {
+		marker string
+		name   string
+		lines  []string
+	}
+	var outputs []repoOutput
+	var others []string
+	counts := map[string]int{}
+	var order []string
+	for _, result := range results {
+		m := resultHeader.FindStringSubmatch(result)
+		if m == nil || !strings.HasPrefix(m[3], "\n") {
+			others = append(others, result)
+			continue
+		}
+		var lines []string
+		for _, line := range strings.Split(m[3], "\n") {
+			line = strings.TrimPrefix(line, "  ")
+			if strings.TrimSpace(line) == "" || slices.Contains(lines, line) {
+				continue
+			}
+			lines = append(lines, line)
+			if counts[line] == 0 {
+				order = append(order, line)
+			}
+			counts[line]++
+		}
+		outputs = append(outputs, repoOutput{marker: m[1], name: m[2], lines: lines})
+	}
+	if len(outputs) == 0 {
+		return others
+	}
+
+	var common []string
+	for _, line := range order {
+		if counts[line]*2 > len(outputs) {
+			common = append(common, line)
+		}
+	}
+	var compared []string
+	if len(common) > 0 {
+		var b strings.Builder
+		fmt.Fprintf(&b, "\033[1mLines most of the %d repos print:\033[0m", len(outputs))
+		for _, line := range common {
+			b.WriteString("\n  " + line)
+			if counts[line] < len(outputs) {
+				fmt.Fprintf(&b, " \033[2m(%d of %d)\033[0m", counts[line], len(outputs))
+			}
+		}
+		compared = append(compared, b.String())
+	}
+
+	var same []string
+	for _, o := range outputs {
+		var diff []string
+		for _, line := range o.lines {
+			if !slices.Contains(common, line) {
+				diff = append(diff, "\033[32m+ "+line+"\033[0m")
+			}
+		}
+		for _, line := range common {
+			if !slices.Contains(o.lines, line) {
+				diff = append(diff, "\033[31m- "+line+"\033[0m")
+			}
+		}
+		if len(diff) == 0 && o.marker == "✅️" {
+			same = append(same, o.name)
+			continue
+		}
+		if len(diff) == 0 {
+			// failed, although it printed the same
+			diff = append(diff, "the same as most")
+		}
+		compared = append(compared, fmt.Sprintf("\033[1m%s %s:\033[0m\n  %s", o.marker, o.name, strings.Join(diff, "\n  ")))
+	}
+	if len(same) > 0 {
+		compared = append(compared, fmt.Sprintf("\033[1mSame as most:\033[0m %s", strings.Join(same, ", ")))
+	}
+	return append(compared, others...)
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// config is the user configuration, read from $GITS_CONFIG or
+// <user config dir>/gits/config.yaml.
+type config struct {
+	Profiles map[string]*profile `yaml:"profiles"`
+	Serve    serveConfig         `yaml:"serve"`
+	// Network applies to the git commands run, unless the profile has its own
+	Network *networkConfig `yaml:"network"`
+	// ReferenceDir holds the reference repositories clones borrow objects from
+	ReferenceDir string `yaml:"reference_dir"`
+	// ReadOnly turns on -read-only by default, such as on shared machines
+	ReadOnly bool `yaml:"read_only"`
+	// WritePatterns are the commands -read-only refuses, replacing the defaults
+	WritePatterns []string `yaml:"write_patterns"`
+	// Pull configures gits pull
+	Pull pullConfig `yaml:"pull"`
+	// Status configures -status
+	Status statusConfig `yaml:"status"`
+	// Open configures gits open
+	Open openConfig `yaml:"open"`
+	// Mux configures gits mux
+	Mux muxConfig `yaml:"mux"`
+	// Tickets link the ticket IDs in branch names to their tracker
+	Tickets []ticketConfig `yaml:"tickets"`
+	// GitConfig are the values gits config report expects git config keys
+	// to have, such as pull.rebase: "true"
+	GitConfig map[string]string `yaml:"git_config"`
+	// PostRunHook is run at the end of each run of a command, with how it
+	// went as JSON on its stdin
+	PostRunHook []string `yaml:"post_run_hook"`
+	// DepartedAuthors are the emails or names, or globs of them, of the
+	// authors who have left, see gits blame-gaps
+	DepartedAuthors []string `yaml:"departed_authors"`
+
+	// path is the file the configuration was read from
+	path string
+}
+
+// serveConfig configures the `gits serve` daemon.
+type serveConfig struct {
+	// Listen is the address of the HTTP API, e.g. 127.0.0.1:7878
+	Listen string `yaml:"listen"`
+	// Token is the bearer token clients must send, prefer TokenEnv to keep it out of the file
+	Token string `yaml:"token"`
+	// TokenEnv is the name of an environment variable holding the bearer token
+	TokenEnv string `yaml:"token_env"`
+	// GRPCListen is the address of the gRPC interface, which is off unless set
+	GRPCListen string `yaml:"grpc_listen"`
+	// TLSCert and TLSKey are the PEM files of the certificate both APIs are served with
+	TLSCert string `yaml:"tls_cert"`
+	TLSKey  string `yaml:"tls_key"`
+	// TLSClientCA is a PEM file of the CAs client certificates must be signed by (mutual TLS)
+	TLSClientCA string      `yaml:"tls_client_ca"`
+	Jobs        []jobConfig `yaml:"jobs"`
+}
+
+func (c serveConfig) token() string {
+	if c.TokenEnv != "" {
+		return os.Getenv(c.TokenEnv)
+	}
+	return c.Token
+}
+
+// jobConfig is a job the daemon runs across the repositories on a schedule.
+type jobConfig struct {
+	Name string `yaml:"name"`
+	// Every is the interval between runs, e.g. 30m or 1d
+	Every string `yaml:"every"`
+	// Command is run in each repository
+	Command []string `yaml:"command"`
+	// Status takes a snapshot of each repository's status instead of running a command
+	Status bool `yaml:"status"`
+}
+
+// profile bundles the settings for one multi-repository world, selected with
+// -profile or $GITS_PROFILE.
+type profile struct {
+	// Roots are the directories searched for repositories
+	Roots []string `yaml:"roots"`
+	// Exclude are glob patterns of repository paths, relative to the root, to skip
+	Exclude []string `yaml:"exclude"`
+	// Flags are default command line options, given before those on the command line
+	Flags []string `yaml:"flags"`
+	// Forges are the credentials for each forge host, e.g. github.com
+	Forges map[string]forgeCredentials `yaml:"forges"`
+	// Network replaces the top level network configuration
+	Network *networkConfig `yaml:"network"`
+	// Artifacts are the names of the build output directories gits clean-artifacts removes
+	Artifacts []string `yaml:"artifacts"`
+}
+
+type forgeCredentials struct {
+	// Kind is github or gitlab (default: gitlab for hosts with gitlab in the name, otherwise github)
+	Kind string `yaml:"kind"`
+	// API is the base URL of the API, for self-hosted forges with a non-standard one,
+	// or of the web interface of a Gerrit host
+	API  string `yaml:"api"`
+	User string `yaml:"user"`
+	// Token is the API token, prefer TokenEnv to keep it out of the file
+	Token string `yaml:"token"`
+	// TokenEnv is the name of an environment variable holding the API token
+	TokenEnv string `yaml:"token_env"`
+}
+
+// token returns the API token for the forge.
+func (c forgeCredentials) token() string {
+	if c.TokenEnv != "" {
+		return os.Getenv(c.TokenEnv)
+	}
+	return c.Token
+}
+
+func configPath() (string, error) {
+	if p := os.Getenv("GITS_CONFIG"); p != "" {
+		return p, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "gits", "config.yaml"), nil
+}
+
+// loadConfig reads the user configuration. A missing file is an empty configuration.
+func loadConfig() (*config, error) {
+	p, err := configPath()
+	if err != nil {
+		return &config{}, nil
+	}
+
+	data, err := os.ReadFile(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return &config{path: p}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	cfg := &config{path: p}
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("%s: %v", p, err)
+	}
+	if err := cfg.Status.validate(); err != nil {
+		return nil, fmt.Errorf("%s: %v", p, err)
+	}
+	if err := cfg.compileTickets(); err != nil {
+		return nil, fmt.Errorf("%s: %v", p, err)
+	}
+
+	if cfg.ReferenceDir != "" {
+		cfg.ReferenceDir = expandPath(filepath.Dir(p), cfg.ReferenceDir)
+	}
+	for _, prof := range cfg.Profiles {
+		if prof == nil {
+			continue
+		}
+		for i, root := range prof.Roots {
+			prof.Roots[i] = expandPath(filepath.Dir(p), root)
+		}
+	}
+	return cfg, nil
+}
+
+// profile returns the named profile, or nil if name is empty.
+func (c *config) profile(name string) (*profile, error) {
+	if name == "" {
+		return nil, nil
+	}
+	prof, ok := c.Profiles[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown profile `%s` in %s", name, c.path)
+	}
+	if prof == nil {
+		prof = &profile{}
+	}
+	return prof, nil
+}
+
+// forgeCredentials returns the credentials configured for the forge host.
+func (p *profile) forgeCredentials(host string) (forgeCredentials, bool) {
+	if p == nil {
+		return forgeCredentials{}, false
+	}
+	c, ok := p.Forges[host]
+	return c, ok
+}
+
+// network returns the network configuration of the profile, or the top level one.
+func (c *config) network(prof *profile) *networkConfig {
+	if prof != nil && prof.Network != nil {
+		return prof.Network
+	}
+	return c.Network
+}
+
+// expandPath expands a leading ~ and resolves relative paths against base.
+func expandPath(base string, path string) string {
+	if rest, ok := strings.CutPrefix(path, "~"); ok && (rest == "" || rest[0] == '/' || rest[0] == filepath.Separator) {
+		if home, err := os.UserHomeDir(); err == nil {
+			path = filepath.Join(home, rest)
+		}
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(base, path)
+	}
+	return filepath.Clean(path)
+}
+
+// selectedProfile finds the -profile option among the leading options of
+// args, falling back to $GITS_PROFILE. It is needed before the options are
+// parsed as the profile supplies default options.
+func selectedProfile(fs *flag.FlagSet, args []string) string {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" || !strings.HasPrefix(arg, "-") {
+			break
+		}
+		name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if name == "profile" {
+			if hasValue {
+				return value
+			}
+			if i+1 < len(args) {
+				return args[i+1]
+			}
+			break
+		}
+		if f := fs.Lookup(name); f != nil && !hasValue {
+			if b, ok := f.Value.(interface{ IsBoolFlag() bool }); !ok || !b.IsBoolFlag() {
+				// skip the option's value
+				i++
+			}
+		}
+	}
+	return os.Getenv("GITS_PROFILE")
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+func init() {
+	registerSubcommand(&subcommand{
+		name:    "config",
+		summary: "report git config values across the repositories, see `gits config report`",
+		run:     runConfig,
+	})
+}
+
+func runConfig(ws *workspace, args []string) int {
+	if len(args) > 0 && args[0] == "report" {
+		return runConfigReport(ws, args[1:])
+	}
+	fmt.Println("Usage: gits [options] config report [report options] [key...]")
+	return 1
+}
+
+func runConfigReport(ws *workspace, args []string) int {
+	fs := newSubcommandFlags("config report", " [key...]")
+	expect := map[string]string{}
+	for key, value := range ws.config.GitConfig {
+		expect[key] = value
+	}
+	var expected []string
+	fs.Var((*stringList)(&expected), "expect", "KEY=VALUE the key is expected to have, in addition to git_config of the configuration (may be repeated)")
+	onlyDrift := fs.Bool("drift", false, "only show the repositories with a value that differs from the expected one")
+	fs.Parse(args)
+
+	for _, e := range expected {
+		key, value, ok := strings.Cut(e, "=")
+		if !ok || key == "" {
+			fmt.Printf("Invalid -expect `%s`: must be KEY=VALUE\n", e)
+			return 1
+		}
+		expect[key] = value
+	}
+	keys := fs.Args()
+	if len(keys) == 0 {
+		keys = sortedKeys(expect)
+	}
+	if len(keys) == 0 {
+		fmt.Println("No keys to report, give them as arguments or set git_config in the configuration")
+		fs.Usage()
+		return 1
+	}
+
+	values := make([][]configValue, len(ws.repos))
+	failures, exitCode := forEachRepo(ws.repos, ws.parallel, func(path string) (string, int) {
+		row := make([]configValue, len(keys))
+		for i, key := range keys {
+			// the effective value, which may come from the global or an included file
+			value, err := getConfigValue(path, key)
+			if err != nil {
+				return fmt.Sprintf("\033[1m❌ %s:\033[0m %s: %v", relativePath(ws.root, path), key, err), 1
+			}
+			row[i] = configValue{value: value, set: value != ""}
+		}
+		values[slices.Index(ws.repos, path)] = row
+		return "", 0
+	})
+
+	widths := make([]int, len(keys)+1)
+	widths[0] = len("repository")
+	for i, key := range keys {
+		widths[i+1] = max(len(key), len(expect[key]))
+	}
+	drifted := 0
+	var lines []string
+	for i, path := range ws.repos {
+		if values[i] == nil {
+			continue
+		}
+		drift := false
+		for j, key := range keys {
+			if want, ok := expect[key]; ok && (!values[i][j].set || values[i][j].value != want) {
+				drift = true
+			}
+			widths[j+1] = max(widths[j+1], len(values[i][j].String()))
+		}
+		if drift {
+			drifted++
+		} else if *onlyDrift {
+			continue
+		}
+		widths[0] = max(widths[0], len(relativePath(ws.root, path)))
+	}
+	for i, path := range ws.repos {
+		if values[i] == nil {
+			continue
+		}
+		line := fmt.Sprintf("%-*s", widths[0], relativePath(ws.root, path))
+		drift := false
+		for j, key := range keys {
+			v := values[i][j]
+			width := widths[j+1]
+			if j == len(keys)-1 {
+				// no padding at the end of the line
+				width = 0
+			}
+			cell := fmt.Sprintf("%-*s", width, v)
+			want, ok := expect[key]
+			switch {
+			case ok && v.set && v.value == want:
+				cell = "\033[32m" + cell + "\033[0m"
+			case ok:
+				cell = "\033[31m" + cell + "\033[0m"
+				drift = true
+			case !v.set:
+				cell = "\033[2m" + cell + "\033[0m"
+			}
+			line += "  " + cell
+		}
+		if drift || !*onlyDrift {
+			lines = append(lines, line)
+		}
+	}
+
+	header := fmt.Sprintf("%-*s", widths[0], "repository")
+	for i, key := range keys {
+		header += fmt.Sprintf("  %-*s", widths[i+1], key)
+	}
+	fmt.Println(plain("\033[1m" + strings.TrimRight(header, " ") + "\033[0m"))
+	if len(expect) > 0 {
+		line := fmt.Sprintf("%-*s", widths[0], "expected")
+		for i, key := range keys {
+			line += fmt.Sprintf("  %-*s", widths[i+1], expect[key])
+		}
+		fmt.Println(plain("\033[2m" + strings.TrimRight(line, " ") + "\033[0m"))
+	}
+	for _, line := range lines {
+		fmt.Println(plain(line))
+	}
+
+	printResults(failures)
+	if drifted > 0 {
+		fmt.Println(tr("%d repos differ from the expected values", drifted))
+		return 1
+	}
+	return exitCode
+}
+
+// configValue is the value of a git config key in a repository, which is
+// unset if it is empty.
+type configValue struct {
+	value string
+	set   bool
+}
+
+func (v configValue) String() string {
+	if !v.set {
+		return "unset"
+	}
+	return v.value
+}
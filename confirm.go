@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// quoteCommand renders the command as it would be typed in a shell.
+func quoteCommand(command []string) string {
+	quoted := make([]string, len(command))
+	for i, arg := range command {
+		quoted[i] = shellQuote(arg)
+	}
+	return strings.Join(quoted, " ")
+}
+
+// printDryRun lists the repositories the command would run in, with the
+// command as it would run in each once its placeholders are expanded.
+func printDryRun(root string, command []string, repos []string) {
+	fmt.Printf("Would run in %d repositories:\n", len(repos))
+	for _, path := range repos {
+		fmt.Println(plain(fmt.Sprintf("\033[1m%s:\033[0m %s", relativePath(root, path), quoteCommand(newRepoInfo(root, path).expand(command)))))
+	}
+}
+
+// confirmRepos asks whether to run the command in all the repositories, in
+// each of them in turn or in none, and returns those to run it in.
+func confirmRepos(root string, command []string, repos []string) []string {
+	in := bufio.NewReader(os.Stdin)
+	ask := func(question string) string {
+		fmt.Print(question)
+		answer, err := in.ReadString('\n')
+		if err != nil && answer == "" {
+			// no more answers, such as at the end of piped input
+			fmt.Println()
+			return "q"
+		}
+		return strings.ToLower(strings.TrimSpace(answer))
+	}
+
+	fmt.Println(plain(fmt.Sprintf("\033[1m%s\033[0m would run in %d repositories:", quoteCommand(command), len(repos))))
+	for _, path := range repos {
+		fmt.Printf("  %s\n", relativePath(root, path))
+	}
+	switch ask("Run it in [a]ll of them, [p]ick each one, or [N]one? ") {
+	case "a", "all":
+		return repos
+	case "p", "pick":
+	default:
+		return nil
+	}
+
+	var picked []string
+	for i, path := range repos {
+		switch ask(fmt.Sprintf("Run in %s? [y]es, [n]o, [a]ll the rest, [q]uit ", relativePath(root, path))) {
+		case "y", "yes":
+			picked = append(picked, path)
+		case "a", "all":
+			return append(picked, repos[i:]...)
+		case "q", "quit":
+			return nil
+		}
+	}
+	return picked
+}
+
+// shellQuote quotes s for a POSIX shell.
+func shellQuote(s string) string {
+	if s != "" && strings.Trim(s, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_./=:@,+") == "" {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
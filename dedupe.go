@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// resultHeader matches the emoji and repository that lead a result, and
+// what follows them: the output of the command or the reason it was skipped.
+var resultHeader = regexp.MustCompile(`(?s)^\033\[1m(\S+) (.+?):\033\[0m(.*)$`)
+
+// dedupeResults merges the results of the repositories where the command
+// had the same outcome and printed the same output into one, headed by the
+// number of repositories and their paths, in the order of the first of each.
+func dedupeResults(results []string) []string {
+	type group struct {
+		marker string
+		body   string
+		repos  []string
+	}
+	var groups []*group
+	byOutput := map[string]*group{}
+	var unmatched []string
+	for _, result := range results {
+		m := resultHeader.FindStringSubmatch(result)
+		if m == nil {
+			unmatched = append(unmatched, result)
+			continue
+		}
+		key := m[1] + "\x00" + m[3]
+		g, ok := byOutput[key]
+		if !ok {
+			g = &group{marker: m[1], body: m[3]}
+			byOutput[key] = g
+			groups = append(groups, g)
+		}
+		g.repos = append(g.repos, m[2])
+	}
+
+	deduped := make([]string, 0, len(groups)+len(unmatched))
+	for _, g := range groups {
+		name := g.repos[0]
+		if len(g.repos) > 1 {
+			name = fmt.Sprintf("%d repos (%s)", len(g.repos), strings.Join(g.repos, ", "))
+		}
+		deduped = append(deduped, fmt.Sprintf("\033[1m%s %s:\033[0m%s", g.marker, name, g.body))
+	}
+	return append(deduped, unmatched...)
+}
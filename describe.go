@@ -0,0 +1,186 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+func init() {
+	registerSubcommand(&subcommand{
+		name:    "describe",
+		summary: "catalog the repositories with their README description, language, latest tag and remote",
+		run:     runDescribe,
+	})
+}
+
+// repoCatalog is the JSON output of `gits describe -json`.
+type repoCatalog struct {
+	SchemaVersion int               `json:"schema_version"`
+	Repos         []repoDescription `json:"repos"`
+}
+
+type repoDescription struct {
+	Path string `json:"path"`
+	// Title is the first heading of the README
+	Title string `json:"title,omitempty"`
+	// Description is the first paragraph of the README
+	Description string `json:"description,omitempty"`
+	// Language is the language of most of the tracked source files
+	Language  string `json:"language,omitempty"`
+	LatestTag string `json:"latest_tag,omitempty"`
+	Remote    string `json:"remote,omitempty"`
+}
+
+// languages maps source file extensions to their language.
+var languages = map[string]string{
+	".go": "Go", ".rs": "Rust", ".java": "Java", ".kt": "Kotlin", ".scala": "Scala",
+	".py": "Python", ".rb": "Ruby", ".php": "PHP", ".js": "JavaScript", ".jsx": "JavaScript",
+	".mjs": "JavaScript", ".ts": "TypeScript", ".tsx": "TypeScript", ".c": "C", ".h": "C",
+	".cc": "C++", ".cpp": "C++", ".hpp": "C++", ".cs": "C#", ".swift": "Swift", ".m": "Objective-C",
+	".ex": "Elixir", ".exs": "Elixir", ".erl": "Erlang", ".hs": "Haskell", ".clj": "Clojure",
+	".lua": "Lua", ".pl": "Perl", ".sh": "Shell", ".tf": "HCL", ".dart": "Dart", ".groovy": "Groovy",
+	".vue": "Vue", ".svelte": "Svelte", ".zig": "Zig", ".nix": "Nix",
+}
+
+// readmeNames are the README files looked for, in order of preference.
+var readmeNames = []string{"README.md", "README.adoc", "README.rst", "README.txt", "README"}
+
+// readmeNoise are lines that are not prose, such as badges and HTML.
+var readmeNoise = regexp.MustCompile(`^(\[!\[|!\[|<|image:|\.\. |:[\w-]+:|\[\[|----|\|)`)
+
+func runDescribe(ws *workspace, args []string) int {
+	fs := newSubcommandFlags("describe", "")
+	asJSON := fs.Bool("json", false, "print the catalog as JSON")
+	fs.Parse(args)
+
+	descriptions := make([]repoDescription, len(ws.repos))
+	results, exitCode := forEachRepo(ws.repos, ws.parallel, func(repo string) (string, int) {
+		d := repoDescription{Path: relativePath(ws.root, repo)}
+		d.Title, d.Description = readmeSummary(repo)
+		d.Language = detectLanguage(repo)
+		d.LatestTag, _ = getLatestTag(repo)
+		d.Remote, _ = getConfigValue(repo, "remote.origin.url")
+		descriptions[slices.Index(ws.repos, repo)] = d
+		return "", 0
+	})
+	printResults(results)
+
+	if *asJSON {
+		data, _ := json.MarshalIndent(repoCatalog{SchemaVersion: schemaVersion, Repos: descriptions}, "", "  ")
+		fmt.Println(string(data))
+		return exitCode
+	}
+
+	width := longestRelPath(ws.root, ws.repos)
+	for _, d := range descriptions {
+		summary := d.Title
+		if d.Description != "" {
+			if summary != "" {
+				summary += " — "
+			}
+			summary += d.Description
+		}
+		if len([]rune(summary)) > 100 {
+			summary = string([]rune(summary)[:99]) + "…"
+		}
+		if a11yOutput {
+			// the empty columns would be read as pauses
+			fields := slices.DeleteFunc([]string{d.Path, d.Language, d.LatestTag, summary, d.Remote}, func(f string) bool { return f == "" })
+			fmt.Println(plain(strings.Join(fields, ", ")))
+			continue
+		}
+		fmt.Println(plain(fmt.Sprintf("\033[1m%-*s\033[0m %-10s %-10s %s", width, d.Path, d.Language, d.LatestTag, summary)))
+		if d.Remote != "" {
+			fmt.Println(plain(fmt.Sprintf("%-*s \033[2m%s\033[0m", width, "", d.Remote)))
+		}
+	}
+	return exitCode
+}
+
+// readmeSummary returns the first heading and the first paragraph of prose
+// of the README of the repository.
+func readmeSummary(repo string) (string, string) {
+	var name string
+	entries, _ := os.ReadDir(repo)
+	for _, candidate := range readmeNames {
+		for _, e := range entries {
+			if strings.EqualFold(e.Name(), candidate) && !e.IsDir() {
+				name = e.Name()
+				break
+			}
+		}
+		if name != "" {
+			break
+		}
+	}
+	if name == "" {
+		return "", ""
+	}
+	data, err := os.ReadFile(filepath.Join(repo, name))
+	if err != nil {
+		return "", ""
+	}
+
+	var title string
+	var paragraph []string
+	lines := strings.Split(string(data), "\n")
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		next := ""
+		if i+1 < len(lines) {
+			next = strings.TrimSpace(lines[i+1])
+		}
+		switch {
+		case strings.HasPrefix(line, "#") || strings.HasPrefix(line, "= ") || strings.HasPrefix(line, "=="):
+			// Markdown and AsciiDoc headings
+			if len(paragraph) > 0 {
+				return title, strings.Join(paragraph, " ")
+			}
+			if title == "" {
+				title = strings.TrimSpace(strings.TrimLeft(line, "#= "))
+			}
+		case next != "" && strings.Trim(next, "=-~") == "" && len(next) >= len(line) && line != "":
+			// reStructuredText and setext headings are underlined
+			if len(paragraph) > 0 {
+				return title, strings.Join(paragraph, " ")
+			}
+			if title == "" {
+				title = line
+			}
+		case line == "" || strings.Trim(line, "=-~") == "":
+			if len(paragraph) > 0 {
+				return title, strings.Join(paragraph, " ")
+			}
+		case readmeNoise.MatchString(line):
+		default:
+			paragraph = append(paragraph, line)
+		}
+	}
+	return title, strings.Join(paragraph, " ")
+}
+
+// detectLanguage returns the language of most of the tracked source files.
+func detectLanguage(repo string) string {
+	files, err := getTrackedFiles(repo)
+	if err != nil {
+		return ""
+	}
+	counts := make(map[string]int)
+	for _, f := range files {
+		if lang, ok := languages[strings.ToLower(filepath.Ext(f))]; ok {
+			counts[lang]++
+		}
+	}
+	best := ""
+	for lang, n := range counts {
+		if n > counts[best] || (n == counts[best] && lang < best) {
+			best = lang
+		}
+	}
+	return best
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// hermeticEnv are the only environment variables passed through in hermetic mode.
+var hermeticEnv = []string{"PATH", "HOME", "USER", "LOGNAME", "TMPDIR", "TERM", "LANG", "LC_ALL", "TZ", "SSH_AUTH_SOCK"}
+
+// environment controls the environment variables passed to the commands that are run.
+type environment struct {
+	// clear starts from an empty environment rather than inheriting ours
+	clear bool
+	// hermetic starts from only the hermeticEnv variables and those in pass
+	hermetic bool
+	pass     []string
+	// set are KEY=VALUE pairs that override everything else
+	set []string
+	// network are the variables applying the network configuration, which
+	// are kept in every mode
+	network []string
+}
+
+func (e *environment) validate() error {
+	for _, kv := range e.set {
+		if k, _, ok := strings.Cut(kv, "="); !ok || k == "" {
+			return fmt.Errorf("`%s` is not of the form KEY=VALUE", kv)
+		}
+	}
+	return nil
+}
+
+// build returns the environment for a command, adding the repository
+// specific variables before any explicitly set ones.
+func (e *environment) build(repoEnv []string) []string {
+	var env []string
+	switch {
+	case e.clear:
+	case e.hermetic:
+		for _, name := range append(hermeticEnv, e.pass...) {
+			if value, ok := os.LookupEnv(name); ok {
+				env = append(env, name+"="+value)
+			}
+		}
+	default:
+		env = os.Environ()
+	}
+	env = append(env, e.network...)
+	env = append(env, repoEnv...)
+	return append(env, e.set...)
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/template"
+)
+
+func init() {
+	registerSubcommand(&subcommand{
+		name:    "envfile",
+		summary: "render an environment file such as .envrc in each repository from a template, see `gits envfile apply` and `gits envfile audit`",
+		run:     runEnvfile,
+	})
+}
+
+func runEnvfile(ws *workspace, args []string) int {
+	if len(args) > 0 {
+		switch args[0] {
+		case "apply":
+			return runEnvfileCommand(ws, "apply", args[1:])
+		case "audit":
+			return runEnvfileCommand(ws, "audit", args[1:])
+		}
+	}
+	fmt.Println("Usage: gits [options] envfile apply|audit -template <file> [envfile options]")
+	return 1
+}
+
+// envfileData is what environment file templates are rendered with: the
+// fields of the repository as in -format templates, and more about it.
+type envfileData struct {
+	repoInfo
+	Branch        string
+	DefaultBranch string
+	// Remote is the URL of origin
+	Remote string
+	// Groups are the workspace manifest groups the repository is in
+	Groups []string
+}
+
+// envfileFuncs are the functions available to environment file templates.
+var envfileFuncs = template.FuncMap{
+	"env":   os.Getenv,
+	"upper": strings.ToUpper,
+	"join":  strings.Join,
+}
+
+func runEnvfileCommand(ws *workspace, mode string, args []string) int {
+	fs := newSubcommandFlags("envfile "+mode, "")
+	templatePath := fs.String("template", "", "Go template of the environment file, such as .envrc.tmpl")
+	output := fs.String("output", "", "the path of the environment file in each repository (default: the name of the template without .tmpl)")
+	allow := fs.Bool("allow", false, "with apply, run direnv allow for the files written")
+	fs.Parse(args)
+
+	if *templatePath == "" {
+		fmt.Println("-template is required")
+		fs.Usage()
+		return 1
+	}
+	text, err := os.ReadFile(*templatePath)
+	if err != nil {
+		fmt.Println("Error reading template:", err)
+		return 1
+	}
+	tmpl, err := template.New(filepath.Base(*templatePath)).Funcs(envfileFuncs).Option("missingkey=error").Parse(string(text))
+	if err != nil {
+		fmt.Println("Invalid template:", err)
+		return 1
+	}
+	if *output == "" {
+		*output = strings.TrimSuffix(filepath.Base(*templatePath), ".tmpl")
+	}
+	if filepath.IsAbs(*output) || strings.HasPrefix(filepath.Clean(*output), "..") {
+		fmt.Println("Invalid -output: it must be a path within the repositories")
+		return 1
+	}
+
+	if mode == "apply" {
+		release, ok := ws.lock("envfile apply")
+		if !ok {
+			return 1
+		}
+		defer release()
+	}
+	m, err := findManifest(ws.root)
+	if err != nil {
+		fmt.Println("Error reading manifest:", err)
+		return 1
+	}
+
+	results, exitCode := forEachRepo(ws.repos, ws.parallel, func(repo string) (string, int) {
+		relPath := relativePath(ws.root, repo)
+		data := envfileData{repoInfo: newRepoInfo(ws.root, repo)}
+		data.Branch, _ = getCurrentBranch(repo)
+		data.DefaultBranch, _ = getDefaultBranch(repo)
+		data.Remote, _ = getConfigValue(repo, "remote.origin.url")
+		if m != nil {
+			data.Groups = m.groupsOf(repo)
+		}
+		var rendered strings.Builder
+		if err := tmpl.Execute(&rendered, data); err != nil {
+			return fmt.Sprintf("\033[1m❌ %s:\033[0m %v", relPath, err), 1
+		}
+		want := rendered.String()
+
+		file := filepath.Join(repo, *output)
+		current, err := os.ReadFile(file)
+		exists := err == nil
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Sprintf("\033[1m❌ %s:\033[0m %v", relPath, err), 1
+		}
+		if exists && string(current) == want {
+			return "", 0
+		}
+
+		if mode == "audit" {
+			if !exists {
+				return fmt.Sprintf("\033[1m⚠️ %s:\033[0m %s is missing", relPath, *output), 1
+			}
+			return fmt.Sprintf("\033[1m⚠️ %s:\033[0m %s differs from the template\n  %s", relPath, *output, strings.Join(envfileDrift(string(current), want), "\n  ")), 1
+		}
+
+		if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+			return fmt.Sprintf("\033[1m❌ %s:\033[0m %v", relPath, err), 1
+		}
+		if err := os.WriteFile(file, []byte(want), 0o644); err != nil {
+			return fmt.Sprintf("\033[1m❌ %s:\033[0m %v", relPath, err), 1
+		}
+		result := "created " + *output
+		if exists {
+			result = "updated " + *output
+		}
+		if *allow {
+			cmd := exec.Command("direnv", "allow", file)
+			cmd.Dir = repo
+			if out, err := cmd.CombinedOutput(); err != nil {
+				return fmt.Sprintf("\033[1m❌ %s:\033[0m %s, direnv allow: %v %s", relPath, result, err, strings.TrimSpace(string(out))), 1
+			}
+			result += " and allowed it"
+		}
+		// environment files often hold secrets that must not be committed
+		if _, err := runGit(repo, "check-ignore", "-q", *output); err != nil {
+			result += ", \033[33mwhich git does not ignore\033[0m"
+		}
+		return fmt.Sprintf("\033[1m✅️ %s:\033[0m %s", relPath, result), 0
+	})
+	printResults(results)
+	return exitCode
+}
+
+// envfileDrift returns the lines only in the current file, prefixed with -,
+// and those only in the rendered template, prefixed with +.
+func envfileDrift(current string, want string) []string {
+	currentLines, wantLines := strings.Split(current, "\n"), strings.Split(want, "\n")
+	var drift []string
+	for _, line := range currentLines {
+		if line != "" && !slices.Contains(wantLines, line) {
+			drift = append(drift, "\033[31m-"+line+"\033[0m")
+		}
+	}
+	for _, line := range wantLines {
+		if line != "" && !slices.Contains(currentLines, line) {
+			drift = append(drift, "\033[32m+"+line+"\033[0m")
+		}
+	}
+	if len(drift) == 0 {
+		// only the order or blank lines differ
+		drift = append(drift, "the lines are in a different order")
+	}
+	return drift
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// feature is an optional part of gits that builds can leave out with a
+// build tag, for a smaller binary with fewer dependencies. go install
+// builds them all, and the Makefile builds the slim gits without them by
+// default and the full one with make full.
+type feature struct {
+	name string
+	// tag is the build tag that leaves it out
+	tag         string
+	description string
+	// subcommands are the subcommands it provides
+	subcommands []string
+}
+
+var optionalFeatures = []feature{
+	{"forge", "noforge", "the GitHub, GitLab and Gerrit APIs, for pull requests, reviews, triage and repository settings", []string{"audit settings", "gerrit", "land", "meta", "missing", "pr", "reviews", "triage"}},
+	{"serve", "noserve", "the gits serve daemon with its HTTP API, scheduled jobs and remote execution", []string{"jobs", "remote-exec", "serve"}},
+	{"grpc", "nogrpc", "the gRPC interface of gits serve", nil},
+	{"tui", "notui", "the interactive -tui mode", nil},
+}
+
+// builtFeatures are the optional features in this build, added by the init
+// of each.
+var builtFeatures = map[string]bool{}
+
+// featureNames returns the names of the optional features in this build.
+func featureNames() []string {
+	names := []string{}
+	for _, f := range optionalFeatures {
+		if builtFeatures[f.name] {
+			names = append(names, f.name)
+		}
+	}
+	return names
+}
+
+// errFeatureMissing is the error of using a feature that this build does
+// not have.
+func errFeatureMissing(name string) error {
+	for _, f := range optionalFeatures {
+		if f.name == name {
+			return fmt.Errorf("this gits is built without %s, the %s build tag leaves it out; build it with make full or go install", name, f.tag)
+		}
+	}
+	return fmt.Errorf("this gits is built without %s", name)
+}
+
+// registerMissingFeature registers the subcommands of a feature this build
+// does not have, so that they print why they are missing rather than being
+// run as commands in each repository.
+func registerMissingFeature(name string) {
+	for _, f := range optionalFeatures {
+		if f.name != name {
+			continue
+		}
+		for _, s := range f.subcommands {
+			sub := &subcommand{
+				name:    s,
+				summary: "not in this build, see `gits version -features`",
+				run: func(ws *workspace, args []string) int {
+					fmt.Println("Error:", errFeatureMissing(name))
+					return 1
+				},
+				standalone: true,
+			}
+			if audit, ok := strings.CutPrefix(s, "audit "); ok {
+				sub.name = audit
+				registerAudit(sub)
+			} else {
+				registerSubcommand(sub)
+			}
+		}
+	}
+}
+
+// printFeatures prints the optional features and whether this build has
+// them.
+func printFeatures() {
+	fmt.Println("Optional features:")
+	for _, f := range optionalFeatures {
+		mark := "✅️"
+		if !builtFeatures[f.name] {
+			mark = "❌"
+		}
+		fmt.Printf("  %s %-6s %s (build tag %s)\n", mark, f.name, f.description, f.tag)
+	}
+}
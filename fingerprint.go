@@ -0,0 +1,218 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const fingerprintsFile = "fingerprints.json"
+
+// fingerprints records, for each command, the state of each repository after
+// the command last succeeded in it.
+type fingerprints struct {
+	// Commands maps a command key to the fingerprint of each repository path
+	Commands map[string]map[string]string `json:"commands"`
+}
+
+// commandKey identifies a command line in the fingerprints.
+func commandKey(command []string) string {
+	sum := sha256.Sum256([]byte(strings.Join(command, "\x00")))
+	return hex.EncodeToString(sum[:])
+}
+
+// worktreeFingerprint hashes the HEAD commit, the changes to tracked files and
+// the size and modification time of untracked files.
+func worktreeFingerprint(path string) (string, error) {
+	return fingerprintWorktree(path, false)
+}
+
+// contentFingerprint hashes the HEAD commit, the changes to tracked files and
+// the content of untracked files, so it is the same wherever the worktree
+// has the same content.
+func contentFingerprint(path string) (string, error) {
+	return fingerprintWorktree(path, true)
+}
+
+func fingerprintWorktree(path string, untrackedContent bool) (string, error) {
+	h := sha256.New()
+
+	head, err := resolveCommit(path, "HEAD")
+	if err != nil {
+		return "", err
+	}
+	fmt.Fprintln(h, head)
+
+	cmd := exec.Command("git", "-C", path, "diff", "HEAD", "--binary")
+	cmd.Stdout = h
+	if err := cmd.Run(); err != nil {
+		return "", err
+	}
+
+	cmd = exec.Command("git", "-C", path, "ls-files", "--others", "--exclude-standard", "-z")
+	out, err := cmd.Output()
+	if err != nil {
+		return "", err
+	}
+	for _, f := range strings.Split(string(out), "\x00") {
+		if f == "" {
+			continue
+		}
+		info, err := os.Lstat(filepath.Join(path, f))
+		switch {
+		case err != nil:
+		case !untrackedContent:
+			fmt.Fprintf(h, "%s %d %d\n", f, info.Size(), info.ModTime().UnixNano())
+		case info.Mode()&os.ModeSymlink != 0:
+			target, _ := os.Readlink(filepath.Join(path, f))
+			fmt.Fprintf(h, "%s -> %s\n", f, target)
+		default:
+			data, err := os.ReadFile(filepath.Join(path, f))
+			if err != nil {
+				return "", err
+			}
+			fmt.Fprintf(h, "%s %x\n", f, sha256.Sum256(data))
+		}
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// skipUnchanged wraps action so that repositories whose fingerprint matches
+// the one recorded after the command last succeeded are skipped. The returned
+// function records the new fingerprints once all the actions have run.
+func skipUnchanged(root string, command []string, action func(path string) (string, int)) (func(path string) (string, int), func() error) {
+	var state fingerprints
+	loadErr := readState(fingerprintsFile, &state)
+	if state.Commands == nil {
+		state.Commands = make(map[string]map[string]string)
+	}
+	key := commandKey(command)
+	previous := state.Commands[key]
+
+	var mu sync.Mutex
+	updated := make(map[string]string)
+
+	wrapped := func(path string) (string, int) {
+		if fp, err := worktreeFingerprint(path); err == nil && previous[path] == fp {
+			if recordOutput {
+				return skippedRecord(root, path, "unchanged since the last successful run"), 0
+			}
+			return fmt.Sprintf("\033[1m⏩ %s:\033[0m unchanged since the last successful run", relativePath(root, path)), 0
+		}
+
+		result, exitCode := action(path)
+
+		fp := ""
+		if exitCode == 0 {
+			fp, _ = worktreeFingerprint(path)
+		}
+		mu.Lock()
+		updated[path] = fp
+		mu.Unlock()
+
+		return result, exitCode
+	}
+
+	save := func() error {
+		if loadErr != nil {
+			return loadErr
+		}
+		// Re-read in case another run has recorded fingerprints since
+		var latest fingerprints
+		if err := readState(fingerprintsFile, &latest); err != nil {
+			return err
+		}
+		if latest.Commands == nil {
+			latest.Commands = make(map[string]map[string]string)
+		}
+		recorded := latest.Commands[key]
+		if recorded == nil {
+			recorded = make(map[string]string)
+			latest.Commands[key] = recorded
+		}
+		for path, fp := range updated {
+			if fp == "" {
+				delete(recorded, path)
+			} else {
+				recorded[path] = fp
+			}
+		}
+		return writeState(fingerprintsFile, &latest)
+	}
+
+	return wrapped, save
+}
+
+const lastRunsFile = "last-runs.json"
+
+// lastRuns records, for each command, the state of each repository at the
+// end of the last run of the command with -since-last-run.
+type lastRuns struct {
+	// Commands maps a command key to the fingerprint of each repository path
+	Commands map[string]map[string]string `json:"commands"`
+}
+
+// changedSinceLastRun returns the repositories whose fingerprint differs from
+// the one recorded at the end of the last run of the command, which is all of
+// them the first time. The returned function records the fingerprints of the
+// repositories the command ran in.
+func changedSinceLastRun(command []string, repos []string, parallel int) ([]string, func(ran []string) error, error) {
+	var state lastRuns
+	if err := readState(lastRunsFile, &state); err != nil {
+		return nil, nil, err
+	}
+	key := commandKey(command)
+	previous := state.Commands[key]
+
+	var mu sync.Mutex
+	var changed []string
+	forEachRepoWith(repos, parallel, false, func(path string) (string, int) {
+		if fp, err := worktreeFingerprint(path); err != nil || previous[path] != fp {
+			mu.Lock()
+			changed = append(changed, path)
+			mu.Unlock()
+		}
+		return "", 0
+	})
+	sortNames(changed)
+
+	record := func(ran []string) error {
+		fps := make(map[string]string)
+		forEachRepoWith(ran, parallel, false, func(path string) (string, int) {
+			if fp, err := worktreeFingerprint(path); err == nil {
+				mu.Lock()
+				fps[path] = fp
+				mu.Unlock()
+			}
+			return "", 0
+		})
+		// Re-read in case another run has recorded fingerprints since
+		var latest lastRuns
+		if err := readState(lastRunsFile, &latest); err != nil {
+			return err
+		}
+		if latest.Commands == nil {
+			latest.Commands = make(map[string]map[string]string)
+		}
+		recorded := latest.Commands[key]
+		if recorded == nil {
+			recorded = make(map[string]string)
+			latest.Commands[key] = recorded
+		}
+		for _, path := range ran {
+			if fp, ok := fps[path]; ok {
+				recorded[path] = fp
+			} else {
+				delete(recorded, path)
+			}
+		}
+		return writeState(lastRunsFile, &latest)
+	}
+	return changed, record, nil
+}
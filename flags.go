@@ -0,0 +1,44 @@
+package main
+
+import "strings"
+
+// stringList is a flag that may be given multiple times, collecting each value.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// optionalValue is a flag that may be given alone, as -name, which sets it
+// to its default, or with a value, as -name=value.
+type optionalValue struct {
+	value string
+	// bare is the value of the flag given alone
+	bare string
+}
+
+func (v *optionalValue) String() string {
+	if v == nil {
+		return ""
+	}
+	return v.value
+}
+
+func (v *optionalValue) Set(value string) error {
+	if value == "true" {
+		value = v.bare
+	} else if value == "false" {
+		value = ""
+	}
+	v.value = value
+	return nil
+}
+
+func (v *optionalValue) IsBoolFlag() bool {
+	return true
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// shortOptions is a cluster of short options, such as -rf, without a value
+// given with =.
+var shortOptions = regexp.MustCompile(`^-[a-zA-Z]+$`)
+
+// gitValueOptions are the short options of git subcommands that take a
+// value, which may follow them in the same argument, as in -Sfoo or -n5.
+const gitValueOptions = "GSmn"
+
+// forceArgument returns the argument of the command that forces it, such as
+// --force, -f or a +refspec of git push, if there is one.
+func forceArgument(command []string) (string, bool) {
+	if len(command) == 0 {
+		return "", false
+	}
+	isGit := filepath.Base(command[0]) == "git"
+	gitSubcommand := ""
+	for i := 1; i < len(command); i++ {
+		arg := command[i]
+		switch {
+		case arg == "--":
+			return "", false
+		case isGit && gitSubcommand == "" && (arg == "-C" || arg == "-c"):
+			// options taking a value
+			i++
+		case arg == "--force" || strings.HasPrefix(arg, "--force-") || strings.HasPrefix(arg, "--force="):
+			return arg, true
+		case forcesShortOption(arg, isGit):
+			return arg, true
+		case isGit && gitSubcommand == "" && !strings.HasPrefix(arg, "-"):
+			gitSubcommand = arg
+		case (gitSubcommand == "push" || gitSubcommand == "fetch") && len(arg) > 1 && arg[0] == '+':
+			return arg, true
+		}
+	}
+	return "", false
+}
+
+// forcesShortOption reports whether the argument is -f, or has it among
+// other short options such as -rf. For git the rest of the argument after an
+// option taking a value is that value, and for other commands a word of more
+// than three letters is a long option, such as -coverprofile of go test.
+func forcesShortOption(arg string, isGit bool) bool {
+	if !shortOptions.MatchString(arg) {
+		return false
+	}
+	if !isGit {
+		return len(arg) <= 4 && strings.ContainsRune(arg, 'f')
+	}
+	for _, c := range arg[1:] {
+		switch {
+		case c == 'f':
+			return true
+		case strings.ContainsRune(gitValueOptions, c):
+			return false
+		}
+	}
+	return false
+}
+
+// confirmForce lists the repositories a forced command would run in and asks
+// for the word force to be typed to go ahead.
+func confirmForce(root string, command []string, arg string, repos []string) bool {
+	// the warning sign would be read as the repository of a result
+	warning := "⚠️ "
+	if a11yOutput {
+		warning = "warning, "
+	}
+	fmt.Println(plain(fmt.Sprintf("\033[1m%s%s\033[0m is forced with %s and would run in %d repositories:", warning, strings.Join(command, " "), arg, len(repos))))
+	for _, repo := range repos {
+		fmt.Printf("  %s\n", relativePath(root, repo))
+	}
+	if !isTerminal(os.Stdin) {
+		fmt.Println("Use -i-know-what-i-am-doing to run it without confirmation")
+		return false
+	}
+	fmt.Print("Type force to run it: ")
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	return strings.TrimSpace(answer) == "force"
+}
+
+// confirmForced asks for a forced command to be confirmed before it runs in
+// the repositories, unless it is not forced or -i-know-what-i-am-doing was
+// given, reporting whether it may run.
+func (ws *workspace) confirmForced(command []string, repos []string) bool {
+	arg, forced := forceArgument(command)
+	if !forced || ws.forceConfirmed || len(repos) == 0 {
+		return true
+	}
+	return confirmForce(ws.root, command, arg, repos)
+}
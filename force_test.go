@@ -0,0 +1,42 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestForceArgument(t *testing.T) {
+	tests := []struct {
+		command string
+		want    string
+	}{
+		{"git push --force", "--force"},
+		{"git push --force-with-lease", "--force-with-lease"},
+		{"git push -f origin main", "-f"},
+		{"git push origin +main", "+main"},
+		{"git fetch origin +refs/heads/*:refs/remotes/origin/*", "+refs/heads/*:refs/remotes/origin/*"},
+		{"git clean -fdx", "-fdx"},
+		{"git -C sub push -f", "-f"},
+		{"git push origin main", ""},
+		{"git log -Sfoo --oneline", ""},
+		{"git log -Gfix", ""},
+		{"git log -n5", ""},
+		{"git commit -mfix", ""},
+		{"git log --oneline -- -f", ""},
+		{"git -c core.fsmonitor=false status", ""},
+		{"rm -rf build", "-rf"},
+		{"rm -f build", "-f"},
+		{"cp -rfv a b", "-rfv"},
+		{"go vet -ldflags=-s", ""},
+		{"go test -coverprofile cover.out", ""},
+		{"go test -bench=foo", ""},
+		{"make -j4", ""},
+		{"terraform apply --force-copy", "--force-copy"},
+	}
+	for _, tt := range tests {
+		got, ok := forceArgument(strings.Fields(tt.command))
+		if got != tt.want || ok != (tt.want != "") {
+			t.Errorf("forceArgument(%q) = %q, %v, want %q", tt.command, got, ok, tt.want)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+//go:build !noforge
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+func init() {
+	builtFeatures["forge"] = true
+}
+
+// forgeClient calls the REST API of a GitHub or GitLab host.
+type forgeClient struct {
+	kind   string
+	api    string
+	token  string
+	client *http.Client
+}
+
+// forgeError is an unsuccessful response from a forge API.
+type forgeError struct {
+	status  int
+	message string
+}
+
+func (e *forgeError) Error() string {
+	return fmt.Sprintf("%d %s: %s", e.status, http.StatusText(e.status), e.message)
+}
+
+// isNotFound reports whether err is a 404 response.
+func isNotFound(err error) bool {
+	fe, ok := err.(*forgeError)
+	return ok && fe.status == http.StatusNotFound
+}
+
+// newForgeClient returns the client for the host, with the credentials of the
+// profile or, without those, from $GITHUB_TOKEN or $GITLAB_TOKEN.
+func newForgeClient(ws *workspace, host string) *forgeClient {
+	creds, _ := ws.profile.forgeCredentials(host)
+
+	kind := creds.Kind
+	if kind == "" {
+		kind = forgeGitHub
+		if strings.Contains(host, "gitlab") {
+			kind = forgeGitLab
+		}
+	}
+
+	api := creds.API
+	switch {
+	case api != "":
+	case kind == forgeGitLab:
+		api = "https://" + host + "/api/v4"
+	case host == "github.com":
+		api = "https://api.github.com"
+	default:
+		// GitHub Enterprise Server
+		api = "https://" + host + "/api/v3"
+	}
+
+	token := creds.token()
+	if token == "" && kind == forgeGitHub {
+		token = os.Getenv("GITHUB_TOKEN")
+	} else if token == "" {
+		token = os.Getenv("GITLAB_TOKEN")
+	}
+
+	return &forgeClient{kind: kind, api: strings.TrimSuffix(api, "/"), token: token, client: &http.Client{Timeout: 30 * time.Second}}
+}
+
+// forgeClientFor returns the client and forge repository of the local
+// repository's origin remote.
+func forgeClientFor(ws *workspace, repo string) (*forgeClient, forgeRepo, error) {
+	remote, err := getConfigValue(repo, "remote.origin.url")
+	if err != nil {
+		return nil, forgeRepo{}, err
+	}
+	if remote == "" {
+		return nil, forgeRepo{}, fmt.Errorf("no origin remote")
+	}
+	fr, ok := parseRemoteURL(remote)
+	if !ok {
+		return nil, forgeRepo{}, fmt.Errorf("origin `%s` is not a forge repository", remote)
+	}
+	return newForgeClient(ws, fr.host), fr, nil
+}
+
+// repoPath returns the API path of the repository, /repos/owner/name on
+// GitHub or /projects/<url encoded path> on GitLab.
+func (c *forgeClient) repoPath(r forgeRepo) string {
+	if c.kind == forgeGitLab {
+		return "/projects/" + url.PathEscape(r.path)
+	}
+	return "/repos/" + r.path
+}
+
+// do calls the API, encoding body and decoding the response into v if they are not nil.
+func (c *forgeClient) do(method string, path string, body any, v any) error {
+	resp, err := c.request(method, path, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if v == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// request calls the API, returning an error for unsuccessful responses.
+func (c *forgeClient) request(method string, path string, body any) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, c.api+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		if c.kind == forgeGitLab {
+			req.Header.Set("PRIVATE-TOKEN", c.token)
+		} else {
+			req.Header.Set("Authorization", "Bearer "+c.token)
+		}
+	}
+	if c.kind == forgeGitHub {
+		req.Header.Set("Accept", "application/vnd.github+json")
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		var e struct {
+			Message string `json:"message"`
+			Error   string `json:"error"`
+		}
+		data, _ := io.ReadAll(resp.Body)
+		json.Unmarshal(data, &e)
+		msg := e.Message + e.Error
+		if msg == "" {
+			msg = strings.TrimSpace(string(data))
+		}
+		return nil, &forgeError{status: resp.StatusCode, message: msg}
+	}
+	return resp, nil
+}
+
+func (c *forgeClient) get(path string, v any) error {
+	return c.do(http.MethodGet, path, nil, v)
+}
+
+// latestRelease returns the tag of the latest release of gits.
+func latestRelease(ws *workspace) (string, error) {
+	var release struct {
+		TagName string `json:"tag_name"`
+	}
+	if err := newForgeClient(ws, "github.com").get("/repos/"+releasesRepo+"/releases/latest", &release); err != nil {
+		return "", err
+	}
+	return release.TagName, nil
+}
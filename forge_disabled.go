@@ -0,0 +1,12 @@
+//go:build noforge
+
+package main
+
+func init() {
+	registerMissingFeature("forge")
+}
+
+// latestRelease fails without the forge APIs to ask for the release.
+func latestRelease(ws *workspace) (string, error) {
+	return "", errFeatureMissing("forge")
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"net/url"
+	"strings"
+)
+
+const (
+	forgeGitHub = "github"
+	forgeGitLab = "gitlab"
+)
+
+// forgeRepo is a repository on a forge, such as github.com acme/api.
+type forgeRepo struct {
+	host string
+	// path is the owner and name, which for GitLab may include subgroups
+	path string
+}
+
+func (r forgeRepo) String() string {
+	return r.host + "/" + r.path
+}
+
+// owner returns the user, organization or group the repository belongs to.
+func (r forgeRepo) owner() string {
+	owner, _, _ := strings.Cut(r.path, "/")
+	return owner
+}
+
+// name returns the name of the repository without its owner.
+func (r forgeRepo) name() string {
+	return r.path[strings.LastIndex(r.path, "/")+1:]
+}
+
+// parseRemoteURL finds the forge repository of a remote URL, in any of the
+// https://host/path, ssh://git@host/path or git@host:path forms.
+func parseRemoteURL(remote string) (forgeRepo, bool) {
+	var host, path string
+	if u, err := url.Parse(remote); err == nil && u.Host != "" {
+		host, path = u.Hostname(), u.Path
+	} else if h, p, ok := strings.Cut(remote, ":"); ok && !strings.Contains(h, "/") {
+		host, path = h, p
+		if _, rest, ok := strings.Cut(host, "@"); ok {
+			host = rest
+		}
+	} else {
+		return forgeRepo{}, false
+	}
+
+	path = strings.TrimSuffix(strings.Trim(path, "/"), ".git")
+	if host == "" || !strings.Contains(path, "/") {
+		return forgeRepo{}, false
+	}
+	return forgeRepo{host: strings.ToLower(host), path: path}, true
+}
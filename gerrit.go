@@ -0,0 +1,269 @@
+//go:build !noforge
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+func init() {
+	registerSubcommand(&subcommand{
+		name:    "gerrit",
+		summary: "upload the repositories' commits as Gerrit changes, or list their open changes, see `gits gerrit push|status`",
+		run:     runGerrit,
+	})
+}
+
+// gerritChange is a change as returned by the Gerrit REST API.
+type gerritChange struct {
+	Number  int    `json:"_number"`
+	Project string `json:"project"`
+	Branch  string `json:"branch"`
+	Topic   string `json:"topic"`
+	Subject string `json:"subject"`
+	Owner   struct {
+		Name     string `json:"name"`
+		Username string `json:"username"`
+	} `json:"owner"`
+	WorkInProgress bool `json:"work_in_progress"`
+}
+
+// gerritClient calls the REST API of a Gerrit host.
+type gerritClient struct {
+	url    string
+	user   string
+	token  string
+	client *http.Client
+}
+
+func runGerrit(ws *workspace, args []string) int {
+	if len(args) > 0 {
+		switch args[0] {
+		case "push":
+			return runGerritPush(ws, args[1:])
+		case "status":
+			return runGerritStatus(ws, args[1:])
+		}
+	}
+	fmt.Println("Usage: gits [options] gerrit push|status [gerrit options]")
+	return 1
+}
+
+func runGerritPush(ws *workspace, args []string) int {
+	fs := newSubcommandFlags("gerrit push", "")
+	remote := fs.String("remote", "origin", "remote of the Gerrit server")
+	target := fs.String("target", "", "branch the changes are for (default: the upstream of the current branch, or the default branch)")
+	topic := fs.String("topic", "", "topic to group the changes of all the repositories under (default: the current branch)")
+	var reviewers []string
+	fs.Var((*stringList)(&reviewers), "reviewer", "reviewer to add to the changes (may be repeated)")
+	wip := fs.Bool("wip", false, "upload the changes as work in progress")
+	fs.Parse(args)
+
+	release, ok := ws.lock("gerrit push")
+	if !ok {
+		return 1
+	}
+	defer release()
+
+	results, exitCode := forEachRepo(ws.repos, ws.parallel, func(repo string) (string, int) {
+		relPath := relativePath(ws.root, repo)
+		branch, err := getCurrentBranch(repo)
+		if err != nil {
+			return fmt.Sprintf("\033[1m❌ %s:\033[0m %v", relPath, err), 1
+		}
+		into := *target
+		if into == "" {
+			into = gerritTarget(repo, branch)
+		}
+		// only the commits the target does not have become changes
+		count, err := runGit(repo, "rev-list", "--count", *remote+"/"+into+"..HEAD")
+		if err != nil {
+			return fmt.Sprintf("\033[1m❌ %s:\033[0m %v", relPath, err), 1
+		}
+		if count == "0" {
+			return "", 0
+		}
+
+		var options []string
+		if t := *topic; t != "" || branch != into {
+			if t == "" {
+				t = branch
+			}
+			options = append(options, "topic="+t)
+		}
+		for _, r := range reviewers {
+			options = append(options, "r="+r)
+		}
+		if *wip {
+			options = append(options, "wip")
+		}
+		ref := "refs/for/" + into
+		if len(options) > 0 {
+			ref += "%" + strings.Join(options, ",")
+		}
+		out, err := runGit(repo, "push", *remote, "HEAD:"+ref)
+		if err != nil {
+			return fmt.Sprintf("\033[1m❌ %s:\033[0m %v", relPath, err), 1
+		}
+		// git prints the URLs of the changes as remote: lines
+		var changes []string
+		for _, line := range strings.Split(out, "\n") {
+			line = strings.TrimSpace(strings.TrimPrefix(line, "remote:"))
+			if strings.HasPrefix(line, "http://") || strings.HasPrefix(line, "https://") {
+				changes = append(changes, line)
+			}
+		}
+		if len(changes) == 0 {
+			return fmt.Sprintf("\033[1m✅️ %s:\033[0m pushed %s commits for %s", relPath, count, into), 0
+		}
+		return fmt.Sprintf("\033[1m✅️ %s:\033[0m\n  %s", relPath, strings.Join(changes, "\n  ")), 0
+	})
+	printResults(results)
+	return exitCode
+}
+
+func runGerritStatus(ws *workspace, args []string) int {
+	fs := newSubcommandFlags("gerrit status", "")
+	remote := fs.String("remote", "origin", "remote of the Gerrit server")
+	topic := fs.String("topic", "", "only show the changes of this topic")
+	mine := fs.Bool("mine", false, "only show the changes you own")
+	fs.Parse(args)
+
+	results, exitCode := forEachRepo(ws.repos, ws.parallel, func(repo string) (string, int) {
+		relPath := relativePath(ws.root, repo)
+		remoteURL, err := getConfigValue(repo, "remote."+*remote+".url")
+		if err != nil {
+			return fmt.Sprintf("\033[1m❌ %s:\033[0m %v", relPath, err), 1
+		}
+		if remoteURL == "" {
+			return fmt.Sprintf("\033[1m⏭️ %s:\033[0m no %s remote", relPath, *remote), 0
+		}
+		host, project, ok := parseGerritRemote(remoteURL)
+		if !ok {
+			return fmt.Sprintf("\033[1m⏭️ %s:\033[0m %s `%s` is not a Gerrit server", relPath, *remote, remoteURL), 0
+		}
+		client := newGerritClient(ws, host)
+
+		query := "status:open project:" + project
+		if *topic != "" {
+			query += " topic:" + *topic
+		}
+		if *mine {
+			query += " owner:self"
+		}
+		var changes []gerritChange
+		if err := client.get("/changes/?o=DETAILED_ACCOUNTS&q="+url.QueryEscape(query), &changes); err != nil {
+			return fmt.Sprintf("\033[1m❌ %s:\033[0m %v", relPath, err), 1
+		}
+		if len(changes) == 0 {
+			return "", 0
+		}
+
+		var lines []string
+		for _, c := range changes {
+			line := fmt.Sprintf("%s/c/%s/+/%d %s \033[2m(%s", client.url, c.Project, c.Number, c.Subject, c.Branch)
+			if c.Topic != "" {
+				line += ", topic " + c.Topic
+			}
+			if c.Owner.Username != "" {
+				line += ", " + c.Owner.Username
+			}
+			if c.WorkInProgress {
+				line += ", work in progress"
+			}
+			lines = append(lines, line+")\033[0m")
+		}
+		return fmt.Sprintf("\033[1m%s:\033[0m\n  %s", relPath, strings.Join(lines, "\n  ")), 0
+	})
+	printResults(results)
+	return exitCode
+}
+
+// gerritTarget returns the branch changes of the branch are for: the
+// branch it tracks, or the default branch.
+func gerritTarget(repo string, branch string) string {
+	if merge, err := getConfigValue(repo, "branch."+branch+".merge"); err == nil && merge != "" {
+		return strings.TrimPrefix(merge, "refs/heads/")
+	}
+	defaultBranch, err := getDefaultBranch(repo)
+	if err != nil {
+		return "main"
+	}
+	return defaultBranch
+}
+
+// parseGerritRemote finds the host and project of a Gerrit remote URL, such
+// as ssh://user@review.example.com:29418/platform/api. Unlike on other forges,
+// a project need not have an owner.
+func parseGerritRemote(remote string) (string, string, bool) {
+	var host, project string
+	if u, err := url.Parse(remote); err == nil && u.Host != "" {
+		host, project = u.Hostname(), u.Path
+		// Gerrit serves authenticated HTTP under /a/
+		project = strings.TrimPrefix(project, "/a/")
+	} else if h, p, ok := strings.Cut(remote, ":"); ok && !strings.Contains(h, "/") {
+		host, project = h, p
+		if _, rest, ok := strings.Cut(host, "@"); ok {
+			host = rest
+		}
+	} else {
+		return "", "", false
+	}
+	project = strings.TrimSuffix(strings.Trim(project, "/"), ".git")
+	return strings.ToLower(host), project, host != "" && project != ""
+}
+
+// newGerritClient returns the client for the host, with the credentials of
+// the profile, whose token is a Gerrit HTTP password, or $GERRIT_USER and
+// $GERRIT_TOKEN.
+func newGerritClient(ws *workspace, host string) *gerritClient {
+	creds, _ := ws.profile.forgeCredentials(host)
+	api := creds.API
+	if api == "" {
+		api = "https://" + host
+	}
+	user, token := creds.User, creds.token()
+	if token == "" {
+		user, token = os.Getenv("GERRIT_USER"), os.Getenv("GERRIT_TOKEN")
+	}
+	return &gerritClient{url: strings.TrimSuffix(api, "/"), user: user, token: token, client: &http.Client{Timeout: 30 * time.Second}}
+}
+
+// get calls the API and decodes the response into v.
+func (c *gerritClient) get(path string, v any) error {
+	api := c.url
+	if c.token != "" {
+		api += "/a"
+	}
+	req, err := http.NewRequest(http.MethodGet, api+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.token != "" {
+		req.SetBasicAuth(c.user, c.token)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 300 {
+		return &forgeError{status: resp.StatusCode, message: strings.TrimSpace(string(data))}
+	}
+	// responses start with a line that stops them being run as JavaScript
+	data = bytes.TrimPrefix(data, []byte(")]}'"))
+	return json.Unmarshal(data, v)
+}
@@ -0,0 +1,982 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// isGitRepo reports whether path is the worktree of a repository, with a
+// .git directory or, for linked worktrees and submodules, a .git file
+// pointing to the git directory.
+func isGitRepo(path string) bool {
+	_, _, ok := findGitDir(path)
+	return ok
+}
+
+// findGitDir returns the git directory of the worktree at path and the
+// common directory it shares with the other worktrees, which holds the
+// objects, refs and configuration.
+func findGitDir(path string) (string, string, bool) {
+	gitDir := filepath.Join(path, ".git")
+	info, err := os.Stat(gitDir)
+	if err != nil {
+		return "", "", false
+	}
+	if info.IsDir() {
+		return gitDir, gitDir, true
+	}
+	data, err := os.ReadFile(gitDir)
+	if err != nil {
+		return "", "", false
+	}
+	target, ok := strings.CutPrefix(strings.TrimSpace(string(data)), "gitdir: ")
+	if !ok {
+		return "", "", false
+	}
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(path, target)
+	}
+	if info, err := os.Stat(target); err != nil || !info.IsDir() {
+		return "", "", false
+	}
+	return target, commonDir(target), true
+}
+
+// commonDir returns the common directory of a git directory, which is
+// itself unless it is the git directory of a linked worktree.
+func commonDir(gitDir string) string {
+	data, err := os.ReadFile(filepath.Join(gitDir, "commondir"))
+	if err != nil {
+		return gitDir
+	}
+	dir := strings.TrimSpace(string(data))
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(gitDir, dir)
+	}
+	return filepath.Clean(dir)
+}
+
+// isBareRepo reports whether path is a bare repository, a git directory
+// without a worktree.
+func isBareRepo(path string) bool {
+	for _, name := range []string{"HEAD", "objects", "refs"} {
+		if _, err := os.Stat(filepath.Join(path, name)); err != nil {
+			return false
+		}
+	}
+	out, err := exec.Command("git", "--git-dir", path, "rev-parse", "--is-bare-repository").Output()
+	return err == nil && strings.TrimSpace(string(out)) == "true"
+}
+
+// lockFiles are the lock files git leaves behind in the git directory when a
+// process is interrupted.
+var lockFiles = []string{"index.lock", "HEAD.lock", "config.lock", "packed-refs.lock", "shallow.lock"}
+
+// staleLockAge is how old a lock file must be to be left behind rather than
+// held by a git process still running.
+const staleLockAge = time.Minute
+
+// worktreeLockFiles are the lock files in the git directory of each
+// worktree, the others are in the common directory.
+var worktreeLockFiles = []string{"index.lock", "HEAD.lock"}
+
+// findRepoProblems checks the git directory for common broken states, such as
+// stale lock files or a missing HEAD, returning a description of each problem.
+func findRepoProblems(path string) []string {
+	gitDir, common, ok := findGitDir(path)
+	if !ok && !isBareRepo(path) {
+		// a repository of another version control system, such as jj
+		return nil
+	}
+	if !ok {
+		// a bare repository is its own git directory
+		gitDir, common = path, path
+	}
+	var problems []string
+
+	for _, name := range lockFiles {
+		dir := common
+		if slices.Contains(worktreeLockFiles, name) {
+			dir = gitDir
+		}
+		// younger lock files are held by a git process still running, such
+		// as one an IDE started, which commands retry on
+		if info, err := os.Stat(filepath.Join(dir, name)); err == nil && time.Since(info.ModTime()) >= staleLockAge {
+			problems = append(problems, fmt.Sprintf("%s present for %s", name, formatAge(time.Since(info.ModTime()))))
+		}
+	}
+
+	head, err := os.ReadFile(filepath.Join(gitDir, "HEAD"))
+	if err != nil {
+		problems = append(problems, "missing HEAD")
+	} else if !isValidHead(strings.TrimSpace(string(head))) {
+		problems = append(problems, "corrupt HEAD")
+	}
+
+	if info, err := os.Stat(filepath.Join(common, "objects")); err != nil || !info.IsDir() {
+		problems = append(problems, "missing objects directory")
+	}
+
+	return problems
+}
+
+func isValidHead(head string) bool {
+	if ref, ok := strings.CutPrefix(head, "ref: "); ok {
+		return strings.HasPrefix(ref, "refs/")
+	}
+	if len(head) != 40 && len(head) != 64 {
+		return false
+	}
+	for _, c := range head {
+		if !strings.ContainsRune("0123456789abcdef", c) {
+			return false
+		}
+	}
+	return true
+}
+
+func getCurrentBranch(path string) (string, error) {
+	// symbolic-ref also works for a branch with no commits yet
+	cmd := exec.Command("git", "-C", path, "symbolic-ref", "--quiet", "--short", "HEAD")
+	out, err := cmd.Output()
+	if err != nil {
+		if exitError, ok := err.(*exec.ExitError); ok && exitError.ExitCode() == 1 {
+			// detached
+			return "HEAD", nil
+		}
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+func getDefaultBranch(path string) (string, error) {
+	cmd := exec.Command("git", "-C", path, "config", "get", "init.defaultbranch")
+	out, err := cmd.Output()
+	if err != nil {
+		return "", err
+	}
+
+	res := strings.TrimSpace(string(out))
+	if res == "" {
+		return "main", nil
+	}
+
+	return res, nil
+}
+
+func getLocalBranches(path string) ([]string, error) {
+	cmd := exec.Command("git", "-C", path, "branch", "--format", "%(refname:short)")
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+
+	names := strings.Split(string(out), "\n")
+
+	res := make([]string, 0, len(names))
+
+	for _, n := range names {
+		n = strings.TrimSpace(n)
+		if n == "" {
+			continue
+		}
+		res = append(res, n)
+	}
+	return res, nil
+}
+
+func isDirty(path string) (bool, error) {
+	cmd := exec.Command("git", "-C", path, "status", "--porcelain")
+	out, err := cmd.Output()
+	if err != nil {
+		return false, err
+	}
+	return len(out) > 0, nil
+}
+
+func isClean(path string) (bool, error) {
+	cmd := exec.Command("git", "-C", path, "status", "--porcelain")
+	out, err := cmd.Output()
+	if err != nil {
+		return false, err
+	}
+	return len(out) == 0, nil
+}
+
+type RemoteSyncState int
+
+const (
+	BehindRemote RemoteSyncState = -1
+	SyncRemote   RemoteSyncState = 0
+	AheadRemote  RemoteSyncState = 1
+)
+
+func (s RemoteSyncState) String() string {
+	switch s {
+	case BehindRemote:
+		return "behind"
+	case AheadRemote:
+		return "ahead"
+	default:
+		return "in-sync"
+	}
+}
+
+func (s RemoteSyncState) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func (s *RemoteSyncState) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "behind":
+		*s = BehindRemote
+	case "ahead":
+		*s = AheadRemote
+	case "in-sync":
+		*s = SyncRemote
+	default:
+		return fmt.Errorf("unknown remote sync state `%s`", text)
+	}
+	return nil
+}
+
+// upstreamState is whether the current branch tracks an upstream branch.
+type upstreamState string
+
+const (
+	upstreamTracking upstreamState = "tracking"
+	// upstreamGone is an upstream branch that no longer exists
+	upstreamGone upstreamState = "gone"
+	upstreamNone upstreamState = "none"
+)
+
+func (upstreamState) schemaEnum() []string {
+	return []string{string(upstreamTracking), string(upstreamGone), string(upstreamNone)}
+}
+
+// getAheadBehind returns how many commits the current branch is ahead of and
+// behind its upstream, as of the last fetch, and whether it has one.
+func getAheadBehind(path string) (int, int, upstreamState, error) {
+	out, err := runGit(path, "status", "--porcelain=v2", "--branch", "--untracked-files=no")
+	if err != nil {
+		return 0, 0, upstreamNone, err
+	}
+	ahead, behind, state := 0, 0, upstreamNone
+	for _, line := range strings.Split(out, "\n") {
+		switch {
+		case strings.HasPrefix(line, "# branch.upstream "):
+			// without a branch.ab line the upstream is gone
+			if state == upstreamNone {
+				state = upstreamGone
+			}
+		case strings.HasPrefix(line, "# branch.ab "):
+			state = upstreamTracking
+			fmt.Sscanf(strings.TrimPrefix(line, "# branch.ab "), "+%d -%d", &ahead, &behind)
+		}
+	}
+	return ahead, behind, state, nil
+}
+
+func getRemoteSyncStatus(path string) (RemoteSyncState, error) {
+	cmd := exec.Command("git", "-C", path, "status", "--porcelain", "--branch")
+	out, err := cmd.Output()
+	if err != nil {
+		return SyncRemote, err
+	}
+
+	firstLine := strings.SplitN(string(out), "\n", 2)[0]
+
+	if !strings.HasPrefix(firstLine, "##") {
+		return SyncRemote, fmt.Errorf("first line `%s` does not start with expected `##`", firstLine)
+	}
+
+	if strings.Contains(firstLine, "[behind") {
+		return BehindRemote, nil
+	}
+	if strings.Contains(firstLine, "[ahead") {
+		return AheadRemote, nil
+	}
+	return SyncRemote, nil
+}
+
+// getTopLevel returns the root of the worktree containing path.
+func getTopLevel(path string) (string, error) {
+	cmd := exec.Command("git", "-C", path, "rev-parse", "--show-toplevel")
+	out, err := cmd.Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// statusSummary is the state of a repository's worktree and current branch,
+// from a single `git status`.
+type statusSummary struct {
+	Branch   string
+	Upstream string
+	Ahead    int
+	Behind   int
+	Dirty    bool
+}
+
+// getStatusSummary reads the branch, its distance from its upstream and
+// whether the worktree is dirty with one git command that takes no locks,
+// which is fast enough to run for every shell prompt.
+func getStatusSummary(path string) (statusSummary, error) {
+	cmd := exec.Command("git", "--no-optional-locks", "-C", path, "status", "--porcelain=v2", "--branch", "-z")
+	out, err := cmd.Output()
+	if err != nil {
+		return statusSummary{}, err
+	}
+
+	var s statusSummary
+	for _, entry := range strings.Split(string(out), "\x00") {
+		header, ok := strings.CutPrefix(entry, "# ")
+		if !ok {
+			if entry != "" {
+				s.Dirty = true
+			}
+			continue
+		}
+		key, value, _ := strings.Cut(header, " ")
+		switch key {
+		case "branch.head":
+			s.Branch = value
+			if value == "(detached)" {
+				s.Branch = "HEAD"
+			}
+		case "branch.upstream":
+			s.Upstream = value
+		case "branch.ab":
+			fmt.Sscanf(value, "+%d -%d", &s.Ahead, &s.Behind)
+		}
+	}
+	return s, nil
+}
+
+func resolveCommit(path string, ref string) (string, error) {
+	cmd := exec.Command("git", "-C", path, "rev-parse", "--verify", "--quiet", ref+"^{commit}")
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("unknown ref `%s`", ref)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// getCommitLog returns the one line summaries of the commits selected by the
+// given revision arguments, newest first.
+func getCommitLog(path string, revs ...string) ([]string, error) {
+	args := append([]string{"-C", path, "log", "--format=%h %s"}, revs...)
+	cmd := exec.Command("git", args...)
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	if len(lines) == 1 && lines[0] == "" {
+		return nil, nil
+	}
+	return lines, nil
+}
+
+func getLastCommitTime(path string, ref string) (time.Time, error) {
+	cmd := exec.Command("git", "-C", path, "log", "-1", "--format=%ct", ref)
+	out, err := cmd.Output()
+	if err != nil {
+		return time.Time{}, err
+	}
+	return parseUnixTime(string(out))
+}
+
+// getBranchCommitTimes returns the committer time of the tip of each local branch.
+func getBranchCommitTimes(path string) (map[string]time.Time, error) {
+	cmd := exec.Command("git", "-C", path, "for-each-ref", "--format=%(committerdate:unix) %(refname:short)", "refs/heads")
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+
+	res := make(map[string]time.Time)
+	for _, line := range strings.Split(string(out), "\n") {
+		ts, name, ok := strings.Cut(strings.TrimSpace(line), " ")
+		if !ok {
+			continue
+		}
+		t, err := parseUnixTime(ts)
+		if err != nil {
+			return nil, err
+		}
+		res[name] = t
+	}
+	return res, nil
+}
+
+// countUnpushedCommits counts the commits on local branches that are not on any remote.
+func countUnpushedCommits(path string) (int, error) {
+	cmd := exec.Command("git", "-C", path, "rev-list", "--count", "--branches", "--not", "--remotes")
+	out, err := cmd.Output()
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(out)))
+}
+
+func getUpstream(path string) (string, error) {
+	cmd := exec.Command("git", "-C", path, "rev-parse", "--abbrev-ref", "--symbolic-full-name", "@{upstream}")
+	out, err := cmd.Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+func isShallow(path string) (bool, error) {
+	cmd := exec.Command("git", "-C", path, "rev-parse", "--is-shallow-repository")
+	out, err := cmd.Output()
+	if err != nil {
+		return false, err
+	}
+	return strings.TrimSpace(string(out)) == "true", nil
+}
+
+// getDirtyFiles returns the paths, relative to the repository root, of the
+// files that are modified, staged or untracked.
+func getDirtyFiles(path string) ([]string, error) {
+	cmd := exec.Command("git", "-C", path, "status", "--porcelain", "-z")
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+
+	var res []string
+	entries := strings.Split(string(out), "\x00")
+	for i := 0; i < len(entries); i++ {
+		entry := entries[i]
+		if len(entry) < 4 {
+			continue
+		}
+		res = append(res, entry[3:])
+		if entry[0] == 'R' || entry[0] == 'C' {
+			// renames and copies are followed by the original path
+			i++
+		}
+	}
+	return res, nil
+}
+
+// getTrackedFiles returns the paths of the files in the index.
+func getTrackedFiles(path string) ([]string, error) {
+	return splitNul(exec.Command("git", "-C", path, "ls-files", "-z"))
+}
+
+// getHistoricalFiles returns the paths of every file changed by a commit
+// reachable from any ref, which includes files that have since been deleted.
+func getHistoricalFiles(path string) ([]string, error) {
+	files, err := splitNul(exec.Command("git", "-C", path, "log", "--all", "--format=", "--name-only", "-z"))
+	if err != nil {
+		return nil, err
+	}
+	slices.Sort(files)
+	return slices.Compact(files), nil
+}
+
+// splitNul runs the command and splits its NUL separated output.
+func splitNul(cmd *exec.Cmd) ([]string, error) {
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+
+	var res []string
+	for _, entry := range strings.Split(string(out), "\x00") {
+		if entry = strings.TrimPrefix(entry, "\n"); entry != "" {
+			res = append(res, entry)
+		}
+	}
+	return res, nil
+}
+
+func parseUnixTime(s string) (time.Time, error) {
+	secs, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(secs, 0), nil
+}
+
+type reflogEntry struct {
+	hash    string
+	when    time.Time
+	message string
+}
+
+// getReflog returns the reflog of ref, newest first. A ref without a reflog
+// has no entries.
+func getReflog(path string, ref string) ([]reflogEntry, error) {
+	cmd := exec.Command("git", "-C", path, "reflog", "show", "--date=unix", "--format=%H%x09%gd%x09%gs", ref, "--")
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+
+	var res []reflogEntry
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		parts := strings.SplitN(line, "\t", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		_, selector, _ := strings.Cut(parts[1], "@{")
+		when, err := parseUnixTime(strings.TrimSuffix(selector, "}"))
+		if err != nil {
+			continue
+		}
+		res = append(res, reflogEntry{hash: parts[0], when: when, message: parts[2]})
+	}
+	return res, nil
+}
+
+func isAncestor(path string, ancestor string, descendant string) bool {
+	cmd := exec.Command("git", "-C", path, "merge-base", "--is-ancestor", ancestor, descendant)
+	return cmd.Run() == nil
+}
+
+// getRefs returns the full names of the refs under the given prefixes.
+func getRefs(path string, prefixes ...string) ([]string, error) {
+	args := append([]string{"-C", path, "for-each-ref", "--format=%(refname)"}, prefixes...)
+	cmd := exec.Command("git", args...)
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+
+	var res []string
+	for _, line := range strings.Split(string(out), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			res = append(res, line)
+		}
+	}
+	return res, nil
+}
+
+// getRefTips returns the commit each ref under the given prefixes points to,
+// by full ref name.
+func getRefTips(path string, prefixes ...string) (map[string]string, error) {
+	args := append([]string{"-C", path, "for-each-ref", "--format=%(objectname) %(refname)"}, prefixes...)
+	cmd := exec.Command("git", args...)
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+
+	tips := make(map[string]string)
+	for _, line := range strings.Split(string(out), "\n") {
+		if hash, ref, ok := strings.Cut(strings.TrimSpace(line), " "); ok {
+			tips[ref] = hash
+		}
+	}
+	return tips, nil
+}
+
+// getHookPath returns the path of the named hook, in core.hooksPath if it
+// is set, whether or not the hook exists.
+func getHookPath(path string, hook string) (string, error) {
+	cmd := exec.Command("git", "-C", path, "rev-parse", "--path-format=absolute", "--git-path", "hooks/"+hook)
+	out, err := cmd.Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// getConfigValue returns the effective value of a git config key, or the
+// empty string if it is not set.
+func getConfigValue(path string, key string) (string, error) {
+	cmd := exec.Command("git", "-C", path, "config", "--get", key)
+	out, err := cmd.Output()
+	if err != nil {
+		if exitError, ok := err.(*exec.ExitError); ok && exitError.ExitCode() == 1 {
+			return "", nil
+		}
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+func setLocalConfigValue(path string, key string, value string) error {
+	cmd := exec.Command("git", "-C", path, "config", "--local", key, value)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%v: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+type commitInfo struct {
+	hash        string
+	authorName  string
+	authorEmail string
+	when        time.Time
+	subject     string
+}
+
+// getCommits returns the commits selected by the given git log arguments.
+func getCommits(path string, args ...string) ([]commitInfo, error) {
+	args = append([]string{"-C", path, "log", "--format=%H%x09%an%x09%ae%x09%at%x09%s"}, args...)
+	cmd := exec.Command("git", args...)
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+
+	var res []commitInfo
+	for _, line := range strings.Split(string(out), "\n") {
+		parts := strings.SplitN(line, "\t", 5)
+		if len(parts) != 5 {
+			continue
+		}
+		when, err := parseUnixTime(parts[3])
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, commitInfo{hash: parts[0], authorName: parts[1], authorEmail: parts[2], when: when, subject: parts[4]})
+	}
+	return res, nil
+}
+
+// getFileAt returns the content of the file at the revision, and false if
+// the revision does not have it.
+func getFileAt(path string, rev string, file string) (string, bool, error) {
+	cmd := exec.Command("git", "-C", path, "cat-file", "-e", rev+":"+file)
+	if cmd.Run() != nil {
+		return "", false, nil
+	}
+	out, err := exec.Command("git", "-C", path, "cat-file", "-p", rev+":"+file).Output()
+	if err != nil {
+		return "", false, err
+	}
+	return string(out), true, nil
+}
+
+// commitFileOnBranch commits the content as the file on top of HEAD and
+// points the branch at the commit, replacing what it pointed at before. The
+// commit is built in a temporary index so the worktree, index and current
+// branch are left alone. The commit follows the commit policy.
+func commitFileOnBranch(path string, branch string, file string, content string, message string, policy *commitPolicy) error {
+	return commitFilesOnBranch(path, branch, map[string]string{file: content}, message, policy)
+}
+
+// commitFilesOnBranch is commitFileOnBranch for the content of several
+// files, by path, in one commit.
+func commitFilesOnBranch(path string, branch string, files map[string]string, message string, policy *commitPolicy) error {
+	head, err := resolveCommit(path, "HEAD")
+	if err != nil {
+		return err
+	}
+	if message, err = policy.message(path, message); err != nil {
+		return err
+	}
+	tmp, err := tempDir()
+	if err != nil {
+		return err
+	}
+	dir, err := os.MkdirTemp(tmp, "index-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	env := append(os.Environ(), "GIT_INDEX_FILE="+filepath.Join(dir, "index"))
+
+	git := func(stdin string, args ...string) (string, error) {
+		cmd := exec.Command("git", append([]string{"-C", path}, args...)...)
+		cmd.Env = env
+		cmd.Stdin = strings.NewReader(stdin)
+		out, err := cmd.Output()
+		if err != nil {
+			return "", fmt.Errorf("git %s: %s", args[0], errorReason(err))
+		}
+		return strings.TrimSpace(string(out)), nil
+	}
+	if _, err := git("", "read-tree", head); err != nil {
+		return err
+	}
+	for _, file := range sortedKeys(files) {
+		blob, err := git(files[file], "hash-object", "-w", "--stdin")
+		if err != nil {
+			return err
+		}
+		if _, err := git("", "update-index", "--add", "--cacheinfo", "100644,"+blob+","+file); err != nil {
+			return err
+		}
+	}
+	tree, err := git("", "write-tree")
+	if err != nil {
+		return err
+	}
+	commit, err := git(message, append([]string{"commit-tree", tree, "-p", head}, policy.signArgs()...)...)
+	if err != nil {
+		return err
+	}
+	_, err = git("", "update-ref", "-m", "gits: "+message, "refs/heads/"+branch, commit)
+	return err
+}
+
+func getRemotes(path string) ([]string, error) {
+	out, err := exec.Command("git", "-C", path, "remote").Output()
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(string(out)), nil
+}
+
+// getStaleRemoteRefs asks the remote which of its remote-tracking refs,
+// such as origin/feature, are of branches deleted upstream.
+func getStaleRemoteRefs(path string, remote string) ([]string, error) {
+	out, err := exec.Command("git", "-C", path, "remote", "prune", "--dry-run", remote).Output()
+	if err != nil {
+		return nil, fmt.Errorf("%s", errorReason(err))
+	}
+	var refs []string
+	for _, line := range strings.Split(string(out), "\n") {
+		if _, ref, ok := strings.Cut(line, "[would prune] "); ok {
+			refs = append(refs, strings.TrimSpace(ref))
+		}
+	}
+	return refs, nil
+}
+
+// objectCounts is the object database summary of `git count-objects -v`,
+// with sizes in KiB.
+type objectCounts struct {
+	Loose       int `json:"loose"`
+	LooseSize   int `json:"loose_size"`
+	InPack      int `json:"in_pack"`
+	Packs       int `json:"packs"`
+	PackSize    int `json:"pack_size"`
+	Garbage     int `json:"garbage"`
+	GarbageSize int `json:"garbage_size"`
+}
+
+func getObjectCounts(path string) (objectCounts, error) {
+	out, err := exec.Command("git", "-C", path, "count-objects", "-v").Output()
+	if err != nil {
+		return objectCounts{}, err
+	}
+	var c objectCounts
+	fields := map[string]*int{
+		"count":        &c.Loose,
+		"size":         &c.LooseSize,
+		"in-pack":      &c.InPack,
+		"packs":        &c.Packs,
+		"size-pack":    &c.PackSize,
+		"garbage":      &c.Garbage,
+		"size-garbage": &c.GarbageSize,
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		key, value, ok := strings.Cut(line, ": ")
+		if field, known := fields[key]; ok && known {
+			*field, _ = strconv.Atoi(strings.TrimSpace(value))
+		}
+	}
+	return c, nil
+}
+
+// needsGC reports whether `git gc --auto` would repack the repository, using
+// its gc.auto and gc.autoPackLimit thresholds, or it has garbage files.
+func needsGC(path string) (bool, error) {
+	c, err := getObjectCounts(path)
+	if err != nil {
+		return false, err
+	}
+	threshold := func(key string, def int) int {
+		if v, err := getConfigValue(path, key); err == nil && v != "" {
+			if n, err := strconv.Atoi(v); err == nil {
+				return n
+			}
+		}
+		return def
+	}
+	// git estimates the loose objects from a sample, which this is close enough to
+	if auto := threshold("gc.auto", 6700); auto > 0 && c.Loose > auto {
+		return true, nil
+	}
+	if limit := threshold("gc.autoPackLimit", 50); limit > 0 && c.Packs > limit {
+		return true, nil
+	}
+	return c.Garbage > 0, nil
+}
+
+// hasTrackedChanges reports whether the worktree or index has changes to
+// tracked files, which a checkout could clobber. Untracked files are ignored.
+func hasTrackedChanges(path string) (bool, error) {
+	out, err := exec.Command("git", "-C", path, "status", "--porcelain", "--untracked-files=no").Output()
+	if err != nil {
+		return false, err
+	}
+	return len(out) > 0, nil
+}
+
+// getHeadPosition returns the current branch, or the commit if HEAD is
+// detached, to check out again later.
+func getHeadPosition(path string) (string, error) {
+	branch, err := getCurrentBranch(path)
+	if err != nil || branch != "HEAD" {
+		return branch, err
+	}
+	return resolveCommit(path, "HEAD")
+}
+
+func checkoutDetached(path string, rev string) error {
+	if out, err := exec.Command("git", "-C", path, "checkout", "--quiet", "--detach", rev).CombinedOutput(); err != nil {
+		return fmt.Errorf("%s", strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// checkoutPosition checks out a branch or commit returned by getHeadPosition.
+func checkoutPosition(path string, position string) error {
+	if out, err := exec.Command("git", "-C", path, "checkout", "--quiet", position).CombinedOutput(); err != nil {
+		return fmt.Errorf("%s", strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// getCommitBefore returns the latest first-parent commit of rev committed at
+// or before t.
+func getCommitBefore(path string, rev string, t time.Time) (string, error) {
+	out, err := exec.Command("git", "-C", path, "rev-list", "-1", "--first-parent", fmt.Sprintf("--before=%d", t.Unix()), rev).Output()
+	if err != nil {
+		return "", fmt.Errorf("%s", errorReason(err))
+	}
+	commit := strings.TrimSpace(string(out))
+	if commit == "" {
+		return "", fmt.Errorf("no commit on %s before %s", rev, t.Format("2006-01-02 15:04"))
+	}
+	return commit, nil
+}
+
+// getLatestTag returns the most recent tag reachable from HEAD, or the empty
+// string if there is none. Of several tags of the same commit, such as
+// v1.10.0 and v1.9.1 of a rebuild, it is the highest version.
+func getLatestTag(path string) (string, error) {
+	out, err := exec.Command("git", "-C", path, "describe", "--tags", "--abbrev=0").Output()
+	if err != nil {
+		if _, ok := err.(*exec.ExitError); ok {
+			return "", nil
+		}
+		return "", err
+	}
+	tag := strings.TrimSpace(string(out))
+	if out, err := runGit(path, "tag", "--points-at", tag+"^{commit}"); err == nil {
+		for _, t := range strings.Fields(out) {
+			if compareNames(t, tag) > 0 {
+				tag = t
+			}
+		}
+	}
+	return tag, nil
+}
+
+// getLastFetchTime returns when the repository was last fetched, from the
+// FETCH_HEAD that fetches write, or the zero time if it never has been.
+func getLastFetchTime(path string) (time.Time, error) {
+	out, err := exec.Command("git", "-C", path, "rev-parse", "--path-format=absolute", "--git-path", "FETCH_HEAD").Output()
+	if err != nil {
+		return time.Time{}, err
+	}
+	info, err := os.Stat(strings.TrimSpace(string(out)))
+	if os.IsNotExist(err) {
+		return time.Time{}, nil
+	} else if err != nil {
+		return time.Time{}, err
+	}
+	return info.ModTime(), nil
+}
+
+// commitFiles commits the current content of the files, relative to the
+// repository root, on the current branch, following the commit policy.
+func commitFiles(path string, files []string, message string, policy *commitPolicy) error {
+	args := append([]string{"-C", path, "add", "--"}, files...)
+	if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("%s", strings.TrimSpace(string(out)))
+	}
+	return policy.commit(path, message, append([]string{"--"}, files...)...)
+}
+
+// runGit runs a git command in the repository, returning its trimmed output
+// or an error with what it printed if it fails.
+func runGit(path string, args ...string) (string, error) {
+	out, _, err := retryLockContention(func() (string, int, error) {
+		out, err := exec.Command("git", append([]string{"-C", path}, args...)...).CombinedOutput()
+		if err != nil {
+			return string(out), 1, err
+		}
+		return string(out), 0, nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("git %s: %s", args[0], strings.TrimSpace(out))
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// getRemoteHeads asks the remote for its default branch and the names of its
+// branches.
+func getRemoteHeads(path string, remote string) (string, []string, error) {
+	out, err := runGit(path, "ls-remote", "--symref", remote, "HEAD", "refs/heads/*")
+	if err != nil {
+		return "", nil, err
+	}
+	var defaultBranch string
+	var branches []string
+	for _, line := range strings.Split(out, "\n") {
+		if target, ok := strings.CutPrefix(line, "ref: "); ok {
+			ref, name, _ := strings.Cut(target, "\t")
+			if name == "HEAD" {
+				defaultBranch = strings.TrimPrefix(ref, "refs/heads/")
+			}
+			continue
+		}
+		if _, ref, ok := strings.Cut(line, "\t"); ok && strings.HasPrefix(ref, "refs/heads/") {
+			branches = append(branches, strings.TrimPrefix(ref, "refs/heads/"))
+		}
+	}
+	return defaultBranch, branches, nil
+}
+
+// getSymbolicRef returns the ref a symbolic ref points to, or "" if it is
+// not a symbolic ref.
+func getSymbolicRef(path string, ref string) string {
+	out, err := exec.Command("git", "-C", path, "symbolic-ref", "--quiet", ref).Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// branchUpstream is the remote branch a local branch tracks.
+type branchUpstream struct {
+	remote string
+	// branch is the name of the branch on the remote
+	branch string
+}
+
+// getBranchUpstreams returns the upstream of each local branch that has one.
+func getBranchUpstreams(path string) (map[string]branchUpstream, error) {
+	out, err := runGit(path, "for-each-ref", "--format=%(refname:short)%00%(upstream:remotename)%00%(upstream:remoteref)", "refs/heads")
+	if err != nil {
+		return nil, err
+	}
+	upstreams := make(map[string]branchUpstream)
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Split(line, "\x00")
+		if len(fields) == 3 && fields[1] != "" {
+			upstreams[fields[0]] = branchUpstream{remote: fields[1], branch: strings.TrimPrefix(fields[2], "refs/heads/")}
+		}
+	}
+	return upstreams, nil
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"path"
+	"strings"
+)
+
+// matchGlob reports whether the slash separated name matches pattern, where
+// each pattern segment is matched with path.Match and a `**` segment matches
+// any number of segments, including none.
+func matchGlob(pattern string, name string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(pattern []string, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// matchesAnyGlob reports whether name matches any of the patterns.
+func matchesAnyGlob(patterns []string, name string) bool {
+	for _, p := range patterns {
+		if matchGlob(strings.TrimSpace(p), name) {
+			return true
+		}
+	}
+	return false
+}
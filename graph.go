@@ -0,0 +1,182 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+func init() {
+	registerSubcommand(&subcommand{
+		name:    "graph",
+		summary: "print a Graphviz or Mermaid diagram of the repositories, their directories, declared dependencies and forge hosts",
+		run:     runGraph,
+	})
+}
+
+// repoGraph is the topology of the workspace.
+type repoGraph struct {
+	// groups are the repository paths of each group, by group name, with
+	// those in no group under ""
+	groups map[string][]string
+	// hosts are the forge host of each repository that has one
+	hosts map[string]string
+	// dependencies are the paths each repository depends on
+	dependencies map[string][]string
+}
+
+func runGraph(ws *workspace, args []string) int {
+	fs := newSubcommandFlags("graph", "")
+	format := fs.String("format", "dot", "diagram format, dot for Graphviz or mermaid")
+	fs.Parse(args)
+
+	if *format != "dot" && *format != "mermaid" {
+		fmt.Println("Invalid -format: must be dot or mermaid")
+		return 1
+	}
+	m, err := findManifest(ws.root)
+	if err != nil {
+		fmt.Println("Error reading manifest:", err)
+		return 1
+	}
+
+	g := collectGraph(ws, m)
+	if *format == "mermaid" {
+		fmt.Print(g.mermaid())
+	} else {
+		fmt.Print(g.dot())
+	}
+	return 0
+}
+
+// collectGraph groups the repositories by their first group in the manifest,
+// or otherwise their parent directory, with the dependencies declared in the
+// manifest, if there is one.
+func collectGraph(ws *workspace, m *manifest) repoGraph {
+	g := repoGraph{groups: make(map[string][]string), hosts: make(map[string]string), dependencies: make(map[string][]string)}
+	var paths []string
+	for _, repo := range ws.repos {
+		relPath := relativePath(ws.root, repo)
+		paths = append(paths, relPath)
+		group := path.Dir(filepath.ToSlash(relPath))
+		if group == "." {
+			group = ""
+		}
+		if m != nil {
+			if names := m.groupsOf(repo); len(names) > 0 {
+				group = names[0]
+			}
+		}
+		g.groups[group] = append(g.groups[group], relPath)
+		if remote, err := getConfigValue(repo, "remote.origin.url"); err == nil {
+			if fr, ok := parseRemoteURL(remote); ok {
+				g.hosts[relPath] = fr.host
+			}
+		}
+	}
+	if m == nil {
+		return g
+	}
+	for _, r := range m.Repos {
+		from := relativePath(ws.root, m.absPath(r))
+		if !slices.Contains(paths, from) {
+			continue
+		}
+		for _, dep := range r.DependsOn {
+			to := relativePath(ws.root, m.absPath(manifestRepo{Path: dep}))
+			if slices.Contains(paths, to) {
+				g.dependencies[from] = append(g.dependencies[from], to)
+			}
+		}
+	}
+	return g
+}
+
+// sortedKeys returns the keys of the map in order.
+func sortedKeys[V any](m map[string]V) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// hostNames returns the forge hosts of the repositories in order.
+func (g repoGraph) hostNames() []string {
+	var hosts []string
+	for _, host := range g.hosts {
+		hosts = append(hosts, host)
+	}
+	slices.Sort(hosts)
+	return slices.Compact(hosts)
+}
+
+// dot renders the graph in the Graphviz DOT language.
+func (g repoGraph) dot() string {
+	var b strings.Builder
+	b.WriteString("digraph gits {\n  rankdir=LR;\n  node [shape=box];\n")
+	for i, group := range sortedKeys(g.groups) {
+		indent := "  "
+		if group != "" {
+			fmt.Fprintf(&b, "  subgraph cluster_%d {\n    label=%q;\n", i, group)
+			indent = "    "
+		}
+		for _, repo := range g.groups[group] {
+			fmt.Fprintf(&b, "%s%q [label=%q];\n", indent, repo, path.Base(repo))
+		}
+		if group != "" {
+			b.WriteString("  }\n")
+		}
+	}
+	for _, host := range g.hostNames() {
+		fmt.Fprintf(&b, "  %q [shape=ellipse, label=%q];\n", "host:"+host, host)
+	}
+	for _, repo := range sortedKeys(g.dependencies) {
+		for _, dep := range g.dependencies[repo] {
+			fmt.Fprintf(&b, "  %q -> %q;\n", repo, dep)
+		}
+	}
+	for _, repo := range sortedKeys(g.hosts) {
+		fmt.Fprintf(&b, "  %q -> %q [style=dashed, arrowhead=none];\n", repo, "host:"+g.hosts[repo])
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// mermaid renders the graph as a Mermaid flowchart.
+func (g repoGraph) mermaid() string {
+	// Mermaid ids cannot have most punctuation, so nodes are numbered
+	ids := make(map[string]string)
+	var b strings.Builder
+	b.WriteString("flowchart LR\n")
+	for i, group := range sortedKeys(g.groups) {
+		indent := "  "
+		if group != "" {
+			fmt.Fprintf(&b, "  subgraph g%d [%q]\n", i, group)
+			indent = "    "
+		}
+		for _, repo := range g.groups[group] {
+			ids[repo] = fmt.Sprintf("r%d", len(ids))
+			fmt.Fprintf(&b, "%s%s[%q]\n", indent, ids[repo], path.Base(repo))
+		}
+		if group != "" {
+			b.WriteString("  end\n")
+		}
+	}
+	for i, host := range g.hostNames() {
+		ids["host:"+host] = fmt.Sprintf("h%d", i)
+		fmt.Fprintf(&b, "  h%d((%q))\n", i, host)
+	}
+	for _, repo := range sortedKeys(g.dependencies) {
+		for _, dep := range g.dependencies[repo] {
+			fmt.Fprintf(&b, "  %s --> %s\n", ids[repo], ids[dep])
+		}
+	}
+	for _, repo := range sortedKeys(g.hosts) {
+		fmt.Fprintf(&b, "  %s -.- %s\n", ids[repo], ids["host:"+g.hosts[repo]])
+	}
+	return b.String()
+}
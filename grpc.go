@@ -0,0 +1,542 @@
+//go:build !nogrpc && !noserve
+
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"slices"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func init() {
+	builtFeatures["grpc"] = true
+}
+
+// The messages of gits.proto are encoded by hand with protowire, which keeps
+// generated code and protoc out of the build. gits.proto is the contract
+// clients generate their stubs from.
+
+// wireMessage is a message of gits.proto.
+type wireMessage interface {
+	marshalWire() []byte
+	unmarshalWire(b []byte) error
+}
+
+// wireCodec encodes the gRPC messages, replacing the default codec which
+// needs generated messages.
+type wireCodec struct{}
+
+func (wireCodec) Name() string { return "proto" }
+
+func (wireCodec) Marshal(v any) ([]byte, error) {
+	m, ok := v.(wireMessage)
+	if !ok {
+		return nil, fmt.Errorf("cannot marshal %T", v)
+	}
+	return m.marshalWire(), nil
+}
+
+func (wireCodec) Unmarshal(data []byte, v any) error {
+	m, ok := v.(wireMessage)
+	if !ok {
+		return fmt.Errorf("cannot unmarshal %T", v)
+	}
+	return m.unmarshalWire(data)
+}
+
+func appendString(b []byte, num protowire.Number, s string) []byte {
+	if s == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, s)
+}
+
+func appendBool(b []byte, num protowire.Number, v bool) []byte {
+	if !v {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, 1)
+}
+
+func appendInt(b []byte, num protowire.Number, v int) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, uint64(int64(v)))
+}
+
+func appendDouble(b []byte, num protowire.Number, v float64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.Fixed64Type)
+	return protowire.AppendFixed64(b, math.Float64bits(v))
+}
+
+func appendMessage(b []byte, num protowire.Number, m wireMessage) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, m.marshalWire())
+}
+
+// decodeFields calls field with the number, type and encoded value of each
+// field of the message.
+func decodeFields(b []byte, field func(num protowire.Number, typ protowire.Type, v []byte) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+		n = protowire.ConsumeFieldValue(num, typ, b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		if err := field(num, typ, b[:n]); err != nil {
+			return err
+		}
+		b = b[n:]
+	}
+	return nil
+}
+
+func decodeString(typ protowire.Type, v []byte) (string, error) {
+	if typ != protowire.BytesType {
+		return "", errors.New("expected a string")
+	}
+	s, _ := protowire.ConsumeString(v)
+	return s, nil
+}
+
+func decodeBool(typ protowire.Type, v []byte) (bool, error) {
+	if typ != protowire.VarintType {
+		return false, errors.New("expected a bool")
+	}
+	x, _ := protowire.ConsumeVarint(v)
+	return x != 0, nil
+}
+
+type listReposRequest struct {
+	filterOptions
+}
+
+func (m *listReposRequest) marshalWire() []byte {
+	return m.filterOptions.appendWire(nil, 1)
+}
+
+func (m *listReposRequest) unmarshalWire(b []byte) error {
+	return decodeFields(b, func(num protowire.Number, typ protowire.Type, v []byte) error {
+		return m.filterOptions.decodeWire(1, num, typ, v)
+	})
+}
+
+// appendWire appends the filters as the fields numbered from first, in the
+// order of filterOptions, which the messages taking filters share.
+func (o *filterOptions) appendWire(b []byte, first protowire.Number) []byte {
+	b = appendString(b, first, o.Branch)
+	b = appendBool(b, first+1, o.Dirty)
+	b = appendBool(b, first+2, o.Clean)
+	b = appendBool(b, first+3, o.NeedsGC)
+	b = appendString(b, first+4, o.Remote)
+	b = appendString(b, first+5, o.HasBranch)
+	b = appendBool(b, first+6, o.Ahead)
+	b = appendBool(b, first+7, o.Behind)
+	b = appendString(b, first+8, o.Stale)
+	b = appendString(b, first+9, o.Bridge)
+	return b
+}
+
+// decodeWire sets the filter of the field if it is one of those numbered
+// from first by appendWire.
+func (o *filterOptions) decodeWire(first protowire.Number, num protowire.Number, typ protowire.Type, v []byte) (err error) {
+	switch num - first {
+	case 0:
+		o.Branch, err = decodeString(typ, v)
+	case 1:
+		o.Dirty, err = decodeBool(typ, v)
+	case 2:
+		o.Clean, err = decodeBool(typ, v)
+	case 3:
+		o.NeedsGC, err = decodeBool(typ, v)
+	case 4:
+		o.Remote, err = decodeString(typ, v)
+	case 5:
+		o.HasBranch, err = decodeString(typ, v)
+	case 6:
+		o.Ahead, err = decodeBool(typ, v)
+	case 7:
+		o.Behind, err = decodeBool(typ, v)
+	case 8:
+		o.Stale, err = decodeString(typ, v)
+	case 9:
+		o.Bridge, err = decodeString(typ, v)
+	}
+	return err
+}
+
+type wireRepo struct {
+	path    string
+	relPath string
+	status  repoStatus
+}
+
+func (m *wireRepo) marshalWire() []byte {
+	var b []byte
+	b = appendString(b, 1, m.path)
+	b = appendString(b, 2, m.relPath)
+	b = appendString(b, 3, m.status.Branch)
+	b = appendString(b, 4, m.status.DefaultBranch)
+	b = appendBool(b, 5, m.status.Dirty)
+	b = appendString(b, 6, m.status.Sync.String())
+	return b
+}
+
+func (m *wireRepo) unmarshalWire(b []byte) error {
+	return errors.New("Repo is only sent by the server")
+}
+
+type listReposResponse struct {
+	repos []wireRepo
+}
+
+func (m *listReposResponse) marshalWire() []byte {
+	var b []byte
+	for i := range m.repos {
+		b = appendMessage(b, 1, &m.repos[i])
+	}
+	return b
+}
+
+func (m *listReposResponse) unmarshalWire(b []byte) error {
+	return errors.New("ListReposResponse is only sent by the server")
+}
+
+func (m *runRequest) marshalWire() []byte {
+	var b []byte
+	for _, arg := range m.Command {
+		b = protowire.AppendTag(b, 1, protowire.BytesType)
+		b = protowire.AppendString(b, arg)
+	}
+	return m.filterOptions.appendWire(b, 2)
+}
+
+func (m *runRequest) unmarshalWire(b []byte) error {
+	return decodeFields(b, func(num protowire.Number, typ protowire.Type, v []byte) error {
+		if num == 1 {
+			arg, err := decodeString(typ, v)
+			m.Command = append(m.Command, arg)
+			return err
+		}
+		return m.filterOptions.decodeWire(2, num, typ, v)
+	})
+}
+
+func (m *jobRepoResult) marshalWire() []byte {
+	var b []byte
+	b = appendString(b, 1, m.Path)
+	b = appendString(b, 2, m.RelPath)
+	b = appendInt(b, 3, m.ExitCode)
+	b = appendString(b, 4, m.Output)
+	b = appendDouble(b, 5, m.Duration)
+	return b
+}
+
+func (m *jobRepoResult) unmarshalWire(b []byte) error {
+	return errors.New("RepoResult is only sent by the server")
+}
+
+func (m *triggeredRun) marshalWire() []byte {
+	var b []byte
+	b = appendString(b, 1, m.ID)
+	for _, arg := range m.Command {
+		b = protowire.AppendTag(b, 2, protowire.BytesType)
+		b = protowire.AppendString(b, arg)
+	}
+	b = appendInt(b, 3, m.Total)
+	b = appendInt(b, 4, m.Failed)
+	b = appendBool(b, 5, m.Finished != nil)
+	for i := range m.Results {
+		b = appendMessage(b, 6, &m.Results[i])
+	}
+	return b
+}
+
+func (m *triggeredRun) unmarshalWire(b []byte) error {
+	return errors.New("Run is only sent by the server")
+}
+
+// runEvent is one of the events streamed by Run and WatchRun, only one of
+// the fields is set.
+type runEvent struct {
+	started *triggeredRun
+	result  *jobRepoResult
+	done    *triggeredRun
+}
+
+func (m *runEvent) marshalWire() []byte {
+	var b []byte
+	switch {
+	case m.started != nil:
+		b = appendMessage(b, 1, m.started)
+	case m.result != nil:
+		b = appendMessage(b, 2, m.result)
+	case m.done != nil:
+		b = appendMessage(b, 3, m.done)
+	}
+	return b
+}
+
+func (m *runEvent) unmarshalWire(b []byte) error {
+	return errors.New("RunEvent is only sent by the server")
+}
+
+type getRunRequest struct {
+	id string
+}
+
+func (m *getRunRequest) marshalWire() []byte {
+	return appendString(nil, 1, m.id)
+}
+
+func (m *getRunRequest) unmarshalWire(b []byte) error {
+	return decodeFields(b, func(num protowire.Number, typ protowire.Type, v []byte) (err error) {
+		if num == 1 {
+			m.id, err = decodeString(typ, v)
+		}
+		return err
+	})
+}
+
+// gitsService is the gits.v1.Gits service of gits.proto.
+type gitsService interface {
+	listRepos(ctx context.Context, req *listReposRequest) (*listReposResponse, error)
+	getRun(ctx context.Context, req *getRunRequest) (*triggeredRun, error)
+	run(req *runRequest, stream grpc.ServerStream) error
+	watchRun(req *getRunRequest, stream grpc.ServerStream) error
+}
+
+var gitsServiceDesc = grpc.ServiceDesc{
+	ServiceName: "gits.v1.Gits",
+	HandlerType: (*gitsService)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListRepos",
+			Handler: func(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+				req := &listReposRequest{}
+				if err := dec(req); err != nil {
+					return nil, err
+				}
+				// the interceptor checks the token, as generated code does
+				if interceptor == nil {
+					return srv.(gitsService).listRepos(ctx, req)
+				}
+				info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/gits.v1.Gits/ListRepos"}
+				return interceptor(ctx, req, info, func(ctx context.Context, req any) (any, error) {
+					return srv.(gitsService).listRepos(ctx, req.(*listReposRequest))
+				})
+			},
+		},
+		{
+			MethodName: "GetRun",
+			Handler: func(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+				req := &getRunRequest{}
+				if err := dec(req); err != nil {
+					return nil, err
+				}
+				if interceptor == nil {
+					return srv.(gitsService).getRun(ctx, req)
+				}
+				info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/gits.v1.Gits/GetRun"}
+				return interceptor(ctx, req, info, func(ctx context.Context, req any) (any, error) {
+					return srv.(gitsService).getRun(ctx, req.(*getRunRequest))
+				})
+			},
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Run",
+			ServerStreams: true,
+			Handler: func(srv any, stream grpc.ServerStream) error {
+				req := &runRequest{}
+				if err := stream.RecvMsg(req); err != nil {
+					return err
+				}
+				return srv.(gitsService).run(req, stream)
+			},
+		},
+		{
+			StreamName:    "WatchRun",
+			ServerStreams: true,
+			Handler: func(srv any, stream grpc.ServerStream) error {
+				req := &getRunRequest{}
+				if err := stream.RecvMsg(req); err != nil {
+					return err
+				}
+				return srv.(gitsService).watchRun(req, stream)
+			},
+		},
+	},
+	Metadata: "gits.proto",
+}
+
+func (s *server) listRepos(ctx context.Context, req *listReposRequest) (*listReposResponse, error) {
+	if err := req.validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	opts := s.ws.discovery
+	opts.filters = append(slices.Clone(opts.filters), req.filters()...)
+	found, err := findRepos(opts)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	repos := make([]wireRepo, len(found.repos))
+	forEachRepoWith(found.repos, s.ws.parallel, false, func(path string) (string, int) {
+		repos[slices.Index(found.repos, path)] = wireRepo{
+			path:    path,
+			relPath: relativePath(s.ws.root, path),
+			status:  collectStatus(path),
+		}
+		return "", 0
+	})
+	return &listReposResponse{repos: repos}, nil
+}
+
+func (s *server) getRun(ctx context.Context, req *getRunRequest) (*triggeredRun, error) {
+	run := s.findRun(req.id)
+	if run == nil {
+		return nil, status.Error(codes.NotFound, "unknown run")
+	}
+	return run.snapshot(), nil
+}
+
+func (s *server) run(req *runRequest, stream grpc.ServerStream) error {
+	run, code, err := s.startRun(*req)
+	if err != nil {
+		return status.Error(grpcCode(code), err.Error())
+	}
+	past, events := run.subscribe()
+	return streamRunMessages(stream, run, past, events)
+}
+
+func (s *server) watchRun(req *getRunRequest, stream grpc.ServerStream) error {
+	run := s.findRun(req.id)
+	if run == nil {
+		return status.Error(codes.NotFound, "unknown run")
+	}
+	past, events := run.subscribe()
+	return streamRunMessages(stream, run, past, events)
+}
+
+// streamRunMessages is the gRPC equivalent of streamRunEvents. The run keeps
+// going if the client goes away.
+func streamRunMessages(stream grpc.ServerStream, run *triggeredRun, past []jobRepoResult, events chan jobRepoResult) error {
+	summary := func() *triggeredRun {
+		snap := run.snapshot()
+		snap.Results = nil
+		return snap
+	}
+
+	if err := stream.SendMsg(&runEvent{started: summary()}); err != nil {
+		return err
+	}
+	for _, result := range past {
+		if err := stream.SendMsg(&runEvent{result: &result}); err != nil {
+			return err
+		}
+	}
+	for result := range events {
+		if err := stream.SendMsg(&runEvent{result: &result}); err != nil {
+			return err
+		}
+	}
+	return stream.SendMsg(&runEvent{done: summary()})
+}
+
+// grpcCode maps the HTTP status of an API error to a gRPC code.
+func grpcCode(httpStatus int) codes.Code {
+	switch httpStatus {
+	case http.StatusBadRequest:
+		return codes.InvalidArgument
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusConflict:
+		return codes.Aborted
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	default:
+		return codes.Internal
+	}
+}
+
+// checkToken rejects calls without the bearer token, if there is one, in the
+// authorization metadata.
+func checkToken(ctx context.Context, token string) error {
+	if token == "" {
+		return nil
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, v := range md.Get("authorization") {
+		if subtle.ConstantTimeCompare([]byte(v), []byte("Bearer "+token)) == 1 {
+			return nil
+		}
+	}
+	return status.Error(codes.Unauthenticated, "missing or invalid token")
+}
+
+// serveGRPC serves the gRPC interface on the address until it fails.
+func (s *server) serveGRPC(address string, tlsCfg *tls.Config) error {
+	lis, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+	return s.grpcServer(tlsCfg).Serve(lis)
+}
+
+// grpcServer returns the gRPC server of the gits.v1.Gits service.
+func (s *server) grpcServer(tlsCfg *tls.Config) *grpc.Server {
+	token := s.token
+	opts := []grpc.ServerOption{
+		grpc.ForceServerCodec(wireCodec{}),
+		grpc.UnaryInterceptor(func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+			if err := checkToken(ctx, token); err != nil {
+				return nil, err
+			}
+			return handler(ctx, req)
+		}),
+		grpc.StreamInterceptor(func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			if err := checkToken(ss.Context(), token); err != nil {
+				return err
+			}
+			return handler(srv, ss)
+		}),
+	}
+	if tlsCfg != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsCfg)))
+	}
+
+	desc := gitsServiceDesc
+	if token == "" {
+		// as with POST /runs, running commands takes a token
+		desc.Streams = slices.DeleteFunc(slices.Clone(desc.Streams), func(d grpc.StreamDesc) bool { return d.StreamName == "Run" })
+	}
+	g := grpc.NewServer(opts...)
+	g.RegisterService(&desc, s)
+	return g
+}
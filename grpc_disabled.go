@@ -0,0 +1,10 @@
+//go:build nogrpc && !noserve
+
+package main
+
+import "crypto/tls"
+
+// serveGRPC fails, so that gits serve -grpc-listen says why it cannot.
+func (s *server) serveGRPC(address string, tlsCfg *tls.Config) error {
+	return errFeatureMissing("grpc")
+}
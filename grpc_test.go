@@ -0,0 +1,147 @@
+//go:build !nogrpc && !noserve
+
+package main
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestCheckToken(t *testing.T) {
+	tests := []struct {
+		name          string
+		token         string
+		authorization []string
+		want          codes.Code
+	}{
+		{"no token configured", "", nil, codes.OK},
+		{"token sent", "s3cret", []string{"Bearer s3cret"}, codes.OK},
+		{"token among others", "s3cret", []string{"Bearer guess", "Bearer s3cret"}, codes.OK},
+		{"no metadata", "s3cret", nil, codes.Unauthenticated},
+		{"wrong token", "s3cret", []string{"Bearer guess"}, codes.Unauthenticated},
+		{"without the scheme", "s3cret", []string{"s3cret"}, codes.Unauthenticated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.authorization != nil {
+				md := metadata.MD{"authorization": tt.authorization}
+				ctx = metadata.NewIncomingContext(ctx, md)
+			}
+			if got := status.Code(checkToken(ctx, tt.token)); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// dialTestServer serves the gRPC interface of a daemon with the token on a
+// free port and connects to it.
+func dialTestServer(t *testing.T, token string) *grpc.ClientConn {
+	t.Helper()
+	ws := &workspace{root: t.TempDir(), parallel: 1, config: &config{}, noLock: true}
+	s := &server{ws: ws, token: token, jobs: make(map[string]*scheduledJob)}
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := s.grpcServer(nil)
+	go g.Serve(lis)
+	t.Cleanup(g.Stop)
+
+	conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()), grpc.WithDefaultCallOptions(grpc.ForceCodec(wireCodec{})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestGRPCUnaryCallsNeedToken(t *testing.T) {
+	conn := dialTestServer(t, "s3cret")
+	calls := []struct {
+		method string
+		req    wireMessage
+		resp   wireMessage
+	}{
+		{"/gits.v1.Gits/ListRepos", &listReposRequest{}, &listReposResponse{}},
+		{"/gits.v1.Gits/GetRun", &getRunRequest{id: "unknown"}, &triggeredRun{}},
+	}
+	for _, c := range calls {
+		err := conn.Invoke(context.Background(), c.method, c.req, c.resp)
+		if got := status.Code(err); got != codes.Unauthenticated {
+			t.Errorf("%s without a token: got %v, want %v", c.method, got, codes.Unauthenticated)
+		}
+	}
+
+	// with the token the call reaches the service
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer s3cret")
+	err := conn.Invoke(ctx, "/gits.v1.Gits/GetRun", &getRunRequest{id: "unknown"}, &triggeredRun{})
+	if got := status.Code(err); got != codes.NotFound {
+		t.Errorf("GetRun with the token: got %v, want %v", got, codes.NotFound)
+	}
+}
+
+func TestGRPCRunNeedsConfiguredToken(t *testing.T) {
+	conn := dialTestServer(t, "")
+	stream, err := conn.NewStream(context.Background(), &grpc.StreamDesc{ServerStreams: true}, "/gits.v1.Gits/Run")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.SendMsg(&runRequest{Command: []string{"true"}}); err != nil {
+		t.Fatal(err)
+	}
+	stream.CloseSend()
+	if got := status.Code(stream.RecvMsg(&runEvent{})); got != codes.Unimplemented {
+		t.Errorf("Run without a configured token: got %v, want %v", got, codes.Unimplemented)
+	}
+}
+
+func TestFilterOptionsWire(t *testing.T) {
+	filters := filterOptions{
+		Branch:    "main",
+		Dirty:     true,
+		Clean:     true,
+		NeedsGC:   true,
+		Remote:    "github.com/acme/*",
+		HasBranch: "topic",
+		Ahead:     true,
+		Behind:    true,
+		Stale:     "90d",
+		Bridge:    "svn",
+	}
+
+	list := &listReposRequest{filterOptions: filters}
+	var gotList listReposRequest
+	if err := gotList.unmarshalWire(list.marshalWire()); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(gotList, *list) {
+		t.Errorf("ListReposRequest: got %+v, want %+v", gotList, *list)
+	}
+
+	run := &runRequest{Command: []string{"git", "status"}, filterOptions: filters}
+	var gotRun runRequest
+	if err := gotRun.unmarshalWire(run.marshalWire()); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(gotRun, *run) {
+		t.Errorf("RunRequest: got %+v, want %+v", gotRun, *run)
+	}
+}
+
+func TestGRPCListReposValidatesFilters(t *testing.T) {
+	conn := dialTestServer(t, "")
+	err := conn.Invoke(context.Background(), "/gits.v1.Gits/ListRepos", &listReposRequest{filterOptions{Stale: "soon"}}, &listReposResponse{})
+	if got := status.Code(err); got != codes.InvalidArgument {
+		t.Errorf("got %v, want %v", got, codes.InvalidArgument)
+	}
+}
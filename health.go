@@ -0,0 +1,214 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func init() {
+	registerSubcommand(&subcommand{
+		name:    "health",
+		summary: "score each repository on how much cleanup it needs",
+		run:     runHealth,
+	})
+}
+
+// healthFactors are the names of the factors that make up the health score,
+// in the order they are reported.
+var healthFactors = []string{"activity", "unpushed", "stale", "upstream", "shallow", "dirty"}
+
+var defaultHealthWeights = map[string]float64{
+	"activity": 1,
+	"unpushed": 3,
+	"stale":    1,
+	"upstream": 2,
+	"shallow":  1,
+	"dirty":    2,
+}
+
+// repoHealth holds the penalty for each factor, from 0 (healthy) to 1, along
+// with a human readable description of any problem found.
+type repoHealth struct {
+	path      string
+	relPath   string
+	penalties map[string]float64
+	problems  []string
+	score     int
+}
+
+func parseHealthWeights(spec string) (map[string]float64, error) {
+	weights := make(map[string]float64, len(defaultHealthWeights))
+	for k, v := range defaultHealthWeights {
+		weights[k] = v
+	}
+	if spec == "" {
+		return weights, nil
+	}
+
+	for _, item := range strings.Split(spec, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(item), "=")
+		if !ok {
+			return nil, fmt.Errorf("weight `%s` is not of the form name=value", item)
+		}
+		if _, known := defaultHealthWeights[name]; !known {
+			return nil, fmt.Errorf("unknown health factor `%s`, expected one of %s", name, strings.Join(healthFactors, ", "))
+		}
+		w, err := strconv.ParseFloat(value, 64)
+		if err != nil || w < 0 {
+			return nil, fmt.Errorf("weight for `%s` must be a non-negative number", name)
+		}
+		weights[name] = w
+	}
+	return weights, nil
+}
+
+func runHealth(ws *workspace, args []string) int {
+	fs := newSubcommandFlags("health", "")
+	weightSpec := fs.String("weights", "", "comma separated factor weights, e.g. unpushed=5,shallow=0 (factors: "+strings.Join(healthFactors, ", ")+")")
+	sortBy := fs.String("sort", "score", "sort order: score (least healthy first), name, or a factor name")
+	staleDays := fs.Int("stale-days", 90, "age in days after which a branch is considered stale")
+	fs.Parse(args)
+
+	weights, err := parseHealthWeights(*weightSpec)
+	if err != nil {
+		fmt.Println("Invalid -weights:", err)
+		return 1
+	}
+	if *sortBy != "score" && *sortBy != "name" && !slices.Contains(healthFactors, *sortBy) {
+		fmt.Printf("Invalid -sort `%s`, expected score, name or one of %s\n", *sortBy, strings.Join(healthFactors, ", "))
+		return 1
+	}
+
+	healths := make([]*repoHealth, len(ws.repos))
+	failures, exitCode := forEachRepo(ws.repos, ws.parallel, func(path string) (string, int) {
+		h, err := assessHealth(path, ws.root, time.Duration(*staleDays)*24*time.Hour)
+		if err != nil {
+			return fmt.Sprintf("\033[1m❌ %s:\033[0m %v", relativePath(ws.root, path), err), 1
+		}
+		h.score = healthScore(h, weights)
+		healths[slices.Index(ws.repos, path)] = h
+		return "", 0
+	})
+	healths = slices.DeleteFunc(healths, func(h *repoHealth) bool { return h == nil })
+
+	slices.SortStableFunc(healths, func(a, b *repoHealth) int {
+		switch *sortBy {
+		case "name":
+			return compareNames(a.relPath, b.relPath)
+		case "score":
+			return a.score - b.score
+		default:
+			if a.penalties[*sortBy] > b.penalties[*sortBy] {
+				return -1
+			} else if a.penalties[*sortBy] < b.penalties[*sortBy] {
+				return 1
+			}
+			return a.score - b.score
+		}
+	})
+
+	width := 0
+	for _, h := range healths {
+		width = max(width, len(h.relPath))
+	}
+
+	for _, h := range healths {
+		color := "32"
+		if h.score < 50 {
+			color = "31"
+		} else if h.score < 80 {
+			color = "33"
+		}
+		fmt.Println(plain(fmt.Sprintf("\033[%sm%*d\033[0m \033[1m%-*s\033[0m %s", color, padding(3), h.score, padding(width), h.relPath, strings.Join(h.problems, ", "))))
+	}
+
+	printResults(failures)
+
+	return exitCode
+}
+
+func assessHealth(path string, root string, staleAfter time.Duration) (*repoHealth, error) {
+	h := &repoHealth{
+		path:      path,
+		relPath:   relativePath(root, path),
+		penalties: make(map[string]float64),
+	}
+	now := time.Now()
+
+	if last, err := getLastCommitTime(path, "HEAD"); err == nil {
+		days := now.Sub(last).Hours() / 24
+		h.penalties["activity"] = min(days/365, 1)
+		if days >= 30 {
+			h.problems = append(h.problems, fmt.Sprintf("last commit %dd ago", int(days)))
+		}
+	} else {
+		h.penalties["activity"] = 1
+		h.problems = append(h.problems, "no commits")
+	}
+
+	if n, err := countUnpushedCommits(path); err == nil && n > 0 {
+		h.penalties["unpushed"] = min(float64(n)/10, 1)
+		h.problems = append(h.problems, fmt.Sprintf("%d unpushed commits", n))
+	}
+
+	if branches, err := getBranchCommitTimes(path); err == nil {
+		stale := 0
+		for _, t := range branches {
+			if now.Sub(t) > staleAfter {
+				stale++
+			}
+		}
+		if stale > 0 {
+			h.penalties["stale"] = min(float64(stale)/5, 1)
+			h.problems = append(h.problems, fmt.Sprintf("%d stale branches", stale))
+		}
+	}
+
+	if _, err := getUpstream(path); err != nil {
+		h.penalties["upstream"] = 1
+		h.problems = append(h.problems, "no upstream")
+	}
+
+	if shallow, err := isShallow(path); err == nil && shallow {
+		h.penalties["shallow"] = 1
+		h.problems = append(h.problems, "shallow")
+	}
+
+	files, err := getDirtyFiles(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) > 0 {
+		oldest := now
+		for _, f := range files {
+			if info, err := os.Lstat(filepath.Join(path, f)); err == nil && info.ModTime().Before(oldest) {
+				oldest = info.ModTime()
+			}
+		}
+		days := now.Sub(oldest).Hours() / 24
+		// Even freshly dirty worktrees carry some penalty
+		h.penalties["dirty"] = min(0.25+days/30, 1)
+		h.problems = append(h.problems, fmt.Sprintf("dirty for %dd", int(days)))
+	}
+
+	return h, nil
+}
+
+// healthScore combines the weighted penalties into a score from 0 to 100
+// where 100 is perfectly healthy.
+func healthScore(h *repoHealth, weights map[string]float64) int {
+	var total, penalty float64
+	for _, name := range healthFactors {
+		total += weights[name]
+		penalty += weights[name] * h.penalties[name]
+	}
+	if total == 0 {
+		return 100
+	}
+	return int(100 - 100*penalty/total + 0.5)
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+func init() {
+	registerSubcommand(&subcommand{
+		name:    "hooks",
+		summary: "run a git hook such as pre-commit in the dirty repositories and summarise the failures, see `gits hooks run`",
+		run:     runHooks,
+	})
+}
+
+func runHooks(ws *workspace, args []string) int {
+	if len(args) > 0 && args[0] == "run" {
+		return runHooksRun(ws, args[1:])
+	}
+	fmt.Println("Usage: gits [options] hooks run [hooks options] <hook>")
+	return 1
+}
+
+func runHooksRun(ws *workspace, args []string) int {
+	fs := newSubcommandFlags("hooks run", " <hook>")
+	all := fs.Bool("all", false, "run in every repository, not only the dirty ones")
+	allFiles := fs.Bool("all-files", false, "with the pre-commit framework, check every file rather than the staged ones")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 1
+	}
+	hook := fs.Arg(0)
+
+	// hooks such as formatters may change files
+	release, ok := ws.lock("hooks run " + hook)
+	if !ok {
+		return 1
+	}
+	defer release()
+
+	failed := make([]bool, len(ws.repos))
+	results, exitCode := forEachRepo(ws.repos, ws.parallel, func(repo string) (string, int) {
+		relPath := relativePath(ws.root, repo)
+		if !*all {
+			if dirty, err := isDirty(repo); err != nil {
+				return fmt.Sprintf("\033[1m❌ %s:\033[0m %v", relPath, err), 1
+			} else if !dirty {
+				return "", 0
+			}
+		}
+		command, err := hookCommand(repo, hook, *allFiles)
+		if err != nil {
+			return fmt.Sprintf("\033[1m❌ %s:\033[0m %v", relPath, err), 1
+		}
+		if command == nil {
+			return fmt.Sprintf("\033[1m⏭️ %s:\033[0m no %s hook", relPath, hook), 0
+		}
+		result, exitCode := processRepo(repo, ws.root, command, ws.cmdOpts)
+		failed[slices.Index(ws.repos, repo)] = exitCode != 0
+		return result, exitCode
+	})
+	printResults(results)
+
+	var failures []string
+	for i, repo := range ws.repos {
+		if failed[i] {
+			failures = append(failures, relativePath(ws.root, repo))
+		}
+	}
+	if len(failures) > 0 {
+		fmt.Printf("%s failed in %d repositories: %s\n", hook, len(failures), strings.Join(failures, ", "))
+	}
+	return exitCode
+}
+
+// hookCommand returns the command running the hook in the repository: the
+// installed hook, or the pre-commit framework if the repository is
+// configured for it but the hook is not installed. It is nil if there is
+// neither.
+func hookCommand(repo string, hook string, allFiles bool) ([]string, error) {
+	path, err := getHookPath(repo, hook)
+	if err != nil {
+		return nil, err
+	}
+	if info, err := os.Stat(path); err == nil && !info.IsDir() && info.Mode()&0o111 != 0 {
+		return []string{path}, nil
+	}
+
+	if _, err := os.Stat(filepath.Join(repo, ".pre-commit-config.yaml")); err != nil {
+		return nil, nil
+	}
+	if _, err := exec.LookPath("pre-commit"); err != nil {
+		return nil, fmt.Errorf("has a .pre-commit-config.yaml but %v", err)
+	}
+	command := []string{"pre-commit", "run", "--hook-stage", hook}
+	if allFiles {
+		command = append(command, "--all-files")
+	}
+	return command, nil
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+	"golang.org/x/text/message/catalog"
+)
+
+// localeFiles are the translations shipped with gits, a JSON object for
+// each language mapping the English messages to their translation.
+//
+//go:embed locales/*.json
+var localeFiles embed.FS
+
+// printer formats the messages of reports in the language of the user, or
+// is nil for English, which is printed as it is written.
+var printer *message.Printer
+
+// locale is the language reports are printed in.
+var locale = language.English
+
+// dateLayouts are how dates are written in reports in each language, and
+// as in ISO 8601 in the others.
+var dateLayouts = map[string]string{
+	"de": "02.01.2006",
+	"es": "02/01/2006",
+	"fr": "02/01/2006",
+	"it": "02/01/2006",
+	"nl": "02-01-2006",
+	"pt": "02/01/2006",
+}
+
+// detectLocale returns the language of the user from -lang or, as for
+// gettext, $LC_ALL, $LC_MESSAGES or $LANG, such as de_DE.UTF-8.
+func detectLocale(lang string) string {
+	for _, v := range []string{lang, os.Getenv("LC_ALL"), os.Getenv("LC_MESSAGES"), os.Getenv("LANG")} {
+		if v != "" {
+			v, _, _ = strings.Cut(v, ".")
+			v, _, _ = strings.Cut(v, "@")
+			if v == "C" || v == "POSIX" {
+				return "en"
+			}
+			return strings.ReplaceAll(v, "_", "-")
+		}
+	}
+	return "en"
+}
+
+// setLocale selects the language of reports, reading the translations
+// shipped with gits and those in the locales directory of the gits
+// configuration directory, which take precedence.
+func setLocale(lang string) error {
+	tag, err := language.Parse(lang)
+	if err != nil {
+		return fmt.Errorf("unknown language `%s`", lang)
+	}
+	locale = tag
+	if base, _ := tag.Base(); base.String() == "en" {
+		printer = nil
+		return nil
+	}
+
+	b := catalog.NewBuilder(catalog.Fallback(language.English))
+	entries, _ := localeFiles.ReadDir("locales")
+	for _, e := range entries {
+		data, _ := localeFiles.ReadFile("locales/" + e.Name())
+		if err := addTranslations(b, e.Name(), data); err != nil {
+			return err
+		}
+	}
+	if p, err := configPath(); err == nil {
+		files, _ := filepath.Glob(filepath.Join(filepath.Dir(p), "locales", "*.json"))
+		for _, file := range files {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				return err
+			}
+			if err := addTranslations(b, filepath.Base(file), data); err != nil {
+				return fmt.Errorf("%s: %v", file, err)
+			}
+		}
+	}
+	printer = message.NewPrinter(tag, message.Catalog(b))
+	return nil
+}
+
+// addTranslations adds the translations of a file named after its
+// language, such as de.json, to the catalog.
+func addTranslations(b *catalog.Builder, name string, data []byte) error {
+	tag, err := language.Parse(strings.TrimSuffix(name, ".json"))
+	if err != nil {
+		return fmt.Errorf("%s is not named after a language", name)
+	}
+	var messages map[string]string
+	if err := json.Unmarshal(data, &messages); err != nil {
+		return err
+	}
+	for key, msg := range messages {
+		if err := b.SetString(tag, key, msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tr formats a message of a report in the language of the user, with the
+// numbers written the way it writes them.
+func tr(format string, args ...any) string {
+	if printer == nil {
+		return fmt.Sprintf(format, args...)
+	}
+	return printer.Sprintf(format, args...)
+}
+
+// formatDate writes the date the way the language of the user does.
+func formatDate(t time.Time) string {
+	base, _ := locale.Base()
+	if layout, ok := dateLayouts[base.String()]; ok {
+		return t.Format(layout)
+	}
+	return t.Format("2006-01-02")
+}
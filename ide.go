@@ -0,0 +1,232 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+func init() {
+	registerSubcommand(&subcommand{
+		name:    "workspace",
+		summary: "generate a VS Code multi-root workspace or an IntelliJ IDEA project of the repositories, see `gits workspace vscode` and `gits workspace idea`",
+		run:     runWorkspace,
+	})
+}
+
+func runWorkspace(ws *workspace, args []string) int {
+	if len(args) > 0 {
+		switch args[0] {
+		case "vscode":
+			return runWorkspaceVSCode(ws, args[1:])
+		case "idea":
+			return runWorkspaceIDEA(ws, args[1:])
+		}
+	}
+	fmt.Println("Usage: gits [options] workspace vscode|idea [workspace options]")
+	return 1
+}
+
+// ideRepo is a repository of an editor workspace, in the group it is shown
+// under.
+type ideRepo struct {
+	path    string
+	relPath string
+	// group is the first manifest group of the repository, or ""
+	group string
+}
+
+// ideRepos returns the repositories in the order the editors show them,
+// by group and then by path, those without a group last.
+func ideRepos(ws *workspace) ([]ideRepo, error) {
+	m, err := findManifest(ws.root)
+	if err != nil {
+		return nil, err
+	}
+	repos := make([]ideRepo, 0, len(ws.repos))
+	for _, path := range ws.repos {
+		r := ideRepo{path: path, relPath: relativePath(ws.root, path)}
+		if m != nil {
+			if groups := m.groupsOf(path); len(groups) > 0 {
+				r.group = groups[0]
+			}
+		}
+		repos = append(repos, r)
+	}
+	slices.SortStableFunc(repos, func(a, b ideRepo) int {
+		switch {
+		case a.group == b.group:
+			return compareNames(a.relPath, b.relPath)
+		case a.group == "":
+			return 1
+		case b.group == "":
+			return -1
+		}
+		return strings.Compare(a.group, b.group)
+	})
+	return repos, nil
+}
+
+// vscodeWorkspace is a .code-workspace file.
+type vscodeWorkspace struct {
+	Folders  []vscodeFolder `json:"folders"`
+	Settings struct{}       `json:"settings"`
+}
+
+type vscodeFolder struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+}
+
+func runWorkspaceVSCode(ws *workspace, args []string) int {
+	fs := newSubcommandFlags("workspace vscode", "")
+	base := fs.String("relative-to", ".", "the directory the workspace file is saved in, which the folder paths are relative to")
+	absolute := fs.Bool("absolute", false, "use absolute folder paths, for a workspace file that can be moved")
+	fs.Parse(args)
+
+	repos, err := ideRepos(ws)
+	if err != nil {
+		fmt.Println("Error reading manifest:", err)
+		return 1
+	}
+	dir, err := filepath.Abs(*base)
+	if err != nil {
+		fmt.Println("Invalid -relative-to:", err)
+		return 1
+	}
+
+	w := vscodeWorkspace{Folders: []vscodeFolder{}}
+	for _, r := range repos {
+		path := r.path
+		if !*absolute {
+			if rel, err := filepath.Rel(dir, r.path); err == nil {
+				path = filepath.ToSlash(rel)
+			}
+		}
+		// VS Code has no folder groups, so the group leads the name
+		name := r.relPath
+		if r.group != "" {
+			name = r.group + " · " + r.relPath
+		}
+		w.Folders = append(w.Folders, vscodeFolder{Name: name, Path: path})
+	}
+	data, _ := json.MarshalIndent(w, "", "  ")
+	fmt.Println(string(data))
+	return 0
+}
+
+// ideaModules is the .idea/modules.xml of an IntelliJ IDEA project.
+type ideaModules struct {
+	XMLName   xml.Name `xml:"project"`
+	Version   string   `xml:"version,attr"`
+	Component struct {
+		Name    string       `xml:"name,attr"`
+		Modules []ideaModule `xml:"modules>module"`
+	} `xml:"component"`
+}
+
+type ideaModule struct {
+	FileURL  string `xml:"fileurl,attr"`
+	FilePath string `xml:"filepath,attr"`
+	Group    string `xml:"group,attr,omitempty"`
+}
+
+// ideaVCS is the .idea/vcs.xml of an IntelliJ IDEA project.
+type ideaVCS struct {
+	XMLName   xml.Name `xml:"project"`
+	Version   string   `xml:"version,attr"`
+	Component struct {
+		Name     string           `xml:"name,attr"`
+		Mappings []ideaVCSMapping `xml:"mapping"`
+	} `xml:"component"`
+}
+
+type ideaVCSMapping struct {
+	Directory string `xml:"directory,attr"`
+	VCS       string `xml:"vcs,attr"`
+}
+
+// ideaModuleFile is a module of the repository, kept in .idea/modules so the
+// repositories themselves are left alone.
+const ideaModuleFile = `<?xml version="1.0" encoding="UTF-8"?>
+<module type="GENERAL_MODULE" version="4">
+  <component name="NewModuleRootManager" inherit-compiler-output="true">
+    <exclude-output />
+    <content url="file://$MODULE_DIR$/%s" />
+    <orderEntry type="sourceFolder" forTests="false" />
+  </component>
+</module>
+`
+
+func runWorkspaceIDEA(ws *workspace, args []string) int {
+	fs := newSubcommandFlags("workspace idea", "")
+	dir := fs.String("dir", ".", "the directory of the project, whose .idea directory is written")
+	fs.Parse(args)
+
+	repos, err := ideRepos(ws)
+	if err != nil {
+		fmt.Println("Error reading manifest:", err)
+		return 1
+	}
+	projectDir, err := filepath.Abs(*dir)
+	if err != nil {
+		fmt.Println("Invalid -dir:", err)
+		return 1
+	}
+	ideaDir := filepath.Join(projectDir, ".idea")
+	if err := os.MkdirAll(filepath.Join(ideaDir, "modules"), 0o755); err != nil {
+		fmt.Println("Error creating the project:", err)
+		return 1
+	}
+
+	var modules ideaModules
+	modules.Version = "4"
+	modules.Component.Name = "ProjectModuleManager"
+	var vcs ideaVCS
+	vcs.Version = "4"
+	vcs.Component.Name = "VcsDirectoryMappings"
+	used := make(map[string]bool)
+	for _, r := range repos {
+		// module names must be unique, and repositories in different
+		// directories can share a name
+		name := filepath.Base(r.path)
+		for i := 2; used[name]; i++ {
+			name = fmt.Sprintf("%s-%d", filepath.Base(r.path), i)
+		}
+		used[name] = true
+
+		content, err := filepath.Rel(filepath.Join(ideaDir, "modules"), r.path)
+		if err != nil {
+			content = r.path
+		}
+		file := filepath.Join(ideaDir, "modules", name+".iml")
+		if err := os.WriteFile(file, []byte(fmt.Sprintf(ideaModuleFile, filepath.ToSlash(content))), 0o644); err != nil {
+			fmt.Println("Error writing the project:", err)
+			return 1
+		}
+		moduleURL := "$PROJECT_DIR$/.idea/modules/" + name + ".iml"
+		modules.Component.Modules = append(modules.Component.Modules, ideaModule{FileURL: "file://" + moduleURL, FilePath: moduleURL, Group: r.group})
+
+		if detectVCS(r.path) == vcsGit {
+			dir := r.path
+			if rel, err := filepath.Rel(projectDir, r.path); err == nil {
+				dir = strings.TrimSuffix("$PROJECT_DIR$/"+filepath.ToSlash(rel), "/.")
+			}
+			vcs.Component.Mappings = append(vcs.Component.Mappings, ideaVCSMapping{Directory: dir, VCS: "Git"})
+		}
+	}
+
+	for name, v := range map[string]any{"modules.xml": modules, "vcs.xml": vcs} {
+		data, _ := xml.MarshalIndent(v, "", "  ")
+		if err := os.WriteFile(filepath.Join(ideaDir, name), append([]byte(xml.Header), append(data, '\n')...), 0o644); err != nil {
+			fmt.Println("Error writing the project:", err)
+			return 1
+		}
+	}
+	fmt.Printf("Wrote the project of %d repositories to %s\n", len(repos), ideaDir)
+	return 0
+}
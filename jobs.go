@@ -0,0 +1,206 @@
+//go:build !noserve
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+func init() {
+	registerSubcommand(&subcommand{
+		name:    "jobs",
+		summary: "show the scheduled jobs of a `gits serve` daemon and their last results",
+		run:     runJobs,
+
+		standalone: true,
+	})
+}
+
+// serverURL returns the base URL of the daemon, from the configuration unless given.
+func serverURL(ws *workspace, server string) string {
+	if server == "" {
+		server = ws.config.Serve.Listen
+	}
+	if server == "" {
+		server = defaultServeAddress
+	}
+	if !strings.Contains(server, "://") {
+		server = "http://" + server
+	}
+	return strings.TrimSuffix(server, "/")
+}
+
+// daemonClient makes requests to a `gits serve` daemon.
+type daemonClient struct {
+	base   string
+	token  string
+	client *http.Client
+}
+
+func newDaemonClient(ws *workspace, server string, timeout time.Duration) *daemonClient {
+	return &daemonClient{
+		base:   serverURL(ws, server),
+		token:  ws.config.Serve.token(),
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+func (c *daemonClient) do(method string, path string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequest(method, c.base+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	return c.client.Do(req)
+}
+
+// getJSON fetches path and decodes the JSON response into v.
+func (c *daemonClient) getJSON(path string, v any) error {
+	resp, err := c.do(http.MethodGet, path, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		var e struct {
+			Error string `json:"error"`
+		}
+		json.NewDecoder(resp.Body).Decode(&e)
+		return fmt.Errorf("%s: %s", resp.Status, e.Error)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func runJobs(ws *workspace, args []string) int {
+	fs := newSubcommandFlags("jobs", " [job]")
+	server := fs.String("server", "", "address of the daemon (default: serve.listen from the configuration)")
+	run := fs.Bool("run", false, "start the job now instead of showing it")
+	fs.Parse(args)
+
+	client := newDaemonClient(ws, *server, 10*time.Second)
+
+	if fs.NArg() == 0 {
+		var jobs []jobSummary
+		if err := client.getJSON("/jobs", &jobs); err != nil {
+			fmt.Println("Error listing jobs:", err)
+			return 1
+		}
+		for _, job := range jobs {
+			printJobSummary(job)
+		}
+		return 0
+	}
+
+	name := fs.Arg(0)
+	if *run {
+		resp, err := client.do(http.MethodPost, "/jobs/"+name+"/run", nil)
+		if err != nil {
+			fmt.Println("Error starting job:", err)
+			return 1
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusAccepted {
+			fmt.Printf("Error starting job: %s\n", resp.Status)
+			return 1
+		}
+		fmt.Printf("Started %s\n", name)
+		return 0
+	}
+
+	var job jobSummary
+	if err := client.getJSON("/jobs/"+name, &job); err != nil {
+		fmt.Println("Error getting job:", err)
+		return 1
+	}
+	printJobSummary(job)
+	if job.LastRun == nil {
+		return 0
+	}
+
+	for _, r := range job.LastRun.Results {
+		if r.Status != nil {
+			line := fmt.Sprintf("  \033[1m%s\033[0m [%s] %s", r.RelPath, r.Status.Branch, r.Status.Sync)
+			if r.Status.Dirty {
+				line += " dirty"
+			}
+			fmt.Println(plain(line))
+			continue
+		}
+		status := "✅️"
+		if r.ExitCode != 0 {
+			status = "❌"
+		}
+		fmt.Println(plain(fmt.Sprintf("  \033[1m%s %s:\033[0m\n    %s", status, r.RelPath, strings.ReplaceAll(strings.TrimRight(r.Output, "\n"), "\n", "\n    "))))
+	}
+	if job.LastRun.Failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+func printJobSummary(job jobSummary) {
+	what := strings.Join(job.Command, " ")
+	if job.Status {
+		what = "status snapshot"
+	}
+
+	last := "never run"
+	if job.LastRun != nil {
+		last = fmt.Sprintf("last run %s ago, %d failed", formatAge(time.Since(job.LastRun.Finished)), job.LastRun.Failed)
+	}
+	if job.Running {
+		last = "running, " + last
+	}
+
+	fmt.Println(plain(fmt.Sprintf("\033[1m%s\033[0m every %s: %s (%s)", job.Name, job.Every, what, last)))
+}
+
+// daemonPromptInfo looks for the repository in the results of the daemon's
+// status jobs, returning nil if the daemon is not running or has no status.
+func daemonPromptInfo(ws *workspace, server string, path string) *promptInfo {
+	client := newDaemonClient(ws, server, 250*time.Millisecond)
+	var jobs []jobSummary
+	if err := client.getJSON("/jobs", &jobs); err != nil {
+		return nil
+	}
+
+	var newest *promptInfo
+	var newestTime time.Time
+	for _, summary := range jobs {
+		if !summary.Status || summary.LastRun == nil || !summary.LastRun.Finished.After(newestTime) {
+			continue
+		}
+		var job jobSummary
+		if err := client.getJSON("/jobs/"+summary.Name, &job); err != nil || job.LastRun == nil {
+			continue
+		}
+		for _, r := range job.LastRun.Results {
+			if r.Status != nil && samePath(filepath.Clean(r.Path), path) {
+				newest = &promptInfo{SchemaVersion: schemaVersion, Path: path, Branch: r.Status.Branch, Dirty: r.Status.Dirty, Sync: r.Status.Sync, Source: "daemon"}
+				newestTime = job.LastRun.Finished
+			}
+		}
+	}
+	return newest
+}
+
+// samePath reports whether the paths name the same file, ignoring case on
+// the filesystems of macOS and Windows, which do, but not on others.
+func samePath(a string, b string) bool {
+	if runtime.GOOS == "darwin" || runtime.GOOS == "windows" {
+		return strings.EqualFold(a, b)
+	}
+	return a == b
+}
@@ -0,0 +1,25 @@
+//go:build !noserve
+
+package main
+
+import (
+	"runtime"
+	"testing"
+)
+
+func TestSamePath(t *testing.T) {
+	foldsCase := runtime.GOOS == "darwin" || runtime.GOOS == "windows"
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"/src/api", "/src/api", true},
+		{"/src/api", "/src/web", false},
+		{"/src/API", "/src/api", foldsCase},
+	}
+	for _, tt := range tests {
+		if got := samePath(tt.a, tt.b); got != tt.want {
+			t.Errorf("samePath(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,386 @@
+//go:build !noforge
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+func init() {
+	registerSubcommand(&subcommand{
+		name:    "land",
+		summary: "merge the approved pull requests of the current branches one at a time, in dependency order, waiting for CI between them",
+		run:     runLand,
+	})
+}
+
+// ciState is how the checks of a commit went.
+type ciState string
+
+const (
+	ciSuccess ciState = "success"
+	ciPending ciState = "pending"
+	ciFailure ciState = "failure"
+	// ciNone is a commit without any checks
+	ciNone ciState = "none"
+)
+
+// landing is the pull request of a repository's current branch to land.
+type landing struct {
+	client *forgeClient
+	repo   forgeRepo
+	pr     pullRequest
+	// sha is the head commit of the pull request, merged only if it is
+	// still the head
+	sha      string
+	approved bool
+}
+
+func runLand(ws *workspace, args []string) int {
+	fs := newSubcommandFlags("land", "")
+	dryRun := fs.Bool("n", false, "only list the pull requests that would land and their checks")
+	method := fs.String("method", "merge", "how pull requests are merged: merge, squash or rebase")
+	poll := fs.Duration("poll", 30*time.Second, "how often to ask the forge how the checks are going")
+	stall := fs.Duration("stall", 30*time.Minute, "stop landing when the checks of a step have not finished after this long")
+	fs.Parse(args)
+
+	if !slices.Contains([]string{"merge", "squash", "rebase"}, *method) {
+		fmt.Printf("Invalid -method: %s is not merge, squash or rebase\n", *method)
+		return 1
+	}
+	if *poll <= 0 || *stall <= 0 {
+		fmt.Println("Invalid -poll or -stall: must be positive")
+		return 1
+	}
+
+	m, err := findManifest(ws.root)
+	if err != nil {
+		fmt.Println("Error reading manifest:", err)
+		return 1
+	}
+	repos := ws.repos
+	if m != nil {
+		if repos, _, err = m.dependencyOrder(ws.root, repos); err != nil {
+			fmt.Println("Error ordering the repositories:", err)
+			return 1
+		}
+	}
+
+	if !*dryRun {
+		release, ok := ws.lock("land")
+		if !ok {
+			return 1
+		}
+		defer release()
+	}
+
+	// the train stops at the first step that fails, as the repositories
+	// after it may need what it brings
+	exitCode := 0
+	steps := &stepMatrix{}
+	var stopped string
+	for _, repo := range repos {
+		relPath := relativePath(ws.root, repo)
+		if stopped != "" {
+			fmt.Println(plain(fmt.Sprintf("\033[1m⛔ %s:\033[0m not landed: %s", relPath, stopped)))
+			continue
+		}
+		var l *landing
+		err := steps.step(relPath, "find", func() (err error) {
+			l, err = findLanding(ws, repo)
+			return err
+		})
+		if err != nil {
+			fmt.Println(plain(fmt.Sprintf("\033[1m❌ %s:\033[0m %v", relPath, err)))
+			exitCode, stopped = 1, relPath+" failed"
+			continue
+		}
+		if l == nil {
+			// no pull request of its own, nothing for the rest to wait for
+			continue
+		}
+		approval := stepResult{Name: "approval"}
+		if !l.approved {
+			approval.ExitCode = 1
+		}
+		steps.add(relPath, approval)
+		if !l.approved {
+			fmt.Println(plain(fmt.Sprintf("\033[1m⏭️ %s:\033[0m #%d is not approved, %s", relPath, l.pr.Number, l.pr.URL)))
+			exitCode = 1
+			if !*dryRun {
+				stopped = relPath + " is not approved"
+			}
+			continue
+		}
+		if *dryRun {
+			var state ciState
+			err := steps.step(relPath, "checks", func() (err error) {
+				state, err = l.client.ciState(l.repo, l.sha)
+				return err
+			})
+			if err != nil {
+				fmt.Println(plain(fmt.Sprintf("\033[1m❌ %s:\033[0m #%d: %v", relPath, l.pr.Number, err)))
+				exitCode = 1
+				continue
+			}
+			fmt.Println(plain(fmt.Sprintf("\033[1m✅️ %s:\033[0m would land #%d %s, checks %s", relPath, l.pr.Number, l.pr.Title, state)))
+			continue
+		}
+
+		err = steps.step(relPath, "checks", func() error {
+			return waitForCI(l, relPath, "#"+fmt.Sprint(l.pr.Number), l.sha, *poll, *stall)
+		})
+		if err != nil {
+			fmt.Println(plain(fmt.Sprintf("\033[1m❌ %s:\033[0m #%d: %v", relPath, l.pr.Number, err)))
+			exitCode, stopped = 1, relPath+" did not land"
+			continue
+		}
+		var merged string
+		err = steps.step(relPath, "merge", func() (err error) {
+			merged, err = l.client.mergePullRequest(l.repo, l.pr.Number, l.sha, *method)
+			return err
+		})
+		if err != nil {
+			fmt.Println(plain(fmt.Sprintf("\033[1m❌ %s:\033[0m merging #%d: %v", relPath, l.pr.Number, err)))
+			exitCode, stopped = 1, relPath+" did not land"
+			continue
+		}
+		// the repositories after it are built against what was merged
+		err = steps.step(relPath, "checks after merge", func() error {
+			return waitForCI(l, relPath, "the merge of #"+fmt.Sprint(l.pr.Number), merged, *poll, *stall)
+		})
+		if err != nil {
+			fmt.Println(plain(fmt.Sprintf("\033[1m❌ %s:\033[0m merged #%d, but %v", relPath, l.pr.Number, err)))
+			exitCode, stopped = 1, relPath+" is broken after merging"
+			continue
+		}
+		fmt.Println(plain(fmt.Sprintf("\033[1m✅️ %s:\033[0m landed #%d %s", relPath, l.pr.Number, l.pr.Title)))
+	}
+	// which step of the train each repository failed at
+	if steps.failed() {
+		steps.write(os.Stdout)
+	}
+	return exitCode
+}
+
+// findLanding finds the open pull request of the current branch of the
+// repository, or returns nil if there is none.
+func findLanding(ws *workspace, repo string) (*landing, error) {
+	branch, err := getCurrentBranch(repo)
+	if err != nil {
+		return nil, err
+	}
+	if def, _ := getDefaultBranch(repo); branch == "" || branch == def {
+		return nil, nil
+	}
+	client, fr, err := forgeClientFor(ws, repo)
+	if err != nil {
+		return nil, err
+	}
+	return client.branchPullRequest(fr, branch)
+}
+
+// waitForCI polls the checks of the commit until they finish, failing if
+// they fail or take longer than the stall.
+func waitForCI(l *landing, relPath string, what string, sha string, poll time.Duration, stall time.Duration) error {
+	start := time.Now()
+	waiting := false
+	for {
+		state, err := l.client.ciState(l.repo, sha)
+		if err != nil {
+			return err
+		}
+		switch state {
+		case ciSuccess, ciNone:
+			return nil
+		case ciFailure:
+			return fmt.Errorf("the checks of %s failed", what)
+		}
+		if time.Since(start) >= stall {
+			return fmt.Errorf("stalled, the checks of %s have not finished after %s", what, stall)
+		}
+		if !waiting {
+			fmt.Println(plain(fmt.Sprintf("\033[1m⏳ %s:\033[0m waiting for the checks of %s", relPath, what)))
+			waiting = true
+		}
+		time.Sleep(poll)
+	}
+}
+
+// branchPullRequest returns the open pull request of the branch, whether it
+// is approved, or nil if there is none.
+func (c *forgeClient) branchPullRequest(r forgeRepo, branch string) (*landing, error) {
+	if c.kind == forgeGitLab {
+		var mrs []struct {
+			IID    int    `json:"iid"`
+			Title  string `json:"title"`
+			WebURL string `json:"web_url"`
+			SHA    string `json:"sha"`
+		}
+		query := url.Values{"state": {"opened"}, "source_branch": {branch}}
+		if err := c.get(c.repoPath(r)+"/merge_requests?"+query.Encode(), &mrs); err != nil {
+			return nil, err
+		}
+		if len(mrs) == 0 {
+			return nil, nil
+		}
+		mr := mrs[0]
+		var approvals struct {
+			Approved bool `json:"approved"`
+		}
+		if err := c.get(fmt.Sprintf("%s/merge_requests/%d/approvals", c.repoPath(r), mr.IID), &approvals); err != nil {
+			return nil, err
+		}
+		return &landing{client: c, repo: r, pr: pullRequest{Host: r.host, Repo: r.path, Number: mr.IID, Title: mr.Title, URL: mr.WebURL}, sha: mr.SHA, approved: approvals.Approved}, nil
+	}
+
+	var prs []struct {
+		Number  int    `json:"number"`
+		Title   string `json:"title"`
+		HTMLURL string `json:"html_url"`
+		Head    struct {
+			SHA string `json:"sha"`
+		} `json:"head"`
+	}
+	query := url.Values{"state": {"open"}, "head": {r.owner() + ":" + branch}}
+	if err := c.get(c.repoPath(r)+"/pulls?"+query.Encode(), &prs); err != nil {
+		return nil, err
+	}
+	if len(prs) == 0 {
+		return nil, nil
+	}
+	pr := prs[0]
+	var reviews []struct {
+		User struct {
+			Login string `json:"login"`
+		} `json:"user"`
+		State string `json:"state"`
+	}
+	if err := c.get(fmt.Sprintf("%s/pulls/%d/reviews?per_page=100", c.repoPath(r), pr.Number), &reviews); err != nil {
+		return nil, err
+	}
+	// the latest review of each reviewer counts, comments aside
+	latest := make(map[string]string)
+	for _, review := range reviews {
+		if review.State == "APPROVED" || review.State == "CHANGES_REQUESTED" || review.State == "DISMISSED" {
+			latest[review.User.Login] = review.State
+		}
+	}
+	approved := false
+	for _, state := range latest {
+		if state == "CHANGES_REQUESTED" {
+			approved = false
+			break
+		}
+		approved = approved || state == "APPROVED"
+	}
+	return &landing{client: c, repo: r, pr: pullRequest{Host: r.host, Repo: r.path, Number: pr.Number, Title: pr.Title, URL: pr.HTMLURL}, sha: pr.Head.SHA, approved: approved}, nil
+}
+
+// ciState returns how the checks of the commit are going: the pipeline on
+// GitLab, and the commit statuses and check runs on GitHub.
+func (c *forgeClient) ciState(r forgeRepo, sha string) (ciState, error) {
+	if c.kind == forgeGitLab {
+		var commit struct {
+			LastPipeline *struct {
+				Status string `json:"status"`
+			} `json:"last_pipeline"`
+		}
+		if err := c.get(c.repoPath(r)+"/repository/commits/"+sha, &commit); err != nil {
+			return "", err
+		}
+		if commit.LastPipeline == nil {
+			return ciNone, nil
+		}
+		switch commit.LastPipeline.Status {
+		case "success":
+			return ciSuccess, nil
+		case "failed", "canceled":
+			return ciFailure, nil
+		case "skipped":
+			return ciNone, nil
+		}
+		return ciPending, nil
+	}
+
+	var status struct {
+		State      string `json:"state"`
+		TotalCount int    `json:"total_count"`
+	}
+	if err := c.get(c.repoPath(r)+"/commits/"+sha+"/status", &status); err != nil {
+		return "", err
+	}
+	var checks struct {
+		TotalCount int `json:"total_count"`
+		CheckRuns  []struct {
+			Status     string `json:"status"`
+			Conclusion string `json:"conclusion"`
+		} `json:"check_runs"`
+	}
+	if err := c.get(c.repoPath(r)+"/commits/"+sha+"/check-runs?per_page=100", &checks); err != nil {
+		return "", err
+	}
+	if status.TotalCount == 0 && checks.TotalCount == 0 {
+		return ciNone, nil
+	}
+	state := ciSuccess
+	switch status.State {
+	case "failure", "error":
+		return ciFailure, nil
+	case "pending":
+		if status.TotalCount > 0 {
+			state = ciPending
+		}
+	}
+	for _, run := range checks.CheckRuns {
+		switch {
+		case run.Status != "completed":
+			state = ciPending
+		case run.Conclusion == "failure" || run.Conclusion == "timed_out" || run.Conclusion == "cancelled" || run.Conclusion == "action_required":
+			return ciFailure, nil
+		}
+	}
+	return state, nil
+}
+
+// mergePullRequest merges the pull request if its head is still the commit,
+// returning the commit it was merged as.
+func (c *forgeClient) mergePullRequest(r forgeRepo, number int, sha string, method string) (string, error) {
+	if c.kind == forgeGitLab {
+		var mr struct {
+			MergeCommitSHA  string `json:"merge_commit_sha"`
+			SquashCommitSHA string `json:"squash_commit_sha"`
+			SHA             string `json:"sha"`
+		}
+		body := map[string]any{"sha": sha, "squash": method == "squash"}
+		if err := c.do(http.MethodPut, fmt.Sprintf("%s/merge_requests/%d/merge", c.repoPath(r), number), body, &mr); err != nil {
+			return "", err
+		}
+		// fast forward merges leave the head as it was
+		for _, merged := range []string{mr.MergeCommitSHA, mr.SquashCommitSHA, mr.SHA} {
+			if merged != "" {
+				return merged, nil
+			}
+		}
+		return sha, nil
+	}
+
+	var result struct {
+		SHA    string `json:"sha"`
+		Merged bool   `json:"merged"`
+	}
+	body := map[string]any{"sha": sha, "merge_method": method}
+	if err := c.do(http.MethodPut, fmt.Sprintf("%s/pulls/%d/merge", c.repoPath(r), number), body, &result); err != nil {
+		return "", err
+	}
+	if !result.Merged {
+		return "", fmt.Errorf("not merged")
+	}
+	return strings.TrimSpace(result.SHA), nil
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// cleanups are run before gits exits, whether it returns, calls exit, panics
+// or is interrupted, so it does not leave the terminal with a half drawn
+// progress line or the workspace lock with a stale description.
+var cleanups struct {
+	sync.Mutex
+	next  int
+	funcs map[int]func()
+}
+
+// atExit registers f to be run once before gits exits, in the reverse order
+// of registration, returning how to run it early and unregister it.
+func atExit(f func()) func() {
+	cleanups.Lock()
+	defer cleanups.Unlock()
+	if cleanups.funcs == nil {
+		cleanups.funcs = make(map[int]func())
+	}
+	id := cleanups.next
+	cleanups.next++
+	cleanups.funcs[id] = f
+	return func() {
+		cleanups.Lock()
+		f, ok := cleanups.funcs[id]
+		delete(cleanups.funcs, id)
+		cleanups.Unlock()
+		if ok {
+			f()
+		}
+	}
+}
+
+// runCleanups runs the registered cleanups, latest first.
+func runCleanups() {
+	cleanups.Lock()
+	funcs := cleanups.funcs
+	cleanups.funcs = nil
+	next := cleanups.next
+	cleanups.Unlock()
+	for id := next - 1; id >= 0; id-- {
+		if f, ok := funcs[id]; ok {
+			f()
+		}
+	}
+}
+
+// exit runs the cleanups and exits, as deferred calls do not run on os.Exit.
+func exit(code int) {
+	runCleanups()
+	os.Exit(code)
+}
+
+// cleanupOnPanic runs the cleanups before a panic ends gits, deferred at the
+// top of goroutines, as a panic in one does not run the deferred calls of
+// the others.
+func cleanupOnPanic() {
+	if r := recover(); r != nil {
+		runCleanups()
+		panic(r)
+	}
+}
+
+// clearProgress clears the progress line.
+const clearProgress = "\r                      \r"
+
+// progressLine draws the progress of a run on the terminal until it is
+// stopped.
+type progressLine struct {
+	// output guards the terminal between the ticker and the results
+	output *sync.Mutex
+	ticker *time.Ticker
+	done   chan struct{}
+	// stopped is closed once the ticker goroutine has returned
+	stopped chan struct{}
+	// clear clears the line and unregisters it as a cleanup
+	clear func()
+}
+
+// startProgressLine starts drawing the progress, completed out of total, once
+// a second. Interrupting gits while it is drawn clears it before exiting.
+func startProgressLine(output *sync.Mutex, completed func() int32, total int) *progressLine {
+	p := &progressLine{
+		output:  output,
+		ticker:  time.NewTicker(1 * time.Second),
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	p.clear = atExit(func() { fmt.Print(clearProgress) })
+
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		defer close(p.stopped)
+		defer signal.Stop(interrupts)
+		dots := "."
+		for {
+			select {
+			case <-p.done:
+				return
+			case sig := <-interrupts:
+				// the commands were interrupted too, as they share the terminal
+				output.Lock()
+				code := 130
+				if sig == syscall.SIGTERM {
+					code = 143
+				}
+				exit(code)
+			case <-p.ticker.C:
+				output.Lock()
+				fmt.Printf("\r⚡️ %d/%d %s   \b\b\b", completed(), total, dots)
+				output.Unlock()
+				dots = dots + "."
+				if len(dots) > 3 {
+					dots = "."
+				}
+			}
+		}
+	}()
+	return p
+}
+
+// stop stops drawing the progress, waiting for the ticker goroutine to
+// return, and clears the line.
+func (p *progressLine) stop() {
+	p.ticker.Stop()
+	close(p.done)
+	<-p.stopped
+	p.output.Lock()
+	p.clear()
+	p.output.Unlock()
+}
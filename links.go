@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// linkOutput is set when the results are printed to a terminal that
+// supports OSC 8 hyperlinks, to link repository names to their forge pages
+// and commits to their commit pages.
+var linkOutput bool
+
+// supportsHyperlinks reports whether the terminal is known to support OSC 8
+// hyperlinks, as there is no way to ask it. $FORCE_HYPERLINK set to 1 or 0
+// overrides this.
+func supportsHyperlinks() bool {
+	if force, ok := os.LookupEnv("FORCE_HYPERLINK"); ok {
+		return force != "0"
+	}
+	if os.Getenv("WT_SESSION") != "" || os.Getenv("KONSOLE_VERSION") != "" || os.Getenv("DOMTERM") != "" {
+		return true
+	}
+	switch os.Getenv("TERM_PROGRAM") {
+	case "iTerm.app", "WezTerm", "vscode", "ghostty", "Hyper":
+		return true
+	}
+	if vte, err := strconv.Atoi(os.Getenv("VTE_VERSION")); err == nil && vte >= 5000 {
+		return true
+	}
+	switch os.Getenv("TERM") {
+	case "xterm-kitty", "alacritty", "foot", "xterm-ghostty":
+		return true
+	}
+	return false
+}
+
+// hyperlink renders the text as an OSC 8 link to the URL with linkOutput,
+// and as the text alone otherwise.
+func hyperlink(url string, text string) string {
+	if url == "" || !linkOutput {
+		return text
+	}
+	return "\033]8;;" + url + "\033\\" + text + "\033]8;;\033\\"
+}
+
+// webURL returns the web page of the forge repository.
+func (r forgeRepo) webURL() string {
+	return "https://" + r.host + "/" + r.path
+}
+
+// commitURL returns the web page of the commit in the forge repository.
+func (r forgeRepo) commitURL(hash string) string {
+	switch {
+	case strings.Contains(r.host, "gitlab"):
+		return r.webURL() + "/-/commit/" + hash
+	case strings.Contains(r.host, "bitbucket"):
+		return r.webURL() + "/commits/" + hash
+	default:
+		return r.webURL() + "/commit/" + hash
+	}
+}
+
+// originForge returns the forge repository of the origin of the repository.
+func originForge(path string) (forgeRepo, bool) {
+	origin, err := getConfigValue(path, "remote.origin.url")
+	if err != nil || origin == "" {
+		return forgeRepo{}, false
+	}
+	return parseRemoteURL(origin)
+}
+
+// repoLink links the text, usually the path of the repository, to the
+// forge page of its origin with linkOutput.
+func repoLink(path string, text string) string {
+	if !linkOutput {
+		return text
+	}
+	if fr, ok := originForge(path); ok {
+		return hyperlink(fr.webURL(), text)
+	}
+	return text
+}
+
+// commitLink renders the commit abbreviated to 7 characters, linked to its
+// forge page with linkOutput.
+func commitLink(path string, hash string) string {
+	short := fmt.Sprintf("%.7s", hash)
+	if !linkOutput {
+		return short
+	}
+	if fr, ok := originForge(path); ok {
+		return hyperlink(fr.commitURL(hash), short)
+	}
+	return short
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// readOnlyGitCommands are git subcommands that never modify a repository, so
+// running them does not need the workspace lock.
+var readOnlyGitCommands = []string{
+	"blame", "cat-file", "describe", "diff", "for-each-ref", "grep", "log", "ls-files",
+	"ls-remote", "ls-tree", "rev-list", "rev-parse", "shortlog", "show", "show-ref", "status",
+}
+
+// isReadOnlyCommand reports whether the command is a git command known not to
+// modify the repository.
+func isReadOnlyCommand(command []string) bool {
+	if len(command) == 0 || filepath.Base(command[0]) != "git" {
+		return false
+	}
+	for i := 1; i < len(command); i++ {
+		arg := command[i]
+		if arg == "-C" || arg == "-c" {
+			// options taking a value
+			i++
+			continue
+		}
+		if !strings.HasPrefix(arg, "-") {
+			return slices.Contains(readOnlyGitCommands, arg)
+		}
+	}
+	return false
+}
+
+// workspaceLock is an advisory lock preventing concurrent gits runs from
+// modifying the repositories under the same root.
+type workspaceLock struct {
+	f *os.File
+}
+
+func workspaceLockPath(root string) (string, error) {
+	dir, err := cacheDir()
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(root))
+	return filepath.Join(dir, "locks", hex.EncodeToString(sum[:8])+".lock"), nil
+}
+
+// acquireWorkspaceLock takes the lock for root, waiting up to timeout for
+// another run to release it.
+func acquireWorkspaceLock(root string, timeout time.Duration, description string) (*workspaceLock, error) {
+	p, err := workspaceLockPath(root)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o700); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(p, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		ok, err := tryLockFile(f)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		if ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			holder, _ := os.ReadFile(p)
+			f.Close()
+			return nil, fmt.Errorf("another gits run is modifying %s (%s)", root, strings.TrimSpace(string(holder)))
+		}
+		time.Sleep(250 * time.Millisecond)
+	}
+
+	f.Truncate(0)
+	f.WriteAt([]byte(fmt.Sprintf("pid %d since %s: %s\n", os.Getpid(), time.Now().Format(time.TimeOnly), description)), 0)
+	return &workspaceLock{f: f}, nil
+}
+
+func (l *workspaceLock) release() {
+	if l == nil {
+		return
+	}
+	l.f.Truncate(0)
+	unlockFile(l.f)
+	l.f.Close()
+}
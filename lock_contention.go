@@ -0,0 +1,33 @@
+package main
+
+import (
+	"regexp"
+	"time"
+)
+
+// lockRetries is how many times to retry a git command that failed because
+// another process, such as the background refresh of an IDE, held a lock
+// file like index.lock, set with -lock-retries.
+var lockRetries = 3
+
+// lockContention matches git failing to take a lock another process holds,
+// such as "fatal: Unable to create '/src/api/.git/index.lock': File exists."
+var lockContention = regexp.MustCompile(`Unable to create '[^']+\.lock': File exists`)
+
+// lockBackoff is how long to wait before retrying a command that found a
+// lock file, doubling with each attempt from a quarter of a second up to two
+// seconds, as the other process usually holds it for moments.
+func lockBackoff(attempt int) time.Duration {
+	return min(250*time.Millisecond<<attempt, 2*time.Second)
+}
+
+// retryLockContention runs a command, running it again after a backoff up
+// to lockRetries times while it fails on a lock file another process holds.
+func retryLockContention[T any](run func() (string, int, T)) (string, int, T) {
+	output, exitCode, extra := run()
+	for attempt := 0; exitCode != 0 && attempt < lockRetries && lockContention.MatchString(output); attempt++ {
+		time.Sleep(lockBackoff(attempt))
+		output, exitCode, extra = run()
+	}
+	return output, exitCode, extra
+}
@@ -1,378 +1,772 @@
 package main
 
 import (
-	"bytes"
+	"cmp"
+	"errors"
 	"flag"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"regexp"
 	"runtime"
 	"slices"
-	"sort"
-	"strconv"
 	"strings"
-	"sync"
+	"text/template"
 	"time"
 )
 
-func isGitRepo(path string) bool {
-	gitDir := filepath.Join(path, ".git")
-	info, err := os.Stat(gitDir)
-	return err == nil && info.IsDir()
-}
-
-func getCurrentBranch(path string) (string, error) {
-	cmd := exec.Command("git", "-C", path, "rev-parse", "--abbrev-ref", "HEAD")
-	out, err := cmd.Output()
+func main() {
+	defer cleanupOnPanic()
+	parallel := flag.Int("parallel", runtime.NumCPU(), "number of parallel tasks, or 0 to adapt it to the latency and failures of the tasks and the load of the machine")
+	var fopts filterOptions
+	flag.StringVar(&fopts.Branch, "branch", "", "only match repositories on this branch")
+	flag.BoolVar(&fopts.Dirty, "dirty", false, "only match repositories with a dirty worktree")
+	flag.BoolVar(&fopts.Clean, "clean", false, "only match repositories with a clean worktree")
+	flag.StringVar(&fopts.Remote, "remote", "", "only match repositories whose origin matches this glob, as host/path such as 'github.com/acme/*' or as the whole URL")
+	flag.StringVar(&fopts.HasBranch, "has-branch", "", "only match repositories with this local branch")
+	flag.BoolVar(&fopts.Ahead, "ahead", false, "only match repositories with commits their upstream does not have")
+	flag.BoolVar(&fopts.Behind, "behind", false, "only match repositories missing commits of their upstream")
+	flag.StringVar(&fopts.Stale, "stale", "", "only match repositories whose HEAD was committed longer ago than this age, e.g. 90d")
+	flag.StringVar(&fopts.Bridge, "bridge", "", "only match git-svn bridge repositories with svn, hg-git ones with hg, or ordinary git repositories with none")
+	flag.BoolVar(&fopts.NeedsGC, "needs-gc", false, "only match repositories with many loose objects or packs, or garbage, that git gc would clean up")
+	help := flag.Bool("help", false, "display help message")
+	status := flag.Bool("status", false, "display a summary of branch statuses and exit")
+	lang := flag.String("lang", "", "the language of reports and summaries, such as de or fr-CA (default: from $LC_ALL, $LC_MESSAGES or $LANG)")
+	a11y := flag.Bool("a11y", false, "print plain linear text for screen readers, without colors, emoji, the progress line or padding, and the status of each repository as a sentence")
+	tuiMode := flag.Bool("tui", false, "show the status of the repositories live in an interactive table, to fuzzy filter and select repositories and run commands in them")
+	fetch := flag.Bool("fetch", false, "with -status, fetch each repository first so the ahead and behind counts are up to date")
+	showDiff := &optionalValue{bare: diffStat}
+	flag.Var(showDiff, "show-diff", "with -status, show a diffstat of the uncommitted changes and the untracked files under each dirty repository, or the whole diff with -show-diff=full")
+	verbose := flag.Bool("v", false, "with -status, also show the object and pack counts")
+	columnsFlag := flag.String("columns", "", "with -status, the columns to show in a table and their order, such as repo,branch,ahead,behind,dirty,lastcommit (default: columns of the status configuration)")
+	var excludes []string
+	flag.Var((*stringList)(&excludes), "exclude", "skip repositories whose path relative to the root matches this glob, such as 'vendor/**', in addition to the exclude of the profile (may be repeated)")
+	rootDir := flag.String("root", "", "directory to search for repositories, paths are shown relative to it (default: current directory)")
+	var groups []string
+	flag.Var((*stringList)(&groups), "group", "only match repositories in this group of the manifest (may be repeated)")
+	includeSkipped := flag.Bool("include-skipped", false, "also match repositories marked to be skipped with a .gitsskip file or gits.skip git config")
+	bare := flag.Bool("bare", false, "also match bare repositories")
+	submodules := flag.Bool("submodules", false, "also match the initialized submodules of the repositories")
+	dryRun := flag.Bool("dry-run", false, "list the repositories the command would run in, after the filters, with the command for each, and exit")
+	confirmRun := flag.Bool("confirm", false, "list the repositories the command would run in and ask whether to run it in all of them, pick each one or abort")
+	flag.BoolVar(confirmRun, "i", false, "short for -confirm")
+	maxDepth := flag.Int("max-depth", 0, "only search this many directories below the root for repositories (default: no limit)")
+	followSymlinks := flag.Bool("follow-symlinks", false, "search the directories symbolic links point to")
+	discoveryCache := flag.Bool("discovery-cache", false, "use the repositories found under the root by an earlier search instead of searching it again")
+	refresh := flag.Bool("refresh", false, "search the root again and update the repositories cached for -discovery-cache")
+	unhealthy := flag.Bool("unhealthy", false, "only match repositories in a broken state, such as with a stale index.lock")
+	continueOnError := flag.Bool("continue-on-error", false, "report repositories where a filter fails as skipped instead of stopping")
+	format := flag.String("format", "", "print the results as json, an array of records, ndjson, a record per line, or plain text without colors, or with a Go template such as '{{.RelPath}}: {{.ExitCode}}'")
+	env := &environment{}
+	flag.BoolVar(&env.clear, "env-clear", false, "run commands with an empty environment apart from -env and GITS_* variables")
+	flag.BoolVar(&env.hermetic, "hermetic", false, "run commands with a minimal environment: "+strings.Join(hermeticEnv, ", "))
+	flag.Var((*stringList)(&env.pass), "env-pass", "name of an environment variable to also pass in -hermetic mode (may be repeated)")
+	flag.Var((*stringList)(&env.set), "env", "KEY=VALUE to set in the environment of commands (may be repeated)")
+	limits := &resourceLimits{}
+	flag.IntVar(&limits.nice, "nice", 0, "run commands with this niceness added, e.g. 10 to leave the machine responsive")
+	flag.StringVar(&limits.ionice, "ionice", "", "run commands in this I/O scheduling class: idle, best-effort or realtime, optionally with a level such as best-effort:7")
+	flag.StringVar(&limits.cpuQuota, "cpu-limit", "", "limit each command to this much CPU time, as a percentage of one CPU such as 200% for two, using systemd-run")
+	failFast := flag.Bool("fail-fast", false, "do not start the command in any more repositories once it fails in one")
+	gateOnFailure := flag.Bool("gate-on-failure", false, "run the command in each repository once it finished in the repositories it depends_on in the manifest, and not at all if it failed in any of them")
+	onlyFailures := flag.Bool("only-failures", false, "only print the results of the repositories where the command fails")
+	retries := flag.Int("retries", 0, "retry the command this many times in a repository where it fails, waiting 1s, 2s, 4s and so on up to 30s in between, for flaky network operations such as fetch and push")
+	flag.IntVar(&lockRetries, "lock-retries", lockRetries, "retry a command this many times, after a moment, where it fails because another process such as an IDE holds a lock file like index.lock")
+	untilOK := flag.Bool("until-ok", false, "repeat the command in each repository until it succeeds, such as to wait for a tag to reach a mirror")
+	untilOutput := flag.String("until-output", "", "repeat the command in each repository until its output matches this regular expression")
+	untilInterval := flag.Duration("until-interval", 10*time.Second, "with -until-ok or -until-output, how long to wait between runs of the command")
+	untilTimeout := flag.Duration("until-timeout", 10*time.Minute, "with -until-ok or -until-output, how long to repeat the command before reporting it as failed")
+	var first, last []string
+	flag.Var((*stringList)(&first), "first", "run the command in the repositories with this path, name, path glob or manifest group first, before starting it in the others (may be repeated)")
+	flag.Var((*stringList)(&last), "last", "run the command in the repositories with this path, name, path glob or manifest group last, once it finished in the others (may be repeated)")
+	dedupeOutput := flag.Bool("dedupe-output", false, "print the output shared by several repositories once, headed by the repositories it came from")
+	compareOutput := flag.Bool("compare-output", false, "print the lines of output most repositories share once, and what each repository prints differently, such as for git config -l")
+	selectWhere := flag.Bool("select", false, "run the command in each repository only to list those where it succeeds, as gits select does, instead of printing its output")
+	flag.BoolVar(&progressJSON, "progress-json", false, "report the progress as NDJSON events on stderr, when each repository starts and finishes and every second, instead of the progress line")
+	stream := flag.Bool("stream", false, "print the result of each repository as soon as it completes instead of all of them in order at the end")
+	iKnow := flag.Bool("i-know-what-i-am-doing", false, "run commands forced with --force, -f or a +refspec without typing a confirmation")
+	readOnly := flag.Bool("read-only", false, "refuse to run commands matching the write patterns of the configuration, such as git push, and subcommands that modify the repositories (default: read_only of the configuration)")
+	flag.StringVar(&cacheDirOverride, "cache-dir", "", "directory of what gits caches, such as the discovery cache, workspace locks and temporary files (default: $XDG_CACHE_HOME/gits)")
+	flag.StringVar(&stateDirOverride, "state-dir", "", "directory of the state gits keeps between runs, such as sessions, snapshots and run history (default: $XDG_STATE_HOME/gits)")
+	flag.BoolVar(&versionSorted, "version-sorted", versionSorted, "sort repositories, branches and tags naturally, repo2 before repo10 and v1.9 before v1.10, or lexicographically with -version-sorted=false")
+	noLock := flag.Bool("no-lock", false, "do not take the workspace lock that stops concurrent runs modifying the same repositories")
+	lockTimeout := flag.Duration("lock-timeout", 0, "how long to wait for another run to release the workspace lock, e.g. 5m (default: fail immediately)")
+	skipUnchangedRepos := flag.Bool("skip-unchanged", false, "skip repositories whose HEAD and worktree are unchanged since the command last succeeded in them")
+	snapshot := flag.Bool("snapshot", false, "before running a command matching the write patterns or forced, snapshot the branches, index and worktree of each repository so gits undo can restore them")
+	sinceLastRun := flag.Bool("since-last-run", false, "only match repositories whose HEAD or worktree changed since the end of the last run of the command with -since-last-run")
+	flag.String("profile", "", "name of the configuration profile to use (default: $GITS_PROFILE)")
+	githubOutput := flag.Bool("github-output", false, "print GitHub Actions error annotations for the repositories where the command fails and add a table of results to the job summary")
+	tap := flag.Bool("tap", false, "print the results as Test Anything Protocol, a test point for each repository, instead")
+	timings := flag.Bool("timings", false, "after the results, print the wall clock and CPU time, peak memory and bytes transferred of the command in each repository")
+	junitPath := flag.String("junit", "", "write a JUnit XML report of the command, with a test case for each repository, to this file")
+	canary := flag.String("canary", "", "first run the command in this many repositories, or percentage of them such as 10%, picked at random, or in a comma separated list of them, and ask before running it in the rest")
+	sessionName := flag.String("session", "", "match the repositories saved by gits select -save under this name instead of searching for them")
+	postRunHook := flag.String("post-run-hook", "", "shell command run at the end of a run of a command, with how it went as JSON on its stdin (default: post_run_hook of the configuration)")
+	summaryTemplate := flag.String("summary-template", "", "Go template of the summary line printed at the end of a run, such as '{{.OK}}/{{len .Repos}} passed{{if .Failed}}, see {{join .Failed \" \"}}{{end}}'")
+
+	cfg, err := loadConfig()
 	if err != nil {
-		return "", err
+		fmt.Println("Error reading configuration:", err)
+		exit(1)
 	}
-	return strings.TrimSpace(string(out)), nil
-}
 
-func getDefaultBranch(path string) (string, error) {
-	cmd := exec.Command("git", "-C", path, "config", "get", "init.defaultbranch")
-	out, err := cmd.Output()
+	prof, err := cfg.profile(selectedProfile(flag.CommandLine, os.Args[1:]))
 	if err != nil {
-		return "", err
+		fmt.Println("Error selecting profile:", err)
+		exit(1)
 	}
 
-	res := strings.TrimSpace(string(out))
-	if res == "" {
-		return "main", nil
+	// The profile's default options come first so the command line overrides them
+	args := os.Args[1:]
+	if prof != nil {
+		args = append(slices.Clone(prof.Flags), args...)
 	}
+	flag.CommandLine.Parse(args)
 
-	return res, nil
-}
+	if *help {
+		fmt.Println("Usage: gits [options] command [args...]")
+		fmt.Println("       gits [options] subcommand [subcommand options]")
+		flag.PrintDefaults()
+		printSubcommands()
+		exit(0)
+	}
 
-func getLocalBranches(path string) ([]string, error) {
-	cmd := exec.Command("git", "-C", path, "branch", "--format", "%(refname:short)")
-	out, err := cmd.Output()
-	if err != nil {
-		return nil, err
+	for _, dir := range []*string{&cacheDirOverride, &stateDirOverride} {
+		if *dir != "" {
+			if abs, err := filepath.Abs(*dir); err == nil {
+				*dir = abs
+			}
+		}
 	}
 
-	names := strings.Split(string(out), "\n")
+	if err := fopts.validate(); err != nil {
+		fmt.Println("Invalid -" + err.Error())
+		exit(1)
+	}
+	filters := fopts.filters()
 
-	res := make([]string, 0, len(names))
+	// the configuration sets the default, which -read-only=false overrides
+	readOnlySet := false
+	flag.Visit(func(f *flag.Flag) { readOnlySet = readOnlySet || f.Name == "read-only" })
+	if !readOnlySet {
+		*readOnly = cfg.ReadOnly
+	}
 
-	for _, n := range names {
-		n = strings.TrimSpace(n)
-		if n == "" {
-			continue
+	command := flag.Args()
+	if *selectWhere {
+		if len(command) == 0 || *status {
+			fmt.Println("Invalid -select: give the command to select the repositories where it succeeds")
+			exit(1)
 		}
-		res = append(res, n)
+		command = append([]string{"select"}, command...)
 	}
-	return res, nil
-}
-
-func isDirty(path string) (bool, error) {
-	cmd := exec.Command("git", "-C", path, "status", "--porcelain")
-	out, err := cmd.Output()
-	if err != nil {
-		return false, err
+	if *tuiMode && len(command) > 0 {
+		fmt.Println("Invalid -tui: commands are run from the table")
+		exit(1)
+	}
+	if *tuiMode && *a11y {
+		fmt.Println("Invalid -tui: the table is not usable with a screen reader, use -status with -a11y")
+		exit(1)
+	}
+	// the table is the status summary
+	*status = *status || *tuiMode
+	if !*status && len(command) == 0 {
+		fmt.Println("No command provided")
+		flag.PrintDefaults()
+		exit(1)
 	}
-	return len(out) > 0, nil
-}
 
-func isClean(path string) (bool, error) {
-	cmd := exec.Command("git", "-C", path, "status", "--porcelain")
-	out, err := cmd.Output()
-	if err != nil {
-		return false, err
+	if showDiff.value != "" && showDiff.value != diffStat && showDiff.value != diffFull {
+		fmt.Println("Invalid -show-diff: must be stat or full")
+		exit(1)
 	}
-	return len(out) == 0, nil
-}
 
-type RemoteSyncState int
+	if err := env.validate(); err != nil {
+		fmt.Println("Invalid -env:", err)
+		exit(1)
+	}
 
-const (
-	BehindRemote RemoteSyncState = -1
-	SyncRemote   RemoteSyncState = 0
-	AheadRemote  RemoteSyncState = 1
-)
+	if err := limits.validate(); err != nil {
+		fmt.Println("Invalid resource limits:", err)
+		exit(1)
+	}
 
-func getRemoteSyncStatus(path string) (RemoteSyncState, error) {
-	cmd := exec.Command("git", "-C", path, "status", "--porcelain", "--branch")
-	out, err := cmd.Output()
+	// The network configuration is also set in our own environment so that it
+	// applies to the git commands gits runs itself, such as for filters
+	env.network, err = cfg.network(prof).env()
 	if err != nil {
-		return SyncRemote, err
+		fmt.Println("Invalid network configuration:", err)
+		exit(1)
+	}
+	for _, kv := range env.network {
+		k, v, _ := strings.Cut(kv, "=")
+		os.Setenv(k, v)
+	}
+
+	plainOutput = !isTerminal(os.Stdout) || os.Getenv("NO_COLOR") != ""
+	var formatTemplate *template.Template
+	switch *format {
+	case "":
+	case formatJSON, formatNDJSON:
+		recordOutput, plainOutput = true, true
+	case formatPlain:
+		plainOutput = true
+	default:
+		var err error
+		formatTemplate, err = template.New("format").Funcs(formatFuncs).Parse(*format)
+		if err != nil {
+			fmt.Println("Invalid -format:", err)
+			exit(1)
+		}
 	}
-
-	firstLine := strings.SplitN(string(out), "\n", 2)[0]
-
-	if !strings.HasPrefix(firstLine, "##") {
-		return SyncRemote, fmt.Errorf("first line `%s` does not start with expected `##`", firstLine)
+	if *a11y {
+		a11yOutput, plainOutput = true, true
 	}
+	if err := setLocale(detectLocale(*lang)); err != nil {
+		fmt.Println("Invalid -lang:", err)
+		exit(1)
+	}
+	linkOutput = !plainOutput && formatTemplate == nil && supportsHyperlinks()
 
-	if strings.Contains(firstLine, "[behind") {
-		return BehindRemote, nil
+	cwd, err := os.Getwd()
+	if err != nil {
+		fmt.Println("Error getting current working directory:", err)
+		exit(1)
 	}
-	if strings.Contains(firstLine, "[ahead") {
-		return AheadRemote, nil
+	manifestDir := cwd
+	if *rootDir != "" {
+		manifestDir, _ = filepath.Abs(*rootDir)
 	}
-	return SyncRemote, nil
-}
-
-func runCommand(path string, command []string) (string, int) {
-	cmd := exec.Command(command[0], command[1:]...)
-	cmd.Dir = path
-	var out bytes.Buffer
-	cmd.Stdout = &out
-	cmd.Stderr = &out
-	err := cmd.Run()
-	exitCode := 0
+	if resolved, err := filepath.EvalSymlinks(manifestDir); err == nil {
+		manifestDir = resolved
+	}
+	m, err := findManifest(manifestDir)
 	if err != nil {
-		if exitError, ok := err.(*exec.ExitError); ok {
-			exitCode = exitError.ExitCode()
-		} else {
-			exitCode = 1
+		fmt.Println("Error reading manifest:", err)
+		exit(1)
+	}
+
+	var roots []string
+	if *rootDir != "" {
+		roots = []string{*rootDir}
+	} else if prof != nil && len(prof.Roots) > 0 {
+		roots = slices.Clone(prof.Roots)
+	} else if m != nil && len(m.Roots) > 0 {
+		for _, r := range m.Roots {
+			roots = append(roots, filepath.Join(m.dir, filepath.FromSlash(r)))
 		}
+	} else {
+		roots = []string{cwd}
 	}
-	return out.String(), exitCode
-}
 
-type filter func(path string) (bool, error)
-
-func processRepo(wg *sync.WaitGroup, mu *sync.Mutex, path string, cwd string, command []string, results *[]string, finalExitCode *int) {
-	defer wg.Done()
+	for i, r := range roots {
+		r, err = filepath.Abs(r)
+		if err != nil {
+			fmt.Println("Error resolving root:", err)
+			exit(1)
+		}
 
-	// Run the command
-	relPath, err := filepath.Rel(cwd, path)
-	if err != nil {
-		relPath = path
+		// Resolve symlink
+		roots[i], err = filepath.EvalSymlinks(r)
+		if err != nil {
+			fmt.Println("Error resolving symlink:", err)
+			exit(1)
+		}
 	}
-	output, exitCode := runCommand(path, command)
-	status := "✅️" // Checkmark
-	if exitCode != 0 {
-		status = "❌" // Cross mark
-		*finalExitCode = 1
+	root := commonRoot(roots)
+	if *rootDir == "" && (prof == nil || len(prof.Roots) == 0) && m != nil && len(m.Roots) > 0 {
+		// paths are shown relative to the manifest
+		root = m.dir
 	}
 
-	result := fmt.Sprintf("\033[1m%s %s:\033[0m\n  %s", status, relPath, strings.ReplaceAll(output, "\n", "\n  "))
-
-	mu.Lock()
-	*results = append(*results, result)
-	mu.Unlock()
-}
+	if len(groups) > 0 {
+		if m == nil {
+			fmt.Printf("Invalid -group: no %s found in %s or above\n", manifestName, manifestDir)
+			exit(1)
+		}
+		for _, g := range groups {
+			if !slices.Contains(m.groupNames(), g) {
+				fmt.Printf("Invalid -group: %s is not a group of %s, which has %s\n", g, filepath.Join(m.dir, manifestName), strings.Join(m.groupNames(), ", "))
+				exit(1)
+			}
+		}
+		filters = append(filters, func(path string) (bool, error) {
+			return slices.ContainsFunc(m.groupsOf(path), func(g string) bool { return slices.Contains(groups, g) }), nil
+		})
+	}
 
-func statusRepo(wg *sync.WaitGroup, mu *sync.Mutex, path string, cwd string, width int, results *[]string, finalExitCode *int) {
-	defer wg.Done()
+	var pinned []string
+	if *sessionName != "" {
+		s, err := loadSession(*sessionName)
+		if err != nil {
+			fmt.Println("Error loading session:", err)
+			exit(1)
+		}
+		pinned = s.Repos
+		if *rootDir == "" {
+			root = s.Root
+		}
+	}
 
-	relPath, err := filepath.Rel(cwd, path)
-	if err != nil {
-		relPath = path
+	var exclude []string
+	if prof != nil {
+		exclude = slices.Clone(prof.Exclude)
 	}
+	exclude = append(exclude, excludes...)
 
-	currentBranch, err := getCurrentBranch(path)
-	if err != nil {
-		currentBranch = "!" + err.Error()
+	discovery := discoveryOptions{
+		roots:          roots,
+		root:           root,
+		exclude:        exclude,
+		filters:        filters,
+		unhealthy:      *unhealthy,
+		pinned:         pinned,
+		includeSkipped: *includeSkipped,
+		bare:           *bare,
+		submodules:     *submodules,
+		maxDepth:       *maxDepth,
+		followSymlinks: *followSymlinks,
+		cache:          *discoveryCache,
+		refresh:        *refresh,
+	}
+	if *maxDepth < 0 {
+		fmt.Println("Invalid -max-depth: must not be negative")
+		exit(1)
 	}
 
-	defaultBranch, err := getDefaultBranch(path)
-	if err != nil {
-		defaultBranch = "main"
+	if *retries < 0 {
+		fmt.Println("Invalid -retries: must not be negative")
+		exit(1)
 	}
 
-	remoteSync, err := getRemoteSyncStatus(path)
-	if err != nil {
-		remoteSync = SyncRemote
+	if lockRetries < 0 {
+		fmt.Println("Invalid -lock-retries: must not be negative")
+		exit(1)
 	}
 
-	clean, err := isClean(path)
-	if err != nil {
-		clean = false
+	cmdOpts := commandOptions{format: formatTemplate, env: env, limits: limits, retries: *retries}
+	if *untilOK || *untilOutput != "" {
+		cmdOpts.until = &untilCondition{ok: *untilOK, interval: *untilInterval, timeout: *untilTimeout}
+		if *untilOutput != "" {
+			pattern, err := regexp.Compile(*untilOutput)
+			if err != nil {
+				fmt.Println("Invalid -until-output:", err)
+				exit(1)
+			}
+			cmdOpts.until.pattern = pattern
+		}
+		if *untilInterval <= 0 || *untilTimeout <= 0 {
+			fmt.Println("Invalid -until-interval or -until-timeout: must be positive")
+			exit(1)
+		}
+	}
+	if *retries > 0 || cmdOpts.until != nil {
+		cmdOpts.steps = &stepMatrix{}
+	}
+	var hook []string
+	switch {
+	case *postRunHook != "":
+		hook = []string{"sh", "-c", *postRunHook}
+	case len(cfg.PostRunHook) > 0:
+		hook = cfg.PostRunHook
+	}
+	var summaryTmpl *template.Template
+	if *summaryTemplate != "" {
+		summaryTmpl, err = template.New("summary").Funcs(summaryFuncs).Parse(*summaryTemplate)
+		if err != nil {
+			fmt.Println("Invalid -summary-template:", err)
+			exit(1)
+		}
+	}
+	if *junitPath != "" || *githubOutput || *tap || *timings || hook != nil || summaryTmpl != nil {
+		cmdOpts.recorder = &runRecorder{}
 	}
 
-	localBranches, err := getLocalBranches(path)
-	localBranches = slices.DeleteFunc(localBranches, func(x string) bool { return x == currentBranch })
-	sort.Strings(localBranches)
+	ws := &workspace{
+		root:        root,
+		parallel:    *parallel,
+		config:      cfg,
+		profile:     prof,
+		noLock:      *noLock,
+		lockTimeout: *lockTimeout,
+		readOnly:    *readOnly,
+		cmdOpts:     cmdOpts,
+		discovery:   discovery,
 
-	var branches strings.Builder
-	if currentBranch == defaultBranch {
-		branches.WriteString(" [\033[1;32m")
-	} else {
-		branches.WriteString(" [\033[1;31m")
+		forceConfirmed: *iKnow,
+		snapshot:       *snapshot,
 	}
-	branches.WriteString(currentBranch)
-	branches.WriteString("\033[0m]")
 
-	var status strings.Builder
-	if !clean {
-		status.WriteString("📝")
+	var sub *subcommand
+	if !*status {
+		sub = subcommands[command[0]]
 	}
-	switch remoteSync {
-	case BehindRemote:
-		status.WriteString("😰")
-	case AheadRemote:
-		status.WriteString("🏎💨")
+	if sub == nil && !*status {
+		if err := ws.checkWrite(command); err != nil {
+			fmt.Println("Error:", err)
+			exit(1)
+		}
 	}
-
-	if status.Len() > 0 {
-		branches.WriteString("\u001B[31m(\u001B[0m")
-		branches.WriteString(status.String())
-		branches.WriteString("\u001B[31m)\u001B[0m")
+	if *sinceLastRun && (*status || (sub != nil && sub.standalone)) {
+		fmt.Println("Invalid -since-last-run: only commands run in the repositories are tracked")
+		exit(1)
 	}
-
-	for _, name := range localBranches {
-		branches.WriteString(" [\033[34m")
-		branches.WriteString(name)
-		branches.WriteString("\033[0m]")
+	if recordOutput && sub != nil {
+		fmt.Println("Invalid -format: json and ndjson records are for -status and commands")
+		exit(1)
+	}
+	if *stream && *format == formatJSON {
+		fmt.Println("Invalid -stream: -format json prints a single array at the end, use ndjson")
+		exit(1)
+	}
+	if *canary != "" && *format == formatJSON {
+		fmt.Println("Invalid -canary: -format json prints a single array at the end")
+		exit(1)
+	}
+	if *gateOnFailure && (m == nil || *status || sub != nil || *canary != "" || len(first) > 0 || len(last) > 0) {
+		fmt.Println("Invalid -gate-on-failure: the order of commands comes from the depends_on of the manifest, without -canary, -first or -last")
+		exit(1)
+	}
+	if (*dryRun || *confirmRun) && (*status || sub != nil) {
+		fmt.Println("Invalid -dry-run or -confirm: only commands run in each repository can be previewed")
+		exit(1)
+	}
+	if cmdOpts.until != nil && (*status || (sub != nil && sub.standalone)) {
+		fmt.Println("Invalid -until-ok or -until-output: only commands run in the repositories are repeated")
+		exit(1)
+	}
+	if *dedupeOutput && (*status || sub != nil || *stream || *tap || *githubOutput || recordOutput || formatTemplate != nil) {
+		fmt.Println("Invalid -dedupe-output: only the text results of commands run in the repositories, printed at the end, are merged")
+		exit(1)
+	}
+	if *compareOutput && (*dedupeOutput || *status || sub != nil || *stream || *tap || *githubOutput || recordOutput || formatTemplate != nil) {
+		fmt.Println("Invalid -compare-output: only the text results of commands run in the repositories, printed at the end, are compared, without -dedupe-output")
+		exit(1)
+	}
+	if *canary != "" && (*status || sub != nil) {
+		fmt.Println("Invalid -canary: only commands run in each repository have canaries")
+		exit(1)
+	}
+	if *columnsFlag != "" && !*status {
+		fmt.Println("Invalid -columns: only -status has columns")
+		exit(1)
+	}
+	var columns []string
+	if spec := cmp.Or(*columnsFlag, strings.Join(cfg.Status.Columns, ",")); *status && spec != "" {
+		columns, err = parseColumns(spec, cfg.Status.Annotations)
+		if err != nil {
+			fmt.Println("Invalid -columns:", err)
+			exit(1)
+		}
+	}
+	if sub != nil && sub.standalone {
+		exit(sub.run(ws, command[1:]))
 	}
 
-	result := fmt.Sprintf("\033[1m%"+strconv.Itoa(-width)+"s\033[0m%s", relPath, branches.String())
-
-	mu.Lock()
-	*results = append(*results, result)
-	mu.Unlock()
-}
+	found, err := findRepos(discovery)
+	if err != nil {
+		fmt.Println("Error walking the path:", err)
+		exit(1)
+	}
+	gitRepos, skipped := found.repos, found.skipped
+	ws.repos = gitRepos
 
-func main() {
-	parallel := flag.Int("parallel", runtime.NumCPU(), "number of parallel tasks")
-	branch := flag.String("branch", "", "only match repositories on this branch")
-	dirty := flag.Bool("dirty", false, "only match repositories with a dirty worktree")
-	clean := flag.Bool("clean", false, "only match repositories with a clean worktree")
-	help := flag.Bool("help", false, "display help message")
-	status := flag.Bool("status", false, "display a summary of branch statuses and exit")
-	flag.Parse()
+	if len(skipped) > 0 && !*continueOnError {
+		for _, s := range skipped {
+			fmt.Printf("Error checking %s: %s\n", relativePath(root, s.path), errorReason(s.reason))
+		}
+		fmt.Println("Use -continue-on-error to skip these repositories")
+		exit(1)
+	}
 
-	if *help {
-		fmt.Println("Usage: gits [options] command [args...]")
-		flag.PrintDefaults()
-		os.Exit(0)
+	var skippedResults []string
+	for _, s := range skipped {
+		skippedResults = append(skippedResults, skippedResult(root, s))
+	}
+	for _, s := range found.unhealthy {
+		skippedResults = append(skippedResults, unhealthyResult(root, s))
 	}
 
-	var filters []filter
+	if *tuiMode {
+		exit(runTUI(ws))
+	}
 
-	if *branch != "" {
-		filters = append(filters, func(path string) (bool, error) {
-			b, err := getCurrentBranch(path)
-			return b == *branch, err
-		})
+	var recordLastRun func(ran []string) error
+	if *sinceLastRun {
+		gitRepos, recordLastRun, err = changedSinceLastRun(command, gitRepos, *parallel)
+		if err != nil {
+			fmt.Println("Error reading the last runs:", err)
+			exit(1)
+		}
+		ws.repos = gitRepos
 	}
 
-	if *dirty {
-		filters = append(filters, isDirty)
+	if sub == nil && !*status && !ws.confirmForced(command, gitRepos) {
+		fmt.Println("Not run")
+		exit(1)
 	}
 
-	if *clean {
-		filters = append(filters, isClean)
+	if *dryRun {
+		printDryRun(root, command, gitRepos)
+		printResults(skippedResults)
+		exit(0)
+	}
+	if *confirmRun && len(gitRepos) > 0 {
+		gitRepos = confirmRepos(root, command, gitRepos)
+		if len(gitRepos) == 0 {
+			fmt.Println("Not run")
+			exit(1)
+		}
+		ws.repos = gitRepos
 	}
 
-	var applyAction func(wg *sync.WaitGroup, mu *sync.Mutex, path string, cwd string, results *[]string, finalExitCode *int)
+	var canaries []string
+	if *canary != "" {
+		canaries, gitRepos, err = pickCanaries(*canary, root, gitRepos)
+		if err != nil {
+			fmt.Println("Invalid -canary:", err)
+			exit(1)
+		}
+	}
 
-	var gitRepos []string
+	var applyAction func(path string) (string, int)
+	var lock *workspaceLock
+	var snapshotID string
 
 	if *status {
-		applyAction = func(wg *sync.WaitGroup, mu *sync.Mutex, path string, cwd string, results *[]string, finalExitCode *int) {
-			var longestName int = 0
-			for _, repo := range gitRepos {
-				relPath, err := filepath.Rel(cwd, repo)
-				if err != nil {
-					relPath = path
-				}
-
-				if len(relPath) > longestName {
-					longestName = len(relPath)
+		longestName := longestRelPath(root, gitRepos)
+		applyAction = func(path string) (string, int) {
+			if recordOutput {
+				if *fetch {
+					if _, err := runGit(path, "fetch", "--quiet"); err != nil {
+						return skippedRecord(root, path, err.Error()), 1
+					}
 				}
+				r := newRepoRecord(root, path)
+				r.Annotations = cfg.Status.annotate(root, path)
+				r.Ticket, r.TicketURL = cfg.ticket(root, path, r.Branch)
+				return r.line(), 0
 			}
-
-			statusRepo(wg, mu, path, cwd, longestName, results, finalExitCode)
+			return statusRepo(path, root, longestName, *verbose, *fetch, showDiff.value, columns, cfg)
+		}
+	} else if sub != nil {
+		exitCode := sub.run(ws, command[1:])
+		if recordLastRun != nil {
+			if err := recordLastRun(ws.repos); err != nil {
+				fmt.Println("Error recording the last run:", err)
+			}
+		}
+		printResults(skippedResults)
+		if len(skipped) > 0 {
+			exitCode = 1
 		}
+		exit(exitCode)
 	} else {
-		command := flag.Args()
-		if len(command) == 0 {
-			fmt.Println("No command provided")
-			flag.PrintDefaults()
-			os.Exit(1)
+		if !*noLock && !isReadOnlyCommand(command) {
+			lock, err = acquireWorkspaceLock(root, *lockTimeout, strings.Join(command, " "))
+			if err != nil {
+				fmt.Println("Error:", err)
+				fmt.Println("Use -lock-timeout to wait for it or -no-lock to run anyway")
+				exit(1)
+			}
+			atExit(lock.release)
 		}
 
-		applyAction = func(wg *sync.WaitGroup, mu *sync.Mutex, path string, cwd string, results *[]string, finalExitCode *int) {
-			processRepo(wg, mu, path, cwd, command, results, finalExitCode)
+		snapshotID, err = ws.snapshotBefore(command, append(slices.Clone(canaries), gitRepos...))
+		if err != nil {
+			fmt.Printf("Error taking a snapshot, not run:\n  %v\n", err)
+			exit(1)
 		}
-	}
 
-	cwd, err := os.Getwd()
-	if err != nil {
-		fmt.Println("Error getting current working directory:", err)
-		os.Exit(1)
+		applyAction = func(path string) (string, int) {
+			return processRepo(path, root, command, cmdOpts)
+		}
 	}
 
-	// Resolve symlink
-	cwd, err = filepath.EvalSymlinks(cwd)
-	if err != nil {
-		fmt.Println("Error resolving symlink:", err)
-		os.Exit(1)
+	var saveFingerprints func() error
+	if *skipUnchangedRepos && !*status {
+		applyAction, saveFingerprints = skipUnchanged(root, command, applyAction)
 	}
 
-	err = filepath.Walk(cwd, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-		if info.IsDir() && isGitRepo(path) {
-			// Check filters
-			for _, f := range filters {
-				r, err := f(path)
-				if err != nil || !r {
-					return filepath.SkipDir
-				}
+	var outcomes *runOutcomes
+	if !*status {
+		outcomes = &runOutcomes{root: root, failFast: *failFast, onlyFailures: *onlyFailures}
+		if *gateOnFailure {
+			gitRepos, err = outcomes.gateOnFailure(m, gitRepos)
+			if err != nil {
+				fmt.Println("Invalid -gate-on-failure:", err)
+				exit(1)
 			}
+		}
+		applyAction = outcomes.wrap(applyAction)
+	}
+
+	// TAP consumers read stdout, which the progress ticker would corrupt
+	progress := !*tap || *status || progressJSON
+	printResult := func(result string) {
+		switch {
+		case result == "":
+		case *tap && !*status:
+		case *githubOutput && !*status:
+			// each repository is a collapsible group of the Actions log
+			header, rest, _ := strings.Cut(result, "\n")
+			fmt.Printf("::group::%s\n%s\n::endgroup::\n", header, rest)
+		default:
+			fmt.Println(plain(result))
+		}
+	}
 
-			gitRepos = append(gitRepos, path)
-			return filepath.SkipDir
+	var emit func(result string)
+	if *stream {
+		emit = printResult
+		if columns != nil {
+			// without the other rows the cells can only be separated
+			emit = func(result string) { printResult(strings.ReplaceAll(result, columnSeparator, "  ")) }
 		}
-		return nil
-	})
-	if err != nil {
-		fmt.Println("Error walking the path:", err)
-		os.Exit(1)
 	}
 
-	sort.Strings(gitRepos)
+	// The canaries go first, and the rest only once they look good
+	var canaryExitCode int
+	if len(canaries) > 0 {
+		var canaryResults []string
+		canaryResults, canaryExitCode = forEachRepoStreaming(canaries, *parallel, progress, applyAction, emit)
+		if !*stream {
+			sortNames(canaryResults)
+			for _, result := range canaryResults {
+				printResult(result)
+			}
+		}
+		outcome := "all succeeded"
+		if canaryExitCode != 0 {
+			outcome = "some failed"
+		}
+		if len(gitRepos) > 0 && !confirm(fmt.Sprintf("Ran in %d canary repositories and %s, continue with the other %d?", len(canaries), outcome, len(gitRepos))) {
+			for _, path := range gitRepos {
+				s := skippedRepo{path: path, reason: errors.New("not run after the canaries")}
+				skipped = append(skipped, s)
+				skippedResults = append(skippedResults, skippedResult(root, s))
+			}
+			gitRepos = nil
+		}
+	}
 
-	var wg sync.WaitGroup
-	var mu sync.Mutex
+	started := time.Now()
+	// pinned repositories run in batches, whatever order the results are shown in
 	var results []string
-	finalExitCode := 0
-
-	totalTasks := len(gitRepos)
-	remainingTasks := totalTasks
-
-	sem := make(chan struct{}, *parallel)
-	ticker := time.NewTicker(1 * time.Second)
-	defer ticker.Stop()
-
-	go func() {
-		dots := "."
-		for range ticker.C {
-			fmt.Printf("\r⚡️ %d/%d %s   \b\b\b", totalTasks-remainingTasks, totalTasks, dots)
-			dots = dots + "."
-			if len(dots) > 3 {
-				dots = "."
-			}
+	finalExitCode := canaryExitCode
+	for _, batch := range pinnedBatches(root, m, gitRepos, first, last) {
+		batchResults, exitCode := forEachRepoStreaming(batch, *parallel, progress, applyAction, emit)
+		results = append(results, batchResults...)
+		finalExitCode = max(finalExitCode, exitCode)
+	}
+	if *stream {
+		// already printed
+		results = nil
+	}
+
+	if saveFingerprints != nil {
+		if err := saveFingerprints(); err != nil {
+			fmt.Println("Error recording fingerprints:", err)
 		}
-	}()
+	}
+	if recordLastRun != nil {
+		if err := recordLastRun(append(slices.Clone(canaries), gitRepos...)); err != nil {
+			fmt.Println("Error recording the last run:", err)
+		}
+	}
 
-	for _, repo := range gitRepos {
-		wg.Add(1)
-		sem <- struct{}{}
-		go func(repo string) {
-			defer func() { <-sem }()
-			applyAction(&wg, &mu, repo, cwd, &results, &finalExitCode)
-			remainingTasks--
-		}(repo)
+	results = append(results, skippedResults...)
+	if len(skipped) > 0 {
+		finalExitCode = 1
+	}
+
+	// TAP replaces the results with its test points, printed with the reports
+	sortNames(results)
+	if *dedupeOutput {
+		results = dedupeResults(results)
+	}
+	if *compareOutput {
+		results = compareResults(results)
+	}
+	if columns != nil && !a11yOutput && !recordOutput {
+		results = alignColumns(results, columns, cfg.Status.Widths)
+	}
+	if *format == formatJSON {
+		fmt.Println(jsonArray(results))
+	} else {
+		for _, result := range results {
+			printResult(result)
+		}
+	}
+	var summary runSummary
+	if outcomes != nil {
+		notRun := make([]string, 0, len(skipped)+len(found.unhealthy))
+		for _, s := range append(slices.Clone(skipped), found.unhealthy...) {
+			notRun = append(notRun, s.path)
+		}
+		var runs []repoRun
+		if cmdOpts.recorder != nil {
+			runs = cmdOpts.recorder.sorted()
+		}
+		summary = newRunSummary(command, root, started, finalExitCode, outcomes, notRun, runs)
+	}
+	// the failures should not get lost in the scrollback
+	if outcomes != nil && !recordOutput && !*tap && formatTemplate == nil {
+		line, err := summary.line(summaryTmpl)
+		if err != nil {
+			fmt.Println("Error formatting the summary:", err)
+			finalExitCode = 1
+		} else {
+			fmt.Println(line)
+		}
+		// where in the retries or repeats each repository failed
+		if cmdOpts.steps.failed() {
+			cmdOpts.steps.write(os.Stdout)
+		}
 	}
 
-	wg.Wait()
-	close(sem)
+	if cmdOpts.recorder != nil && !*status {
+		for _, s := range skipped {
+			cmdOpts.recorder.record(repoRun{commandResult: commandResult{repoInfo: newRepoInfo(root, s.path)}, Skipped: errorReason(s.reason)})
+		}
+		for _, s := range found.unhealthy {
+			cmdOpts.recorder.record(repoRun{commandResult: commandResult{repoInfo: newRepoInfo(root, s.path)}, Skipped: "unhealthy: " + s.reason.Error()})
+		}
+		runs := cmdOpts.recorder.sorted()
+		if *tap {
+			writeTAP(os.Stdout, runs)
+		}
+		if *timings && !recordOutput {
+			writeTimings(os.Stdout, runs)
+		}
+		if *junitPath != "" {
+			if err := writeJUnit(*junitPath, command, runs); err != nil {
+				fmt.Println("Error writing JUnit report:", err)
+				finalExitCode = 1
+			}
+		}
+		if *githubOutput {
+			if err := writeGitHubOutput(command, runs); err != nil {
+				fmt.Println("Error writing GitHub Actions job summary:", err)
+				finalExitCode = 1
+			}
+		}
+	}
 
-	fmt.Print("\r                      \r")
+	if snapshotID != "" {
+		// records are the only output of json and ndjson
+		out := os.Stdout
+		if recordOutput {
+			out = os.Stderr
+		}
+		fmt.Fprintf(out, "Snapshot %s taken before the run, gits undo %s restores the repositories\n", snapshotID, snapshotID)
+	}
 
-	sort.Strings(results)
-	for _, result := range results {
-		fmt.Println(result)
+	// the hook sees the run as it ended, and cannot change how it went
+	if hook != nil && outcomes != nil {
+		if err := runPostRunHook(hook, summary); err != nil {
+			fmt.Fprintln(os.Stderr, "Error running the post-run hook:", err)
+		}
 	}
 
-	os.Exit(finalExitCode)
+	exit(finalExitCode)
 }
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"gopkg.in/yaml.v3"
+)
+
+// manifestName is the workspace manifest, found in the root or a directory above it.
+const manifestName = ".gits.yaml"
+
+// manifest declares the repositories of a workspace.
+type manifest struct {
+	// Defaults apply to every repository that does not set them
+	Defaults manifestDefaults `yaml:"defaults"`
+	// Roots are the directories to search for repositories, relative to the
+	// manifest, instead of the current directory
+	Roots []string `yaml:"roots"`
+	// Groups are glob patterns of the repository paths of each named group
+	Groups map[string][]string `yaml:"groups"`
+	Repos  []manifestRepo      `yaml:"repos"`
+	// Commits is how the commits gits makes are written
+	Commits commitPolicy `yaml:"commits"`
+	// Toolchains are the versions of the toolchains, such as go: "1.22",
+	// the repositories must pin, see gits audit toolchain
+	Toolchains map[string]string `yaml:"toolchains"`
+
+	// dir is the directory containing the manifest, repository paths are relative to it
+	dir string
+}
+
+type manifestDefaults struct {
+	Partial string `yaml:"partial"`
+}
+
+// manifestRepo is a repository of the workspace.
+type manifestRepo struct {
+	// Path is the checkout directory relative to the manifest
+	Path string `yaml:"path"`
+	// URL is the remote to clone from
+	URL string `yaml:"url"`
+	// Branch is checked out when cloning instead of the remote's default
+	Branch string `yaml:"branch"`
+	// Partial clones without blobs (blobless) or trees (treeless) until they are needed
+	Partial string `yaml:"partial"`
+	// Sparse are the directories checked out in cone mode, everything if empty
+	Sparse []string `yaml:"sparse"`
+	// Groups are the named groups the repository is in, along with those
+	// whose patterns match its path
+	Groups []string `yaml:"groups"`
+	// DependsOn are the paths of the repositories of the manifest this one depends on
+	DependsOn []string `yaml:"depends_on"`
+	// Retired is the date the repository was retired with gits retire, it is
+	// no longer cloned or synced
+	Retired string `yaml:"retired"`
+}
+
+// partialFilters are the git clone filters of the partial clone kinds.
+var partialFilters = map[string]string{
+	"blobless": "blob:none",
+	"treeless": "tree:0",
+}
+
+// findManifest looks for the manifest in dir and the directories above it,
+// returning nil if there is none.
+func findManifest(dir string) (*manifest, error) {
+	for {
+		path := filepath.Join(dir, manifestName)
+		data, err := os.ReadFile(path)
+		if err == nil {
+			m := &manifest{dir: dir}
+			if err := yaml.Unmarshal(data, m); err != nil {
+				return nil, fmt.Errorf("%s: %v", path, err)
+			}
+			return m, m.validate()
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil, nil
+		}
+		dir = parent
+	}
+}
+
+func (m *manifest) validate() error {
+	if _, ok := partialFilters[m.Defaults.Partial]; m.Defaults.Partial != "" && !ok {
+		return fmt.Errorf("%s: unknown partial clone `%s`, expected blobless or treeless", filepath.Join(m.dir, manifestName), m.Defaults.Partial)
+	}
+	if err := m.Commits.validate(); err != nil {
+		return fmt.Errorf("%s: %v", filepath.Join(m.dir, manifestName), err)
+	}
+	for _, root := range m.Roots {
+		if filepath.IsAbs(root) {
+			return fmt.Errorf("%s: roots must be relative to the manifest", filepath.Join(m.dir, manifestName))
+		}
+	}
+	for _, r := range m.Repos {
+		if r.Path == "" || filepath.IsAbs(r.Path) {
+			return fmt.Errorf("%s: repositories need a relative path", filepath.Join(m.dir, manifestName))
+		}
+		if _, ok := partialFilters[r.Partial]; r.Partial != "" && r.Partial != "none" && !ok {
+			return fmt.Errorf("%s: unknown partial clone `%s` for %s, expected blobless, treeless or none", filepath.Join(m.dir, manifestName), r.Partial, r.Path)
+		}
+		for _, dep := range r.DependsOn {
+			if !slices.ContainsFunc(m.Repos, func(o manifestRepo) bool { return o.Path == dep }) {
+				return fmt.Errorf("%s: %s depends on %s, which is not a repository of the manifest", filepath.Join(m.dir, manifestName), r.Path, dep)
+			}
+		}
+	}
+	return nil
+}
+
+// absPath returns the absolute path of the repository's checkout.
+func (m *manifest) absPath(r manifestRepo) string {
+	return filepath.Join(m.dir, filepath.FromSlash(r.Path))
+}
+
+// groupsOf returns the groups the repository at the absolute path is in.
+func (m *manifest) groupsOf(path string) []string {
+	relPath := filepath.ToSlash(relativePath(m.dir, path))
+	var groups []string
+	for _, r := range m.Repos {
+		if filepath.ToSlash(filepath.Clean(r.Path)) == relPath {
+			groups = append(groups, r.Groups...)
+		}
+	}
+	for name, patterns := range m.Groups {
+		if matchesAnyGlob(patterns, relPath) {
+			groups = append(groups, name)
+		}
+	}
+	slices.Sort(groups)
+	return slices.Compact(groups)
+}
+
+// groupNames returns the names of all the groups of the manifest.
+func (m *manifest) groupNames() []string {
+	var names []string
+	for name := range m.Groups {
+		names = append(names, name)
+	}
+	for _, r := range m.Repos {
+		names = append(names, r.Groups...)
+	}
+	slices.Sort(names)
+	return slices.Compact(names)
+}
+
+// partial returns the partial clone kind of the repository, or "" for a full clone.
+func (m *manifest) partial(r manifestRepo) string {
+	switch {
+	case r.Partial == "none":
+		return ""
+	case r.Partial != "":
+		return r.Partial
+	default:
+		return m.Defaults.Partial
+	}
+}
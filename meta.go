@@ -0,0 +1,174 @@
+//go:build !noforge
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"text/template"
+)
+
+func init() {
+	registerSubcommand(&subcommand{
+		name:    "meta",
+		summary: "set the topics, description and default branch of the repositories on their forges, see `gits meta set`",
+		run:     runMeta,
+	})
+}
+
+// repoMetadata is the descriptive metadata of a forge repository.
+type repoMetadata struct {
+	description   string
+	defaultBranch string
+	topics        []string
+}
+
+func runMeta(ws *workspace, args []string) int {
+	if len(args) == 0 || args[0] != "set" {
+		fmt.Println("Usage: gits [options] meta set [meta options]")
+		return 1
+	}
+
+	fs := newSubcommandFlags("meta set", "")
+	var topics, removeTopics []string
+	fs.Var((*stringList)(&topics), "topic", "topic to add (may be repeated)")
+	fs.Var((*stringList)(&removeTopics), "remove-topic", "topic to remove (may be repeated)")
+	description := fs.String("description", "", "description to set, a Go template of the repository path fields such as {{.Name}}")
+	descriptionFile := fs.String("description-file", "", "file with the description template, for longer descriptions")
+	defaultBranch := fs.String("default-branch", "", "default branch to set, which must already exist on the forge")
+	dryRun := fs.Bool("dry-run", false, "show the changes without making them")
+	fs.Parse(args[1:])
+
+	if *descriptionFile != "" {
+		data, err := os.ReadFile(*descriptionFile)
+		if err != nil {
+			fmt.Println("Error reading -description-file:", err)
+			return 1
+		}
+		*description = strings.TrimSpace(string(data))
+	}
+	var descTemplate *template.Template
+	if *description != "" {
+		var err error
+		descTemplate, err = template.New("description").Parse(*description)
+		if err != nil {
+			fmt.Println("Invalid description:", err)
+			return 1
+		}
+	}
+	if descTemplate == nil && *defaultBranch == "" && len(topics) == 0 && len(removeTopics) == 0 {
+		fmt.Println("Nothing to set, use -topic, -remove-topic, -description or -default-branch")
+		return 1
+	}
+
+	results, exitCode := forEachRepo(ws.repos, ws.parallel, func(repo string) (string, int) {
+		info := newRepoInfo(ws.root, repo)
+		client, fr, err := forgeClientFor(ws, repo)
+		if err != nil {
+			return fmt.Sprintf("\033[1m⏭️ %s:\033[0m %v", info.RelPath, err), 0
+		}
+		current, err := client.repoMetadata(fr)
+		if err != nil {
+			return fmt.Sprintf("\033[1m❌ %s:\033[0m %s: %v", info.RelPath, fr, err), 1
+		}
+
+		wanted := repoMetadata{description: current.description, defaultBranch: current.defaultBranch}
+		if descTemplate != nil {
+			var out strings.Builder
+			if err := descTemplate.Execute(&out, info); err != nil {
+				return fmt.Sprintf("\033[1m❌ %s:\033[0m %v", info.RelPath, err), 1
+			}
+			wanted.description = out.String()
+		}
+		if *defaultBranch != "" {
+			wanted.defaultBranch = *defaultBranch
+		}
+		for _, t := range current.topics {
+			if !slices.Contains(removeTopics, t) {
+				wanted.topics = append(wanted.topics, t)
+			}
+		}
+		for _, t := range topics {
+			if !slices.Contains(wanted.topics, t) {
+				wanted.topics = append(wanted.topics, t)
+			}
+		}
+
+		var changes []string
+		if wanted.description != current.description {
+			changes = append(changes, fmt.Sprintf("description: %q", wanted.description))
+		}
+		if wanted.defaultBranch != current.defaultBranch {
+			changes = append(changes, fmt.Sprintf("default branch: %s → %s", current.defaultBranch, wanted.defaultBranch))
+		}
+		if !slices.Equal(wanted.topics, current.topics) {
+			changes = append(changes, fmt.Sprintf("topics: %s", strings.Join(wanted.topics, ", ")))
+		}
+		if len(changes) == 0 {
+			return "", 0
+		}
+
+		if *dryRun {
+			return fmt.Sprintf("\033[1m%s:\033[0m %s would change\n  %s", info.RelPath, fr, strings.Join(changes, "\n  ")), 0
+		}
+		if err := client.setRepoMetadata(fr, current, wanted); err != nil {
+			return fmt.Sprintf("\033[1m❌ %s:\033[0m %s: %v", info.RelPath, fr, err), 1
+		}
+		return fmt.Sprintf("\033[1m✅️ %s:\033[0m %s\n  %s", info.RelPath, fr, strings.Join(changes, "\n  ")), 0
+	})
+	printResults(results)
+	return exitCode
+}
+
+func (c *forgeClient) repoMetadata(r forgeRepo) (repoMetadata, error) {
+	var repo struct {
+		Description   *string  `json:"description"`
+		DefaultBranch string   `json:"default_branch"`
+		Topics        []string `json:"topics"`
+	}
+	if err := c.get(c.repoPath(r), &repo); err != nil {
+		return repoMetadata{}, err
+	}
+	meta := repoMetadata{defaultBranch: repo.DefaultBranch, topics: repo.Topics}
+	if repo.Description != nil {
+		meta.description = *repo.Description
+	}
+	return meta, nil
+}
+
+// setRepoMetadata makes the changes from current to wanted.
+func (c *forgeClient) setRepoMetadata(r forgeRepo, current repoMetadata, wanted repoMetadata) error {
+	update := make(map[string]any)
+	if wanted.description != current.description {
+		update["description"] = wanted.description
+	}
+	if wanted.defaultBranch != current.defaultBranch {
+		update["default_branch"] = wanted.defaultBranch
+	}
+	topicsChanged := !slices.Equal(wanted.topics, current.topics)
+	topics := wanted.topics
+	if topics == nil {
+		topics = []string{}
+	}
+
+	if c.kind == forgeGitLab {
+		if topicsChanged {
+			update["topics"] = topics
+		}
+		return c.do(http.MethodPut, c.repoPath(r), update, nil)
+	}
+
+	// GitHub sets the topics separately
+	if len(update) > 0 {
+		if err := c.do(http.MethodPatch, c.repoPath(r), update, nil); err != nil {
+			return err
+		}
+	}
+	if topicsChanged {
+		return c.do(http.MethodPut, c.repoPath(r)+"/topics", map[string]any{"names": topics}, nil)
+	}
+	return nil
+}
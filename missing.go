@@ -0,0 +1,192 @@
+//go:build !noforge
+
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+func init() {
+	registerSubcommand(&subcommand{
+		name:    "missing",
+		summary: "compare the repositories of a GitHub organization or GitLab group with the checkouts, optionally cloning those missing",
+		run:     runMissing,
+	})
+}
+
+// orgRepo is a repository of a forge organization or group.
+type orgRepo struct {
+	forgeRepo
+	sshURL   string
+	httpsURL string
+}
+
+func runMissing(ws *workspace, args []string) int {
+	fs := newSubcommandFlags("missing", "")
+	githubOrg := fs.String("github-org", "", "GitHub organization or user to compare with")
+	gitlabGroup := fs.String("gitlab-group", "", "GitLab group to compare with, including its subgroups")
+	host := fs.String("host", "", "host of the forge (default: github.com or gitlab.com)")
+	archived := fs.Bool("include-archived", false, "also expect archived repositories to be checked out")
+	forks := fs.Bool("include-forks", false, "also expect forks to be checked out")
+	cloneMissing := fs.Bool("clone-missing", false, "clone the missing repositories under the workspace root, at their path within the organization or group")
+	https := fs.Bool("https", false, "clone over HTTPS instead of SSH")
+	fs.Parse(args)
+
+	if (*githubOrg == "") == (*gitlabGroup == "") {
+		fmt.Println("One of -github-org or -gitlab-group is required")
+		fs.Usage()
+		return 1
+	}
+	org := *githubOrg
+	if *host == "" {
+		*host = "github.com"
+	}
+	if *gitlabGroup != "" {
+		org = *gitlabGroup
+		if *host == "github.com" {
+			*host = "gitlab.com"
+		}
+	}
+
+	client := newForgeClient(ws, *host)
+	if *gitlabGroup != "" {
+		client.kind = forgeGitLab
+	}
+	remote, err := client.orgRepos(org, *archived, *forks)
+	if err != nil {
+		fmt.Printf("Error listing the repositories of %s: %v\n", org, err)
+		return 1
+	}
+
+	// the checkouts of the organization, by repository path on the forge
+	local := make(map[string]string)
+	for _, repo := range ws.repos {
+		origin, err := getConfigValue(repo, "remote.origin.url")
+		if err != nil {
+			continue
+		}
+		if fr, ok := parseRemoteURL(origin); ok && fr.host == *host && strings.HasPrefix(strings.ToLower(fr.path), strings.ToLower(org)+"/") {
+			local[strings.ToLower(fr.path)] = repo
+		}
+	}
+
+	// repositories retired with gits retire are not expected to be checked out
+	if m, _ := findManifest(ws.root); m != nil {
+		for _, r := range m.Repos {
+			if fr, ok := parseRemoteURL(r.URL); ok && r.Retired != "" && fr.host == *host {
+				local[strings.ToLower(fr.path)] = ""
+			}
+		}
+	}
+
+	var missing []orgRepo
+	for _, r := range remote {
+		if _, ok := local[strings.ToLower(r.path)]; !ok {
+			missing = append(missing, r)
+		} else {
+			delete(local, strings.ToLower(r.path))
+		}
+	}
+	var gone []string
+	for _, repo := range local {
+		if repo != "" {
+			gone = append(gone, relativePath(ws.root, repo))
+		}
+	}
+	slices.Sort(gone)
+
+	fmt.Printf("%d repositories on %s/%s, %d checked out\n", len(remote), *host, org, len(remote)-len(missing))
+	for _, r := range missing {
+		fmt.Printf("\033[1m➕ %s:\033[0m not checked out\n", r.path)
+	}
+	for _, relPath := range gone {
+		fmt.Printf("\033[1m➖ %s:\033[0m not on %s/%s, it may have been deleted, renamed or archived\n", relPath, *host, org)
+	}
+	if !*cloneMissing || len(missing) == 0 {
+		return 0
+	}
+
+	release, ok := ws.lock("missing -clone-missing")
+	if !ok {
+		return 1
+	}
+	defer release()
+
+	byPath := make(map[string]orgRepo)
+	var paths []string
+	for _, r := range missing {
+		// the path within the organization, which for GitLab may have subgroups
+		path := filepath.Join(ws.root, filepath.FromSlash(r.path[len(org)+1:]))
+		byPath[path] = r
+		paths = append(paths, path)
+	}
+	results, exitCode := forEachRepo(paths, ws.parallel, func(path string) (string, int) {
+		relPath := relativePath(ws.root, path)
+		cloneURL := byPath[path].sshURL
+		if *https {
+			cloneURL = byPath[path].httpsURL
+		}
+		if out, err := exec.Command("git", "clone", "--quiet", "--", cloneURL, path).CombinedOutput(); err != nil {
+			return fmt.Sprintf("\033[1m❌ %s:\033[0m\n  %s", relPath, strings.ReplaceAll(strings.TrimSpace(string(out)), "\n", "\n  ")), 1
+		}
+		return fmt.Sprintf("\033[1m✅️ %s:\033[0m cloned from %s", relPath, cloneURL), 0
+	})
+	printResults(results)
+	return exitCode
+}
+
+// orgRepos lists the repositories of a GitHub organization or user, or a
+// GitLab group and its subgroups, in order of their paths.
+func (c *forgeClient) orgRepos(org string, archived bool, forks bool) ([]orgRepo, error) {
+	var repos []orgRepo
+	for page := 1; ; page++ {
+		var batch []struct {
+			// GitHub
+			FullName string `json:"full_name"`
+			SSHURL   string `json:"ssh_url"`
+			CloneURL string `json:"clone_url"`
+			Fork     bool   `json:"fork"`
+			// GitLab
+			PathWithNamespace string `json:"path_with_namespace"`
+			SSHURLToRepo      string `json:"ssh_url_to_repo"`
+			HTTPURLToRepo     string `json:"http_url_to_repo"`
+			ForkedFrom        any    `json:"forked_from_project"`
+			Archived          bool   `json:"archived"`
+		}
+		query := url.Values{"per_page": {"100"}, "page": {fmt.Sprint(page)}}
+		var err error
+		if c.kind == forgeGitLab {
+			query.Set("include_subgroups", "true")
+			err = c.get("/groups/"+url.PathEscape(org)+"/projects?"+query.Encode(), &batch)
+		} else {
+			err = c.get("/orgs/"+url.PathEscape(org)+"/repos?"+query.Encode(), &batch)
+			if isNotFound(err) {
+				// a user rather than an organization
+				err = c.get("/users/"+url.PathEscape(org)+"/repos?"+query.Encode(), &batch)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, b := range batch {
+			if (b.Archived && !archived) || ((b.Fork || b.ForkedFrom != nil) && !forks) {
+				continue
+			}
+			r := orgRepo{forgeRepo: forgeRepo{path: b.FullName}, sshURL: b.SSHURL, httpsURL: b.CloneURL}
+			if c.kind == forgeGitLab {
+				r = orgRepo{forgeRepo: forgeRepo{path: b.PathWithNamespace}, sshURL: b.SSHURLToRepo, httpsURL: b.HTTPURLToRepo}
+			}
+			repos = append(repos, r)
+		}
+		if len(batch) < 100 {
+			break
+		}
+	}
+	slices.SortFunc(repos, func(a, b orgRepo) int { return compareNames(a.path, b.path) })
+	return repos, nil
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+func init() {
+	registerSubcommand(&subcommand{
+		name:    "mux",
+		summary: "open a tmux session with a window or pane in each repository",
+		run:     runMux,
+	})
+}
+
+// muxConfig configures gits mux.
+type muxConfig struct {
+	// Layout is windows for a window for each repository, or the tmux
+	// layout of a pane for each in one window, such as tiled (default:
+	// windows)
+	Layout string `yaml:"layout"`
+	// Command is typed in each window or pane, with the repository
+	// placeholders such as {branch} expanded
+	Command string `yaml:"command"`
+}
+
+// muxLayoutWindows opens a window for each repository rather than a pane.
+const muxLayoutWindows = "windows"
+
+// tmuxLayouts are the layouts of panes tmux has.
+var tmuxLayouts = []string{"tiled", "even-horizontal", "even-vertical", "main-horizontal", "main-vertical"}
+
+func runMux(ws *workspace, args []string) int {
+	fs := newSubcommandFlags("mux", "")
+	session := fs.String("session", "gits-"+filepath.Base(ws.root), "the name of the tmux session")
+	layout := fs.String("layout", cmp.Or(ws.config.Mux.Layout, muxLayoutWindows), "windows for a window for each repository, or the tmux layout of a pane for each in one window: "+strings.Join(tmuxLayouts, ", ")+" (default: mux.layout of the configuration or windows)")
+	command := fs.String("command", ws.config.Mux.Command, "the command typed in each window or pane, with the repository placeholders expanded (default: mux.command of the configuration)")
+	detach := fs.Bool("detach", false, "create the session without attaching to it")
+	fs.Parse(args)
+
+	if *layout != muxLayoutWindows && !slices.Contains(tmuxLayouts, *layout) {
+		fmt.Printf("Invalid -layout: %s is not windows or one of %s\n", *layout, strings.Join(tmuxLayouts, ", "))
+		return 1
+	}
+	if len(ws.repos) == 0 {
+		fmt.Println("No repositories to open")
+		return 1
+	}
+	// tmux uses : and . to separate the window and pane of targets
+	name := strings.NewReplacer(":", "-", ".", "-").Replace(*session)
+
+	if _, err := tmux("has-session", "-t", "="+name); err == nil {
+		// the session is kept as it is, as there could be work going on in it
+		fmt.Printf("Session %s already exists\n", name)
+	} else if err := createMuxSession(ws, name, *layout, *command); err != nil {
+		fmt.Println("Error creating the tmux session:", err)
+		// leave no half built session behind
+		tmux("kill-session", "-t", "="+name)
+		return 1
+	} else if *detach {
+		fmt.Printf("Created session %s, attach to it with tmux attach -t %s\n", name, name)
+	}
+	if *detach {
+		return 0
+	}
+
+	attach := "attach-session"
+	if os.Getenv("TMUX") != "" {
+		// attaching from inside tmux would nest it
+		attach = "switch-client"
+	}
+	cmd := exec.Command("tmux", attach, "-t", "="+name)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		fmt.Println("Error attaching to the tmux session:", err)
+		return 1
+	}
+	return 0
+}
+
+// createMuxSession creates the detached tmux session with a window or pane
+// in each repository, typing the command in each.
+func createMuxSession(ws *workspace, name string, layout string, command string) error {
+	for i, path := range ws.repos {
+		relPath := relativePath(ws.root, path)
+		var args []string
+		switch {
+		case i == 0:
+			args = []string{"new-session", "-d", "-s", name, "-n", relPath}
+		case layout == muxLayoutWindows:
+			args = []string{"new-window", "-t", "=" + name + ":", "-n", relPath}
+		default:
+			args = []string{"split-window", "-t", "=" + name + ":"}
+		}
+		pane, err := tmux(append(args, "-c", path, "-P", "-F", "#{pane_id}")...)
+		if err != nil {
+			return err
+		}
+		if layout != muxLayoutWindows {
+			// rearranged after each split so there is room for the next
+			if _, err := tmux("select-layout", "-t", pane, layout); err != nil {
+				return err
+			}
+		}
+		if command != "" {
+			typed := newRepoInfo(ws.root, path).expand([]string{command})[0]
+			if _, err := tmux("send-keys", "-t", pane, typed, "Enter"); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// tmux runs a tmux command, returning its trimmed output.
+func tmux(args ...string) (string, error) {
+	out, err := exec.Command("tmux", args...).CombinedOutput()
+	if err != nil {
+		reason := strings.TrimSpace(string(out))
+		if reason == "" {
+			reason = err.Error()
+		}
+		return "", fmt.Errorf("tmux %s: %s", args[0], reason)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"slices"
+	"strings"
+)
+
+// versionSorted sorts names naturally, set with -version-sorted, which is on
+// unless turned off with -version-sorted=false for plain lexicographic order.
+var versionSorted = true
+
+// naturalCompare compares names with the runs of digits in them compared
+// as numbers, so repo2 comes before repo10 and v1.9 before v1.10, returning
+// -1, 0 or 1 as with strings.Compare.
+func naturalCompare(a string, b string) int {
+	for a != "" && b != "" {
+		i, j := digitsPrefix(a), digitsPrefix(b)
+		if i == 0 || j == 0 {
+			// compare up to where the next run of digits starts
+			i, j = textPrefix(a), textPrefix(b)
+			n := min(i, j)
+			if n == 0 {
+				// one of them continues with digits, which sort first
+				if i == 0 {
+					return -1
+				}
+				return 1
+			}
+			if c := strings.Compare(a[:n], b[:n]); c != 0 {
+				return c
+			}
+			a, b = a[n:], b[n:]
+			continue
+		}
+		x, y := strings.TrimLeft(a[:i], "0"), strings.TrimLeft(b[:j], "0")
+		if len(x) != len(y) {
+			if len(x) < len(y) {
+				return -1
+			}
+			return 1
+		}
+		if c := strings.Compare(x, y); c != 0 {
+			return c
+		}
+		// the same number, such as 07 and 7, with fewer leading zeros first
+		if i != j {
+			if i < j {
+				return -1
+			}
+			return 1
+		}
+		a, b = a[i:], b[j:]
+	}
+	return strings.Compare(a, b)
+}
+
+// digitsPrefix returns the length of the run of digits s starts with.
+func digitsPrefix(s string) int {
+	i := 0
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+	}
+	return i
+}
+
+// textPrefix returns the length of s up to its first digit.
+func textPrefix(s string) int {
+	if i := strings.IndexAny(s, "0123456789"); i >= 0 {
+		return i
+	}
+	return len(s)
+}
+
+// compareNames compares repository paths, branches and tags in the order
+// they are shown in, naturally unless -version-sorted=false.
+func compareNames(a string, b string) int {
+	if versionSorted {
+		return naturalCompare(a, b)
+	}
+	return strings.Compare(a, b)
+}
+
+// sortNames sorts repository paths, branches or tags in the order they are
+// shown in.
+func sortNames(names []string) {
+	slices.SortFunc(names, compareNames)
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// networkConfig controls how the git commands gits runs reach remotes, so
+// that working behind a proxy does not need the global git configuration.
+type networkConfig struct {
+	// Proxy is the proxy for HTTP(S) remotes, e.g. http://proxy:3128 or socks5h://proxy:1080
+	Proxy string `yaml:"proxy"`
+	// NoProxy is a comma separated list of hosts reached directly
+	NoProxy string `yaml:"no_proxy"`
+	// Rewrites replace URL prefixes, like git's url.<base>.insteadOf
+	Rewrites []urlRewrite `yaml:"rewrites"`
+	// SSH are ssh options for each host, e.g. github.com
+	SSH map[string]sshHostConfig `yaml:"ssh"`
+}
+
+type urlRewrite struct {
+	// URL is the prefix that is used
+	URL string `yaml:"url"`
+	// InsteadOf is the prefix that is replaced
+	InsteadOf string `yaml:"instead_of"`
+}
+
+type sshHostConfig struct {
+	User         string `yaml:"user"`
+	Port         int    `yaml:"port"`
+	IdentityFile string `yaml:"identity_file"`
+	ProxyJump    string `yaml:"proxy_jump"`
+	// Options are any other ssh_config options, e.g. ProxyCommand
+	Options map[string]string `yaml:"options"`
+}
+
+// env returns the environment variables applying the network configuration
+// to git, which are added to those already set.
+func (n *networkConfig) env() ([]string, error) {
+	if n == nil {
+		return nil, nil
+	}
+
+	// Configuration given with GIT_CONFIG_COUNT applies like -c options,
+	// continuing after any entries that are already set
+	count, _ := strconv.Atoi(os.Getenv("GIT_CONFIG_COUNT"))
+	var env []string
+	setConfig := func(key string, value string) {
+		env = append(env,
+			fmt.Sprintf("GIT_CONFIG_KEY_%d=%s", count, key),
+			fmt.Sprintf("GIT_CONFIG_VALUE_%d=%s", count, value))
+		count++
+	}
+
+	if n.Proxy != "" {
+		setConfig("http.proxy", n.Proxy)
+	}
+	for _, r := range n.Rewrites {
+		if r.URL == "" || r.InsteadOf == "" {
+			return nil, fmt.Errorf("rewrites need both url and instead_of")
+		}
+		setConfig("url."+r.URL+".insteadOf", r.InsteadOf)
+	}
+	if len(env) > 0 {
+		env = append(env, fmt.Sprintf("GIT_CONFIG_COUNT=%d", count))
+	}
+
+	if n.NoProxy != "" {
+		env = append(env, "NO_PROXY="+n.NoProxy, "no_proxy="+n.NoProxy)
+	}
+
+	if len(n.SSH) > 0 {
+		path, err := writeSSHConfig(n.SSH)
+		if err != nil {
+			return nil, err
+		}
+		env = append(env, "GIT_SSH_COMMAND=ssh -F "+shellQuote(path))
+	}
+	return env, nil
+}
+
+// writeSSHConfig writes an ssh configuration with the options for each host
+// that then includes the user's and system's configuration, returning its path.
+func writeSSHConfig(hosts map[string]sshHostConfig) (string, error) {
+	names := make([]string, 0, len(hosts))
+	for name := range hosts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// ssh uses the first value it finds for each option, so these come
+	// before the included configuration
+	var b strings.Builder
+	for _, name := range names {
+		h := hosts[name]
+		fmt.Fprintf(&b, "Host %s\n", name)
+		if h.User != "" {
+			fmt.Fprintf(&b, "  User %s\n", h.User)
+		}
+		if h.Port != 0 {
+			fmt.Fprintf(&b, "  Port %d\n", h.Port)
+		}
+		if h.IdentityFile != "" {
+			fmt.Fprintf(&b, "  IdentityFile %s\n", h.IdentityFile)
+		}
+		if h.ProxyJump != "" {
+			fmt.Fprintf(&b, "  ProxyJump %s\n", h.ProxyJump)
+		}
+		options := make([]string, 0, len(h.Options))
+		for option := range h.Options {
+			options = append(options, option)
+		}
+		sort.Strings(options)
+		for _, option := range options {
+			fmt.Fprintf(&b, "  %s %s\n", option, h.Options[option])
+		}
+	}
+	b.WriteString("Host *\nInclude ~/.ssh/config\nInclude /etc/ssh/ssh_config\n")
+
+	cache, err := cacheDir()
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(b.String()))
+	path := filepath.Join(cache, "ssh", hex.EncodeToString(sum[:8])+".config")
+	if _, err := os.Stat(path); err == nil {
+		return path, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return "", err
+	}
+	return path, os.WriteFile(path, []byte(b.String()), 0o600)
+}
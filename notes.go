@@ -0,0 +1,186 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+func init() {
+	registerSubcommand(&subcommand{
+		name:    "notes",
+		summary: "push, pull or show the unpushed git notes refs, as used by review tools, see `gits notes push|pull|status`",
+		run:     runNotes,
+	})
+}
+
+// notesTrackingPrefix is where the notes refs of a remote are fetched to,
+// as git has no remote-tracking refs for notes.
+const notesTrackingPrefix = "refs/gits/notes/"
+
+func runNotes(ws *workspace, args []string) int {
+	if len(args) > 0 {
+		switch args[0] {
+		case "push":
+			return runNotesSync(ws, "push", args[1:])
+		case "pull":
+			return runNotesSync(ws, "pull", args[1:])
+		case "status":
+			return runNotesStatus(ws, args[1:])
+		}
+	}
+	fmt.Println("Usage: gits [options] notes push|pull|status [notes options]")
+	return 1
+}
+
+func runNotesSync(ws *workspace, action string, args []string) int {
+	fs := newSubcommandFlags("notes "+action, "")
+	remote := fs.String("remote", "origin", "remote to "+action+" the notes refs of")
+	fs.Parse(args)
+
+	release, ok := ws.lock("notes " + action)
+	if !ok {
+		return 1
+	}
+	defer release()
+
+	results, exitCode := forEachRepo(ws.repos, ws.parallel, func(repo string) (string, int) {
+		relPath := relativePath(ws.root, repo)
+		remotes, err := getRemotes(repo)
+		if err != nil {
+			return fmt.Sprintf("\033[1m❌ %s:\033[0m %v", relPath, err), 1
+		}
+		if !slices.Contains(remotes, *remote) {
+			return fmt.Sprintf("\033[1m⏭️ %s:\033[0m no %s remote", relPath, *remote), 0
+		}
+		var changed []string
+		if action == "push" {
+			changed, err = pushNotes(repo, *remote)
+		} else {
+			changed, err = pullNotes(repo, *remote)
+		}
+		if err != nil {
+			return fmt.Sprintf("\033[1m❌ %s:\033[0m %v", relPath, err), 1
+		}
+		if len(changed) == 0 {
+			return "", 0
+		}
+		return fmt.Sprintf("\033[1m✅️ %s:\033[0m\n  %s", relPath, strings.Join(changed, "\n  ")), 0
+	})
+	printResults(results)
+	return exitCode
+}
+
+func runNotesStatus(ws *workspace, args []string) int {
+	fs := newSubcommandFlags("notes status", "")
+	remote := fs.String("remote", "origin", "remote to compare the notes refs with, as of the last notes push or pull")
+	fs.Parse(args)
+
+	results, exitCode := forEachRepo(ws.repos, ws.parallel, func(repo string) (string, int) {
+		relPath := relativePath(ws.root, repo)
+		unpushed, err := getUnpushedNotes(repo, *remote)
+		if err != nil {
+			return fmt.Sprintf("\033[1m❌ %s:\033[0m %v", relPath, err), 1
+		}
+		if len(unpushed) == 0 {
+			return "", 0
+		}
+		return fmt.Sprintf("\033[1m⚠️ %s:\033[0m unpushed notes: %s", relPath, strings.Join(unpushed, ", ")), 0
+	})
+	printResults(results)
+	return exitCode
+}
+
+// getUnpushedNotes returns the names of the notes refs with notes the remote
+// did not have as of the last notes push or pull.
+func getUnpushedNotes(repo string, remote string) ([]string, error) {
+	tips, err := getRefTips(repo, "refs/notes/", notesTrackingPrefix+remote+"/")
+	if err != nil {
+		return nil, err
+	}
+	return unpushedNotes(repo, remote, tips), nil
+}
+
+// unpushedNotes returns the names of the notes refs among the tips that the
+// remote did not have, going by its tracking refs among the tips too.
+func unpushedNotes(repo string, remote string, tips map[string]string) []string {
+	var unpushed []string
+	for ref, tip := range tips {
+		name, ok := strings.CutPrefix(ref, "refs/notes/")
+		if !ok {
+			continue
+		}
+		pushed, ok := tips[notesTrackingPrefix+remote+"/"+name]
+		if !ok || (pushed != tip && !isAncestor(repo, tip, pushed)) {
+			unpushed = append(unpushed, name)
+		}
+	}
+	slices.Sort(unpushed)
+	return unpushed
+}
+
+// pushNotes pushes the notes refs to the remote, returning the names of
+// those that were unpushed.
+func pushNotes(repo string, remote string) ([]string, error) {
+	unpushed, err := getUnpushedNotes(repo, remote)
+	if err != nil || len(unpushed) == 0 {
+		return nil, err
+	}
+	var refspecs []string
+	for _, name := range unpushed {
+		refspecs = append(refspecs, "refs/notes/"+name+":refs/notes/"+name)
+	}
+	if _, err := runGit(repo, append([]string{"push", "-q", remote}, refspecs...)...); err != nil {
+		return nil, fmt.Errorf("%w, gits notes pull merges the notes of the remote", err)
+	}
+	var pushed []string
+	for _, name := range unpushed {
+		if _, err := runGit(repo, "update-ref", notesTrackingPrefix+remote+"/"+name, "refs/notes/"+name); err != nil {
+			return nil, err
+		}
+		pushed = append(pushed, "pushed "+name)
+	}
+	return pushed, nil
+}
+
+// pullNotes fetches the notes refs of the remote and merges them into the
+// local ones, concatenating the notes both sides have for the same object.
+func pullNotes(repo string, remote string) ([]string, error) {
+	before, err := getRefTips(repo, "refs/notes/")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := runGit(repo, "fetch", "-q", remote, "+refs/notes/*:"+notesTrackingPrefix+remote+"/*"); err != nil {
+		return nil, err
+	}
+	tracking, err := getRefTips(repo, notesTrackingPrefix+remote+"/")
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for ref := range tracking {
+		names = append(names, strings.TrimPrefix(ref, notesTrackingPrefix+remote+"/"))
+	}
+	slices.Sort(names)
+
+	var pulled []string
+	for _, name := range names {
+		ref, theirs := "refs/notes/"+name, tracking[notesTrackingPrefix+remote+"/"+name]
+		ours, ok := before[ref]
+		switch {
+		case ok && (ours == theirs || isAncestor(repo, theirs, ours)):
+			continue
+		case !ok || isAncestor(repo, ours, theirs):
+			if _, err := runGit(repo, "update-ref", ref, theirs); err != nil {
+				return nil, err
+			}
+			pulled = append(pulled, "updated "+name)
+		default:
+			if _, err := runGit(repo, "notes", "--ref", ref, "merge", "-q", "-s", "cat_sort_uniq", theirs); err != nil {
+				return nil, err
+			}
+			pulled = append(pulled, "merged "+name)
+		}
+	}
+	return pulled, nil
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+func init() {
+	registerSubcommand(&subcommand{
+		name:    "open",
+		summary: "open the forge pages of the repositories in the browser, or the repositories in the editor",
+		run:     runOpen,
+	})
+}
+
+// openConfig configures gits open.
+type openConfig struct {
+	// Browser opens a URL given as its last argument (default: $BROWSER, or
+	// the opener of the desktop such as xdg-open or open)
+	Browser []string `yaml:"browser"`
+	// Editor opens all the repository directories given as its last
+	// arguments in one window, e.g. [code, -n] (default: $VISUAL or $EDITOR)
+	Editor []string `yaml:"editor"`
+}
+
+// maxOpenWithoutAsking is how many browser tabs gits open opens before
+// asking first.
+const maxOpenWithoutAsking = 10
+
+func runOpen(ws *workspace, args []string) int {
+	fs := newSubcommandFlags("open", "")
+	web := fs.Bool("web", false, "open the forge page of the origin of each repository in the browser (the default)")
+	editor := fs.Bool("editor", false, "open the repositories in the editor, all in one window")
+	yes := fs.Bool("y", false, "do not ask before opening many browser tabs")
+	fs.Parse(args)
+
+	if *web && *editor {
+		fmt.Println("Invalid options: -web and -editor cannot be used together")
+		return 1
+	}
+	if len(ws.repos) == 0 {
+		fmt.Println("No repositories to open")
+		return 1
+	}
+
+	if *editor {
+		command := ws.config.Open.Editor
+		if len(command) == 0 {
+			command = strings.Fields(cmp.Or(os.Getenv("VISUAL"), os.Getenv("EDITOR")))
+		}
+		if len(command) == 0 {
+			fmt.Println("No editor configured, set open.editor in the configuration or $VISUAL or $EDITOR")
+			return 1
+		}
+		cmd := exec.Command(command[0], append(command[1:], ws.repos...)...)
+		cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+		if err := cmd.Run(); err != nil {
+			fmt.Printf("Error running %s: %v\n", command[0], err)
+			return 1
+		}
+		return 0
+	}
+
+	browser := ws.config.Open.Browser
+	if len(browser) == 0 {
+		browser = defaultBrowser()
+	}
+	var urls []string
+	var results []string
+	exitCode := 0
+	for _, path := range ws.repos {
+		relPath := relativePath(ws.root, path)
+		fr, ok := originForge(path)
+		if !ok {
+			results = append(results, fmt.Sprintf("\033[1m⏭️ %s:\033[0m origin is not on a forge", relPath))
+			continue
+		}
+		urls = append(urls, fr.webURL())
+	}
+	if len(urls) > maxOpenWithoutAsking && !*yes && !confirm(fmt.Sprintf("Open %d browser tabs?", len(urls))) {
+		fmt.Println("Not opened")
+		return 1
+	}
+	for _, url := range urls {
+		cmd := exec.Command(browser[0], append(browser[1:], url)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			results = append(results, fmt.Sprintf("\033[1m❌ %s:\033[0m %v %s", url, err, strings.TrimSpace(string(out))))
+			exitCode = 1
+		}
+	}
+	printResults(results)
+	return exitCode
+}
+
+// defaultBrowser returns the command that opens a URL in the browser of the
+// user.
+func defaultBrowser() []string {
+	if b := strings.Fields(os.Getenv("BROWSER")); len(b) > 0 {
+		return b
+	}
+	switch runtime.GOOS {
+	case "darwin":
+		return []string{"open"}
+	case "windows":
+		return []string{"rundll32", "url.dll,FileProtocolHandler"}
+	default:
+		return []string{"xdg-open"}
+	}
+}
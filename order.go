@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// pinnedBatches splits the repositories into those pinned to run first,
+// the rest and those pinned to run last, leaving out empty batches. Each
+// batch is run to completion before the next starts. A pin matches the
+// path of a repository relative to the root, its name, a glob of its path
+// or a group of the manifest it is in.
+func pinnedBatches(root string, m *manifest, repos []string, first []string, last []string) [][]string {
+	if len(first) == 0 && len(last) == 0 {
+		return [][]string{repos}
+	}
+	pinned := func(pins []string, path string) bool {
+		relPath := filepath.ToSlash(relativePath(root, path))
+		for _, pin := range pins {
+			if pin == filepath.Base(path) || matchGlob(pin, relPath) {
+				return true
+			}
+			if m != nil && slices.Contains(m.groupsOf(path), pin) {
+				return true
+			}
+		}
+		return false
+	}
+
+	var firsts, rest, lasts []string
+	for _, path := range repos {
+		switch {
+		case pinned(first, path):
+			firsts = append(firsts, path)
+		case pinned(last, path):
+			lasts = append(lasts, path)
+		default:
+			rest = append(rest, path)
+		}
+	}
+	var batches [][]string
+	for _, batch := range [][]string{firsts, rest, lasts} {
+		if len(batch) > 0 {
+			batches = append(batches, batch)
+		}
+	}
+	return batches
+}
+
+// dependencyOrder returns the repositories in an order where each comes
+// after the repositories of the manifest it depends on, keeping their order
+// where it does not matter, and the dependencies of each among them.
+func (m *manifest) dependencyOrder(root string, repos []string) ([]string, map[string][]string, error) {
+	dependencies := make(map[string][]string)
+	matched := make(map[string]bool)
+	for _, path := range repos {
+		matched[path] = true
+	}
+	for _, r := range m.Repos {
+		path := m.absPath(r)
+		if !matched[path] {
+			continue
+		}
+		for _, dep := range r.DependsOn {
+			if depPath := m.absPath(manifestRepo{Path: dep}); matched[depPath] {
+				dependencies[path] = append(dependencies[path], depPath)
+			}
+		}
+	}
+
+	// depth first
+	var ordered []string
+	state := make(map[string]int) // 1 while visiting, 2 once ordered
+	var visit func(path string, chain []string) error
+	visit = func(path string, chain []string) error {
+		switch state[path] {
+		case 1:
+			return fmt.Errorf("the dependencies of the manifest have a cycle: %s", strings.Join(append(chain, relativePath(root, path)), " -> "))
+		case 2:
+			return nil
+		}
+		state[path] = 1
+		for _, dep := range dependencies[path] {
+			if err := visit(dep, append(chain, relativePath(root, path))); err != nil {
+				return err
+			}
+		}
+		state[path] = 2
+		ordered = append(ordered, path)
+		return nil
+	}
+	for _, path := range repos {
+		if err := visit(path, nil); err != nil {
+			return nil, nil, err
+		}
+	}
+	return ordered, dependencies, nil
+}
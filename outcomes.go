@@ -0,0 +1,122 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// runOutcomes tracks how the command went in each repository, to stop
+// after the first failure with -fail-fast, to block the dependents of the
+// repositories where it failed with -gate-on-failure, to hide the results
+// of the repositories where it succeeded with -only-failures and for the
+// summary at the end of the run.
+type runOutcomes struct {
+	root         string
+	failFast     bool
+	onlyFailures bool
+	// dependencies are the repositories each must wait for, and is blocked
+	// by if they fail, with -gate-on-failure
+	dependencies map[string][]string
+	// done is closed once the repository finished, was skipped or blocked
+	done map[string]chan struct{}
+
+	failed atomic.Bool
+	mu     sync.Mutex
+	ok     int
+	// failures are the paths of the repositories where the command failed
+	failures []string
+	// notRun are the paths of the repositories skipped after a failure
+	notRun []string
+	// blocked are the paths of the repositories not run as a dependency failed
+	blocked []string
+}
+
+// gateOnFailure makes the repositories wait for the repositories of the
+// manifest they depend on, and be blocked if any of them fail. It returns
+// the repositories in an order where each comes after its dependencies, so
+// that those are started first.
+func (o *runOutcomes) gateOnFailure(m *manifest, repos []string) ([]string, error) {
+	o.done = make(map[string]chan struct{})
+	for _, path := range repos {
+		o.done[path] = make(chan struct{})
+	}
+	ordered, dependencies, err := m.dependencyOrder(o.root, repos)
+	o.dependencies = dependencies
+	return ordered, err
+}
+
+// wrap returns the action recording its outcomes.
+func (o *runOutcomes) wrap(action func(path string) (string, int)) func(path string) (string, int) {
+	return func(path string) (string, int) {
+		if done := o.done[path]; done != nil {
+			defer close(done)
+		}
+		if blocker := o.blockingDependency(path); blocker != "" {
+			o.mu.Lock()
+			o.blocked = append(o.blocked, path)
+			o.mu.Unlock()
+			return blockedResult(o.root, path, blocker), 0
+		}
+		if o.failFast && o.failed.Load() {
+			o.mu.Lock()
+			o.notRun = append(o.notRun, path)
+			o.mu.Unlock()
+			return skippedResult(o.root, skippedRepo{path: path, reason: errors.New("not run after a failure with -fail-fast")}), 0
+		}
+
+		result, exitCode := action(path)
+
+		o.mu.Lock()
+		if exitCode == 0 {
+			o.ok++
+		} else {
+			o.failures = append(o.failures, path)
+		}
+		o.mu.Unlock()
+		if exitCode != 0 {
+			o.failed.Store(true)
+		}
+		if o.onlyFailures && exitCode == 0 {
+			return "", 0
+		}
+		return result, exitCode
+	}
+}
+
+// blockingDependency waits for the dependencies of the repository to
+// finish and describes the first that failed or was blocked, if any did.
+func (o *runOutcomes) blockingDependency(path string) string {
+	for _, dep := range o.dependencies[path] {
+		<-o.done[dep]
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for _, dep := range o.dependencies[path] {
+		if slices.Contains(o.failures, dep) {
+			return relativePath(o.root, dep) + " failed"
+		}
+		if slices.Contains(o.blocked, dep) {
+			return relativePath(o.root, dep) + " is blocked"
+		}
+	}
+	return ""
+}
+
+// blockedResult formats a repository blocked by a dependency as a result row.
+func blockedResult(root string, path string, blocker string) string {
+	reason := "blocked: " + blocker
+	if recordOutput {
+		return skippedRecord(root, path, reason)
+	}
+	return fmt.Sprintf("\033[1m⛔ %s:\033[0m %s", relativePath(root, path), reason)
+}
+
+// retryBackoff is how long to wait before retrying a command that failed,
+// doubling with each attempt from a second up to half a minute.
+func retryBackoff(attempt int) time.Duration {
+	return min(time.Second<<attempt, 30*time.Second)
+}
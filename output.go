@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"regexp"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// Output formats of -format besides Go templates.
+const (
+	formatJSON   = "json"
+	formatNDJSON = "ndjson"
+	formatPlain  = "plain"
+)
+
+// plainOutput is set when stdout is not a terminal, with $NO_COLOR or with
+// -format plain, to print the results without colors.
+var plainOutput bool
+
+// recordOutput is set with -format json or ndjson to print a repoRecord for
+// each repository instead of its result.
+var recordOutput bool
+
+// ansiEscape matches the escape sequences of colors and of OSC 8 links.
+var ansiEscape = regexp.MustCompile("\033\\[[0-9;]*[A-Za-z]|\033\\]8;[^\033]*\033\\\\")
+
+// isTerminal reports whether the file is a terminal.
+func isTerminal(f *os.File) bool {
+	return term.IsTerminal(int(f.Fd()))
+}
+
+// plain removes the colors from a result if the output is plain, and with
+// -a11y replaces the emoji leading it with words.
+func plain(result string) string {
+	if !plainOutput {
+		return result
+	}
+	result = ansiEscape.ReplaceAllString(result, "")
+	if a11yOutput {
+		result = a11yLine(result)
+	}
+	return result
+}
+
+// repoRecord is the structured result of a repository printed with
+// -format json or ndjson, for -status or a command.
+type repoRecord struct {
+	SchemaVersion int             `json:"schema_version"`
+	Path          string          `json:"path"`
+	AbsPath       string          `json:"abs_path"`
+	Branch        string          `json:"branch,omitempty"`
+	Dirty         bool            `json:"dirty"`
+	Sync          RemoteSyncState `json:"sync"`
+	Ahead         int             `json:"ahead"`
+	Behind        int             `json:"behind"`
+	Upstream      upstreamState   `json:"upstream"`
+	// VCS is jj or sl for the repositories of those version control
+	// systems, and not set for git
+	VCS string `json:"vcs,omitempty"`
+	// Bridge is svn or hg for git-svn and hg-git bridge repositories
+	Bridge string `json:"bridge,omitempty"`
+	// Annotations are the output of the annotations of the configuration
+	// by name, and only set for -status
+	Annotations map[string]string `json:"annotations,omitempty"`
+	// Ticket is the ID of the ticket in the branch name and TicketURL its
+	// page, and only set for -status
+	Ticket    string `json:"ticket,omitempty"`
+	TicketURL string `json:"ticket_url,omitempty"`
+	// ExitCode and Output are of the command, and not set for -status
+	ExitCode *int    `json:"exit_code,omitempty"`
+	Output   *string `json:"output,omitempty"`
+	// Resources are what the command used, and not set for -status
+	Resources *resourceUsage `json:"resources,omitempty"`
+	// Steps are the runs of a command run several times, with -retries or
+	// -until-ok and -until-output, and FirstFailingStep the first that failed
+	Steps            []stepResult `json:"steps,omitempty"`
+	FirstFailingStep string       `json:"first_failing_step,omitempty"`
+	// Skipped is why the repository was not run, if it wasn't
+	Skipped string `json:"skipped,omitempty"`
+	// GitsVersion is the version of gits that printed the record, to
+	// find the machines running an old one
+	GitsVersion string `json:"gits_version,omitempty"`
+}
+
+// newRepoRecord collects the state of the repository.
+func newRepoRecord(root string, path string) repoRecord {
+	r := repoRecord{SchemaVersion: schemaVersion, Path: relativePath(root, path), AbsPath: path, GitsVersion: gitsVersion()}
+	if k := detectVCS(path); k.status != nil {
+		st := k.status(path)
+		r.VCS, r.Branch, r.Dirty, r.Sync, r.Upstream = st.VCS, st.Branch, st.Dirty, st.Sync, st.Upstream
+		return r
+	}
+	r.Bridge = detectBridge(path)
+	r.Branch, _ = getCurrentBranch(path)
+	clean, err := isClean(path)
+	r.Dirty = err != nil || !clean
+	r.Sync, _ = getRemoteSyncStatus(path)
+	r.Ahead, r.Behind, r.Upstream, _ = getAheadBehind(path)
+	return r
+}
+
+// skippedRecord is the record of a repository that was not run.
+func skippedRecord(root string, path string, reason string) string {
+	return repoRecord{SchemaVersion: schemaVersion, Path: relativePath(root, path), AbsPath: path, GitsVersion: gitsVersion(), Skipped: reason}.line()
+}
+
+// line renders the record as a line of JSON.
+func (r repoRecord) line() string {
+	data, _ := json.Marshal(r)
+	return string(data)
+}
+
+// jsonArray joins the lines of JSON results into an indented array.
+func jsonArray(lines []string) string {
+	var values []json.RawMessage
+	for _, line := range lines {
+		if line != "" {
+			values = append(values, json.RawMessage(line))
+		}
+	}
+	if values == nil {
+		return "[]"
+	}
+	data, _ := json.MarshalIndent(values, "", "  ")
+	return strings.TrimSpace(string(data))
+}
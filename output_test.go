@@ -0,0 +1,36 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+// runGits runs gits with the arguments in a child process of the test
+// binary, through the test that calls it, returning its output.
+func runGits(t *testing.T, test string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], "-test.run=^"+test+"$")
+	cmd.Env = append(os.Environ(), "GITS_TEST_MAIN_ARGS="+strings.Join(args, "\n"))
+	out, _ := cmd.CombinedOutput()
+	return string(out)
+}
+
+func TestPlainOutputHasNoColors(t *testing.T) {
+	if args := os.Getenv("GITS_TEST_MAIN_ARGS"); args != "" {
+		os.Args = append([]string{"gits"}, strings.Split(args, "\n")...)
+		main()
+		return
+	}
+
+	repo := initTestRepo(t)
+	testWorkspace(t, repo)
+	for _, sub := range []string{"health", "overview", "describe"} {
+		// the output of the child process is piped, so it is plain
+		out := runGits(t, "TestPlainOutputHasNoColors", "-root", repo, "-no-lock", sub)
+		if strings.Contains(out, "\033") {
+			t.Errorf("%s printed escape sequences:\n%q", sub, out)
+		}
+	}
+}
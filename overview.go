@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+func init() {
+	registerSubcommand(&subcommand{
+		name:    "overview",
+		summary: "summarise the workspace: repositories by language, forge and directory, changes, disk usage and fetches",
+		run:     runOverview,
+	})
+}
+
+// repoOverview is what `gits overview` aggregates of each repository.
+type repoOverview struct {
+	relPath   string
+	language  string
+	host      string
+	directory string
+	status    statusSummary
+	diskUsage int64
+	lastFetch time.Time
+}
+
+func runOverview(ws *workspace, args []string) int {
+	flags := newSubcommandFlags("overview", "")
+	flags.Parse(args)
+
+	var mu sync.Mutex
+	var repos []repoOverview
+	results, exitCode := forEachRepo(ws.repos, ws.parallel, func(repo string) (string, int) {
+		relPath := relativePath(ws.root, repo)
+		o := repoOverview{relPath: relPath, language: detectLanguage(repo), host: "(none)", directory: "."}
+		if dir, _, ok := strings.Cut(relPath, "/"); ok {
+			o.directory = dir
+		}
+		if remote, _ := getConfigValue(repo, "remote.origin.url"); remote != "" {
+			if fr, ok := parseRemoteURL(remote); ok {
+				o.host = fr.host
+			}
+		}
+		var err error
+		if o.status, err = getStatusSummary(repo); err != nil {
+			return fmt.Sprintf("\033[1m❌ %s:\033[0m %v", relPath, err), 1
+		}
+		if o.lastFetch, err = getLastFetchTime(repo); err != nil {
+			return fmt.Sprintf("\033[1m❌ %s:\033[0m %v", relPath, err), 1
+		}
+		o.diskUsage = diskUsage(repo)
+		mu.Lock()
+		repos = append(repos, o)
+		mu.Unlock()
+		return "", 0
+	})
+	printResults(results)
+
+	var dirty, ahead, aheadRepos, behind, behindRepos int
+	var diskUsage int64
+	var oldest *repoOverview
+	var neverFetched []string
+	byLanguage := make(map[string]int)
+	byHost := make(map[string]int)
+	byDirectory := make(map[string]int)
+	for i, o := range repos {
+		if o.status.Dirty {
+			dirty++
+		}
+		if o.status.Ahead > 0 {
+			ahead += o.status.Ahead
+			aheadRepos++
+		}
+		if o.status.Behind > 0 {
+			behind += o.status.Behind
+			behindRepos++
+		}
+		diskUsage += o.diskUsage
+		if o.lastFetch.IsZero() {
+			neverFetched = append(neverFetched, o.relPath)
+		} else if oldest == nil || o.lastFetch.Before(oldest.lastFetch) {
+			oldest = &repos[i]
+		}
+		language := o.language
+		if language == "" {
+			language = "(unknown)"
+		}
+		byLanguage[language]++
+		byHost[o.host]++
+		byDirectory[o.directory]++
+	}
+
+	fmt.Println(plain(fmt.Sprintf("\033[1m%-*s\033[0m %s", padding(14), tr("Repositories"), tr("%d", len(repos)))))
+	fmt.Println(plain(fmt.Sprintf("\033[1m%-*s\033[0m %s", padding(14), tr("Dirty"), tr("%d", dirty))))
+	fmt.Println(plain(fmt.Sprintf("\033[1m%-*s\033[0m %s", padding(14), tr("Ahead"), tr("%d commits in %d repositories", ahead, aheadRepos))))
+	fmt.Println(plain(fmt.Sprintf("\033[1m%-*s\033[0m %s", padding(14), tr("Behind"), tr("%d commits in %d repositories", behind, behindRepos))))
+	fmt.Println(plain(fmt.Sprintf("\033[1m%-*s\033[0m %s", padding(14), tr("Disk usage"), formatBytes(diskUsage))))
+	if oldest != nil {
+		fmt.Println(plain(fmt.Sprintf("\033[1m%-*s\033[0m %s", padding(14), tr("Oldest fetch"), tr("%s, %s ago", oldest.relPath, formatAge(time.Since(oldest.lastFetch))))))
+	}
+	if len(neverFetched) > 0 {
+		slices.Sort(neverFetched)
+		fmt.Println(plain(fmt.Sprintf("\033[1m%-*s\033[0m %s", padding(14), tr("Never fetched"), strings.Join(neverFetched, ", "))))
+	}
+	printBreakdown(tr("By language"), byLanguage)
+	printBreakdown(tr("By forge"), byHost)
+	printBreakdown(tr("By directory"), byDirectory)
+	return exitCode
+}
+
+// printBreakdown prints the counts, most first.
+func printBreakdown(title string, counts map[string]int) {
+	var keys []string
+	width := 0
+	for k := range counts {
+		keys = append(keys, k)
+		width = max(width, len(k))
+	}
+	slices.SortFunc(keys, func(a, b string) int {
+		if counts[a] != counts[b] {
+			return counts[b] - counts[a]
+		}
+		return strings.Compare(a, b)
+	})
+	fmt.Printf("\n%s\n", plain("\033[1m"+title+"\033[0m"))
+	for _, k := range keys {
+		fmt.Println(plain(fmt.Sprintf("  %-*s %s", padding(width), k, tr("%d", counts[k]))))
+	}
+}
+
+// diskUsage returns the total size of the regular files under dir.
+func diskUsage(dir string) int64 {
+	var total int64
+	filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+		if err == nil && d.Type().IsRegular() {
+			if info, err := d.Info(); err == nil {
+				total += info.Size()
+			}
+		}
+		return nil
+	})
+	return total
+}
+
+// formatBytes renders a size in the largest binary unit, e.g. 1.2 GiB.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return tr("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	// the number is written the way the language of the user writes it
+	return tr("%.1f %s", float64(n)/float64(div), string("KMGTPE"[exp])+"iB")
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// limiter bounds how many tasks run at once.
+type limiter interface {
+	acquire()
+	// release frees the slot of a task that took elapsed and failed or not
+	release(elapsed time.Duration, failed bool)
+}
+
+// newLimiter returns a limiter of parallel tasks, or an adaptive one if
+// parallel is 0.
+func newLimiter(parallel int) limiter {
+	if parallel == 0 {
+		return newAdaptiveLimiter(1, 4*runtime.NumCPU())
+	}
+	return make(fixedLimiter, max(parallel, 1))
+}
+
+type fixedLimiter chan struct{}
+
+func (l fixedLimiter) acquire() { l <- struct{}{} }
+
+func (l fixedLimiter) release(time.Duration, bool) { <-l }
+
+// adaptiveLimiter starts with a couple of tasks and adds one each time a
+// task finishes in good health, halving the limit when tasks slow down
+// compared to the fastest they have been, start failing, or the machine is
+// overloaded.
+type adaptiveLimiter struct {
+	mu   sync.Mutex
+	cond *sync.Cond
+
+	active int
+	limit  int
+	min    int
+	max    int
+
+	// latency and errorRate are moving averages of the tasks
+	latency   time.Duration
+	errorRate float64
+	// fastest is the lowest latency seen, the baseline slowdowns are measured against
+	fastest time.Duration
+}
+
+func newAdaptiveLimiter(low int, high int) *adaptiveLimiter {
+	l := &adaptiveLimiter{limit: clamp(2, low, high), min: low, max: high}
+	l.cond = sync.NewCond(&l.mu)
+	return l
+}
+
+func (l *adaptiveLimiter) acquire() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for l.active >= l.limit {
+		l.cond.Wait()
+	}
+	l.active++
+}
+
+func (l *adaptiveLimiter) release(elapsed time.Duration, failed bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.active--
+	defer l.cond.Broadcast()
+
+	const weight = 0.3
+	if l.latency == 0 {
+		l.latency = elapsed
+	} else {
+		l.latency = time.Duration(weight*float64(elapsed) + (1-weight)*float64(l.latency))
+	}
+	if l.fastest == 0 || l.latency < l.fastest {
+		l.fastest = l.latency
+	}
+	failure := 0.0
+	if failed {
+		failure = 1
+	}
+	l.errorRate = weight*failure + (1-weight)*l.errorRate
+
+	load, ok := loadAverage()
+	switch {
+	case l.errorRate > 0.5, l.latency > 2*l.fastest && l.fastest > 100*time.Millisecond,
+		ok && load > float64(runtime.NumCPU()):
+		l.limit = clamp(l.limit/2, l.min, l.max)
+	default:
+		l.limit = clamp(l.limit+1, l.min, l.max)
+	}
+}
+
+func clamp(n int, low int, high int) int {
+	return max(low, min(n, high))
+}
+
+// loadAverage returns the one minute load average, where the platform has
+// one that is cheap to read.
+func loadAverage() (float64, bool) {
+	data, err := os.ReadFile("/proc/loadavg")
+	if err != nil {
+		return 0, false
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return 0, false
+	}
+	load, err := strconv.ParseFloat(fields[0], 64)
+	return load, err == nil
+}
+
+// overlapLocks returns a lock for each repository that must not run at the
+// same time as another: linked worktrees of the same repository, which share
+// its refs and packs, and repositories nested in one another. Repositories
+// that overlap with none have no lock.
+func overlapLocks(repos []string) []*sync.Mutex {
+	// group is the union-find parent of each repository
+	group := make([]int, len(repos))
+	for i := range group {
+		group[i] = i
+	}
+	var find func(i int) int
+	find = func(i int) int {
+		if group[i] != i {
+			group[i] = find(group[i])
+		}
+		return group[i]
+	}
+	overlapping := make([]bool, len(repos))
+	union := func(i int, j int) {
+		group[find(i)] = find(j)
+		overlapping[i], overlapping[j] = true, true
+	}
+
+	byCommonDir := map[string]int{}
+	for i, repo := range repos {
+		key := filepath.Clean(repo)
+		if _, common, ok := findGitDir(repo); ok {
+			key = common
+		}
+		if j, ok := byCommonDir[key]; ok {
+			union(i, j)
+		} else {
+			byCommonDir[key] = i
+		}
+	}
+	for i, outer := range repos {
+		for j, inner := range repos {
+			if i != j && strings.HasPrefix(filepath.Clean(inner), filepath.Clean(outer)+string(filepath.Separator)) {
+				union(i, j)
+			}
+		}
+	}
+
+	locks := make([]*sync.Mutex, len(repos))
+	byGroup := map[int]*sync.Mutex{}
+	for i := range repos {
+		if !overlapping[i] {
+			continue
+		}
+		g := find(i)
+		if byGroup[g] == nil {
+			byGroup[g] = &sync.Mutex{}
+		}
+		locks[i] = byGroup[g]
+	}
+	return locks
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+func init() {
+	registerSubcommand(&subcommand{
+		name:    "patches",
+		summary: "export the repositories' commits as email patch series, or apply exported series, see `gits patches export|import`",
+		run:     runPatches,
+	})
+}
+
+// patchesManifest is the patches.json index of `gits patches export`.
+type patchesManifest struct {
+	SchemaVersion int            `json:"schema_version"`
+	Created       time.Time      `json:"created"`
+	Since         string         `json:"since"`
+	Repos         []patchesEntry `json:"repos"`
+}
+
+// patchesEntry is the patch series of one repository.
+type patchesEntry struct {
+	Path string `json:"path"`
+	// Base and Head are the commits the series goes from and to
+	Base string `json:"base"`
+	Head string `json:"head"`
+	// Patches are the files of the series relative to the manifest, in order
+	Patches []string `json:"patches"`
+}
+
+func runPatches(ws *workspace, args []string) int {
+	if len(args) > 0 {
+		switch args[0] {
+		case "export":
+			return runPatchesExport(ws, args[1:])
+		case "import":
+			return runPatchesImport(ws, args[1:])
+		}
+	}
+	fmt.Println("Usage: gits [options] patches export|import [patches options]")
+	return 1
+}
+
+func runPatchesExport(ws *workspace, args []string) int {
+	fs := newSubcommandFlags("patches export", "")
+	since := fs.String("since", "@{upstream}", "commit, tag or branch the series of each repository starts after")
+	out := fs.String("out", "patches", "directory to write the series and the patches.json manifest to")
+	coverLetter := fs.Bool("cover-letter", false, "also write a cover letter for each series")
+	fs.Parse(args)
+
+	if entries, _ := os.ReadDir(*out); len(entries) > 0 {
+		fmt.Printf("%s is not empty\n", *out)
+		return 1
+	}
+
+	var mu sync.Mutex
+	manifest := patchesManifest{SchemaVersion: schemaVersion, Created: time.Now(), Since: *since}
+	results, exitCode := forEachRepo(ws.repos, ws.parallel, func(repo string) (string, int) {
+		relPath := relativePath(ws.root, repo)
+		base, err := resolveCommit(repo, *since)
+		if err != nil {
+			return fmt.Sprintf("\033[1m⏭️ %s:\033[0m %v", relPath, err), 0
+		}
+		head, err := resolveCommit(repo, "HEAD")
+		if err != nil {
+			return fmt.Sprintf("\033[1m❌ %s:\033[0m %v", relPath, err), 1
+		}
+		if base == head {
+			return "", 0
+		}
+
+		dir, err := filepath.Abs(filepath.Join(*out, relPath))
+		if err != nil {
+			return fmt.Sprintf("\033[1m❌ %s:\033[0m %v", relPath, err), 1
+		}
+		formatArgs := []string{"format-patch", "-o", dir}
+		if *coverLetter {
+			formatArgs = append(formatArgs, "--cover-letter")
+		}
+		files, err := runGit(repo, append(formatArgs, base+"..HEAD")...)
+		if err != nil {
+			return fmt.Sprintf("\033[1m❌ %s:\033[0m %v", relPath, err), 1
+		}
+
+		entry := patchesEntry{Path: filepath.ToSlash(relPath), Base: base, Head: head}
+		for _, f := range strings.Split(files, "\n") {
+			if f != "" {
+				entry.Patches = append(entry.Patches, filepath.ToSlash(filepath.Join(relPath, filepath.Base(f))))
+			}
+		}
+		mu.Lock()
+		manifest.Repos = append(manifest.Repos, entry)
+		mu.Unlock()
+		return fmt.Sprintf("\033[1m✅️ %s:\033[0m %d patches", relPath, len(entry.Patches)), 0
+	})
+	printResults(results)
+
+	if len(manifest.Repos) == 0 {
+		fmt.Println("No commits to export")
+		return exitCode
+	}
+	slices.SortFunc(manifest.Repos, func(a, b patchesEntry) int { return strings.Compare(a.Path, b.Path) })
+	data, _ := json.MarshalIndent(manifest, "", "  ")
+	if err := os.WriteFile(filepath.Join(*out, "patches.json"), append(data, '\n'), 0o644); err != nil {
+		fmt.Println("Error writing manifest:", err)
+		return 1
+	}
+	return exitCode
+}
+
+func runPatchesImport(ws *workspace, args []string) int {
+	fs := newSubcommandFlags("patches import", "")
+	in := fs.String("in", "patches", "directory with the patches.json manifest written by gits patches export")
+	threeWay := fs.Bool("3way", false, "fall back to a three way merge where a patch does not apply cleanly")
+	fs.Parse(args)
+
+	// git am runs in each repository
+	dir, err := filepath.Abs(*in)
+	if err != nil {
+		fmt.Println("Error resolving -in:", err)
+		return 1
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "patches.json"))
+	if err != nil {
+		fmt.Println("Error reading manifest:", err)
+		return 1
+	}
+	var manifest patchesManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		fmt.Println("Error reading manifest:", err)
+		return 1
+	}
+	series := make(map[string]patchesEntry)
+	var repos []string
+	for _, entry := range manifest.Repos {
+		repo := filepath.Join(ws.root, filepath.FromSlash(entry.Path))
+		if !slices.Contains(ws.repos, repo) {
+			fmt.Printf("\033[1m⏭️ %s:\033[0m not a matched repository\n", entry.Path)
+			continue
+		}
+		series[repo] = entry
+		repos = append(repos, repo)
+	}
+
+	release, ok := ws.lock("patches import")
+	if !ok {
+		return 1
+	}
+	defer release()
+
+	policy, err := ws.commitPolicy()
+	if err != nil {
+		fmt.Println("Error reading manifest:", err)
+		return 1
+	}
+
+	results, exitCode := forEachRepo(repos, ws.parallel, func(repo string) (string, int) {
+		entry := series[repo]
+		amArgs := append([]string{"am", "-q"}, policy.amArgs()...)
+		if *threeWay {
+			amArgs = append(amArgs, "-3")
+		}
+		var patches []string
+		for _, p := range entry.Patches {
+			if !strings.HasSuffix(p, "/0000-cover-letter.patch") {
+				patches = append(patches, filepath.Join(dir, filepath.FromSlash(p)))
+			}
+		}
+		if _, err := runGit(repo, append(amArgs, patches...)...); err != nil {
+			runGit(repo, "am", "--abort")
+			return fmt.Sprintf("\033[1m❌ %s:\033[0m %v", entry.Path, err), 1
+		}
+		return fmt.Sprintf("\033[1m✅️ %s:\033[0m applied %d patches", entry.Path, len(patches)), 0
+	})
+	printResults(results)
+	return exitCode
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+func init() {
+	registerSubcommand(&subcommand{
+		name:    "pickaxe",
+		summary: "find the commits that added or removed a string across the repositories, oldest first",
+		run:     runPickaxe,
+	})
+}
+
+func runPickaxe(ws *workspace, args []string) int {
+	fs := newSubcommandFlags("pickaxe", " <string>")
+	regex := fs.Bool("regex", false, "match commits whose diff has a line matching the regular expression (git log -G) instead of changing the number of occurrences of the string (-S)")
+	all := fs.Bool("all", false, "search the commits of all branches instead of only HEAD")
+	since := fs.String("since", "", "only search commits newer than this age, e.g. 90d")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 1
+	}
+
+	logArgs := []string{"-S" + fs.Arg(0)}
+	if *regex {
+		logArgs = []string{"-G" + fs.Arg(0)}
+	}
+	if *all {
+		logArgs = append(logArgs, "--all")
+	}
+	if *since != "" {
+		age, err := parseAge(*since)
+		if err != nil {
+			fmt.Println("Invalid -since:", err)
+			return 1
+		}
+		logArgs = append(logArgs, "--since="+time.Now().Add(-age).Format(time.RFC3339))
+	}
+
+	type match struct {
+		relPath string
+		commit  commitInfo
+	}
+	var mu sync.Mutex
+	var matches []match
+
+	results, exitCode := forEachRepo(ws.repos, ws.parallel, func(repo string) (string, int) {
+		relPath := relativePath(ws.root, repo)
+		commits, err := getCommits(repo, logArgs...)
+		if err != nil {
+			return fmt.Sprintf("\033[1m❌ %s:\033[0m %v", relPath, errorReason(err)), 1
+		}
+		// git lists the newest first, so commits made in the same second
+		// stay in order when sorting oldest first
+		slices.Reverse(commits)
+		mu.Lock()
+		for _, c := range commits {
+			matches = append(matches, match{relPath: relPath, commit: c})
+		}
+		mu.Unlock()
+		return "", 0
+	})
+	printResults(results)
+
+	slices.SortStableFunc(matches, func(a, b match) int { return a.commit.when.Compare(b.commit.when) })
+	for _, m := range matches {
+		fmt.Printf("%s \033[1m%s\033[0m %.7s %s \033[2m(%s)\033[0m\n", formatDate(m.commit.when), m.relPath, m.commit.hash, m.commit.subject, m.commit.authorName)
+	}
+
+	if exitCode == 0 && len(matches) == 0 {
+		return 1
+	}
+	return exitCode
+}
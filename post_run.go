@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// runSummary is how a run of a command went, given to the post-run hook as
+// JSON on its stdin and to -summary-template.
+type runSummary struct {
+	SchemaVersion int       `json:"schema_version"`
+	Command       []string  `json:"command"`
+	Root          string    `json:"root"`
+	Started       time.Time `json:"started"`
+	DurationMS    int64     `json:"duration_ms"`
+	ExitCode      int       `json:"exit_code"`
+	OK            int       `json:"ok"`
+	// Failed, Blocked and Skipped are the paths of the repositories where
+	// the command failed, was not run as a dependency failed, and was not
+	// run for any other reason
+	Failed  []string `json:"failed"`
+	Blocked []string `json:"blocked"`
+	Skipped []string `json:"skipped"`
+	// Repos are the results of the repositories the command ran in
+	Repos       []runSummaryRepo `json:"repos"`
+	GitsVersion string           `json:"gits_version"`
+}
+
+// runSummaryRepo is the result of a repository in a run summary.
+type runSummaryRepo struct {
+	Path       string        `json:"path"`
+	ExitCode   int           `json:"exit_code"`
+	Output     string        `json:"output"`
+	DurationMS int64         `json:"duration_ms"`
+	Resources  resourceUsage `json:"resources"`
+	// Steps are the runs of a command run several times, with -retries or
+	// -until-ok and -until-output, and FirstFailingStep the first that failed
+	Steps            []stepResult `json:"steps,omitempty"`
+	FirstFailingStep string       `json:"first_failing_step,omitempty"`
+}
+
+// summaryFuncs are the functions available to -summary-template.
+var summaryFuncs = template.FuncMap{
+	"json": formatFuncs["json"],
+	"join": strings.Join,
+}
+
+// newRunSummary collects how the run went from its outcomes and, if they
+// were recorded, the results of each repository.
+func newRunSummary(command []string, root string, started time.Time, exitCode int, o *runOutcomes, skipped []string, runs []repoRun) runSummary {
+	s := runSummary{
+		SchemaVersion: schemaVersion,
+		Command:       command,
+		Root:          root,
+		Started:       started,
+		DurationMS:    time.Since(started).Milliseconds(),
+		ExitCode:      exitCode,
+		Repos:         []runSummaryRepo{},
+		GitsVersion:   gitsVersion(),
+	}
+	o.mu.Lock()
+	s.OK = o.ok
+	rel := func(paths []string) []string {
+		r := make([]string, len(paths))
+		for i, path := range paths {
+			r[i] = relativePath(root, path)
+		}
+		sortNames(r)
+		return r
+	}
+	s.Failed, s.Blocked, s.Skipped = rel(o.failures), rel(o.blocked), rel(append(skipped, o.notRun...))
+	o.mu.Unlock()
+
+	for _, run := range runs {
+		if run.Skipped != "" {
+			continue
+		}
+		s.Repos = append(s.Repos, runSummaryRepo{Path: run.RelPath, ExitCode: run.ExitCode, Output: run.Output, DurationMS: run.Duration.Milliseconds(), Resources: run.Usage, Steps: run.Steps, FirstFailingStep: firstFailingStep(run.Steps)})
+	}
+	return s
+}
+
+// line formats the summary with the template, or as the usual "3 ok, 1
+// failed: api" line without one.
+func (s runSummary) line(tmpl *template.Template) (string, error) {
+	if tmpl == nil {
+		line := tr("%d ok, %d failed", s.OK, len(s.Failed))
+		if len(s.Failed) > 0 {
+			line += ": " + strings.Join(s.Failed, ", ")
+		}
+		if len(s.Blocked) > 0 {
+			line += tr(", %d blocked", len(s.Blocked))
+		}
+		if len(s.Skipped) > 0 {
+			line += tr(", %d skipped", len(s.Skipped))
+		}
+		return line, nil
+	}
+	var out strings.Builder
+	if err := tmpl.Execute(&out, s); err != nil {
+		return "", err
+	}
+	return strings.TrimRight(out.String(), "\n"), nil
+}
+
+// runPostRunHook runs the hook in the workspace root with the summary as
+// JSON on its stdin, and its output going to that of gits.
+func runPostRunHook(hook []string, s runSummary) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(hook[0], hook[1:]...)
+	cmd.Dir = s.Root
+	cmd.Stdin = bytes.NewReader(data)
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	cmd.Env = append(os.Environ(), fmt.Sprintf("GITS_EXIT_CODE=%d", s.ExitCode))
+	return cmd.Run()
+}
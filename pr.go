@@ -0,0 +1,93 @@
+//go:build !noforge
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	registerSubcommand(&subcommand{
+		name:    "pr",
+		summary: "check out a pull request in the repository it belongs to, see `gits pr checkout`",
+		run:     runPullRequest,
+	})
+}
+
+func runPullRequest(ws *workspace, args []string) int {
+	if len(args) == 0 || args[0] != "checkout" {
+		fmt.Println("Usage: gits [options] pr checkout <number|url>")
+		return 1
+	}
+
+	fs := newSubcommandFlags("pr checkout", " <number|url>")
+	fs.Parse(args[1:])
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 1
+	}
+
+	repo, number, err := resolvePullRequest(ws, fs.Arg(0))
+	if err != nil {
+		fmt.Println("Error finding the pull request:", err)
+		return 1
+	}
+	relPath := relativePath(ws.root, repo)
+
+	release, ok := ws.lock("pr checkout")
+	if !ok {
+		return 1
+	}
+	defer release()
+
+	client, _, err := forgeClientFor(ws, repo)
+	if err != nil {
+		fmt.Printf("Error checking out #%d in %s: %v\n", number, relPath, err)
+		return 1
+	}
+	branch, err := checkoutPullRequest(repo, client, number)
+	if err != nil {
+		fmt.Printf("Error checking out #%d in %s: %v\n", number, relPath, err)
+		return 1
+	}
+	fmt.Printf("Checked out #%d on %s in %s\n", number, branch, relPath)
+	return 0
+}
+
+// resolvePullRequest finds the local repository and number of the pull
+// request. A URL is matched against the origin of each repository, a bare
+// number is of the only repository or the one containing the current directory.
+func resolvePullRequest(ws *workspace, arg string) (string, int, error) {
+	if number, err := strconv.Atoi(arg); err == nil {
+		if len(ws.repos) == 1 {
+			return ws.repos[0], number, nil
+		}
+		cwd, err := os.Getwd()
+		if err != nil {
+			return "", 0, err
+		}
+		if top, err := getTopLevel(cwd); err == nil && slices.Contains(ws.repos, top) {
+			return top, number, nil
+		}
+		return "", 0, fmt.Errorf("give the URL of pull request #%d, or run in its repository", number)
+	}
+
+	target, number, ok := parsePullRequestURL(arg)
+	if !ok {
+		return "", 0, fmt.Errorf("not a pull request number or URL: %s", arg)
+	}
+	for _, repo := range ws.repos {
+		remote, err := getConfigValue(repo, "remote.origin.url")
+		if err != nil {
+			continue
+		}
+		if fr, ok := parseRemoteURL(remote); ok && fr.host == target.host && strings.EqualFold(fr.path, target.path) {
+			return repo, number, nil
+		}
+	}
+	return "", 0, fmt.Errorf("no repository in the workspace has %s as its origin", target)
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+func init() {
+	registerSubcommand(&subcommand{
+		name:    "profiles",
+		summary: "list the configuration profiles",
+		run:     runProfiles,
+
+		standalone: true,
+	})
+}
+
+func runProfiles(ws *workspace, args []string) int {
+	fs := newSubcommandFlags("profiles", "")
+	fs.Parse(args)
+
+	if len(ws.config.Profiles) == 0 {
+		fmt.Printf("No profiles configured in %s\n", ws.config.path)
+		return 0
+	}
+
+	names := make([]string, 0, len(ws.config.Profiles))
+	for name := range ws.config.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		prof, _ := ws.config.profile(name)
+		marker := " "
+		if prof == ws.profile {
+			marker = "*"
+		}
+		if a11yOutput {
+			marker = "profile"
+			if prof == ws.profile {
+				marker = "current profile"
+			}
+		}
+		fmt.Println(plain(fmt.Sprintf("%s \033[1m%s\033[0m %s", marker, name, strings.Join(prof.Roots, ", "))))
+		if len(prof.Flags) > 0 {
+			fmt.Printf("    flags: %s\n", strings.Join(prof.Flags, " "))
+		}
+		if len(prof.Exclude) > 0 {
+			fmt.Printf("    exclude: %s\n", strings.Join(prof.Exclude, ", "))
+		}
+		for host := range prof.Forges {
+			fmt.Printf("    forge: %s\n", host)
+		}
+	}
+	return 0
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+)
+
+// progressJSON is set with -progress-json to report the progress of runs as
+// NDJSON events on stderr, for wrappers such as IDE plugins to show their
+// own progress, instead of the progress line.
+var progressJSON bool
+
+// progressEvent is a line of -progress-json: started or finished for each
+// repository, progress every second, and done at the end of each batch.
+type progressEvent struct {
+	SchemaVersion int       `json:"schema_version"`
+	Event         string    `json:"event"`
+	Time          time.Time `json:"time"`
+	// Path is the repository that started or finished
+	Path     string `json:"path,omitempty"`
+	ExitCode *int   `json:"exit_code,omitempty"`
+	// Duration is how long the command took in the repository that finished
+	Duration  float64 `json:"duration_seconds,omitempty"`
+	Completed int     `json:"completed"`
+	Running   int     `json:"running"`
+	Total     int     `json:"total"`
+	// ETA is the estimated time left, once a repository has finished
+	ETA *float64 `json:"eta_seconds,omitempty"`
+}
+
+// progressReporter writes the -progress-json events of a run.
+type progressReporter struct {
+	mu        sync.Mutex
+	enc       *json.Encoder
+	start     time.Time
+	total     int
+	completed int
+	running   int
+
+	// stopTicker stops the progress events, waiting for them to stop
+	stopTicker func()
+}
+
+// newProgressReporter starts reporting the progress of a run of total
+// repositories, with a progress event every second until it is done.
+func newProgressReporter(total int) *progressReporter {
+	p := &progressReporter{enc: json.NewEncoder(os.Stderr), start: time.Now(), total: total}
+	ticker := time.NewTicker(1 * time.Second)
+	done, stopped := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(stopped)
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				p.progress()
+			}
+		}
+	}()
+	p.stopTicker = func() {
+		ticker.Stop()
+		close(done)
+		<-stopped
+	}
+	return p
+}
+
+func (p *progressReporter) started(path string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.running++
+	p.write(progressEvent{Event: "started", Path: path})
+}
+
+func (p *progressReporter) finished(path string, exitCode int, elapsed time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.running--
+	p.completed++
+	p.write(progressEvent{Event: "finished", Path: path, ExitCode: &exitCode, Duration: elapsed.Seconds()})
+}
+
+func (p *progressReporter) progress() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.write(progressEvent{Event: "progress"})
+}
+
+// done stops the progress events and reports the end of the run.
+func (p *progressReporter) done() {
+	p.stopTicker()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.write(progressEvent{Event: "done"})
+}
+
+// write fills in the counts and estimate of the event and writes it.
+func (p *progressReporter) write(e progressEvent) {
+	e.SchemaVersion = schemaVersion
+	e.Time = time.Now().UTC()
+	e.Completed, e.Running, e.Total = p.completed, p.running, p.total
+	if p.completed > 0 {
+		// as long again per repository as those so far took on average
+		eta := time.Since(p.start).Seconds() / float64(p.completed) * float64(p.total-p.completed)
+		e.ETA = &eta
+	}
+	p.enc.Encode(e)
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+func init() {
+	registerSubcommand(&subcommand{
+		name:    "prompt-info",
+		summary: "print the branch and status of one repository, for shell prompts",
+		run:     runPromptInfo,
+
+		standalone: true,
+	})
+}
+
+// promptInfo is the summary of a repository printed by `gits prompt-info`.
+type promptInfo struct {
+	SchemaVersion int             `json:"schema_version"`
+	Path          string          `json:"path"`
+	Branch        string          `json:"branch"`
+	Dirty         bool            `json:"dirty"`
+	Sync          RemoteSyncState `json:"sync"`
+	// Ahead and Behind count the commits against the upstream, they are not
+	// known from the daemon
+	Ahead  int `json:"ahead,omitempty"`
+	Behind int `json:"behind,omitempty"`
+	// Source is where the summary came from, git or daemon
+	Source string `json:"source"`
+}
+
+// short is the compact form for a prompt, e.g. `main* ↑2 ↓1`.
+func (p promptInfo) short() string {
+	s := p.Branch
+	if p.Dirty {
+		s += "*"
+	}
+	switch {
+	case p.Ahead > 0 || p.Behind > 0:
+		if p.Ahead > 0 {
+			s += " ↑" + strconv.Itoa(p.Ahead)
+		}
+		if p.Behind > 0 {
+			s += " ↓" + strconv.Itoa(p.Behind)
+		}
+	case p.Sync == AheadRemote:
+		s += " ↑"
+	case p.Sync == BehindRemote:
+		s += " ↓"
+	}
+	return s
+}
+
+func runPromptInfo(ws *workspace, args []string) int {
+	fs := newSubcommandFlags("prompt-info", " [repo-path]")
+	short := fs.Bool("short", false, "print a compact summary such as `main* ↑2 ↓1` instead of JSON")
+	daemon := fs.Bool("daemon", false, "use the last status snapshot of a `gits serve` daemon if it has one, instead of running git")
+	server := fs.String("server", "", "address of the daemon (default: serve.listen from the configuration)")
+	fs.Parse(args)
+
+	dir := "."
+	if fs.NArg() > 0 {
+		dir = fs.Arg(0)
+	}
+
+	// Prompts run this outside of repositories all the time, so that is
+	// quietly an error
+	path, err := getTopLevel(dir)
+	if err != nil {
+		return 1
+	}
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+
+	var info *promptInfo
+	if *daemon {
+		info = daemonPromptInfo(ws, *server, path)
+	}
+	if info == nil {
+		s, err := getStatusSummary(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error reading status:", errorReason(err))
+			return 1
+		}
+		info = &promptInfo{SchemaVersion: schemaVersion, Path: path, Branch: s.Branch, Dirty: s.Dirty, Ahead: s.Ahead, Behind: s.Behind, Source: "git"}
+		switch {
+		case s.Behind > 0:
+			info.Sync = BehindRemote
+		case s.Ahead > 0:
+			info.Sync = AheadRemote
+		}
+	}
+
+	if *short {
+		fmt.Println(info.short())
+		return 0
+	}
+	data, _ := json.Marshal(info)
+	fmt.Println(string(data))
+	return 0
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+func init() {
+	registerAudit(&subcommand{
+		name:    "stale-remotes",
+		summary: "count the remote-tracking refs of branches deleted upstream",
+		run:     runAuditStaleRemotes,
+	})
+	registerSubcommand(&subcommand{
+		name:    "prune-remotes",
+		summary: "delete the remote-tracking refs of branches deleted upstream",
+		run:     runPruneRemotes,
+	})
+}
+
+// staleRemotesState is the number of stale remote-tracking refs of each
+// repository, by absolute path, as last found by `gits audit stale-remotes`,
+// for status to show without asking the remotes.
+type staleRemotesState struct {
+	Repos map[string]int `json:"repos"`
+}
+
+// staleRemoteCounts reads the state once for all the repositories.
+var staleRemoteCounts = sync.OnceValue(func() map[string]int {
+	var state staleRemotesState
+	readState("stale-remotes.json", &state)
+	return state.Repos
+})
+
+// recordStaleRemotes updates the counts of the given repositories in the state.
+func recordStaleRemotes(counts map[string]int) {
+	var state staleRemotesState
+	if err := readState("stale-remotes.json", &state); err != nil {
+		fmt.Println("Error reading stale remotes:", err)
+		return
+	}
+	if state.Repos == nil {
+		state.Repos = make(map[string]int)
+	}
+	for path, n := range counts {
+		if n == 0 {
+			delete(state.Repos, path)
+		} else {
+			state.Repos[path] = n
+		}
+	}
+	if err := writeState("stale-remotes.json", state); err != nil {
+		fmt.Println("Error saving stale remotes:", err)
+	}
+}
+
+func runAuditStaleRemotes(ws *workspace, args []string) int {
+	fs := newSubcommandFlags("audit stale-remotes", "")
+	verbose := fs.Bool("v", false, "list the stale refs")
+	fs.Parse(args)
+
+	var mu sync.Mutex
+	counts := make(map[string]int)
+	results, exitCode := forEachRepo(ws.repos, ws.parallel, func(repo string) (string, int) {
+		relPath := relativePath(ws.root, repo)
+		remotes, err := getRemotes(repo)
+		if err != nil {
+			return fmt.Sprintf("\033[1m❌ %s:\033[0m %v", relPath, err), 1
+		}
+		var stale []string
+		for _, remote := range remotes {
+			refs, err := getStaleRemoteRefs(repo, remote)
+			if err != nil {
+				return fmt.Sprintf("\033[1m❌ %s:\033[0m %s: %v", relPath, remote, err), 1
+			}
+			stale = append(stale, refs...)
+		}
+		mu.Lock()
+		counts[repo] = len(stale)
+		mu.Unlock()
+
+		if len(stale) == 0 {
+			return "", 0
+		}
+		result := fmt.Sprintf("\033[1m⚠️ %s:\033[0m %d stale remote-tracking refs, run `gits prune-remotes`", relPath, len(stale))
+		if *verbose {
+			result += "\n  " + strings.Join(stale, "\n  ")
+		}
+		return result, 1
+	})
+	printResults(results)
+	recordStaleRemotes(counts)
+	return exitCode
+}
+
+func runPruneRemotes(ws *workspace, args []string) int {
+	fs := newSubcommandFlags("prune-remotes", "")
+	fs.Parse(args)
+
+	release, ok := ws.lock("prune-remotes")
+	if !ok {
+		return 1
+	}
+	defer release()
+
+	var mu sync.Mutex
+	counts := make(map[string]int)
+	results, exitCode := forEachRepo(ws.repos, ws.parallel, func(repo string) (string, int) {
+		relPath := relativePath(ws.root, repo)
+		remotes, err := getRemotes(repo)
+		if err != nil {
+			return fmt.Sprintf("\033[1m❌ %s:\033[0m %v", relPath, err), 1
+		}
+		pruned := 0
+		for _, remote := range remotes {
+			out, err := exec.Command("git", "-C", repo, "remote", "prune", remote).Output()
+			if err != nil {
+				return fmt.Sprintf("\033[1m❌ %s:\033[0m %s: %s", relPath, remote, errorReason(err)), 1
+			}
+			pruned += strings.Count(string(out), "[pruned] ")
+		}
+		mu.Lock()
+		counts[repo] = 0
+		mu.Unlock()
+
+		if pruned == 0 {
+			return "", 0
+		}
+		return fmt.Sprintf("\033[1m✅️ %s:\033[0m pruned %d remote-tracking refs", relPath, pruned), 0
+	})
+	printResults(results)
+	recordStaleRemotes(counts)
+	return exitCode
+}
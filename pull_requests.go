@@ -0,0 +1,72 @@
+//go:build !noforge
+
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// pullRequest is a GitHub pull request or GitLab merge request.
+type pullRequest struct {
+	Host   string `json:"host"`
+	Repo   string `json:"repo"`
+	Number int    `json:"number"`
+	Title  string `json:"title"`
+	Author string `json:"author"`
+	URL    string `json:"url"`
+}
+
+// pullRequestRef is the ref the forge publishes the head of the pull request as.
+func (c *forgeClient) pullRequestRef(number int) string {
+	if c.kind == forgeGitLab {
+		return fmt.Sprintf("refs/merge-requests/%d/head", number)
+	}
+	return fmt.Sprintf("refs/pull/%d/head", number)
+}
+
+// checkoutPullRequest fetches the head of the pull request from origin and
+// checks it out on a local branch, pr/<number> or mr/<number>, which is
+// reset to it if it already exists.
+func checkoutPullRequest(repo string, c *forgeClient, number int) (string, error) {
+	branch := fmt.Sprintf("pr/%d", number)
+	if c.kind == forgeGitLab {
+		branch = fmt.Sprintf("mr/%d", number)
+	}
+
+	if out, err := exec.Command("git", "-C", repo, "fetch", "--quiet", "origin", c.pullRequestRef(number)).CombinedOutput(); err != nil {
+		return "", fmt.Errorf("%s", strings.TrimSpace(string(out)))
+	}
+	if out, err := exec.Command("git", "-C", repo, "checkout", "--quiet", "-B", branch, "FETCH_HEAD").CombinedOutput(); err != nil {
+		return "", fmt.Errorf("%s", strings.TrimSpace(string(out)))
+	}
+	return branch, nil
+}
+
+// parsePullRequestURL parses the web URL of a GitHub pull request,
+// https://host/owner/name/pull/<n>, or GitLab merge request,
+// https://host/group/name/-/merge_requests/<n>.
+func parsePullRequestURL(s string) (forgeRepo, int, bool) {
+	u, err := url.Parse(s)
+	if err != nil || u.Host == "" {
+		return forgeRepo{}, 0, false
+	}
+	path := strings.Trim(u.Path, "/")
+	repo, rest, ok := strings.Cut(path, "/-/merge_requests/")
+	if !ok {
+		repo, rest, ok = strings.Cut(path, "/pull/")
+	}
+	if !ok {
+		return forgeRepo{}, 0, false
+	}
+	// the URL may be of a tab of the pull request such as /files
+	rest, _, _ = strings.Cut(rest, "/")
+	number, err := strconv.Atoi(rest)
+	if err != nil || !strings.Contains(repo, "/") {
+		return forgeRepo{}, 0, false
+	}
+	return forgeRepo{host: strings.ToLower(u.Hostname()), path: repo}, number, true
+}
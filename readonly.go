@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// defaultWritePatterns are the commands -read-only refuses unless the
+// configuration has its own write_patterns.
+var defaultWritePatterns = []string{
+	"git add", "git am", "git apply", "git branch -d", "git branch -D", "git branch -m", "git branch -M",
+	"git checkout", "git cherry-pick", "git clean", "git commit", "git gc", "git merge", "git mv",
+	"git pull", "git push", "git rebase", "git remote add", "git remote remove", "git remote rename",
+	"git remote set-url", "git reset", "git restore", "git revert", "git rm", "git stash",
+	"git submodule sync", "git submodule update", "git switch", "git tag -d", "git update-ref",
+	"rm", "mv",
+}
+
+// writePatterns returns the write_patterns of the configuration, or the
+// default ones.
+func (c *config) writePatterns() []string {
+	if len(c.WritePatterns) > 0 {
+		return c.WritePatterns
+	}
+	return defaultWritePatterns
+}
+
+// isWriteCommand reports whether the command matches a write pattern.
+func (c *config) isWriteCommand(command []string) bool {
+	_, ok := matchWritePattern(c.writePatterns(), command)
+	return ok
+}
+
+// checkWrite refuses, in -read-only mode, a command matching a write
+// pattern. Every way of running a command in the repositories checks it:
+// gits itself, gits shell, and the runs and jobs of gits serve.
+func (ws *workspace) checkWrite(command []string) error {
+	if !ws.readOnly {
+		return nil
+	}
+	if pattern, ok := matchWritePattern(ws.config.writePatterns(), command); ok {
+		return fmt.Errorf("refusing to run %s in -read-only mode, it matches the write pattern `%s`", strings.Join(command, " "), pattern)
+	}
+	return nil
+}
+
+// matchWritePattern returns the first of the patterns that matches the
+// command. The first word of a pattern matches the command's name and, for
+// git, the second the git subcommand, after any git options. The rest are
+// arguments that must all be given. Words are matched as path.Match globs.
+func matchWritePattern(patterns []string, command []string) (string, bool) {
+	if len(command) == 0 {
+		return "", false
+	}
+	name := filepath.Base(command[0])
+	args := command[1:]
+	var subcommand string
+	if name == "git" {
+		for i := 0; i < len(args); i++ {
+			if args[i] == "-C" || args[i] == "-c" {
+				// options taking a value
+				i++
+				continue
+			}
+			if !strings.HasPrefix(args[i], "-") {
+				subcommand, args = args[i], args[i+1:]
+				break
+			}
+		}
+	}
+
+	for _, pattern := range patterns {
+		words := strings.Fields(pattern)
+		if len(words) == 0 {
+			continue
+		}
+		if ok, _ := path.Match(words[0], name); !ok {
+			continue
+		}
+		rest := words[1:]
+		if name == "git" && len(rest) > 0 {
+			if ok, _ := path.Match(rest[0], subcommand); !ok {
+				continue
+			}
+			rest = rest[1:]
+		}
+		if allArgsGiven(rest, args) {
+			return pattern, true
+		}
+	}
+	return "", false
+}
+
+// allArgsGiven reports whether each of the patterns matches one of the args.
+func allArgsGiven(patterns []string, args []string) bool {
+	for _, p := range patterns {
+		found := false
+		for _, arg := range args {
+			if ok, _ := path.Match(p, arg); ok {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMatchWritePattern(t *testing.T) {
+	tests := []struct {
+		patterns []string
+		command  string
+		want     string
+	}{
+		{defaultWritePatterns, "git push", "git push"},
+		{defaultWritePatterns, "git push --force origin main", "git push"},
+		{defaultWritePatterns, "/usr/bin/git commit -m x", "git commit"},
+		{defaultWritePatterns, "git -C sub -c user.name=x commit", "git commit"},
+		{defaultWritePatterns, "git --no-pager reset --hard", "git reset"},
+		{defaultWritePatterns, "git branch -D topic", "git branch -D"},
+		{defaultWritePatterns, "git branch -a", ""},
+		{defaultWritePatterns, "git status", ""},
+		{defaultWritePatterns, "git log --oneline", ""},
+		{defaultWritePatterns, "git -c push.default=current log", ""},
+		{defaultWritePatterns, "rm -rf build", "rm"},
+		{defaultWritePatterns, "ls", ""},
+		{[]string{"make deploy*"}, "make deploy-prod", "make deploy*"},
+		{[]string{"make deploy*"}, "make -j4 deploy-prod", "make deploy*"},
+		{[]string{"make deploy*"}, "make test", ""},
+		{[]string{"git remote set-*"}, "git remote set-url origin x", "git remote set-*"},
+		{[]string{"git remote set-*"}, "git set-url", ""},
+		{[]string{"git stash"}, "git stash list", "git stash"},
+		{[]string{"*"}, "anything at all", "*"},
+		{[]string{""}, "git push", ""},
+	}
+	for _, tt := range tests {
+		got, ok := matchWritePattern(tt.patterns, strings.Fields(tt.command))
+		if got != tt.want || ok != (tt.want != "") {
+			t.Errorf("matchWritePattern(%q, %q) = %q, %v, want %q", tt.patterns, tt.command, got, ok, tt.want)
+		}
+	}
+}
+
+// commitCount returns the number of commits of HEAD of the repository.
+func commitCount(t *testing.T, repo string) string {
+	t.Helper()
+	out, err := exec.Command("git", "-C", repo, "rev-list", "--count", "HEAD").Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func TestReadOnlyRefusesCommands(t *testing.T) {
+	// gits itself, run by the test binary in a child process
+	if args := os.Getenv("GITS_TEST_MAIN_ARGS"); args != "" {
+		os.Args = append([]string{"gits"}, strings.Split(args, "\n")...)
+		main()
+		return
+	}
+
+	repo := initTestRepo(t)
+	t.Setenv("GITS_CONFIG", filepath.Join(t.TempDir(), "config.yaml"))
+	os.WriteFile(os.Getenv("GITS_CONFIG"), []byte("read_only: true\n"), 0o644)
+
+	t.Run("gits", func(t *testing.T) {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestReadOnlyRefusesCommands$")
+		cmd.Env = append(os.Environ(), "GITS_TEST_MAIN_ARGS="+strings.Join([]string{"-root", repo, "-no-lock", "git", "commit", "--allow-empty", "-m", "x"}, "\n"))
+		out, err := cmd.CombinedOutput()
+		if err == nil {
+			t.Errorf("git commit did not fail in read only mode:\n%s", out)
+		}
+		if !strings.Contains(string(out), "read-only") {
+			t.Errorf("got output %q, want the refusal", out)
+		}
+	})
+
+	t.Run("shell", func(t *testing.T) {
+		ws := testWorkspace(t, repo)
+		ws.readOnly = true
+		sh := &gitsShell{ws: ws, all: []string{repo}, statuses: make(map[string]repoStatus)}
+		if code, _ := sh.execute("git commit --allow-empty -m x"); code == 0 {
+			t.Error("git commit did not fail in read only mode")
+		}
+		if code, _ := sh.execute("git status"); code != 0 {
+			t.Errorf("git status exited with %d in read only mode", code)
+		}
+	})
+
+	t.Run("watch-run", func(t *testing.T) {
+		ws := testWorkspace(t, repo)
+		ws.readOnly = true
+		if code := runWatchRun(ws, []string{"git", "commit", "--allow-empty", "-m", "x"}); code == 0 {
+			t.Error("git commit did not fail in read only mode")
+		}
+	})
+
+	t.Run("gerrit push", func(t *testing.T) {
+		sub := subcommands["gerrit"]
+		if sub == nil {
+			t.Skip("built without the forges")
+		}
+		ws := testWorkspace(t, repo)
+		ws.readOnly = true
+		if code := sub.run(ws, []string{"push"}); code == 0 {
+			t.Error("gerrit push did not fail in read only mode")
+		}
+	})
+
+	if got := commitCount(t, repo); got != "1" {
+		t.Errorf("got %s commits, want the repository left as it was", got)
+	}
+}
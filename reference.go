@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+func init() {
+	registerSubcommand(&subcommand{
+		name:    "cache",
+		summary: "maintain the reference repositories clones borrow objects from, see `gits cache update`, and clear what gits caches with `gits cache clear`",
+		run:     runCache,
+
+		standalone: true,
+	})
+}
+
+// referencePath returns where the reference repository for the remote URL
+// is kept in dir, e.g. github.com/acme/api.git for git@github.com:acme/api.git.
+func referencePath(dir string, url string) string {
+	name := url
+	if _, rest, ok := strings.Cut(name, "://"); ok {
+		name = rest
+	} else if host, path, ok := strings.Cut(name, ":"); ok {
+		// scp-like syntax, user@host:path
+		name = host + "/" + path
+	}
+	if at := strings.Index(name, "@"); at >= 0 && !strings.Contains(name[:at], "/") {
+		name = name[at+1:]
+	}
+	name = strings.TrimSuffix(strings.TrimSuffix(name, "/"), ".git")
+
+	var parts []string
+	for _, p := range strings.Split(strings.ReplaceAll(name, ":", "/"), "/") {
+		if p != "" && p != "." && p != ".." {
+			parts = append(parts, p)
+		}
+	}
+	return filepath.Join(dir, filepath.Join(parts...)+".git")
+}
+
+// referenceDir returns the reference directory from the option or the configuration.
+func referenceDir(ws *workspace, option string) string {
+	if option != "" {
+		return option
+	}
+	return ws.config.ReferenceDir
+}
+
+func runCache(ws *workspace, args []string) int {
+	if len(args) > 0 {
+		switch args[0] {
+		case "dir":
+			return runCacheDir(args[1:])
+		case "clear":
+			return runCacheClear(args[1:])
+		}
+	}
+	if len(args) == 0 || args[0] != "update" {
+		fmt.Println("Usage: gits [options] cache update|dir|clear [cache options]")
+		return 1
+	}
+
+	fs := newSubcommandFlags("cache update", "")
+	dirOption := fs.String("reference-dir", "", "directory of the reference repositories (default: reference_dir from the configuration)")
+	fs.Parse(args[1:])
+
+	dir := referenceDir(ws, *dirOption)
+	if dir == "" {
+		fmt.Println("No reference directory, use -reference-dir or set reference_dir in the configuration")
+		return 1
+	}
+
+	m, err := findManifest(ws.root)
+	if err != nil {
+		fmt.Println("Error reading manifest:", err)
+		return 1
+	}
+	if m == nil {
+		fmt.Printf("No %s found in %s or above\n", manifestName, ws.root)
+		return 1
+	}
+
+	// Several repositories may share a remote
+	urls := make(map[string]string)
+	for _, r := range m.Repos {
+		if r.URL != "" && r.Retired == "" {
+			urls[referencePath(dir, r.URL)] = r.URL
+		}
+	}
+	paths := make([]string, 0, len(urls))
+	for path := range urls {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	results, exitCode := forEachRepo(paths, ws.parallel, func(path string) (string, int) {
+		return updateReference(path, urls[path], relativePath(dir, path))
+	})
+	printResults(results)
+	return exitCode
+}
+
+// updateReference fetches into the reference repository, creating it as a
+// mirror of the remote if it does not exist yet.
+func updateReference(path string, url string, relPath string) (string, int) {
+	var cmd *exec.Cmd
+	action := "updated"
+	if _, err := os.Stat(path); err == nil {
+		cmd = exec.Command("git", "-C", path, "fetch", "--quiet", "--prune")
+	} else {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return fmt.Sprintf("\033[1m❌ %s:\033[0m %v", relPath, err), 1
+		}
+		cmd = exec.Command("git", "clone", "--quiet", "--mirror", "--", url, path)
+		action = "created"
+	}
+
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Sprintf("\033[1m❌ %s:\033[0m\n  %s", relPath, strings.ReplaceAll(strings.TrimSpace(string(out)), "\n", "\n  ")), 1
+	}
+	return fmt.Sprintf("\033[1m✅️ %s:\033[0m %s from %s", relPath, action, url), 0
+}
@@ -0,0 +1,264 @@
+//go:build !noserve
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+func init() {
+	registerSubcommand(&subcommand{
+		name:    "remote-exec",
+		summary: "run a command on the repositories of several hosts, through their daemons or over ssh",
+		run:     runRemoteExec,
+
+		standalone: true,
+	})
+}
+
+// hostsFile lists the hosts `gits remote-exec` runs commands on.
+type hostsFile struct {
+	Hosts []remoteHost `yaml:"hosts"`
+}
+
+// remoteHost is a machine with repositories, reached either through its
+// `gits serve` daemon or by running gits over ssh.
+type remoteHost struct {
+	// Name prefixes the host's results
+	Name string `yaml:"name"`
+	// Server is the address of the host's daemon, e.g. build1:7878
+	Server string `yaml:"server"`
+	// Token is the daemon's bearer token, prefer TokenEnv to keep it out of the file
+	Token string `yaml:"token"`
+	// TokenEnv is the name of an environment variable holding the bearer token
+	TokenEnv string `yaml:"token_env"`
+	// SSH is the destination to run gits on over ssh, e.g. ci@build1
+	SSH string `yaml:"ssh"`
+	// Root is the directory searched for repositories over ssh (default: the login directory)
+	Root string `yaml:"root"`
+	// Gits is the gits executable on the host (default: gits)
+	Gits string `yaml:"gits"`
+}
+
+func (h remoteHost) token() string {
+	if h.TokenEnv != "" {
+		return os.Getenv(h.TokenEnv)
+	}
+	return h.Token
+}
+
+// remoteResult is the result of the command in one repository of a host.
+type remoteResult struct {
+	host     string
+	relPath  string
+	output   string
+	exitCode int
+}
+
+func (r remoteResult) String() string {
+	status := "✅️"
+	if r.exitCode != 0 {
+		status = "❌"
+	}
+	label := r.host
+	if r.relPath != "" {
+		label += ":" + r.relPath
+	}
+	return fmt.Sprintf("\033[1m%s %s:\033[0m\n  %s", status, label, strings.ReplaceAll(r.output, "\n", "\n  "))
+}
+
+func runRemoteExec(ws *workspace, args []string) int {
+	fs := newSubcommandFlags("remote-exec", " -- command [args...]")
+	hostsPath := fs.String("hosts", "", "YAML file listing the hosts (default: hosts.yaml next to the configuration file)")
+	var fopts filterOptions
+	fs.StringVar(&fopts.Branch, "branch", "", "only match repositories on this branch")
+	fs.BoolVar(&fopts.Dirty, "dirty", false, "only match repositories with a dirty worktree")
+	fs.BoolVar(&fopts.Clean, "clean", false, "only match repositories with a clean worktree")
+	fs.Parse(args)
+
+	command := fs.Args()
+	if len(command) == 0 {
+		fmt.Println("No command provided")
+		fs.Usage()
+		return 1
+	}
+
+	if *hostsPath == "" {
+		*hostsPath = filepath.Join(filepath.Dir(ws.config.path), "hosts.yaml")
+	}
+	data, err := os.ReadFile(*hostsPath)
+	if err != nil {
+		fmt.Println("Error reading hosts:", err)
+		return 1
+	}
+	var hosts hostsFile
+	if err := yaml.Unmarshal(data, &hosts); err != nil {
+		fmt.Printf("Error reading hosts: %s: %v\n", *hostsPath, err)
+		return 1
+	}
+	for _, h := range hosts.Hosts {
+		if h.Name == "" || (h.Server == "") == (h.SSH == "") {
+			fmt.Printf("Host `%s` needs a name and either a server or ssh destination\n", h.Name)
+			return 1
+		}
+	}
+
+	// Each host runs the command across its repositories in parallel, so the
+	// hosts are all contacted at once
+	perHost := make([][]remoteResult, len(hosts.Hosts))
+	var wg sync.WaitGroup
+	for i, h := range hosts.Hosts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var err error
+			if h.Server != "" {
+				perHost[i], err = remoteExecDaemon(h, command, fopts)
+			} else {
+				perHost[i], err = remoteExecSSH(h, command, fopts)
+			}
+			if err != nil {
+				perHost[i] = append(perHost[i], remoteResult{host: h.Name, output: err.Error(), exitCode: 1})
+			}
+		}()
+	}
+	wg.Wait()
+
+	exitCode := 0
+	for _, results := range perHost {
+		sort.Slice(results, func(a, b int) bool { return compareNames(results[a].relPath, results[b].relPath) < 0 })
+		for _, r := range results {
+			fmt.Println(r)
+			if r.exitCode != 0 {
+				exitCode = 1
+			}
+		}
+	}
+	return exitCode
+}
+
+// remoteExecDaemon starts the command through the daemon's API and collects
+// the streamed results.
+func remoteExecDaemon(h remoteHost, command []string, fopts filterOptions) ([]remoteResult, error) {
+	body, err := json.Marshal(runRequest{Command: command, filterOptions: fopts})
+	if err != nil {
+		return nil, err
+	}
+
+	base := h.Server
+	if !strings.Contains(base, "://") {
+		base = "http://" + base
+	}
+	req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(base, "/")+"/runs", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "text/event-stream")
+	if token := h.token(); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		var e struct {
+			Error string `json:"error"`
+		}
+		json.NewDecoder(resp.Body).Decode(&e)
+		return nil, fmt.Errorf("%s: %s", resp.Status, e.Error)
+	}
+
+	var results []remoteResult
+	var event string
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(nil, 64<<20)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if name, ok := strings.CutPrefix(line, "event: "); ok {
+			event = name
+		} else if data, ok := strings.CutPrefix(line, "data: "); ok {
+			switch event {
+			case "result":
+				var r jobRepoResult
+				if err := json.Unmarshal([]byte(data), &r); err != nil {
+					return results, err
+				}
+				results = append(results, remoteResult{host: h.Name, relPath: r.RelPath, output: r.Output, exitCode: r.ExitCode})
+			case "done":
+				return results, nil
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return results, err
+	}
+	return results, fmt.Errorf("the daemon stopped before the run finished")
+}
+
+// remoteExecSSH runs gits on the host over ssh, printing each result as JSON.
+func remoteExecSSH(h remoteHost, command []string, fopts filterOptions) ([]remoteResult, error) {
+	gits := h.Gits
+	if gits == "" {
+		gits = "gits"
+	}
+	remote := []string{gits, "-format", "{{json .}}", "-continue-on-error"}
+	if h.Root != "" {
+		remote = append(remote, "-root", h.Root)
+	}
+	if fopts.Branch != "" {
+		remote = append(remote, "-branch", fopts.Branch)
+	}
+	if fopts.Dirty {
+		remote = append(remote, "-dirty")
+	}
+	if fopts.Clean {
+		remote = append(remote, "-clean")
+	}
+	remote = append(remote, "--")
+	remote = append(remote, command...)
+
+	// ssh runs its arguments with the remote shell, so they are quoted for it
+	quoted := make([]string, len(remote))
+	for i, arg := range remote {
+		quoted[i] = shellQuote(arg)
+	}
+	cmd := exec.Command("ssh", "-o", "BatchMode=yes", h.SSH, strings.Join(quoted, " "))
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+
+	var results []remoteResult
+	var other []string
+	for _, line := range strings.Split(strings.TrimRight(string(out), "\n"), "\n") {
+		var r commandResult
+		if json.Unmarshal([]byte(line), &r) == nil && r.RelPath != "" {
+			results = append(results, remoteResult{host: h.Name, relPath: r.RelPath, output: r.Output, exitCode: r.ExitCode})
+		} else if line != "" {
+			// skipped repositories and errors from gits itself
+			other = append(other, line)
+		}
+	}
+	if len(results) == 0 && err != nil {
+		return nil, fmt.Errorf("%v\n%s", err, strings.TrimSpace(stderr.String()+"\n"+strings.Join(other, "\n")))
+	}
+	if len(other) > 0 {
+		results = append(results, remoteResult{host: h.Name, output: strings.Join(other, "\n"), exitCode: 1})
+	}
+	return results, nil
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// repoInfo describes where a repository is relative to the workspace root.
+// The same fields are available to -format templates and, as GITS_*
+// environment variables, to the commands that are run.
+type repoInfo struct {
+	// AbsPath is the absolute path of the repository
+	AbsPath string
+	// RelPath is the path of the repository relative to the workspace root
+	RelPath string
+	// Name is the last element of the repository path
+	Name string
+	// Parent is the directory containing the repository relative to the workspace root
+	Parent string
+	// Root is the absolute path of the workspace root
+	Root string
+	// VCS is the version control system of the repository: git, jj or sl
+	VCS string
+}
+
+func newRepoInfo(root string, path string) repoInfo {
+	relPath := relativePath(root, path)
+	return repoInfo{
+		AbsPath: path,
+		RelPath: relPath,
+		Name:    filepath.Base(path),
+		Parent:  filepath.Dir(relPath),
+		Root:    root,
+		VCS:     detectVCS(path).name,
+	}
+}
+
+// environ returns the environment variables describing the repository.
+func (r repoInfo) environ() []string {
+	return []string{
+		"GITS_ABS_PATH=" + r.AbsPath,
+		"GITS_REL_PATH=" + r.RelPath,
+		"GITS_NAME=" + r.Name,
+		"GITS_PARENT=" + r.Parent,
+		"GITS_ROOT=" + r.Root,
+		"GITS_VCS=" + r.VCS,
+	}
+}
+
+// placeholder matches the placeholders of command arguments, which
+// expand to the value for each repository.
+var placeholder = regexp.MustCompile(`\{(path|rel_path|name|branch|default_branch|remote|vcs)\}`)
+
+// expand replaces the placeholders in the arguments of the command: {path}
+// and {rel_path} with the absolute and relative path of the repository,
+// {name} with its name, {branch} with the current branch, {default_branch}
+// with the default branch of origin, {remote} with the URL of origin and
+// {vcs} with the command of its version control system, git, jj or sl.
+// Placeholders preceded by $ are left for the shell, as in ${name}.
+func (r repoInfo) expand(command []string) []string {
+	values := make(map[string]string)
+	value := func(name string) string {
+		if v, ok := values[name]; ok {
+			return v
+		}
+		var v string
+		switch name {
+		case "path":
+			v = r.AbsPath
+		case "rel_path":
+			v = r.RelPath
+		case "name":
+			v = r.Name
+		case "branch":
+			if k := detectVCS(r.AbsPath); k.status != nil {
+				v = k.status(r.AbsPath).Branch
+			} else {
+				v, _ = getCurrentBranch(r.AbsPath)
+			}
+		case "default_branch":
+			v = strings.TrimPrefix(getSymbolicRef(r.AbsPath, "refs/remotes/origin/HEAD"), "refs/remotes/origin/")
+		case "remote":
+			v, _ = getConfigValue(r.AbsPath, "remote.origin.url")
+		case "vcs":
+			v = detectVCS(r.AbsPath).binary
+		}
+		values[name] = v
+		return v
+	}
+
+	expanded := make([]string, len(command))
+	for i, arg := range command {
+		var b strings.Builder
+		last := 0
+		for _, m := range placeholder.FindAllStringSubmatchIndex(arg, -1) {
+			if m[0] > 0 && arg[m[0]-1] == '$' {
+				continue
+			}
+			b.WriteString(arg[last:m[0]])
+			b.WriteString(value(arg[m[2]:m[3]]))
+			last = m[1]
+		}
+		b.WriteString(arg[last:])
+		expanded[i] = b.String()
+	}
+	return expanded
+}
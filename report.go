@@ -0,0 +1,186 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// repoRun is the outcome of the command in one repository, as recorded for
+// the reports written after a run.
+type repoRun struct {
+	commandResult
+	Duration time.Duration
+	Usage    resourceUsage
+	// Skipped is why the command was not run in the repository, if it wasn't
+	Skipped string
+	// Steps are the runs of a command run several times, with -retries or
+	// -until-ok and -until-output
+	Steps []stepResult
+}
+
+// runRecorder collects the outcome of the command in each repository.
+type runRecorder struct {
+	mu   sync.Mutex
+	runs []repoRun
+}
+
+func (r *runRecorder) record(run repoRun) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.runs = append(r.runs, run)
+}
+
+// sorted returns the runs ordered by repository path.
+func (r *runRecorder) sorted() []repoRun {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	runs := slices.Clone(r.runs)
+	slices.SortFunc(runs, func(a, b repoRun) int { return compareNames(a.RelPath, b.RelPath) })
+	return runs
+}
+
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Skipped  int             `xml:"skipped,attr"`
+	Time     string          `xml:"time,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// writeJUnit writes the runs as a JUnit XML report with a test case for
+// each repository.
+func writeJUnit(path string, command []string, runs []repoRun) error {
+	suite := junitTestSuite{Name: strings.Join(command, " ")}
+	var total time.Duration
+	for _, run := range runs {
+		c := junitTestCase{
+			Name:      run.RelPath,
+			ClassName: "gits",
+			Time:      fmt.Sprintf("%.3f", run.Duration.Seconds()),
+		}
+		switch {
+		case run.Skipped != "":
+			c.Skipped = &junitMessage{Message: run.Skipped}
+			suite.Skipped++
+		case run.ExitCode != 0:
+			c.Failure = &junitMessage{Message: fmt.Sprintf("exit code %d", run.ExitCode), Text: run.Output}
+			suite.Failures++
+		default:
+			c.SystemOut = run.Output
+		}
+		suite.Cases = append(suite.Cases, c)
+		total += run.Duration
+	}
+	suite.Tests = len(suite.Cases)
+	suite.Time = fmt.Sprintf("%.3f", total.Seconds())
+
+	data, err := xml.MarshalIndent(junitTestSuites{Suites: []junitTestSuite{suite}}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append([]byte(xml.Header), append(data, '\n')...), 0o644)
+}
+
+// githubEscape escapes data for a GitHub Actions workflow command, and
+// property values too if property is set.
+func githubEscape(s string, property bool) string {
+	s = strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+	if property {
+		s = strings.NewReplacer(":", "%3A", ",", "%2C").Replace(s)
+	}
+	return s
+}
+
+// writeGitHubOutput prints an error annotation for each repository where the
+// command failed and appends a summary table to the job summary, if the
+// GITHUB_STEP_SUMMARY file is set.
+func writeGitHubOutput(command []string, runs []repoRun) error {
+	for _, run := range runs {
+		if run.Skipped == "" && run.ExitCode != 0 {
+			fmt.Printf("::error title=%s::%s\n", githubEscape(run.RelPath+" exited with "+fmt.Sprint(run.ExitCode), true), githubEscape(strings.TrimSpace(run.Output), false))
+		}
+	}
+
+	summaryPath := os.Getenv("GITHUB_STEP_SUMMARY")
+	if summaryPath == "" {
+		return nil
+	}
+	var summary strings.Builder
+	var failed []repoRun
+	fmt.Fprintf(&summary, "### `gits %s`\n\n", strings.Join(command, " "))
+	fmt.Fprintf(&summary, "| %s | %s | %s |\n|---|---|---|\n", tr("Repository"), tr("Result"), tr("Duration"))
+	for _, run := range runs {
+		result := "✅ " + tr("passed")
+		switch {
+		case run.Skipped != "":
+			result = "⏭️ " + tr("skipped: %s", run.Skipped)
+		case run.ExitCode != 0:
+			result = "❌ " + tr("exit code %d", run.ExitCode)
+			failed = append(failed, run)
+		}
+		fmt.Fprintf(&summary, "| %s | %s | %s |\n", run.RelPath, strings.ReplaceAll(result, "|", "\\|"), run.Duration.Round(time.Millisecond))
+	}
+	for _, run := range failed {
+		fmt.Fprintf(&summary, "\n<details><summary>%s</summary>\n\n```\n%s\n```\n\n</details>\n", run.RelPath, strings.TrimSpace(run.Output))
+	}
+
+	f, err := os.OpenFile(summaryPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(summary.String() + "\n"); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// writeTAP prints the runs as Test Anything Protocol version 13, a test
+// point for each repository with the output of failures as YAML diagnostics.
+func writeTAP(w io.Writer, runs []repoRun) {
+	fmt.Fprintf(w, "TAP version 13\n1..%d\n", len(runs))
+	for i, run := range runs {
+		switch {
+		case run.Skipped != "":
+			fmt.Fprintf(w, "ok %d - %s # SKIP %s\n", i+1, run.RelPath, strings.ReplaceAll(run.Skipped, "\n", " "))
+		case run.ExitCode != 0:
+			fmt.Fprintf(w, "not ok %d - %s\n", i+1, run.RelPath)
+			fmt.Fprintf(w, "  ---\n  exit_code: %d\n  duration_ms: %d\n", run.ExitCode, run.Duration.Milliseconds())
+			if output := strings.TrimRight(run.Output, "\n"); output != "" {
+				fmt.Fprintf(w, "  output: |\n    %s\n", strings.ReplaceAll(output, "\n", "\n    "))
+			}
+			fmt.Fprintln(w, "  ...")
+		default:
+			fmt.Fprintf(w, "ok %d - %s\n", i+1, run.RelPath)
+		}
+	}
+}
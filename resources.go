@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// ioniceClasses maps the -ionice scheduling classes to their ionice number.
+var ioniceClasses = map[string]string{"realtime": "1", "best-effort": "2", "idle": "3"}
+
+// resourceLimits lowers the priority of the commands that are run, by
+// running them under nice, ionice and systemd-run.
+type resourceLimits struct {
+	// nice is the niceness added to commands, 0 for unchanged
+	nice int
+	// ionice is the I/O scheduling class, optionally with a level, e.g. best-effort:7
+	ionice string
+	// cpuQuota is the CPU time each command may use, as a percentage of one CPU
+	cpuQuota string
+}
+
+func (l *resourceLimits) validate() error {
+	if l.nice < -20 || l.nice > 19 {
+		return fmt.Errorf("-nice %d is not between -20 and 19", l.nice)
+	}
+	if l.nice != 0 {
+		if _, err := exec.LookPath("nice"); err != nil {
+			return err
+		}
+	}
+	if l.ionice != "" {
+		class, level, hasLevel := strings.Cut(l.ionice, ":")
+		if _, ok := ioniceClasses[class]; !ok {
+			return fmt.Errorf("-ionice %s is not idle, best-effort or realtime", l.ionice)
+		}
+		if n, err := strconv.Atoi(level); hasLevel && (err != nil || n < 0 || n > 7) {
+			return fmt.Errorf("-ionice level %s is not between 0 and 7", level)
+		}
+		if _, err := exec.LookPath("ionice"); err != nil {
+			return err
+		}
+	}
+	if l.cpuQuota != "" {
+		if n, err := strconv.Atoi(strings.TrimSuffix(l.cpuQuota, "%")); err != nil || n <= 0 || !strings.HasSuffix(l.cpuQuota, "%") {
+			return fmt.Errorf("-cpu-limit %s is not a percentage such as 50%%", l.cpuQuota)
+		}
+		if _, err := exec.LookPath("systemd-run"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// wrap returns the command run under the limits.
+func (l *resourceLimits) wrap(command []string) []string {
+	if l == nil {
+		return command
+	}
+	var prefix []string
+	if l.cpuQuota != "" {
+		// a transient cgroup for the command and everything it starts
+		prefix = append(prefix, "systemd-run", "--user", "--scope", "--quiet", "--collect", "-p", "CPUQuota="+l.cpuQuota, "--")
+	}
+	if l.nice != 0 {
+		prefix = append(prefix, "nice", "-n", strconv.Itoa(l.nice))
+	}
+	if l.ionice != "" {
+		class, level, hasLevel := strings.Cut(l.ionice, ":")
+		prefix = append(prefix, "ionice", "-c", ioniceClasses[class])
+		if hasLevel {
+			prefix = append(prefix, "-n", level)
+		}
+	}
+	if len(prefix) == 0 {
+		return command
+	}
+	return append(prefix, command...)
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+func init() {
+	registerSubcommand(&subcommand{
+		name:    "retire",
+		summary: "back up a repository with nothing unpublished to a bundle, remove it and record its retirement in the manifest",
+		run:     runRetire,
+	})
+}
+
+func runRetire(ws *workspace, args []string) int {
+	fs := newSubcommandFlags("retire", " <repository>")
+	bundleDir := fs.String("bundle-dir", "", "directory to write the final bundle to (default: retired in the workspace root)")
+	archiveDir := fs.String("archive-dir", "", "move the repository to this directory, at its path in the workspace, instead of deleting it")
+	yes := fs.Bool("yes", false, "do not ask for confirmation")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fmt.Println("the repository is required")
+		fs.Usage()
+		return 1
+	}
+	repo, within, ok := ws.repoContaining(fs.Arg(0))
+	if !ok || within != "" {
+		fmt.Printf("%s is not a matched repository\n", fs.Arg(0))
+		return 1
+	}
+	relPath := relativePath(ws.root, repo)
+	if relPath == "." {
+		fmt.Println("The workspace root cannot be retired")
+		return 1
+	}
+	if *bundleDir == "" {
+		*bundleDir = filepath.Join(ws.root, "retired")
+	}
+	var archivePath string
+	if *archiveDir != "" {
+		dir, err := filepath.Abs(*archiveDir)
+		if err != nil {
+			fmt.Println("Invalid -archive-dir:", err)
+			return 1
+		}
+		archivePath = filepath.Join(dir, relPath)
+		if _, err := os.Stat(archivePath); err == nil {
+			fmt.Printf("%s already exists\n", archivePath)
+			return 1
+		}
+	}
+
+	release, ok := ws.lock("retire")
+	if !ok {
+		return 1
+	}
+	defer release()
+
+	// everything must be on a remote, as the bundle is only a last resort
+	if clean, err := isClean(repo); err != nil || !clean {
+		fmt.Printf("%s has uncommitted changes or untracked files\n", relPath)
+		return 1
+	}
+	unpublished, err := findUnpublished(repo)
+	if err != nil {
+		fmt.Printf("Error checking %s for unpublished work: %v\n", relPath, err)
+		return 1
+	}
+	if len(unpublished) > 0 {
+		fmt.Printf("%s has work on no remote:\n  %s\n", relPath, strings.Join(unpublished, "\n  "))
+		return 1
+	}
+
+	action := "delete"
+	if archivePath != "" {
+		action = "move to " + archivePath
+	}
+	if !*yes && !confirm(fmt.Sprintf("Retire %s, backing it up to %s and then %s?", relPath, *bundleDir, action)) {
+		return 1
+	}
+
+	date := time.Now().Format(time.DateOnly)
+	bundle := filepath.Join(*bundleDir, strings.ReplaceAll(filepath.ToSlash(relPath), "/", "_")+"-"+date+".bundle")
+	if err := os.MkdirAll(*bundleDir, 0o755); err != nil {
+		fmt.Println("Error creating the bundle directory:", err)
+		return 1
+	}
+	if _, err := runGit(repo, "bundle", "create", "--quiet", bundle, "--all"); err != nil {
+		fmt.Printf("Error bundling %s: %s\n", relPath, errorReason(err))
+		return 1
+	}
+	if _, err := runGit(repo, "bundle", "verify", "--quiet", bundle); err != nil {
+		fmt.Printf("Error verifying %s: %s\n", bundle, errorReason(err))
+		return 1
+	}
+	fmt.Printf("Backed up %s to %s\n", relPath, bundle)
+
+	origin, _ := getConfigValue(repo, "remote.origin.url")
+	if archivePath != "" {
+		err = os.MkdirAll(filepath.Dir(archivePath), 0o755)
+		if err == nil {
+			err = os.Rename(repo, archivePath)
+		}
+	} else {
+		err = os.RemoveAll(repo)
+	}
+	if err != nil {
+		fmt.Printf("Error removing %s: %v\n", relPath, err)
+		return 1
+	}
+
+	path, err := recordRetirement(ws.root, repo, origin, date)
+	if err != nil {
+		fmt.Println("Error recording the retirement in the manifest:", err)
+		return 1
+	}
+	fmt.Printf("\033[1m➖ %s:\033[0m retired, recorded in %s\n", relPath, path)
+	return 0
+}
+
+// recordRetirement sets the retired date of the repository in the workspace
+// manifest, adding it if it is not there and creating the manifest in the
+// root if there is none. The manifest is edited as a YAML node to keep its
+// comments and order. It returns the path of the manifest.
+func recordRetirement(root string, repo string, url string, date string) (string, error) {
+	m, err := findManifest(root)
+	if err != nil {
+		return "", err
+	}
+	dir := root
+	if m != nil {
+		dir = m.dir
+	}
+	path := filepath.Join(dir, manifestName)
+	relPath := filepath.ToSlash(relativePath(dir, repo))
+
+	var doc yaml.Node
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return "", err
+	}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return "", fmt.Errorf("%s: %v", path, err)
+	}
+	if doc.Kind == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}
+	}
+
+	repos := mappingValue(doc.Content[0], "repos")
+	if repos == nil {
+		repos = &yaml.Node{Kind: yaml.SequenceNode}
+		doc.Content[0].Content = append(doc.Content[0].Content, scalarNode("repos"), repos)
+	}
+	var entry *yaml.Node
+	for _, r := range repos.Content {
+		if p := mappingValue(r, "path"); p != nil && filepath.ToSlash(filepath.Clean(p.Value)) == relPath {
+			entry = r
+			break
+		}
+	}
+	if entry == nil {
+		entry = &yaml.Node{Kind: yaml.MappingNode, Content: []*yaml.Node{scalarNode("path"), scalarNode(relPath)}}
+		if url != "" {
+			entry.Content = append(entry.Content, scalarNode("url"), scalarNode(url))
+		}
+		repos.Content = append(repos.Content, entry)
+	}
+	if retired := mappingValue(entry, "retired"); retired != nil {
+		retired.Value = date
+	} else {
+		entry.Content = append(entry.Content, scalarNode("retired"), scalarNode(date))
+	}
+
+	var out bytes.Buffer
+	enc := yaml.NewEncoder(&out)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return "", err
+	}
+	return path, os.WriteFile(path, out.Bytes(), 0o644)
+}
+
+// mappingValue returns the value of the key of a YAML mapping, or nil.
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// scalarNode returns a YAML string node.
+func scalarNode(value string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
+}
@@ -0,0 +1,198 @@
+//go:build !noforge
+
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+func init() {
+	registerSubcommand(&subcommand{
+		name:    "reviews",
+		summary: "list the pull requests of the repositories awaiting your review, `gits reviews checkout <n>` checks one out",
+		run:     runReviews,
+	})
+}
+
+// reviewsState is the last list of reviews, which `gits reviews checkout`
+// refers to by number.
+type reviewsState struct {
+	Reviews []pendingReview `json:"reviews"`
+}
+
+type pendingReview struct {
+	pullRequest
+	// Path is the local repository
+	Path string `json:"path"`
+}
+
+func runReviews(ws *workspace, args []string) int {
+	if len(args) > 0 && args[0] == "checkout" {
+		return runReviewsCheckout(ws, args[1:])
+	}
+
+	fs := newSubcommandFlags("reviews", "")
+	fs.Parse(args)
+
+	// Each forge is asked once for everything awaiting review, which is then
+	// matched to the local repositories
+	type localRepo struct {
+		path string
+		repo forgeRepo
+	}
+	byHost := make(map[string][]localRepo)
+	clients := make(map[string]*forgeClient)
+	for _, repo := range ws.repos {
+		client, fr, err := forgeClientFor(ws, repo)
+		if err != nil {
+			continue
+		}
+		byHost[fr.host] = append(byHost[fr.host], localRepo{path: repo, repo: fr})
+		clients[fr.host] = client
+	}
+
+	var mu sync.Mutex
+	var reviews []pendingReview
+	var hosts []string
+	for host := range byHost {
+		hosts = append(hosts, host)
+	}
+	slices.Sort(hosts)
+
+	results, exitCode := forEachRepo(hosts, ws.parallel, func(host string) (string, int) {
+		requested, err := clients[host].reviewRequests(host)
+		if err != nil {
+			return fmt.Sprintf("\033[1m❌ %s:\033[0m %v", host, err), 1
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		for _, pr := range requested {
+			for _, local := range byHost[host] {
+				if strings.EqualFold(local.repo.path, pr.Repo) {
+					reviews = append(reviews, pendingReview{pullRequest: pr, Path: local.path})
+				}
+			}
+		}
+		return "", 0
+	})
+	printResults(results)
+
+	slices.SortFunc(reviews, func(a, b pendingReview) int {
+		if c := compareNames(a.Path, b.Path); c != 0 {
+			return c
+		}
+		return a.Number - b.Number
+	})
+	if err := writeState("reviews.json", reviewsState{Reviews: reviews}); err != nil {
+		fmt.Println("Error saving reviews:", err)
+	}
+
+	if len(reviews) == 0 && exitCode == 0 {
+		fmt.Println("Nothing awaiting your review")
+	}
+	for i, r := range reviews {
+		fmt.Printf("%3d. \033[1m%s\033[0m #%d %s \033[2m(%s)\033[0m\n     %s\n", i+1, relativePath(ws.root, r.Path), r.Number, r.Title, r.Author, r.URL)
+	}
+	return exitCode
+}
+
+func runReviewsCheckout(ws *workspace, args []string) int {
+	fs := newSubcommandFlags("reviews checkout", " <n>")
+	fs.Parse(args)
+
+	var state reviewsState
+	if err := readState("reviews.json", &state); err != nil {
+		fmt.Println("Error reading reviews:", err)
+		return 1
+	}
+	if len(state.Reviews) == 0 {
+		fmt.Println("No reviews to check out, run `gits reviews` first")
+		return 1
+	}
+	n, err := strconv.Atoi(fs.Arg(0))
+	if fs.NArg() != 1 || err != nil || n < 1 || n > len(state.Reviews) {
+		fmt.Printf("Give the number of a review listed by the last `gits reviews`, 1 to %d\n", len(state.Reviews))
+		return 1
+	}
+	r := state.Reviews[n-1]
+
+	release, ok := ws.lock("reviews checkout")
+	if !ok {
+		return 1
+	}
+	defer release()
+
+	client, _, err := forgeClientFor(ws, r.Path)
+	if err != nil {
+		fmt.Printf("Error checking out #%d in %s: %v\n", r.Number, relativePath(ws.root, r.Path), err)
+		return 1
+	}
+	branch, err := checkoutPullRequest(r.Path, client, r.Number)
+	if err != nil {
+		fmt.Printf("Error checking out #%d in %s: %v\n", r.Number, relativePath(ws.root, r.Path), err)
+		return 1
+	}
+	fmt.Printf("Checked out #%d %s on %s in %s\n", r.Number, r.Title, branch, relativePath(ws.root, r.Path))
+	return 0
+}
+
+// reviewRequests lists the open pull requests on the forge that request a
+// review from the user the token belongs to.
+func (c *forgeClient) reviewRequests(host string) ([]pullRequest, error) {
+	var prs []pullRequest
+	if c.kind == forgeGitLab {
+		var user struct {
+			Username string `json:"username"`
+		}
+		if err := c.get("/user", &user); err != nil {
+			return nil, err
+		}
+		var mrs []struct {
+			IID        int    `json:"iid"`
+			Title      string `json:"title"`
+			WebURL     string `json:"web_url"`
+			References struct {
+				Full string `json:"full"`
+			} `json:"references"`
+			Author struct {
+				Username string `json:"username"`
+			} `json:"author"`
+		}
+		query := url.Values{"state": {"opened"}, "scope": {"all"}, "reviewer_username": {user.Username}, "per_page": {"100"}}
+		if err := c.get("/merge_requests?"+query.Encode(), &mrs); err != nil {
+			return nil, err
+		}
+		for _, mr := range mrs {
+			repo, _, _ := strings.Cut(mr.References.Full, "!")
+			prs = append(prs, pullRequest{Host: host, Repo: repo, Number: mr.IID, Title: mr.Title, Author: mr.Author.Username, URL: mr.WebURL})
+		}
+		return prs, nil
+	}
+
+	var result struct {
+		Items []struct {
+			Number        int    `json:"number"`
+			Title         string `json:"title"`
+			HTMLURL       string `json:"html_url"`
+			RepositoryURL string `json:"repository_url"`
+			User          struct {
+				Login string `json:"login"`
+			} `json:"user"`
+		} `json:"items"`
+	}
+	query := url.Values{"q": {"is:open is:pr review-requested:@me"}, "per_page": {"100"}}
+	if err := c.get("/search/issues?"+query.Encode(), &result); err != nil {
+		return nil, err
+	}
+	for _, item := range result.Items {
+		// https://api.github.com/repos/<owner>/<name>
+		_, repo, _ := strings.Cut(item.RepositoryURL, "/repos/")
+		prs = append(prs, pullRequest{Host: host, Repo: repo, Number: item.Number, Title: item.Title, Author: item.User.Login, URL: item.HTMLURL})
+	}
+	return prs, nil
+}
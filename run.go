@@ -0,0 +1,586 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/template"
+	"time"
+)
+
+type filter func(path string) (bool, error)
+
+// filterOptions are the conditions a repository must meet to be matched.
+type filterOptions struct {
+	// Branch matches repositories on this branch
+	Branch string `json:"branch,omitempty"`
+	// Dirty matches repositories with a dirty worktree
+	Dirty bool `json:"dirty,omitempty"`
+	// Clean matches repositories with a clean worktree
+	Clean bool `json:"clean,omitempty"`
+	// NeedsGC matches repositories whose object database needs repacking
+	NeedsGC bool `json:"needs_gc,omitempty"`
+	// Remote matches repositories whose origin matches this glob, as
+	// host/path such as github.com/acme/* or as the whole URL
+	Remote string `json:"remote,omitempty"`
+	// HasBranch matches repositories with this local branch
+	HasBranch string `json:"has_branch,omitempty"`
+	// Ahead matches repositories with commits their upstream does not have
+	Ahead bool `json:"ahead,omitempty"`
+	// Behind matches repositories missing commits of their upstream
+	Behind bool `json:"behind,omitempty"`
+	// Stale matches repositories whose HEAD was committed longer ago than
+	// this age, such as 90d
+	Stale string `json:"stale,omitempty"`
+	// Bridge matches git-svn repositories with svn, hg-git repositories
+	// with hg and ordinary git repositories with none
+	Bridge string `json:"bridge,omitempty"`
+}
+
+// validate checks the options that are parsed.
+func (o filterOptions) validate() error {
+	if o.Stale != "" {
+		if _, err := parseAge(o.Stale); err != nil {
+			return fmt.Errorf("stale: %v", err)
+		}
+	}
+	switch o.Bridge {
+	case "", bridgeSVN, bridgeHg, bridgeNone:
+	default:
+		return fmt.Errorf("bridge: %s is not svn, hg or none", o.Bridge)
+	}
+	return nil
+}
+
+// filters returns the filters of the options, the cheapest first so the
+// others are not run for repositories that are already excluded.
+func (o filterOptions) filters() []filter {
+	var filters []filter
+
+	if o.Bridge != "" {
+		filters = append(filters, func(path string) (bool, error) {
+			bridge := detectBridge(path)
+			return bridge == o.Bridge || (bridge == "" && o.Bridge == bridgeNone), nil
+		})
+	}
+
+	if o.Remote != "" {
+		filters = append(filters, func(path string) (bool, error) {
+			return remoteMatches(path, o.Remote), nil
+		})
+	}
+
+	if o.HasBranch != "" {
+		filters = append(filters, func(path string) (bool, error) {
+			_, err := runGit(path, "show-ref", "--verify", "--quiet", "refs/heads/"+o.HasBranch)
+			return err == nil, nil
+		})
+	}
+
+	if o.Branch != "" {
+		filters = append(filters, func(path string) (bool, error) {
+			b, err := getCurrentBranch(path)
+			return b == o.Branch, err
+		})
+	}
+
+	if o.Stale != "" {
+		age, _ := parseAge(o.Stale)
+		filters = append(filters, func(path string) (bool, error) {
+			out, err := runGit(path, "log", "-1", "--format=%ct")
+			if err != nil {
+				return false, err
+			}
+			unix, err := strconv.ParseInt(out, 10, 64)
+			if err != nil {
+				return false, err
+			}
+			return time.Since(time.Unix(unix, 0)) > age, nil
+		})
+	}
+
+	if o.Dirty {
+		filters = append(filters, isDirty)
+	}
+
+	if o.Clean {
+		filters = append(filters, isClean)
+	}
+
+	if o.Ahead || o.Behind {
+		filters = append(filters, func(path string) (bool, error) {
+			ahead, behind, _, err := getAheadBehind(path)
+			return (!o.Ahead || ahead > 0) && (!o.Behind || behind > 0), err
+		})
+	}
+
+	if o.NeedsGC {
+		filters = append(filters, needsGC)
+	}
+
+	return filters
+}
+
+// remoteMatches reports whether the origin of the repository matches the
+// glob, as host/path or as the whole URL.
+func remoteMatches(path string, pattern string) bool {
+	origin, err := getConfigValue(path, "remote.origin.url")
+	if err != nil || origin == "" {
+		return false
+	}
+	if fr, ok := parseRemoteURL(origin); ok && matchGlob(pattern, fr.host+"/"+fr.path) {
+		return true
+	}
+	ok, _ := filepath.Match(pattern, origin)
+	return ok
+}
+
+// skippedRepo is a repository that was found but not matched, along with why.
+type skippedRepo struct {
+	path   string
+	reason error
+}
+
+// discoveryOptions controls which repositories findRepos matches.
+type discoveryOptions struct {
+	// roots are the directories to search
+	roots []string
+	// root is the directory paths are matched relative to
+	root string
+	// exclude are glob patterns of paths, relative to the root being searched, that are skipped
+	exclude []string
+	filters []filter
+	// unhealthy matches only the repositories in a broken state
+	unhealthy bool
+	// pinned are the repositories of a session, which are matched instead
+	// of searching the roots
+	pinned []string
+	// includeSkipped also matches the repositories marked to be skipped
+	includeSkipped bool
+	// bare also matches bare repositories
+	bare bool
+	// submodules also matches the initialized submodules of the repositories
+	submodules bool
+	// maxDepth is how many directories below the roots are searched, 0 for all
+	maxDepth int
+	// followSymlinks descends into symbolic links to directories
+	followSymlinks bool
+	// cache uses the repositories found by an earlier search of the roots,
+	// and refresh searches them again to update it
+	cache   bool
+	refresh bool
+}
+
+// discoveryResult holds the repositories found by findRepos.
+type discoveryResult struct {
+	// repos are the matched repositories
+	repos []string
+	// skipped are the repositories where a filter failed
+	skipped []skippedRepo
+	// unhealthy are the repositories excluded because they are in a broken state
+	unhealthy []skippedRepo
+}
+
+// findRepos walks the roots looking for git repositories that pass all the
+// filters, which are run concurrently across the repositories.
+// Repositories where a filter returns an error are returned as skipped.
+func findRepos(opts discoveryOptions) (*discoveryResult, error) {
+	res := &discoveryResult{}
+	var candidates []string
+
+	consider := func(path string) {
+		// the repositories of a session were picked explicitly
+		if !opts.includeSkipped && opts.pinned == nil && isMarkedSkip(path) {
+			return
+		}
+		problems := findRepoProblems(path)
+		if len(problems) > 0 && !opts.unhealthy {
+			res.unhealthy = append(res.unhealthy, skippedRepo{path: path, reason: errors.New(strings.Join(problems, ", "))})
+			return
+		}
+		if len(problems) == 0 && opts.unhealthy {
+			return
+		}
+		candidates = append(candidates, path)
+	}
+
+	found := func(path string) {
+		consider(path)
+		if opts.submodules {
+			// git prints the absolute paths of the initialized submodules
+			out, _ := exec.Command("git", "-C", path, "submodule", "foreach", "--quiet", "--recursive", "pwd").Output()
+			for _, sub := range strings.Fields(string(out)) {
+				consider(sub)
+			}
+		}
+	}
+
+	if opts.pinned != nil {
+		for _, path := range opts.pinned {
+			_, err := os.Stat(path)
+			if err == nil && !isRepo(path, opts.bare) {
+				err = errors.New("no longer a repository")
+			}
+			if err != nil {
+				res.skipped = append(res.skipped, skippedRepo{path: path, reason: err})
+				continue
+			}
+			found(path)
+		}
+	}
+
+	walk := walkOptions{exclude: opts.exclude, bare: opts.bare, maxDepth: opts.maxDepth, followSymlinks: opts.followSymlinks}
+	for _, root := range opts.roots {
+		if opts.pinned != nil {
+			break
+		}
+		var repos []string
+		var err error
+		if opts.cache || opts.refresh {
+			repos, err = walkReposCached(root, walk, opts.refresh)
+		} else {
+			repos, err = walkRepos(root, walk)
+		}
+		if err != nil {
+			return nil, err
+		}
+		sortNames(repos)
+		for _, path := range repos {
+			found(path)
+		}
+	}
+
+	sortNames(candidates)
+	candidates = slices.Compact(candidates)
+
+	// Check filters, all of which must pass
+	matched := make([]bool, len(candidates))
+	failed := make([]error, len(candidates))
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, runtime.NumCPU())
+	for i, path := range candidates {
+		if len(opts.filters) == 0 {
+			matched[i] = true
+			continue
+		}
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(i int, path string) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			for _, f := range opts.filters {
+				r, err := f(path)
+				if err != nil {
+					failed[i] = err
+					return
+				}
+				if !r {
+					return
+				}
+			}
+			matched[i] = true
+		}(i, path)
+	}
+	wg.Wait()
+
+	for i, path := range candidates {
+		if failed[i] != nil {
+			res.skipped = append(res.skipped, skippedRepo{path: path, reason: failed[i]})
+		} else if matched[i] {
+			res.repos = append(res.repos, path)
+		}
+	}
+	return res, nil
+}
+
+// skipMarker is the file that marks a repository to be skipped, as does
+// the gits.skip git config key.
+const skipMarker = ".gitsskip"
+
+// isMarkedSkip reports whether the repository is marked to be skipped.
+func isMarkedSkip(path string) bool {
+	if _, err := os.Stat(filepath.Join(path, skipMarker)); err == nil {
+		return true
+	}
+	out, err := exec.Command("git", "-C", path, "config", "--type=bool", "--get", "gits.skip").Output()
+	return err == nil && strings.TrimSpace(string(out)) == "true"
+}
+
+// skippedResult formats a skipped repository as a result row.
+func skippedResult(root string, s skippedRepo) string {
+	if recordOutput {
+		return skippedRecord(root, s.path, errorReason(s.reason))
+	}
+	return fmt.Sprintf("\033[1m⏭️ %s:\033[0m skipped: %s", relativePath(root, s.path), errorReason(s.reason))
+}
+
+// unhealthyResult formats an unhealthy repository as a result row.
+func unhealthyResult(root string, s skippedRepo) string {
+	if recordOutput {
+		return skippedRecord(root, s.path, "unhealthy: "+s.reason.Error())
+	}
+	return fmt.Sprintf("\033[1m🩺 %s:\033[0m unhealthy: %s", relativePath(root, s.path), s.reason)
+}
+
+// errorReason describes an error, including the stderr of failed git commands.
+func errorReason(err error) string {
+	if exitError, ok := err.(*exec.ExitError); ok && len(exitError.Stderr) > 0 {
+		return strings.TrimSpace(string(exitError.Stderr))
+	}
+	return err.Error()
+}
+
+// commonRoot returns the deepest directory containing all the paths.
+func commonRoot(paths []string) string {
+	root := paths[0]
+	for _, p := range paths[1:] {
+		for {
+			rel := relativePath(root, p)
+			if rel != ".." && !strings.HasPrefix(rel, "../") {
+				break
+			}
+			root = filepath.Dir(root)
+		}
+	}
+	return root
+}
+
+// relativePath returns path relative to base, falling back to path itself.
+func relativePath(base string, path string) string {
+	relPath, err := filepath.Rel(base, path)
+	if err != nil {
+		return path
+	}
+	return relPath
+}
+
+func runCommand(path string, command []string, env []string) (string, int) {
+	output, exitCode, _ := runCommandUsage(path, command, env)
+	return output, exitCode
+}
+
+// runCommandUsage is runCommand that also measures the resources the
+// command used.
+func runCommandUsage(path string, command []string, env []string) (string, int, resourceUsage) {
+	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Dir = path
+	cmd.Env = env
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	start := time.Now()
+	err := cmd.Run()
+	usage := measureUsage(cmd.ProcessState, time.Since(start), out.String())
+	exitCode := 0
+	if err != nil {
+		if exitError, ok := err.(*exec.ExitError); ok {
+			exitCode = exitError.ExitCode()
+		} else {
+			exitCode = 1
+		}
+	}
+	return out.String(), exitCode, usage
+}
+
+// forEachRepo applies action to every repository using at most parallel
+// concurrent tasks, or an adaptive number if it is 0, while displaying the
+// progress ticker. The results are
+// returned in the same order as repos, and the exit code is 1 if any action
+// returned a non-zero exit code.
+func forEachRepo(repos []string, parallel int, action func(path string) (string, int)) ([]string, int) {
+	return forEachRepoWith(repos, parallel, true, action)
+}
+
+// forEachRepoWith is forEachRepo with control over the progress ticker, for
+// use when the output is not a terminal.
+func forEachRepoWith(repos []string, parallel int, progress bool, action func(path string) (string, int)) ([]string, int) {
+	return forEachRepoStreaming(repos, parallel, progress, action, nil)
+}
+
+// forEachRepoStreaming is forEachRepoWith that also passes each result to
+// emit, if it is not nil, as soon as its action completes. The progress
+// ticker is cleared first so it does not clobber what emit prints.
+func forEachRepoStreaming(repos []string, parallel int, progress bool, action func(path string) (string, int), emit func(result string)) ([]string, int) {
+	var wg sync.WaitGroup
+	results := make([]string, len(repos))
+	var finalExitCode atomic.Int32
+
+	totalTasks := len(repos)
+	var completedTasks atomic.Int32
+
+	// output guards the terminal between the ticker and emit
+	var output sync.Mutex
+	slots := newLimiter(parallel)
+	// worktrees of one repository and nested repositories take turns, as
+	// they would collide on its lock files
+	overlaps := overlapLocks(repos)
+	var reporter *progressReporter
+	if progress && progressJSON {
+		reporter = newProgressReporter(totalTasks)
+	}
+	// the ticker would corrupt output that is not a terminal
+	spinner := progress && !plainOutput && reporter == nil
+	var line *progressLine
+	if spinner {
+		line = startProgressLine(&output, completedTasks.Load, totalTasks)
+	}
+
+	for i, repo := range repos {
+		wg.Add(1)
+		slots.acquire()
+		go func(i int, repo string) {
+			defer wg.Done()
+			defer cleanupOnPanic()
+			if overlaps[i] != nil {
+				overlaps[i].Lock()
+				defer overlaps[i].Unlock()
+			}
+			if reporter != nil {
+				reporter.started(repo)
+			}
+			start := time.Now()
+			result, exitCode := action(repo)
+			slots.release(time.Since(start), exitCode != 0)
+			if reporter != nil {
+				reporter.finished(repo, exitCode, time.Since(start))
+			}
+			results[i] = result
+			if exitCode != 0 {
+				finalExitCode.Store(1)
+			}
+			completedTasks.Add(1)
+			if emit != nil {
+				output.Lock()
+				if spinner {
+					fmt.Print(clearProgress)
+				}
+				emit(result)
+				output.Unlock()
+			}
+		}(i, repo)
+	}
+
+	wg.Wait()
+
+	if reporter != nil {
+		reporter.done()
+	}
+	if line != nil {
+		line.stop()
+	}
+
+	return results, int(finalExitCode.Load())
+}
+
+// commandResult is the outcome of running a command in a repository, as
+// made available to -format templates.
+type commandResult struct {
+	SchemaVersion int
+	repoInfo
+	Output   string
+	ExitCode int
+}
+
+// formatFuncs are the functions available to -format templates.
+var formatFuncs = template.FuncMap{
+	"json": func(v any) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+// commandOptions controls how commands are run and their results printed.
+type commandOptions struct {
+	format *template.Template
+	env    *environment
+	// limits, if set, lower the priority of the commands
+	limits *resourceLimits
+	// recorder, if set, records the outcome in each repository for reports
+	recorder *runRecorder
+	// retries is how many times to retry a command that fails, with backoff
+	retries int
+	// until, if set, repeats the command until it meets the condition
+	until *untilCondition
+	// steps, if set, records each run of the command in a repository as a
+	// step, for commands run several times with retries or until
+	steps *stepMatrix
+}
+
+func processRepo(path string, root string, command []string, opts commandOptions) (string, int) {
+	info := newRepoInfo(root, path)
+
+	// Run the command
+	start := time.Now()
+	run := func() (string, int, resourceUsage) {
+		return runCommandUsage(path, opts.limits.wrap(info.expand(command)), opts.env.build(info.environ()))
+	}
+	var steps []stepResult
+	step := func(name string, stepStart time.Time, exitCode int) {
+		if opts.steps != nil {
+			steps = append(steps, stepResult{Name: name, ExitCode: exitCode, DurationMS: time.Since(stepStart).Milliseconds()})
+		}
+	}
+	output, exitCode, usage := retryLockContention(run)
+	step("run", start, exitCode)
+	for attempt := 0; exitCode != 0 && attempt < opts.retries; attempt++ {
+		time.Sleep(retryBackoff(attempt))
+		retryStart := time.Now()
+		output, exitCode, usage = retryLockContention(run)
+		step(fmt.Sprintf("retry %d", attempt+1), retryStart, exitCode)
+	}
+	if opts.until != nil {
+		untilStart := time.Now()
+		output, exitCode, usage = opts.until.poll(run, output, exitCode, usage)
+		step("until", untilStart, exitCode)
+	}
+	for _, s := range steps {
+		opts.steps.add(info.RelPath, s)
+	}
+	opts.recorder.record(repoRun{
+		commandResult: commandResult{SchemaVersion: schemaVersion, repoInfo: info, Output: output, ExitCode: exitCode},
+		Duration:      time.Since(start),
+		Usage:         usage,
+		Steps:         steps,
+	})
+
+	if recordOutput {
+		r := newRepoRecord(root, path)
+		r.ExitCode, r.Output, r.Resources = &exitCode, &output, &usage
+		r.Steps, r.FirstFailingStep = steps, firstFailingStep(steps)
+		return r.line(), exitCode
+	}
+
+	if format := opts.format; format != nil {
+		var out strings.Builder
+		if err := format.Execute(&out, commandResult{SchemaVersion: schemaVersion, repoInfo: info, Output: output, ExitCode: exitCode}); err != nil {
+			return fmt.Sprintf("\033[1m❌ %s:\033[0m %v", info.RelPath, err), 1
+		}
+		return out.String(), exitCode
+	}
+
+	status := "✅️" // Checkmark
+	if exitCode != 0 {
+		status = "❌" // Cross mark
+	}
+
+	return fmt.Sprintf("\033[1m%s %s:\033[0m\n  %s", status, repoLink(path, info.RelPath), strings.ReplaceAll(output, "\n", "\n  ")), exitCode
+}
+
+// printResults prints the non-empty per-repository results.
+func printResults(results []string) {
+	for _, result := range results {
+		if result != "" {
+			fmt.Println(plain(result))
+		}
+	}
+}
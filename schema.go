@@ -0,0 +1,158 @@
+package main
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// schemaVersion is the version of the JSON that gits prints and serves.
+// Within a version fields are only ever added, removing or changing the
+// meaning of one needs a new version.
+const schemaVersion = 1
+
+func init() {
+	registerSubcommand(&subcommand{
+		name:    "schema",
+		summary: "print the JSON Schema of a JSON output of gits",
+		run:     runSchema,
+
+		standalone: true,
+	})
+}
+
+// jsonOutput is a JSON output of gits with a published schema.
+type jsonOutput struct {
+	name        string
+	description string
+	value       any
+}
+
+var jsonOutputs = []jsonOutput{
+	{"command-result", "a command result printed with -format '{{json .}}'", commandResult{}},
+	{"repo-record", "a repository record printed with -format json or ndjson", repoRecord{}},
+	{"prompt-info", "the output of `gits prompt-info`", promptInfo{}},
+	{"bundle-index", "the bundles.json index written by `gits bundle create`", bundleIndex{}},
+	{"describe", "the catalog printed by `gits describe -json`", repoCatalog{}},
+	{"patches", "the patches.json manifest written by `gits patches export`", patchesManifest{}},
+	{"cache-key", "the keys printed by `gits cache-key -json`", cacheKeys{}},
+	{"version", "the build printed by `gits version -json`", buildInfo{}},
+	{"progress", "a progress event printed on stderr with -progress-json", progressEvent{}},
+	{"run-summary", "how a run went, given to the post-run hook and -summary-template", runSummary{}},
+}
+
+// schemaEnum is implemented by types encoded as one of a fixed set of strings.
+type schemaEnum interface {
+	schemaEnum() []string
+}
+
+func (RemoteSyncState) schemaEnum() []string {
+	return []string{BehindRemote.String(), SyncRemote.String(), AheadRemote.String()}
+}
+
+func runSchema(ws *workspace, args []string) int {
+	fs := newSubcommandFlags("schema", " [output]")
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		fmt.Printf("JSON outputs, schema version %d:\n", schemaVersion)
+		for _, o := range jsonOutputs {
+			fmt.Printf("  %-16s %s\n", o.name, o.description)
+		}
+		return 0
+	}
+
+	for _, o := range jsonOutputs {
+		if o.name == fs.Arg(0) {
+			schema := jsonSchema(reflect.TypeOf(o.value))
+			schema["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+			schema["$id"] = fmt.Sprintf("https://github.com/stephenc/gits/schema/v%d/%s.json", schemaVersion, o.name)
+			schema["title"] = o.name
+			schema["description"] = o.description
+			data, _ := json.MarshalIndent(schema, "", "  ")
+			fmt.Println(string(data))
+			return 0
+		}
+	}
+	fmt.Printf("Unknown output `%s`, see `gits schema`\n", fs.Arg(0))
+	return 1
+}
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	schemaEnumType    = reflect.TypeOf((*schemaEnum)(nil)).Elem()
+)
+
+// jsonSchema describes how encoding/json encodes values of the type.
+func jsonSchema(t reflect.Type) map[string]any {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case t.Implements(schemaEnumType):
+		return map[string]any{"type": "string", "enum": reflect.Zero(t).Interface().(schemaEnum).schemaEnum()}
+	case t.Implements(textMarshalerType):
+		return map[string]any{"type": "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": jsonSchema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": jsonSchema(t.Elem())}
+	case reflect.Struct:
+		properties := make(map[string]any)
+		var required []string
+		addStructFields(t, properties, &required)
+		schema := map[string]any{"type": "object", "properties": properties}
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+		return schema
+	}
+	return map[string]any{}
+}
+
+// addStructFields adds the fields encoding/json encodes for the struct,
+// including those of embedded structs.
+func addStructFields(t reflect.Type, properties map[string]any, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		if f.Anonymous && tag == "" && f.Type.Kind() == reflect.Struct {
+			addStructFields(f.Type, properties, required)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = f.Name
+		}
+		properties[name] = jsonSchema(f.Type)
+		if !strings.Contains(opts, "omitempty") && f.Type.Kind() != reflect.Pointer {
+			*required = append(*required, name)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+func init() {
+	registerSubcommand(&subcommand{
+		name:    "select",
+		summary: "list the matched repositories, or those where a command succeeds, `gits select -save name` pins them as a session for -session",
+		run:     runSelect,
+	})
+}
+
+// session is a saved selection of repositories, by absolute path.
+type session struct {
+	// Root is the directory the paths were shown relative to
+	Root  string    `json:"root"`
+	Saved time.Time `json:"saved"`
+	// Args are the options the repositories were selected with
+	Args  []string `json:"args,omitempty"`
+	Repos []string `json:"repos"`
+	// Command is the command the repositories were selected by succeeding in
+	Command []string `json:"command,omitempty"`
+}
+
+// sessionsState is the sessions saved by `gits select -save`, by name.
+type sessionsState struct {
+	Sessions map[string]session `json:"sessions"`
+}
+
+// loadSession reads the named session.
+func loadSession(name string) (session, error) {
+	var state sessionsState
+	if err := readState("sessions.json", &state); err != nil {
+		return session{}, err
+	}
+	s, ok := state.Sessions[name]
+	if !ok {
+		return session{}, fmt.Errorf("no session named %q, see gits select -list", name)
+	}
+	return s, nil
+}
+
+func runSelect(ws *workspace, args []string) int {
+	fs := newSubcommandFlags("select", " [command...]")
+	save := fs.String("save", "", "save the matched repositories as a session of this name")
+	list := fs.Bool("list", false, "list the saved sessions")
+	del := fs.String("delete", "", "delete the session of this name")
+	fs.Parse(args)
+
+	var state sessionsState
+	if err := readState("sessions.json", &state); err != nil {
+		fmt.Println("Error reading sessions:", err)
+		return 1
+	}
+
+	switch {
+	case *list:
+		var names []string
+		for name := range state.Sessions {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		for _, name := range names {
+			s := state.Sessions[name]
+			fmt.Printf("\033[1m%s:\033[0m %d repositories in %s, saved %s\n", name, len(s.Repos), s.Root, s.Saved.Format("2006-01-02 15:04"))
+			if len(s.Args) > 0 {
+				fmt.Printf("  selected with %s\n", strings.Join(s.Args, " "))
+			}
+			if len(s.Command) > 0 {
+				fmt.Printf("  where %s succeeded\n", strings.Join(s.Command, " "))
+			}
+		}
+		return 0
+	case *del != "":
+		if _, ok := state.Sessions[*del]; !ok {
+			fmt.Printf("No session named %q\n", *del)
+			return 1
+		}
+		delete(state.Sessions, *del)
+		if err := writeState("sessions.json", state); err != nil {
+			fmt.Println("Error saving sessions:", err)
+			return 1
+		}
+		fmt.Printf("Deleted session %s\n", *del)
+		return 0
+	}
+
+	repos := ws.repos
+	predicate := fs.Args()
+	if len(predicate) > 0 {
+		if pattern, ok := matchWritePattern(ws.config.writePatterns(), predicate); ok && ws.readOnly {
+			fmt.Printf("Refusing to run %s in -read-only mode, it matches the write pattern `%s`\n", strings.Join(predicate, " "), pattern)
+			return 1
+		}
+		repos = selectWhere(ws, predicate)
+	}
+
+	for _, repo := range repos {
+		fmt.Println(relativePath(ws.root, repo))
+	}
+	// like grep, selecting nothing is a failure for scripts to test
+	exitCode := 0
+	if len(predicate) > 0 && len(repos) == 0 {
+		exitCode = 1
+	}
+	if *save == "" {
+		return exitCode
+	}
+
+	if state.Sessions == nil {
+		state.Sessions = make(map[string]session)
+	}
+	state.Sessions[*save] = session{
+		Root:    ws.root,
+		Saved:   time.Now(),
+		Args:    selectionArgs(os.Args[1:]),
+		Repos:   slices.Clone(repos),
+		Command: predicate,
+	}
+	if err := writeState("sessions.json", state); err != nil {
+		fmt.Println("Error saving sessions:", err)
+		return 1
+	}
+	fmt.Printf("Saved %d repositories as session %s, use gits -session %s to run commands in them\n", len(repos), *save, *save)
+	return exitCode
+}
+
+// selectWhere runs the command in each repository, its output discarded,
+// returning the repositories where it exits with 0, in order.
+func selectWhere(ws *workspace, command []string) []string {
+	selected := make([]bool, len(ws.repos))
+	forEachRepo(ws.repos, ws.parallel, func(path string) (string, int) {
+		info := newRepoInfo(ws.root, path)
+		_, exitCode := runCommand(path, ws.cmdOpts.limits.wrap(info.expand(command)), ws.cmdOpts.env.build(info.environ()))
+		selected[slices.Index(ws.repos, path)] = exitCode == 0
+		return "", 0
+	})
+	var repos []string
+	for i, repo := range ws.repos {
+		if selected[i] {
+			repos = append(repos, repo)
+		}
+	}
+	return repos
+}
+
+// selectionArgs returns the global options before the select subcommand, or
+// the command of -select, to describe how a session was selected.
+func selectionArgs(args []string) []string {
+	options := slices.Clone(args[:max(len(args)-flag.NArg(), 0)])
+	return slices.DeleteFunc(options, func(arg string) bool {
+		return strings.TrimLeft(arg, "-") == "select"
+	})
+}
@@ -0,0 +1,408 @@
+//go:build !noserve
+
+package main
+
+import (
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultServeAddress = "127.0.0.1:7878"
+
+func init() {
+	registerSubcommand(&subcommand{
+		name:    "serve",
+		summary: "run a daemon with an HTTP API and scheduled jobs",
+		run:     runServe,
+	})
+	builtFeatures["serve"] = true
+	jsonOutputs = append(jsonOutputs,
+		jsonOutput{"job", "a job of the `gits serve` API", jobSummary{}},
+		jsonOutput{"run", "a run of the `gits serve` API", triggeredRun{}},
+	)
+}
+
+// jobRepoResult is the outcome of a job in one repository.
+type jobRepoResult struct {
+	Path     string      `json:"path"`
+	RelPath  string      `json:"rel_path"`
+	ExitCode int         `json:"exit_code"`
+	Output   string      `json:"output,omitempty"`
+	Status   *repoStatus `json:"status,omitempty"`
+	Duration float64     `json:"duration_seconds"`
+}
+
+// jobRun is one run of a job across the repositories.
+type jobRun struct {
+	Job      string          `json:"job"`
+	Started  time.Time       `json:"started"`
+	Finished time.Time       `json:"finished"`
+	Failed   int             `json:"failed"`
+	Results  []jobRepoResult `json:"results"`
+}
+
+// jobSummary describes a scheduled job and its last run.
+type jobSummary struct {
+	Name    string    `json:"name"`
+	Every   string    `json:"every"`
+	Command []string  `json:"command,omitempty"`
+	Status  bool      `json:"status,omitempty"`
+	Running bool      `json:"running"`
+	NextRun time.Time `json:"next_run"`
+	LastRun *jobRun   `json:"last_run,omitempty"`
+}
+
+type scheduledJob struct {
+	cfg     jobConfig
+	every   time.Duration
+	running bool
+	nextRun time.Time
+	last    *jobRun
+}
+
+// server is the state of the `gits serve` daemon.
+type server struct {
+	ws *workspace
+	// token is the bearer token clients must send, without which commands
+	// cannot be run through the APIs
+	token string
+	mu    sync.Mutex
+	jobs  map[string]*scheduledJob
+	// runs are the runs triggered through the API, oldest first
+	runs []*triggeredRun
+}
+
+func runServe(ws *workspace, args []string) int {
+	fs := newSubcommandFlags("serve", "")
+	listen := fs.String("listen", ws.config.Serve.Listen, "address to serve the HTTP API on (default: "+defaultServeAddress+")")
+	grpcListen := fs.String("grpc-listen", ws.config.Serve.GRPCListen, "address to also serve the gRPC interface on (default: off)")
+	tlsCert := fs.String("tls-cert", ws.config.Serve.TLSCert, "PEM certificate to serve both APIs over TLS with")
+	tlsKey := fs.String("tls-key", ws.config.Serve.TLSKey, "PEM private key of -tls-cert")
+	tlsClientCA := fs.String("tls-client-ca", ws.config.Serve.TLSClientCA, "PEM CA certificates that clients must present a certificate signed by (mutual TLS)")
+	fs.Parse(args)
+
+	if *listen == "" {
+		*listen = defaultServeAddress
+	}
+
+	tlsCfg, err := tlsConfig(*tlsCert, *tlsKey, *tlsClientCA)
+	if err != nil {
+		fmt.Println("Invalid TLS configuration:", err)
+		return 1
+	}
+
+	s := &server{ws: ws, token: ws.config.Serve.token(), jobs: make(map[string]*scheduledJob)}
+	for _, cfg := range ws.config.Serve.Jobs {
+		every, err := parseAge(cfg.Every)
+		if err != nil || every <= 0 {
+			fmt.Printf("Invalid interval `%s` for job `%s`\n", cfg.Every, cfg.Name)
+			return 1
+		}
+		if cfg.Name == "" || (len(cfg.Command) == 0) == !cfg.Status {
+			fmt.Printf("Job `%s` needs a name and either a command or status: true\n", cfg.Name)
+			return 1
+		}
+		if _, dup := s.jobs[cfg.Name]; dup {
+			fmt.Printf("Duplicate job `%s`\n", cfg.Name)
+			return 1
+		}
+		s.jobs[cfg.Name] = &scheduledJob{cfg: cfg, every: every, nextRun: time.Now()}
+	}
+
+	if s.token == "" {
+		fmt.Println("Commands cannot be run through the APIs without serve.token or serve.token_env")
+	}
+
+	go s.schedule()
+
+	errs := make(chan error, 2)
+	if *grpcListen != "" {
+		fmt.Printf("Serving gRPC on %s\n", *grpcListen)
+		go func() { errs <- s.serveGRPC(*grpcListen, tlsCfg) }()
+	}
+
+	httpServer := &http.Server{Addr: *listen, Handler: s.handler(*listen), TLSConfig: tlsCfg}
+	if tlsCfg != nil {
+		fmt.Printf("Serving %d jobs on https://%s\n", len(s.jobs), *listen)
+		go func() { errs <- httpServer.ListenAndServeTLS("", "") }()
+	} else {
+		fmt.Printf("Serving %d jobs on http://%s\n", len(s.jobs), *listen)
+		go func() { errs <- httpServer.ListenAndServe() }()
+	}
+
+	fmt.Println("Error serving:", <-errs)
+	return 1
+}
+
+// handler serves the HTTP API of the daemon listening on the address.
+func (s *server) handler(listen string) http.Handler {
+	mux := http.NewServeMux()
+	s.routes(mux)
+	s.runRoutes(mux)
+	return checkHost(listen, requireToken(s.token, mux))
+}
+
+func (s *server) routes(mux *http.ServeMux) {
+	mux.HandleFunc("GET /jobs", s.handleJobs)
+	mux.HandleFunc("GET /jobs/{name}", s.handleJob)
+	mux.HandleFunc("POST /jobs/{name}/run", s.handleRunJob)
+}
+
+// schedule starts the jobs that are due, checking every second.
+func (s *server) schedule() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		s.mu.Lock()
+		for _, job := range s.jobs {
+			if !job.running && !now.Before(job.nextRun) {
+				s.startJob(job)
+			}
+		}
+		s.mu.Unlock()
+	}
+}
+
+// startJob runs the job in the background, it must be called with s.mu held.
+func (s *server) startJob(job *scheduledJob) {
+	job.running = true
+	job.nextRun = time.Now().Add(job.every)
+
+	go func() {
+		run := s.runJob(job.cfg)
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		job.running = false
+		job.last = run
+	}()
+}
+
+func (s *server) runJob(cfg jobConfig) *jobRun {
+	run := &jobRun{Job: cfg.Name, Started: time.Now()}
+
+	repos, err := s.ws.rediscover()
+	if err == nil && !cfg.Status {
+		err = s.ws.checkWrite(cfg.Command)
+	}
+	if err != nil {
+		run.Finished = time.Now()
+		run.Failed = 1
+		run.Results = []jobRepoResult{{Path: s.ws.root, ExitCode: 1, Output: err.Error()}}
+		return run
+	}
+
+	results := make([]jobRepoResult, len(repos))
+	forEachRepoWith(repos, s.ws.parallel, false, func(path string) (string, int) {
+		start := time.Now()
+		info := newRepoInfo(s.ws.root, path)
+		r := jobRepoResult{Path: path, RelPath: info.RelPath}
+		if cfg.Status {
+			st := collectStatus(path)
+			r.Status = &st
+		} else {
+			r.Output, r.ExitCode = runCommand(path, s.ws.cmdOpts.limits.wrap(info.expand(cfg.Command)), s.ws.cmdOpts.env.build(info.environ()))
+		}
+		r.Duration = time.Since(start).Seconds()
+		results[slices.Index(repos, path)] = r
+		return "", r.ExitCode
+	})
+
+	for _, r := range results {
+		if r.ExitCode != 0 {
+			run.Failed++
+		}
+	}
+	run.Results = results
+	run.Finished = time.Now()
+	return run
+}
+
+func (s *server) summary(job *scheduledJob) jobSummary {
+	return jobSummary{
+		Name:    job.cfg.Name,
+		Every:   job.cfg.Every,
+		Command: job.cfg.Command,
+		Status:  job.cfg.Status,
+		Running: job.running,
+		NextRun: job.nextRun,
+		LastRun: job.last,
+	}
+}
+
+func (s *server) handleJobs(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	summaries := make([]jobSummary, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		sum := s.summary(job)
+		if sum.LastRun != nil {
+			// The list only describes the last run, the results are per job
+			last := *sum.LastRun
+			last.Results = nil
+			sum.LastRun = &last
+		}
+		summaries = append(summaries, sum)
+	}
+	s.mu.Unlock()
+
+	slices.SortFunc(summaries, func(a, b jobSummary) int { return strings.Compare(a.Name, b.Name) })
+	writeJSON(w, http.StatusOK, summaries)
+}
+
+func (s *server) handleJob(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.jobs[r.PathValue("name")]
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "unknown job"})
+		return
+	}
+	writeJSON(w, http.StatusOK, s.summary(job))
+}
+
+func (s *server) handleRunJob(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.jobs[r.PathValue("name")]
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "unknown job"})
+		return
+	}
+	if job.running {
+		writeJSON(w, http.StatusConflict, map[string]string{"error": "job is already running"})
+		return
+	}
+	s.startJob(job)
+	writeJSON(w, http.StatusAccepted, s.summary(job))
+}
+
+// requireToken rejects requests without the bearer token, if there is one.
+func requireToken(token string, next http.Handler) http.Handler {
+	if token == "" {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+token)) != 1 {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "missing or invalid token"})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// checkHost rejects requests whose Host or Origin header is not the address
+// the daemon listens on. A web page can make the browser send requests to
+// the daemon, such as by pointing its own domain at 127.0.0.1, but not with
+// the daemon's address in those headers.
+func checkHost(listen string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defaultPort := "80"
+		if r.TLS != nil {
+			defaultPort = "443"
+		}
+		ok := allowedHost(listen, r.Host, defaultPort)
+		if origin := r.Header.Get("Origin"); ok && origin != "" {
+			ok = allowedOrigin(listen, origin)
+		}
+		if !ok {
+			writeJSON(w, http.StatusForbidden, map[string]string{"error": "the host or origin is not the address of the daemon"})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// allowedHost reports whether host, as host:port or a host on the default
+// port, is the address the daemon listens on. A daemon listening on every
+// interface answers to any name, and one listening on a loopback address to
+// localhost and the other loopback addresses.
+func allowedHost(listen string, host string, defaultPort string) bool {
+	listenHost, listenPort, err := net.SplitHostPort(listen)
+	if err != nil {
+		return false
+	}
+	name, port, err := net.SplitHostPort(host)
+	if err != nil {
+		name, port = strings.Trim(host, "[]"), defaultPort
+	}
+	if port != listenPort {
+		return false
+	}
+	if ip := net.ParseIP(listenHost); listenHost == "" || (ip != nil && ip.IsUnspecified()) {
+		return true
+	}
+	isLoopback := func(h string) bool {
+		ip := net.ParseIP(h)
+		return strings.EqualFold(h, "localhost") || (ip != nil && ip.IsLoopback())
+	}
+	return strings.EqualFold(name, listenHost) || (isLoopback(listenHost) && isLoopback(name))
+}
+
+// allowedOrigin reports whether the origin, the scheme and host of the web
+// page making a request, is the address the daemon listens on.
+func allowedOrigin(listen string, origin string) bool {
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	defaultPort := "80"
+	if u.Scheme == "https" {
+		defaultPort = "443"
+	}
+	return allowedHost(listen, u.Host, defaultPort)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Gits-Schema-Version", strconv.Itoa(schemaVersion))
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
+
+// tlsConfig loads the server certificate and, if clientCA is set, requires
+// clients to present a certificate signed by it.
+func tlsConfig(cert string, key string, clientCA string) (*tls.Config, error) {
+	if cert == "" && key == "" && clientCA == "" {
+		return nil, nil
+	}
+	if cert == "" || key == "" {
+		return nil, errors.New("both -tls-cert and -tls-key are needed")
+	}
+	pair, err := tls.LoadX509KeyPair(cert, key)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &tls.Config{Certificates: []tls.Certificate{pair}, MinVersion: tls.VersionTLS12}
+
+	if clientCA != "" {
+		pem, err := os.ReadFile(clientCA)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates in %s", clientCA)
+		}
+		cfg.ClientCAs = pool
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return cfg, nil
+}
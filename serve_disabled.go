@@ -0,0 +1,12 @@
+//go:build noserve
+
+package main
+
+func init() {
+	registerMissingFeature("serve")
+}
+
+// daemonPromptInfo has no daemon to ask, so prompt-info runs git.
+func daemonPromptInfo(ws *workspace, server string, path string) *promptInfo {
+	return nil
+}
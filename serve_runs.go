@@ -0,0 +1,278 @@
+//go:build !noserve
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxRuns is how many triggered runs the daemon remembers.
+const maxRuns = 100
+
+// runRequest is the body of `POST /runs`.
+type runRequest struct {
+	Command []string `json:"command"`
+	filterOptions
+}
+
+// triggeredRun is a command run across the repositories through the API.
+type triggeredRun struct {
+	ID       string          `json:"id"`
+	Command  []string        `json:"command"`
+	Filters  filterOptions   `json:"filters"`
+	Started  time.Time       `json:"started"`
+	Finished *time.Time      `json:"finished,omitempty"`
+	Total    int             `json:"total"`
+	Failed   int             `json:"failed"`
+	Results  []jobRepoResult `json:"results,omitempty"`
+
+	mu          sync.Mutex
+	subscribers []chan jobRepoResult
+}
+
+// subscribe returns the results so far and, unless the run has finished, a
+// channel receiving the remaining results that is closed when it finishes.
+func (r *triggeredRun) subscribe() ([]jobRepoResult, chan jobRepoResult) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	results := slices.Clone(r.Results)
+	if r.Finished != nil {
+		return results, nil
+	}
+	ch := make(chan jobRepoResult, r.Total)
+	r.subscribers = append(r.subscribers, ch)
+	return results, ch
+}
+
+func (r *triggeredRun) add(result jobRepoResult) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.Results = append(r.Results, result)
+	if result.ExitCode != 0 {
+		r.Failed++
+	}
+	for _, ch := range r.subscribers {
+		ch <- result
+	}
+}
+
+func (r *triggeredRun) finish() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	r.Finished = &now
+	for _, ch := range r.subscribers {
+		close(ch)
+	}
+	r.subscribers = nil
+}
+
+// snapshot returns a copy of the run that is safe to encode.
+func (r *triggeredRun) snapshot() *triggeredRun {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return &triggeredRun{
+		ID:       r.ID,
+		Command:  r.Command,
+		Filters:  r.Filters,
+		Started:  r.Started,
+		Finished: r.Finished,
+		Total:    r.Total,
+		Failed:   r.Failed,
+		Results:  slices.Clone(r.Results),
+	}
+}
+
+func (s *server) runRoutes(mux *http.ServeMux) {
+	// whoever can run a command can run anything as the user of the daemon
+	if s.token != "" {
+		mux.HandleFunc("POST /runs", s.handleStartRun)
+	}
+	mux.HandleFunc("GET /runs", s.handleRuns)
+	mux.HandleFunc("GET /runs/{id}", s.handleRun)
+	mux.HandleFunc("GET /runs/{id}/events", s.handleRunEvents)
+}
+
+func (s *server) handleStartRun(w http.ResponseWriter, r *http.Request) {
+	// web pages can only send other content types after asking permission
+	// the daemon does not give
+	if mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil || mediaType != "application/json" {
+		writeJSON(w, http.StatusUnsupportedMediaType, map[string]string{"error": "the body must be application/json"})
+		return
+	}
+	var req runRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	run, status, err := s.startRun(req)
+	if err != nil {
+		writeJSON(w, status, map[string]string{"error": err.Error()})
+		return
+	}
+
+	w.Header().Set("Location", "/runs/"+run.ID)
+	if strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+		past, events := run.subscribe()
+		streamRunEvents(w, run, past, events)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, run.snapshot())
+}
+
+// startRun runs the command across the matching repositories in the
+// background. On failure it returns the HTTP status describing the error.
+func (s *server) startRun(req runRequest) (*triggeredRun, int, error) {
+	if len(req.Command) == 0 {
+		return nil, http.StatusBadRequest, errors.New("command is required")
+	}
+	if err := req.filterOptions.validate(); err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	if err := s.ws.checkWrite(req.Command); err != nil {
+		return nil, http.StatusForbidden, err
+	}
+	// there is no one to type the confirmation
+	if arg, forced := forceArgument(req.Command); forced && !s.ws.forceConfirmed {
+		return nil, http.StatusForbidden, fmt.Errorf("refusing to run %s, it is forced with %s and gits serve was not started with -i-know-what-i-am-doing", strings.Join(req.Command, " "), arg)
+	}
+
+	opts := s.ws.discovery
+	opts.filters = append(slices.Clone(opts.filters), req.filterOptions.filters()...)
+	found, err := findRepos(opts)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	var lock *workspaceLock
+	if !s.ws.noLock && !isReadOnlyCommand(req.Command) {
+		lock, err = acquireWorkspaceLock(s.ws.root, s.ws.lockTimeout, strings.Join(req.Command, " "))
+		if err != nil {
+			return nil, http.StatusConflict, err
+		}
+	}
+	if _, err := s.ws.snapshotBefore(req.Command, found.repos); err != nil {
+		lock.release()
+		return nil, http.StatusInternalServerError, fmt.Errorf("taking a snapshot, not run: %w", err)
+	}
+
+	run := &triggeredRun{
+		ID:      newRunID(),
+		Command: req.Command,
+		Filters: req.filterOptions,
+		Started: time.Now(),
+		Total:   len(found.repos),
+		Results: []jobRepoResult{},
+	}
+
+	s.mu.Lock()
+	s.runs = append(s.runs, run)
+	if len(s.runs) > maxRuns {
+		s.runs = s.runs[len(s.runs)-maxRuns:]
+	}
+	s.mu.Unlock()
+
+	go func() {
+		defer lock.release()
+		forEachRepoWith(found.repos, s.ws.parallel, false, func(path string) (string, int) {
+			start := time.Now()
+			info := newRepoInfo(s.ws.root, path)
+			result := jobRepoResult{Path: path, RelPath: info.RelPath}
+			result.Output, result.ExitCode = runCommand(path, s.ws.cmdOpts.limits.wrap(info.expand(req.Command)), s.ws.cmdOpts.env.build(info.environ()))
+			result.Duration = time.Since(start).Seconds()
+			run.add(result)
+			return "", result.ExitCode
+		})
+		run.finish()
+	}()
+
+	return run, http.StatusAccepted, nil
+}
+
+func (s *server) findRun(id string) *triggeredRun {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, run := range s.runs {
+		if run.ID == id {
+			return run
+		}
+	}
+	return nil
+}
+
+func (s *server) handleRuns(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	runs := slices.Clone(s.runs)
+	s.mu.Unlock()
+
+	summaries := make([]*triggeredRun, 0, len(runs))
+	for i := len(runs) - 1; i >= 0; i-- {
+		snap := runs[i].snapshot()
+		snap.Results = nil
+		summaries = append(summaries, snap)
+	}
+	writeJSON(w, http.StatusOK, summaries)
+}
+
+func (s *server) handleRun(w http.ResponseWriter, r *http.Request) {
+	run := s.findRun(r.PathValue("id"))
+	if run == nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "unknown run"})
+		return
+	}
+	writeJSON(w, http.StatusOK, run.snapshot())
+}
+
+func (s *server) handleRunEvents(w http.ResponseWriter, r *http.Request) {
+	run := s.findRun(r.PathValue("id"))
+	if run == nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "unknown run"})
+		return
+	}
+	past, events := run.subscribe()
+	streamRunEvents(w, run, past, events)
+}
+
+// streamRunEvents writes the results of the run as server-sent events, a
+// `result` event per repository followed by a `done` event with the summary.
+func streamRunEvents(w http.ResponseWriter, run *triggeredRun, past []jobRepoResult, events chan jobRepoResult) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Gits-Run-Id", run.ID)
+	w.Header().Set("X-Gits-Schema-Version", strconv.Itoa(schemaVersion))
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+
+	send := func(event string, v any) {
+		data, _ := json.Marshal(v)
+		fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+
+	for _, result := range past {
+		send("result", result)
+	}
+	for result := range events {
+		send("result", result)
+	}
+
+	done := run.snapshot()
+	done.Results = nil
+	send("done", done)
+}
@@ -0,0 +1,187 @@
+//go:build !noserve
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequireToken(t *testing.T) {
+	tests := []struct {
+		name          string
+		token         string
+		authorization string
+		want          int
+	}{
+		{"no token configured", "", "", http.StatusOK},
+		{"no token configured, any header", "", "Bearer anything", http.StatusOK},
+		{"token sent", "s3cret", "Bearer s3cret", http.StatusOK},
+		{"no header", "s3cret", "", http.StatusUnauthorized},
+		{"wrong token", "s3cret", "Bearer guess", http.StatusUnauthorized},
+		{"without the scheme", "s3cret", "s3cret", http.StatusUnauthorized},
+		{"prefix of the token", "s3cret", "Bearer s3c", http.StatusUnauthorized},
+	}
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/jobs", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			rec := httptest.NewRecorder()
+			requireToken(tt.token, ok).ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("got status %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestAllowedHost(t *testing.T) {
+	tests := []struct {
+		listen string
+		host   string
+		want   bool
+	}{
+		{"127.0.0.1:7878", "127.0.0.1:7878", true},
+		{"127.0.0.1:7878", "localhost:7878", true},
+		{"127.0.0.1:7878", "[::1]:7878", true},
+		{"127.0.0.1:7878", "127.0.0.1:7879", false},
+		{"127.0.0.1:7878", "evil.example:7878", false},
+		{"127.0.0.1:7878", "127.0.0.1", false},
+		{"localhost:7878", "127.0.0.1:7878", true},
+		{"build1:7878", "BUILD1:7878", true},
+		{"build1:7878", "localhost:7878", false},
+		{"0.0.0.0:7878", "build1.example:7878", true},
+		{":7878", "build1.example:7878", true},
+		{"0.0.0.0:7878", "build1.example:80", false},
+		{"0.0.0.0:80", "build1.example", true},
+	}
+	for _, tt := range tests {
+		if got := allowedHost(tt.listen, tt.host, "80"); got != tt.want {
+			t.Errorf("allowedHost(%q, %q) = %v, want %v", tt.listen, tt.host, got, tt.want)
+		}
+	}
+}
+
+func TestAllowedOrigin(t *testing.T) {
+	tests := []struct {
+		origin string
+		want   bool
+	}{
+		{"http://127.0.0.1:7878", true},
+		{"http://localhost:7878", true},
+		{"https://evil.example", false},
+		{"http://evil.example:7878", false},
+		{"null", false},
+	}
+	for _, tt := range tests {
+		if got := allowedOrigin("127.0.0.1:7878", tt.origin); got != tt.want {
+			t.Errorf("allowedOrigin(%q) = %v, want %v", tt.origin, got, tt.want)
+		}
+	}
+}
+
+func TestStartRunRequest(t *testing.T) {
+	const listen = "127.0.0.1:7878"
+	body := `{"command": ["sh", "-c", "echo pwned > pwned.txt"]}`
+	tests := []struct {
+		name        string
+		token       string
+		contentType string
+		host        string
+		origin      string
+		want        int
+	}{
+		{"without a token configured", "", "application/json", listen, "", http.StatusMethodNotAllowed},
+		{"text/plain simple request", "s3cret", "text/plain", listen, "", http.StatusUnsupportedMediaType},
+		{"form simple request", "s3cret", "application/x-www-form-urlencoded", listen, "", http.StatusUnsupportedMediaType},
+		{"no content type", "s3cret", "", listen, "", http.StatusUnsupportedMediaType},
+		{"rebound host", "s3cret", "application/json", "evil.example:7878", "", http.StatusForbidden},
+		{"other origin", "s3cret", "application/json", listen, "https://evil.example", http.StatusForbidden},
+		{"json", "s3cret", "application/json", listen, "", http.StatusAccepted},
+		{"json with charset", "s3cret", "application/json; charset=utf-8", "localhost:7878", "http://localhost:7878", http.StatusAccepted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ws := &workspace{root: t.TempDir(), parallel: 1, config: &config{}, noLock: true}
+			s := &server{ws: ws, token: tt.token, jobs: make(map[string]*scheduledJob)}
+			req := httptest.NewRequest(http.MethodPost, "/runs", strings.NewReader(body))
+			req.Host = tt.host
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			rec := httptest.NewRecorder()
+			s.handler(listen).ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("got status %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+}
+
+func TestReadOnlyRefusesRunsAndJobs(t *testing.T) {
+	repo := initTestRepo(t)
+	ws := testWorkspace(t, repo)
+	ws.readOnly = true
+	ws.discovery = discoveryOptions{pinned: []string{repo}}
+	s := &server{ws: ws, token: "s3cret", jobs: make(map[string]*scheduledJob)}
+	commit := []string{"git", "commit", "--allow-empty", "-m", "x"}
+
+	if _, code, err := s.startRun(runRequest{Command: commit}); err == nil || code != http.StatusForbidden {
+		t.Errorf("run of git commit: got status %d, %v, want it refused", code, err)
+	}
+
+	run := s.runJob(jobConfig{Name: "commit", Every: "1h", Command: commit})
+	if run.Failed != 1 || len(run.Results) != 1 || !strings.Contains(run.Results[0].Output, "read-only") {
+		t.Errorf("job of git commit: got %+v, want it refused", run)
+	}
+	run = s.runJob(jobConfig{Name: "status", Every: "1h", Command: []string{"git", "status"}})
+	if run.Failed != 0 || len(run.Results) != 1 {
+		t.Errorf("job of git status: got %+v, want it run", run)
+	}
+
+	if got := commitCount(t, repo); got != "1" {
+		t.Errorf("got %s commits, want the repository left as it was", got)
+	}
+}
+
+func TestStartRunGuardsForcedCommands(t *testing.T) {
+	repo := initTestRepo(t)
+	ws := testWorkspace(t, repo)
+	ws.snapshot = true
+	ws.discovery = discoveryOptions{pinned: []string{repo}}
+	s := &server{ws: ws, token: "s3cret", jobs: make(map[string]*scheduledJob)}
+	forced := []string{"sh", "-c", "touch ran", "--force"}
+
+	if _, code, err := s.startRun(runRequest{Command: forced}); err == nil || code != http.StatusForbidden {
+		t.Errorf("forced run: got status %d, %v, want it refused", code, err)
+	}
+
+	ws.forceConfirmed = true
+	run, code, err := s.startRun(runRequest{Command: forced})
+	if err != nil || code != http.StatusAccepted {
+		t.Fatalf("forced run with -i-know-what-i-am-doing: got status %d, %v", code, err)
+	}
+	if _, events := run.subscribe(); events != nil {
+		for range events {
+		}
+	}
+	if run.snapshot().Failed != 0 {
+		t.Errorf("forced run with -i-know-what-i-am-doing failed: %+v", run.snapshot())
+	}
+	var state snapshotState
+	readState(snapshotsFile, &state)
+	if len(state.Runs) != 1 || state.Runs[0].Repos[repo].Head == "" {
+		t.Errorf("got snapshots %+v, want one of %s before the forced run", state.Runs, repo)
+	}
+}
@@ -0,0 +1,412 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"sync"
+
+	"golang.org/x/term"
+)
+
+func init() {
+	registerSubcommand(&subcommand{
+		name:    "shell",
+		summary: "run successive commands interactively, discovering the repositories once",
+		run:     runShell,
+	})
+}
+
+// shellBuiltins are the commands of `gits shell` besides the subcommands
+// and commands run in each repository.
+var shellBuiltins = map[string]string{
+	"help":       "list the built in commands",
+	"repos":      "list the matched repositories",
+	"status":     "show the branch summary, cached until the next command",
+	"filter":     "set the filters of every command, e.g. `filter -dirty`, or clear them",
+	"rediscover": "search for the repositories again",
+	"exit":       "leave the shell",
+}
+
+// gitsShell is the state kept between the commands of `gits shell`.
+type gitsShell struct {
+	ws *workspace
+	// all are the discovered repositories, which the filters are applied to
+	all     []string
+	filters filterOptions
+
+	// statuses caches the status of each repository until a command runs
+	mu       sync.Mutex
+	statuses map[string]repoStatus
+}
+
+func runShell(ws *workspace, args []string) int {
+	fs := newSubcommandFlags("shell", "")
+	fs.Parse(args)
+
+	sh := &gitsShell{ws: ws, all: ws.repos, statuses: make(map[string]repoStatus)}
+
+	// Interrupting a command stops the command, not the shell
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	defer signal.Stop(interrupts)
+	go func() {
+		for range interrupts {
+		}
+	}()
+
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		// scripted, one command a line
+		scanner := bufio.NewScanner(os.Stdin)
+		exitCode := 0
+		for scanner.Scan() {
+			code, exit := sh.execute(scanner.Text())
+			if exit {
+				break
+			}
+			exitCode = code
+		}
+		return exitCode
+	}
+
+	fmt.Printf("%d repositories, `help` lists the built in commands\n", len(sh.all))
+	t := term.NewTerminal(struct {
+		io.Reader
+		io.Writer
+	}{os.Stdin, os.Stdout}, "gits> ")
+	t.AutoCompleteCallback = sh.complete
+	for {
+		// the terminal is only raw while editing the line, commands get it as it was
+		state, err := term.MakeRaw(fd)
+		if err != nil {
+			fmt.Println("Error:", err)
+			return 1
+		}
+		if width, height, err := term.GetSize(fd); err == nil && width > 0 {
+			t.SetSize(width, height)
+		}
+		line, err := t.ReadLine()
+		term.Restore(fd, state)
+		if errors.Is(err, io.EOF) {
+			fmt.Println()
+			return 0
+		} else if err != nil && !errors.Is(err, term.ErrPasteIndicator) {
+			fmt.Println("Error:", err)
+			return 1
+		}
+		if _, exit := sh.execute(line); exit {
+			return 0
+		}
+	}
+}
+
+// execute runs a line of input, returning the exit code and whether to leave the shell.
+func (sh *gitsShell) execute(line string) (int, bool) {
+	args, err := splitCommandLine(line)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return 1, false
+	}
+	if len(args) == 0 {
+		return 0, false
+	}
+
+	switch args[0] {
+	case "exit", "quit":
+		return 0, true
+	case "help":
+		var names []string
+		for name := range shellBuiltins {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		for _, name := range names {
+			fmt.Printf("  %-12s %s\n", name, shellBuiltins[name])
+		}
+		fmt.Println("Anything else is a gits subcommand or a command to run in each repository, optionally after filter options such as -dirty.")
+		return 0, false
+	case "filter":
+		fopts, rest, err := parseShellFilters(filterOptions{}, args[1:])
+		if err != nil || len(rest) > 0 {
+			fmt.Println("Usage: filter [-branch name] [-dirty] [-clean] [-needs-gc]")
+			return 1, false
+		}
+		sh.filters = fopts
+		fmt.Printf("Filters: %s\n", describeFilters(fopts))
+		return 0, false
+	case "rediscover":
+		repos, err := sh.ws.rediscover()
+		if err != nil {
+			fmt.Println("Error walking the path:", err)
+			return 1, false
+		}
+		sh.all = repos
+		sh.clearStatuses()
+		fmt.Printf("%d repositories\n", len(repos))
+		return 0, false
+	case "shell":
+		fmt.Println("Already in the shell")
+		return 1, false
+	}
+
+	fopts, command, err := parseShellFilters(sh.filters, args)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return 1, false
+	}
+	if len(command) == 0 {
+		fmt.Println("No command provided")
+		return 1, false
+	}
+	repos := sh.match(fopts)
+
+	switch command[0] {
+	case "repos":
+		for _, repo := range repos {
+			fmt.Println(relativePath(sh.ws.root, repo))
+		}
+		return 0, false
+	case "status":
+		width := longestRelPath(sh.ws.root, repos)
+		results, exitCode := forEachRepo(repos, sh.ws.parallel, func(path string) (string, int) {
+			return formatStatus(path, sh.ws.root, width, slices.Contains(command, "-v"), sh.status(path)), 0
+		})
+		printResults(results)
+		return exitCode, false
+	}
+
+	// Anything run may change the state of the repositories
+	defer sh.clearStatuses()
+
+	if sub := subcommands[command[0]]; sub != nil {
+		ws := *sh.ws
+		ws.repos = repos
+		return sub.run(&ws, command[1:]), false
+	}
+
+	// the same guards as commands given to gits itself
+	if err := sh.ws.checkWrite(command); err != nil {
+		fmt.Println("Error:", err)
+		return 1, false
+	}
+	if !sh.ws.confirmForced(command, repos) {
+		fmt.Println("Not run")
+		return 1, false
+	}
+	if !sh.ws.noLock && !isReadOnlyCommand(command) {
+		lock, err := acquireWorkspaceLock(sh.ws.root, sh.ws.lockTimeout, strings.Join(command, " "))
+		if err != nil {
+			fmt.Println("Error:", err)
+			return 1, false
+		}
+		defer lock.release()
+	}
+	snapshotID, err := sh.ws.snapshotBefore(command, repos)
+	if err != nil {
+		fmt.Printf("Error taking a snapshot, not run:\n  %v\n", err)
+		return 1, false
+	}
+	// the steps of each command would pile up over the session
+	opts := sh.ws.cmdOpts
+	opts.steps = nil
+	results, exitCode := forEachRepo(repos, sh.ws.parallel, func(path string) (string, int) {
+		return processRepo(path, sh.ws.root, command, opts)
+	})
+	printResults(results)
+	if snapshotID != "" {
+		fmt.Printf("Snapshot %s taken before the run, gits undo %s restores the repositories\n", snapshotID, snapshotID)
+	}
+	return exitCode, false
+}
+
+// match returns the discovered repositories that match the filters.
+func (sh *gitsShell) match(fopts filterOptions) []string {
+	filters := fopts.filters()
+	if len(filters) == 0 {
+		return sh.all
+	}
+	matched := make([]bool, len(sh.all))
+	results, _ := forEachRepoWith(sh.all, sh.ws.parallel, false, func(path string) (string, int) {
+		for _, f := range filters {
+			ok, err := f(path)
+			if err != nil {
+				return skippedResult(sh.ws.root, skippedRepo{path: path, reason: err}), 1
+			}
+			if !ok {
+				return "", 0
+			}
+		}
+		matched[slices.Index(sh.all, path)] = true
+		return "", 0
+	})
+	printResults(results)
+
+	var repos []string
+	for i, path := range sh.all {
+		if matched[i] {
+			repos = append(repos, path)
+		}
+	}
+	return repos
+}
+
+func (sh *gitsShell) status(path string) repoStatus {
+	sh.mu.Lock()
+	st, ok := sh.statuses[path]
+	sh.mu.Unlock()
+	if ok {
+		return st
+	}
+	st = collectStatus(path)
+	sh.mu.Lock()
+	sh.statuses[path] = st
+	sh.mu.Unlock()
+	return st
+}
+
+func (sh *gitsShell) clearStatuses() {
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	clear(sh.statuses)
+}
+
+// complete completes the word before the cursor on tab, with the commands
+// for the command and the repository paths for its arguments, to their
+// longest common prefix.
+func (sh *gitsShell) complete(line string, pos int, key rune) (string, int, bool) {
+	if key != '\t' {
+		return "", 0, false
+	}
+	start := strings.LastIndex(line[:pos], " ") + 1
+	word := line[start:pos]
+
+	// the command comes after any filter options
+	commandPosition := true
+	before := strings.Fields(line[:start])
+	for i := 0; i < len(before); i++ {
+		if !strings.HasPrefix(before[i], "-") {
+			commandPosition = false
+			break
+		}
+		if before[i] == "-branch" {
+			i++
+		}
+	}
+
+	var candidates []string
+	if commandPosition {
+		for name := range shellBuiltins {
+			candidates = append(candidates, name)
+		}
+		for name := range subcommands {
+			candidates = append(candidates, name)
+		}
+	} else {
+		for _, repo := range sh.all {
+			candidates = append(candidates, relativePath(sh.ws.root, repo))
+		}
+	}
+	var matches []string
+	for _, c := range candidates {
+		if strings.HasPrefix(c, word) {
+			matches = append(matches, c)
+		}
+	}
+	if len(matches) == 0 {
+		return "", 0, false
+	}
+
+	prefix := matches[0]
+	for _, m := range matches[1:] {
+		for !strings.HasPrefix(m, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	if len(matches) == 1 {
+		prefix += " "
+	}
+	return line[:start] + prefix + line[pos:], start + len(prefix), true
+}
+
+// parseShellFilters parses the filter options at the start of args on top of
+// the given ones, returning the rest of the arguments.
+func parseShellFilters(fopts filterOptions, args []string) (filterOptions, []string, error) {
+	fs := flag.NewFlagSet("filter", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.StringVar(&fopts.Branch, "branch", fopts.Branch, "")
+	fs.BoolVar(&fopts.Dirty, "dirty", fopts.Dirty, "")
+	fs.BoolVar(&fopts.Clean, "clean", fopts.Clean, "")
+	fs.BoolVar(&fopts.NeedsGC, "needs-gc", fopts.NeedsGC, "")
+	err := fs.Parse(args)
+	return fopts, fs.Args(), err
+}
+
+func describeFilters(fopts filterOptions) string {
+	var parts []string
+	if fopts.Branch != "" {
+		parts = append(parts, "-branch "+fopts.Branch)
+	}
+	if fopts.Dirty {
+		parts = append(parts, "-dirty")
+	}
+	if fopts.Clean {
+		parts = append(parts, "-clean")
+	}
+	if fopts.NeedsGC {
+		parts = append(parts, "-needs-gc")
+	}
+	if len(parts) == 0 {
+		return "none"
+	}
+	return strings.Join(parts, " ")
+}
+
+// splitCommandLine splits a line into words like a POSIX shell, with single
+// and double quotes and backslash escapes but no expansions.
+func splitCommandLine(line string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	inWord := false
+	var quote rune
+	escaped := false
+	for _, r := range line {
+		switch {
+		case escaped:
+			word.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped, inWord = true, true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote, inWord = r, true
+		case r == ' ' || r == '\t':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quote != 0 || escaped {
+		return nil, errors.New("unterminated quote or escape")
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
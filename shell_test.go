@@ -0,0 +1,88 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// initTestRepo creates a repository with a commit in a temporary directory.
+func initTestRepo(t *testing.T) string {
+	t.Helper()
+	t.Setenv("GIT_AUTHOR_NAME", "Test")
+	t.Setenv("GIT_AUTHOR_EMAIL", "test@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "Test")
+	t.Setenv("GIT_COMMITTER_EMAIL", "test@example.com")
+	dir := t.TempDir()
+	if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+		dir = resolved
+	}
+	for _, args := range [][]string{{"init", "-q"}, {"commit", "-q", "--allow-empty", "-m", "initial"}} {
+		if out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	return dir
+}
+
+// testWorkspace is a workspace of the repositories that does not take the
+// workspace lock or keep state outside of the test.
+func testWorkspace(t *testing.T, repos ...string) *workspace {
+	t.Helper()
+	stateDirOverride, cacheDirOverride = t.TempDir(), t.TempDir()
+	t.Cleanup(func() { stateDirOverride, cacheDirOverride = "", "" })
+	return &workspace{
+		root:     filepath.Dir(repos[0]),
+		repos:    repos,
+		parallel: 1,
+		config:   &config{},
+		noLock:   true,
+		cmdOpts:  commandOptions{env: &environment{}},
+	}
+}
+
+func TestShellAsksBeforeForcedCommands(t *testing.T) {
+	repo := initTestRepo(t)
+	sh := &gitsShell{ws: testWorkspace(t, repo), all: []string{repo}, statuses: make(map[string]repoStatus)}
+
+	// the tests have no terminal to type the confirmation in
+	if code, _ := sh.execute("sh -c 'touch ran' --force"); code == 0 {
+		t.Error("forced command ran without confirmation")
+	}
+	if _, err := os.Stat(filepath.Join(repo, "ran")); err == nil {
+		t.Error("forced command ran without confirmation")
+	}
+
+	sh.ws.forceConfirmed = true
+	if code, _ := sh.execute("sh -c 'touch ran' --force"); code != 0 {
+		t.Errorf("forced command with -i-know-what-i-am-doing exited with %d", code)
+	}
+	if _, err := os.Stat(filepath.Join(repo, "ran")); err != nil {
+		t.Error("forced command with -i-know-what-i-am-doing did not run")
+	}
+}
+
+func TestShellSnapshotsBeforeWriteCommands(t *testing.T) {
+	repo := initTestRepo(t)
+	ws := testWorkspace(t, repo)
+	ws.snapshot = true
+	sh := &gitsShell{ws: ws, all: []string{repo}, statuses: make(map[string]repoStatus)}
+
+	if code, _ := sh.execute("git status"); code != 0 {
+		t.Fatalf("git status exited with %d", code)
+	}
+	var state snapshotState
+	readState(snapshotsFile, &state)
+	if len(state.Runs) != 0 {
+		t.Errorf("snapshot taken before a read only command")
+	}
+
+	if code, _ := sh.execute("git commit -q --allow-empty -m second"); code != 0 {
+		t.Fatalf("git commit exited with %d", code)
+	}
+	readState(snapshotsFile, &state)
+	if len(state.Runs) != 1 || state.Runs[0].Repos[repo].Head == "" {
+		t.Errorf("got snapshots %+v, want one of %s before git commit", state.Runs, repo)
+	}
+}
@@ -0,0 +1,322 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+func init() {
+	registerSubcommand(&subcommand{
+		name:    "pull",
+		summary: "fast forward each repository from its upstream, skipping or stashing around uncommitted changes",
+		run:     runPull,
+	})
+	registerSubcommand(&subcommand{
+		name:    "fetch",
+		summary: "fetch all the remotes of each repository, pruning the refs of deleted branches",
+		run:     runFetch,
+	})
+	registerSubcommand(&subcommand{
+		name:    "switch",
+		summary: "switch the repositories that have the branch to it, optionally creating it from a remote-tracking branch",
+		run:     runSwitch,
+	})
+	registerSubcommand(&subcommand{
+		name:    "prune-branches",
+		summary: "delete the local branches already merged into the default branch",
+		run:     runPruneBranches,
+	})
+}
+
+// pullConfig configures gits pull.
+type pullConfig struct {
+	// Autostash stashes uncommitted changes around the pull instead of
+	// skipping the repository
+	Autostash bool `yaml:"autostash"`
+}
+
+func runPull(ws *workspace, args []string) int {
+	fs := newSubcommandFlags("pull", "")
+	autostash := fs.Bool("autostash", ws.config.Pull.Autostash, "stash the uncommitted changes of dirty repositories and pop them after pulling, instead of skipping them (default: pull.autostash of the configuration)")
+	fs.Parse(args)
+
+	release, ok := ws.lock("pull")
+	if !ok {
+		return 1
+	}
+	defer release()
+
+	results, exitCode := forEachRepo(ws.repos, ws.parallel, func(path string) (string, int) {
+		return pullRepo(path, relativePath(ws.root, path), *autostash)
+	})
+	printResults(results)
+	return exitCode
+}
+
+// pullRepo fast forwards the current branch of the repository from its
+// upstream, stashing its uncommitted changes first if autostash is set.
+func pullRepo(path string, relPath string, autostash bool) (string, int) {
+	if detectBridge(path) == bridgeSVN {
+		return svnRebaseRepo(path, relPath)
+	}
+	branch, err := getCurrentBranch(path)
+	if err != nil {
+		return fmt.Sprintf("\033[1m❌ %s:\033[0m %v", relPath, err), 1
+	}
+	if branch == "HEAD" {
+		return fmt.Sprintf("\033[1m⏭️ %s:\033[0m detached HEAD", relPath), 0
+	}
+	switch _, _, upstream, _ := getAheadBehind(path); upstream {
+	case upstreamNone:
+		return fmt.Sprintf("\033[1m⏭️ %s:\033[0m %s has no upstream", relPath, branch), 0
+	case upstreamGone:
+		return fmt.Sprintf("\033[1m⏭️ %s:\033[0m the upstream of %s is gone", relPath, branch), 0
+	}
+	changed, err := hasTrackedChanges(path)
+	if err != nil {
+		return fmt.Sprintf("\033[1m❌ %s:\033[0m %v", relPath, err), 1
+	}
+	if changed && !autostash {
+		return fmt.Sprintf("\033[1m⏭️ %s:\033[0m has uncommitted changes, -autostash stashes them", relPath), 0
+	}
+
+	if changed {
+		if _, err := runGit(path, "stash", "push", "-q", "-m", "gits pull"); err != nil {
+			return fmt.Sprintf("\033[1m❌ %s:\033[0m %v", relPath, err), 1
+		}
+	}
+	before, _ := resolveCommit(path, "HEAD")
+	_, pullErr := runGit(path, "pull", "-q", "--ff-only")
+	after, _ := resolveCommit(path, "HEAD")
+	if changed {
+		// the changes are popped whether or not the pull worked
+		if _, err := runGit(path, "stash", "pop", "-q"); err != nil {
+			return fmt.Sprintf("\033[1m❌ %s:\033[0m the stashed changes conflict, they are kept in the stash: %v", relPath, err), 1
+		}
+	}
+	if pullErr != nil {
+		return fmt.Sprintf("\033[1m❌ %s:\033[0m %v", relPath, pullErr), 1
+	}
+
+	result := "up to date"
+	if before != after {
+		result = fmt.Sprintf("fast forwarded %s %s..%s", branch, commitLink(path, before), commitLink(path, after))
+	}
+	if changed {
+		result += ", around stashed changes"
+	}
+	return fmt.Sprintf("\033[1m✅️ %s:\033[0m %s", relPath, result), 0
+}
+
+func runFetch(ws *workspace, args []string) int {
+	fs := newSubcommandFlags("fetch", "")
+	tags := fs.Bool("prune-tags", false, "also delete the local tags that are gone from the remotes")
+	fs.Parse(args)
+
+	release, ok := ws.lock("fetch")
+	if !ok {
+		return 1
+	}
+	defer release()
+
+	results, exitCode := forEachRepo(ws.repos, ws.parallel, func(path string) (string, int) {
+		relPath := relativePath(ws.root, path)
+		remotes, err := getRemotes(path)
+		if err != nil {
+			return fmt.Sprintf("\033[1m❌ %s:\033[0m %v", relPath, err), 1
+		}
+		bridge := detectBridge(path)
+		if len(remotes) == 0 && bridge != bridgeSVN {
+			return fmt.Sprintf("\033[1m⏭️ %s:\033[0m no remotes", relPath), 0
+		}
+		before, err := getRefTips(path, "refs/remotes/", "refs/tags/")
+		if err != nil {
+			return fmt.Sprintf("\033[1m❌ %s:\033[0m %v", relPath, err), 1
+		}
+		fetchArgs := []string{"fetch", "-q", "--all", "--prune"}
+		if *tags {
+			fetchArgs = append(fetchArgs, "--prune-tags")
+		}
+		if len(remotes) > 0 {
+			if _, err := runGit(path, fetchArgs...); err != nil {
+				return fmt.Sprintf("\033[1m❌ %s:\033[0m %v", relPath, err), 1
+			}
+		}
+		if bridge == bridgeSVN {
+			// the svn remote-tracking refs are only updated by git svn
+			if _, err := runGit(path, "svn", "fetch", "-q"); err != nil {
+				return fmt.Sprintf("\033[1m❌ %s:\033[0m %v", relPath, err), 1
+			}
+		}
+		after, err := getRefTips(path, "refs/remotes/", "refs/tags/")
+		if err != nil {
+			return fmt.Sprintf("\033[1m❌ %s:\033[0m %v", relPath, err), 1
+		}
+		return fmt.Sprintf("\033[1m✅️ %s:\033[0m %s", relPath, describeRefChanges(before, after)), 0
+	})
+	printResults(results)
+	return exitCode
+}
+
+// describeRefChanges summarizes how the refs changed, such as
+// "3 updated, 1 pruned", leaving out the remote HEADs that follow them.
+func describeRefChanges(before map[string]string, after map[string]string) string {
+	updated, pruned := 0, 0
+	for ref, hash := range after {
+		if before[ref] != hash && !strings.HasSuffix(ref, "/HEAD") {
+			updated++
+		}
+	}
+	for ref := range before {
+		if _, ok := after[ref]; !ok {
+			pruned++
+		}
+	}
+	var parts []string
+	if updated > 0 {
+		parts = append(parts, fmt.Sprintf("%d updated", updated))
+	}
+	if pruned > 0 {
+		parts = append(parts, fmt.Sprintf("%d pruned", pruned))
+	}
+	if len(parts) == 0 {
+		return "up to date"
+	}
+	return strings.Join(parts, ", ")
+}
+
+func runSwitch(ws *workspace, args []string) int {
+	fs := newSubcommandFlags("switch", " <branch>")
+	create := fs.Bool("create", false, "in repositories where the branch is only on a remote, create it tracking the remote-tracking branch")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fmt.Println("the branch is required")
+		fs.Usage()
+		return 1
+	}
+	branch := fs.Arg(0)
+
+	release, ok := ws.lock("switch")
+	if !ok {
+		return 1
+	}
+	defer release()
+
+	results, exitCode := forEachRepo(ws.repos, ws.parallel, func(path string) (string, int) {
+		return switchRepo(path, relativePath(ws.root, path), branch, *create)
+	})
+	printResults(results)
+	return exitCode
+}
+
+// switchRepo switches the repository to the branch if it has it, or with
+// create to a new branch tracking the only remote that has it.
+func switchRepo(path string, relPath string, branch string, create bool) (string, int) {
+	current, err := getCurrentBranch(path)
+	if err != nil {
+		return fmt.Sprintf("\033[1m❌ %s:\033[0m %v", relPath, err), 1
+	}
+	if current == branch {
+		return fmt.Sprintf("\033[1m✅️ %s:\033[0m already on %s", relPath, branch), 0
+	}
+
+	args := []string{"switch", "-q", branch}
+	result := "switched to " + branch
+	if _, err := resolveCommit(path, "refs/heads/"+branch); err != nil {
+		tips, err := getRefTips(path, "refs/remotes/")
+		if err != nil {
+			return fmt.Sprintf("\033[1m❌ %s:\033[0m %v", relPath, err), 1
+		}
+		var remoteBranches []string
+		for ref := range tips {
+			if remote, name, ok := strings.Cut(strings.TrimPrefix(ref, "refs/remotes/"), "/"); ok && name == branch {
+				remoteBranches = append(remoteBranches, remote+"/"+name)
+			}
+		}
+		sortNames(remoteBranches)
+		switch {
+		case len(remoteBranches) == 0:
+			return fmt.Sprintf("\033[1m⏭️ %s:\033[0m no branch %s", relPath, branch), 0
+		case !create:
+			return fmt.Sprintf("\033[1m⏭️ %s:\033[0m %s is only on %s, -create creates it", relPath, branch, strings.Join(remoteBranches, ", ")), 0
+		case len(remoteBranches) > 1:
+			return fmt.Sprintf("\033[1m⚠️ %s:\033[0m %s is on several remotes: %s", relPath, branch, strings.Join(remoteBranches, ", ")), 1
+		}
+		args = []string{"switch", "-q", "--track", remoteBranches[0]}
+		result = "created " + branch + " tracking " + remoteBranches[0]
+	}
+	if _, err := runGit(path, args...); err != nil {
+		return fmt.Sprintf("\033[1m❌ %s:\033[0m %v", relPath, err), 1
+	}
+	return fmt.Sprintf("\033[1m✅️ %s:\033[0m %s", relPath, result), 0
+}
+
+func runPruneBranches(ws *workspace, args []string) int {
+	fs := newSubcommandFlags("prune-branches", "")
+	dryRun := fs.Bool("n", false, "only list the branches that would be deleted")
+	fs.Parse(args)
+
+	if !*dryRun {
+		release, ok := ws.lock("prune-branches")
+		if !ok {
+			return 1
+		}
+		defer release()
+	}
+
+	results, exitCode := forEachRepo(ws.repos, ws.parallel, func(path string) (string, int) {
+		relPath := relativePath(ws.root, path)
+		target, base := mergeTarget(path)
+		if target == "" {
+			return fmt.Sprintf("\033[1m⏭️ %s:\033[0m origin/HEAD is not set, run git remote set-head origin -a", relPath), 0
+		}
+		merged, err := mergedBranches(path, target, base)
+		if err != nil {
+			return fmt.Sprintf("\033[1m❌ %s:\033[0m %v", relPath, err), 1
+		}
+		if len(merged) == 0 {
+			return "", 0
+		}
+		if *dryRun {
+			return fmt.Sprintf("\033[1m%s:\033[0m would delete %s, merged into %s", relPath, strings.Join(merged, ", "), target), 0
+		}
+		if _, err := runGit(path, append([]string{"branch", "-q", "-D"}, merged...)...); err != nil {
+			return fmt.Sprintf("\033[1m❌ %s:\033[0m %v", relPath, err), 1
+		}
+		return fmt.Sprintf("\033[1m✅️ %s:\033[0m deleted %s, merged into %s", relPath, strings.Join(merged, ", "), target), 0
+	})
+	printResults(results)
+	return exitCode
+}
+
+// mergeTarget returns the ref branches are merged into, origin's default
+// branch or the local one if there is no remote-tracking branch, and the
+// name of the default branch, which is never pruned.
+func mergeTarget(path string) (string, string) {
+	base := strings.TrimPrefix(getSymbolicRef(path, "refs/remotes/origin/HEAD"), "refs/remotes/origin/")
+	if base == "" {
+		return "", ""
+	}
+	if _, err := resolveCommit(path, "refs/remotes/origin/"+base); err == nil {
+		return "origin/" + base, base
+	}
+	return base, base
+}
+
+// mergedBranches returns the local branches merged into the target, apart
+// from the default branch and the branches checked out in a worktree.
+func mergedBranches(path string, target string, base string) ([]string, error) {
+	out, err := runGit(path, "for-each-ref", "--merged="+target, "--format=%(refname:short) %(worktreepath)", "refs/heads/")
+	if err != nil {
+		return nil, err
+	}
+	var merged []string
+	for _, line := range strings.Split(out, "\n") {
+		name, worktree, _ := strings.Cut(line, " ")
+		if name != "" && name != base && worktree == "" {
+			merged = append(merged, name)
+		}
+	}
+	return merged, nil
+}
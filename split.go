@@ -0,0 +1,193 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+func init() {
+	registerSubcommand(&subcommand{
+		name:    "split",
+		summary: "extract a directory of a repository into a new repository with its history",
+		run:     runSplit,
+	})
+}
+
+func runSplit(ws *workspace, args []string) int {
+	fs := newSubcommandFlags("split", " <repository>")
+	path := fs.String("path", "", "directory to extract, relative to the repository")
+	to := fs.String("to", "", "directory to create the new repository in, relative to the current directory")
+	remote := fs.String("remote", "", "URL of the origin remote of the new repository")
+	push := fs.Bool("push", false, "push the new repository to -remote")
+	replace := fs.String("replace", "", "on a new branch of the repository, replace the directory with a submodule of the new repository, or a pointer README")
+	branch := fs.String("branch", "", "with -replace, branch to create (default: split/<directory name>)")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 || *path == "" || *to == "" {
+		fmt.Println("the repository, -path and -to are required")
+		fs.Usage()
+		return 1
+	}
+	if *replace != "" && *replace != "submodule" && *replace != "pointer" {
+		fmt.Println("Invalid -replace: must be submodule or pointer")
+		return 1
+	}
+	if (*push || *replace != "") && *remote == "" {
+		fmt.Println("-push and -replace need -remote")
+		return 1
+	}
+	source, within, ok := ws.repoContaining(fs.Arg(0))
+	if !ok || within != "" {
+		fmt.Printf("%s is not a matched repository\n", fs.Arg(0))
+		return 1
+	}
+	relPath := relativePath(ws.root, source)
+	dir := filepath.ToSlash(filepath.Clean(*path))
+	dest, err := filepath.Abs(*to)
+	if err != nil {
+		fmt.Println("Error resolving -to:", err)
+		return 1
+	}
+	if _, err := os.Stat(dest); err == nil {
+		fmt.Printf("%s already exists\n", *to)
+		return 1
+	}
+	if *branch == "" {
+		*branch = "split/" + filepath.Base(dir)
+	}
+
+	release, ok := ws.lock("split")
+	if !ok {
+		return 1
+	}
+	defer release()
+
+	policy, err := ws.commitPolicy()
+	if err != nil {
+		fmt.Println("Error reading manifest:", err)
+		return 1
+	}
+
+	if *replace != "" {
+		if changed, err := hasTrackedChanges(source); err != nil || changed {
+			fmt.Printf("%s has uncommitted changes\n", relPath)
+			return 1
+		}
+	}
+	if _, err := runGit(source, "cat-file", "-e", "HEAD:"+dir); err != nil {
+		fmt.Printf("%s has no %s\n", relPath, dir)
+		return 1
+	}
+
+	fmt.Printf("Splitting the history of %s out of %s\n", dir, relPath)
+	split, err := splitSubtree(source, dir)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return 1
+	}
+
+	defaultBranch, err := getDefaultBranch(source)
+	if err != nil {
+		defaultBranch = "main"
+	}
+	if err := createSplitRepo(dest, defaultBranch, source, split, *remote); err != nil {
+		fmt.Printf("Error creating %s: %v\n", *to, err)
+		return 1
+	}
+	fmt.Printf("\033[1m✅️ %s:\033[0m created with the history of %s on %s\n", *to, dir, defaultBranch)
+
+	if *push {
+		if _, err := runGit(dest, "push", "-q", "-u", "origin", defaultBranch); err != nil {
+			fmt.Printf("Error pushing %s: %v\n", *to, err)
+			return 1
+		}
+		fmt.Printf("\033[1m✅️ %s:\033[0m pushed to %s\n", *to, *remote)
+	}
+
+	switch *replace {
+	case "submodule":
+		err = replaceWithSubmodule(source, *branch, dir, dest, *remote, policy)
+	case "pointer":
+		err = replaceWithPointer(source, *branch, dir, *remote, policy)
+	default:
+		return 0
+	}
+	if err != nil {
+		fmt.Printf("Error replacing %s in %s: %v\n", dir, relPath, err)
+		return 1
+	}
+	fmt.Printf("\033[1m✅️ %s:\033[0m replaced %s with a %s on %s\n", relPath, dir, *replace, *branch)
+	return 0
+}
+
+// createSplitRepo initialises a repository at dest with the branch at the
+// commit of the source repository.
+func createSplitRepo(dest string, branch string, source string, commit string, remote string) error {
+	if err := os.MkdirAll(dest, 0o755); err != nil {
+		return err
+	}
+	if _, err := runGit(dest, "init", "-q", "-b", branch); err != nil {
+		return err
+	}
+	if err := fetchCommit(dest, source, commit); err != nil {
+		return err
+	}
+	if _, err := runGit(dest, "reset", "-q", "--hard", "FETCH_HEAD"); err != nil {
+		return err
+	}
+	if remote != "" {
+		if _, err := runGit(dest, "remote", "add", "origin", remote); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// replaceWithSubmodule commits, on a new branch, the directory replaced by
+// a submodule of the new repository with the remote as its URL.
+func replaceWithSubmodule(repo string, branch string, dir string, dest string, remote string, policy *commitPolicy) error {
+	if _, err := runGit(repo, "checkout", "-q", "-b", branch); err != nil {
+		return err
+	}
+	if _, err := runGit(repo, "rm", "-r", "-q", "--", dir); err != nil {
+		return err
+	}
+	// cloned from the new repository, which may not have been pushed yet
+	if _, err := runGit(repo, "-c", "protocol.file.allow=always", "submodule", "add", "-q", "--name", filepath.Base(dir), dest, dir); err != nil {
+		return err
+	}
+	if _, err := runGit(repo, "config", "-f", ".gitmodules", "submodule."+filepath.Base(dir)+".url", remote); err != nil {
+		return err
+	}
+	if _, err := runGit(repo, "submodule", "sync", "-q"); err != nil {
+		return err
+	}
+	if _, err := runGit(repo, "add", ".gitmodules"); err != nil {
+		return err
+	}
+	return policy.commit(repo, fmt.Sprintf("Replace %s with a submodule of %s", dir, remote))
+}
+
+// replaceWithPointer commits, on a new branch, the directory replaced by a
+// README saying where it went.
+func replaceWithPointer(repo string, branch string, dir string, remote string, policy *commitPolicy) error {
+	if _, err := runGit(repo, "checkout", "-q", "-b", branch); err != nil {
+		return err
+	}
+	if _, err := runGit(repo, "rm", "-r", "-q", "--", dir); err != nil {
+		return err
+	}
+	readme := filepath.Join(repo, filepath.FromSlash(dir), "README.md")
+	if err := os.MkdirAll(filepath.Dir(readme), 0o755); err != nil {
+		return err
+	}
+	content := fmt.Sprintf("# %s\n\nThis directory has moved to its own repository, %s\n", filepath.Base(dir), remote)
+	if err := os.WriteFile(readme, []byte(content), 0o644); err != nil {
+		return err
+	}
+	if _, err := runGit(repo, "add", "--", dir); err != nil {
+		return err
+	}
+	return policy.commit(repo, fmt.Sprintf("Move %s to %s", dir, remote))
+}
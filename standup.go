@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+func init() {
+	registerSubcommand(&subcommand{
+		name:    "standup",
+		summary: "list recent commits across the repositories",
+		run:     runStandup,
+	})
+}
+
+func runStandup(ws *workspace, args []string) int {
+	fs := newSubcommandFlags("standup", "")
+	days := fs.Int("days", 1, "number of days to look back")
+	team := fs.Bool("team", false, "list everyone's commits grouped by author")
+	author := fs.String("author", "", "list this author's commits instead of the configured user.email")
+	fs.Parse(args)
+
+	since := time.Now().Add(-time.Duration(*days) * 24 * time.Hour)
+
+	type teamCommit struct {
+		relPath string
+		commit  commitInfo
+	}
+	var mu sync.Mutex
+	byAuthor := make(map[string][]teamCommit)
+
+	results, exitCode := forEachRepo(ws.repos, ws.parallel, func(repo string) (string, int) {
+		relPath := relativePath(ws.root, repo)
+
+		me := *author
+		if me == "" && !*team {
+			me, _ = getConfigValue(repo, "user.email")
+		}
+
+		commits, err := getCommits(repo, "--branches", "--remotes", "--since="+since.Format(time.RFC3339))
+		if err != nil {
+			return fmt.Sprintf("\033[1m❌ %s:\033[0m %v", relPath, err), 1
+		}
+
+		var lines []string
+		for _, c := range commits {
+			if *team {
+				who := fmt.Sprintf("%s <%s>", c.authorName, c.authorEmail)
+				mu.Lock()
+				byAuthor[who] = append(byAuthor[who], teamCommit{relPath: relPath, commit: c})
+				mu.Unlock()
+			} else if c.authorEmail == me || c.authorName == me {
+				lines = append(lines, fmt.Sprintf("  %s %s \033[2m(%s)\033[0m", commitLink(repo, c.hash), c.subject, tr("%s ago", formatAge(time.Since(c.when)))))
+			}
+		}
+
+		if len(lines) == 0 {
+			return "", 0
+		}
+		return fmt.Sprintf("\033[1m%s:\033[0m\n%s", relPath, strings.Join(lines, "\n")), 0
+	})
+
+	if *team {
+		authors := make([]string, 0, len(byAuthor))
+		for who := range byAuthor {
+			authors = append(authors, who)
+		}
+		sort.Strings(authors)
+		for _, who := range authors {
+			commits := byAuthor[who]
+			slices.SortStableFunc(commits, func(a, b teamCommit) int { return b.commit.when.Compare(a.commit.when) })
+			fmt.Println(plain("\033[1m" + who + ":\033[0m"))
+			for _, tc := range commits {
+				fmt.Println(plain(fmt.Sprintf("  \033[1m%s\033[0m %.7s %s \033[2m(%s)\033[0m", tc.relPath, tc.commit.hash, tc.commit.subject, tr("%s ago", formatAge(time.Since(tc.commit.when))))))
+			}
+		}
+	}
+
+	printResults(results)
+	return exitCode
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// stateDirOverride and cacheDirOverride are the directories given with
+// -state-dir and -cache-dir, such as for each user of a shared build machine.
+var (
+	stateDirOverride string
+	cacheDirOverride string
+)
+
+// stateDir returns the directory where gits keeps state between runs, such
+// as sessions, snapshots and the history of runs: -state-dir, or
+// $XDG_STATE_HOME/gits or ~/.local/state/gits.
+func stateDir() (string, error) {
+	if stateDirOverride != "" {
+		return stateDirOverride, nil
+	}
+	if dir := os.Getenv("XDG_STATE_HOME"); dir != "" {
+		return filepath.Join(dir, "gits"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".local", "state", "gits"), nil
+}
+
+// cacheDir returns the directory where gits keeps what it can find again,
+// such as the repositories found by a search, the workspace locks and
+// temporary files: -cache-dir, or $XDG_CACHE_HOME/gits or the cache
+// directory of the platform.
+func cacheDir() (string, error) {
+	if cacheDirOverride != "" {
+		return cacheDirOverride, nil
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "gits"), nil
+}
+
+// tempDir returns the directory for temporary files, such as temporary
+// indexes, in the cache directory so it is private to the user.
+func tempDir() (string, error) {
+	dir, err := cacheDir()
+	if err != nil {
+		return "", err
+	}
+	dir = filepath.Join(dir, "tmp")
+	return dir, os.MkdirAll(dir, 0o700)
+}
+
+// readState decodes the named JSON state file into v, leaving v unchanged
+// if the file does not exist.
+func readState(name string, v any) error {
+	dir, err := stateDir()
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// writeState atomically replaces the named JSON state file with v.
+func writeState(name string, v any) error {
+	dir, err := stateDir()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, name+".*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(dir, name))
+}
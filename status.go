@@ -0,0 +1,293 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// repoStatus is a summary of the branches and state of a repository.
+type repoStatus struct {
+	Branch        string          `json:"branch"`
+	DefaultBranch string          `json:"default_branch"`
+	Dirty         bool            `json:"dirty"`
+	Sync          RemoteSyncState `json:"sync"`
+	// Ahead and Behind are the commits of the difference with the upstream
+	Ahead    int           `json:"ahead"`
+	Behind   int           `json:"behind"`
+	Upstream upstreamState `json:"upstream"`
+	// Branches are the other local branches
+	Branches []string `json:"branches"`
+	// StaleRemoteRefs is the number of remote-tracking refs of deleted
+	// branches found by the last `gits audit stale-remotes`
+	StaleRemoteRefs int `json:"stale_remote_refs,omitempty"`
+	// UnpushedNotes are the notes refs with notes origin did not have as of
+	// the last `gits notes push` or `gits notes pull`
+	UnpushedNotes []string `json:"unpushed_notes,omitempty"`
+	// VCS is jj or sl for the repositories of those version control
+	// systems, and empty for git
+	VCS string `json:"vcs,omitempty"`
+	// Bridge is svn or hg for git-svn and hg-git bridge repositories, and
+	// empty for the others
+	Bridge string `json:"bridge,omitempty"`
+	// Annotations are the output of the annotations of the configuration
+	// by name, set by -status rather than collectStatus
+	Annotations map[string]string `json:"annotations,omitempty"`
+	// Ticket is the ID of the ticket in the branch name and TicketURL its
+	// page, from the tickets of the configuration
+	Ticket    string `json:"ticket,omitempty"`
+	TicketURL string `json:"ticket_url,omitempty"`
+}
+
+// statusDefaultBranch is the default branch status compares the branches
+// with, read once for all the repositories. init.defaultBranch only names
+// the first branch of new repositories, so it is a setting of the user
+// rather than of each repository.
+var statusDefaultBranch = sync.OnceValue(func() string {
+	defaultBranch, err := getDefaultBranch("")
+	if err != nil {
+		return "main"
+	}
+	return defaultBranch
+})
+
+// collectStatus collects the status of the repository with one git status
+// and one git for-each-ref, rather than a git process for each part of it,
+// as it is run for every repository of the workspace.
+func collectStatus(path string) repoStatus {
+	if k := detectVCS(path); k.status != nil {
+		return k.status(path)
+	}
+	st := repoStatus{Sync: SyncRemote, Upstream: upstreamNone, Bridge: detectBridge(path)}
+
+	out, err := exec.Command("git", "-C", path, "status", "--porcelain=v2", "--branch").Output()
+	if err == nil {
+		parseStatusV2(string(out), &st)
+	} else if isBareRepo(path) {
+		// a bare repository has no worktree to be dirty
+		if st.Branch, err = getCurrentBranch(path); err != nil {
+			st.Branch = "!" + err.Error()
+		}
+	} else {
+		st.Branch = "!" + err.Error()
+		st.Dirty = true
+	}
+
+	st.DefaultBranch = statusDefaultBranch()
+
+	tips, _ := getRefTips(path, "refs/heads/", "refs/notes/", notesTrackingPrefix+"origin/")
+	for ref := range tips {
+		if name, ok := strings.CutPrefix(ref, "refs/heads/"); ok && name != st.Branch {
+			st.Branches = append(st.Branches, name)
+		}
+	}
+	sortNames(st.Branches)
+	st.UnpushedNotes = unpushedNotes(path, "origin", tips)
+
+	// the remotes are not asked, so this is as of the last audit
+	st.StaleRemoteRefs = staleRemoteCounts()[path]
+	return st
+}
+
+// parseStatusV2 sets the branch, upstream and dirtiness of the status from
+// the output of git status --porcelain=v2 --branch.
+func parseStatusV2(out string, st *repoStatus) {
+	for _, line := range strings.Split(out, "\n") {
+		switch {
+		case strings.HasPrefix(line, "# branch.head "):
+			st.Branch = strings.TrimPrefix(line, "# branch.head ")
+			if st.Branch == "(detached)" {
+				st.Branch = "HEAD"
+			}
+		case strings.HasPrefix(line, "# branch.upstream "):
+			// without a branch.ab line the upstream is gone
+			if st.Upstream == upstreamNone {
+				st.Upstream = upstreamGone
+			}
+		case strings.HasPrefix(line, "# branch.ab "):
+			st.Upstream = upstreamTracking
+			fmt.Sscanf(strings.TrimPrefix(line, "# branch.ab "), "+%d -%d", &st.Ahead, &st.Behind)
+		case line != "" && !strings.HasPrefix(line, "#"):
+			st.Dirty = true
+		}
+	}
+	// a diverged branch is behind, as it has to be pulled before it can be
+	// pushed
+	switch {
+	case st.Behind > 0:
+		st.Sync = BehindRemote
+	case st.Ahead > 0:
+		st.Sync = AheadRemote
+	}
+}
+
+// Kinds of diff shown under dirty repositories by -show-diff.
+const (
+	diffStat = "stat"
+	diffFull = "full"
+)
+
+// maxDiffLines is how many lines of a diff -show-diff shows for each repository.
+const maxDiffLines = 40
+
+// statusRepo renders the status row of the repository, first fetching its
+// remotes if fetch is set, with the annotations and ticket link of the
+// configuration, followed by what is uncommitted if showDiff is stat or full
+// and the repository is dirty.
+func statusRepo(path string, root string, width int, verbose bool, fetch bool, showDiff string, columns []string, cfg *config) (string, int) {
+	if fetch {
+		if _, err := runGit(path, "fetch", "--quiet"); err != nil {
+			return fmt.Sprintf("\033[1m❌ %s:\033[0m %v", relativePath(root, path), err), 1
+		}
+	}
+	st := collectStatus(path)
+	st.Annotations = cfg.Status.annotate(root, path)
+	st.Ticket, st.TicketURL = cfg.ticket(root, path, st.Branch)
+	row := formatStatus(path, root, width, verbose, st)
+	if columns != nil {
+		row = formatStatusColumns(path, root, st, columns, cfg.Status.Widths)
+	}
+	if showDiff != "" && st.Dirty {
+		if diff := uncommittedDiff(path, showDiff); diff != "" {
+			row += "\n  " + strings.ReplaceAll(diff, "\n", "\n  ")
+		}
+	}
+	return row, 0
+}
+
+// uncommittedDiff renders the changes to the tracked files, as a diffstat or
+// the full diff, in color, and lists the untracked files, cut down to
+// maxDiffLines.
+func uncommittedDiff(path string, kind string) string {
+	args := []string{"diff", "--color=always", "HEAD"}
+	if kind == diffStat {
+		args = []string{"diff", "--color=always", "--stat", "HEAD"}
+	}
+	// the output is not trimmed, the diffstat is aligned with leading spaces
+	out, err := exec.Command("git", append([]string{"-C", path}, args...)...).Output()
+	if err != nil {
+		// without any commits everything is untracked or staged
+		out, _ = exec.Command("git", append([]string{"-C", path}, append(args[:len(args)-1], "--cached")...)...).Output()
+	}
+	diff := strings.TrimRight(string(out), "\n")
+	lines := strings.Split(diff, "\n")
+	if diff == "" {
+		lines = nil
+	}
+	if untracked, _ := runGit(path, "ls-files", "--others", "--exclude-standard"); untracked != "" {
+		for _, file := range strings.Split(untracked, "\n") {
+			lines = append(lines, "\033[31m?? "+file+"\033[0m")
+		}
+	}
+	if len(lines) > maxDiffLines {
+		lines = append(lines[:maxDiffLines], fmt.Sprintf("… %d more lines", len(lines)-maxDiffLines))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// formatStatus renders the status of the repository as a row of the -status summary.
+func formatStatus(path string, root string, width int, verbose bool, st repoStatus) string {
+	relPath := relativePath(root, path)
+	if a11yOutput {
+		return formatStatusA11y(relPath, st)
+	}
+	currentBranch, defaultBranch, clean, remoteSync, localBranches := st.Branch, st.DefaultBranch, !st.Dirty, st.Sync, st.Branches
+
+	var branches strings.Builder
+	if currentBranch == defaultBranch {
+		branches.WriteString(" [\033[1;32m")
+	} else {
+		branches.WriteString(" [\033[1;31m")
+	}
+	branches.WriteString(hyperlink(st.TicketURL, currentBranch))
+	branches.WriteString("\033[0m]")
+
+	var status strings.Builder
+	if !clean {
+		status.WriteString("📝")
+	}
+	switch remoteSync {
+	case BehindRemote:
+		status.WriteString("😰")
+	case AheadRemote:
+		status.WriteString("🏎💨")
+	}
+
+	if status.Len() > 0 {
+		branches.WriteString("\u001B[31m(\u001B[0m")
+		branches.WriteString(status.String())
+		branches.WriteString("\u001B[31m)\u001B[0m")
+	}
+
+	switch {
+	case st.Upstream == upstreamGone:
+		branches.WriteString(" \033[31mupstream gone\033[0m")
+	case st.Upstream == upstreamNone && st.VCS == "" && st.Bridge != bridgeSVN && currentBranch != "HEAD" && !strings.HasPrefix(currentBranch, "!"):
+		branches.WriteString(" \033[2mno upstream\033[0m")
+	case st.Ahead > 0 || st.Behind > 0:
+		var counts []string
+		if st.Ahead > 0 {
+			counts = append(counts, fmt.Sprintf("↑%d", st.Ahead))
+		}
+		if st.Behind > 0 {
+			counts = append(counts, fmt.Sprintf("↓%d", st.Behind))
+		}
+		fmt.Fprintf(&branches, " %s", strings.Join(counts, " "))
+	}
+
+	for _, name := range localBranches {
+		branches.WriteString(" [\033[34m")
+		branches.WriteString(name)
+		branches.WriteString("\033[0m]")
+	}
+
+	if st.VCS != "" {
+		fmt.Fprintf(&branches, " \033[35m%s\033[0m", st.VCS)
+	}
+
+	if st.Bridge != "" {
+		fmt.Fprintf(&branches, " \033[35m⇄%s\033[0m", st.Bridge)
+	}
+
+	for _, name := range sortedKeys(st.Annotations) {
+		fmt.Fprintf(&branches, " \033[36m%s\033[0m", st.Annotations[name])
+	}
+
+	if st.StaleRemoteRefs > 0 {
+		fmt.Fprintf(&branches, " \033[2m✂%d\033[0m", st.StaleRemoteRefs)
+	}
+
+	if len(st.UnpushedNotes) > 0 {
+		fmt.Fprintf(&branches, " \033[33m🗒%d\033[0m", len(st.UnpushedNotes))
+	}
+
+	if verbose {
+		if c, err := getObjectCounts(path); err == nil {
+			fmt.Fprintf(&branches, " \033[2m%d loose (%d KiB), %d packs (%d KiB)", c.Loose, c.LooseSize, c.Packs, c.PackSize)
+			if c.Garbage > 0 {
+				fmt.Fprintf(&branches, ", %d garbage (%d KiB)", c.Garbage, c.GarbageSize)
+			}
+			branches.WriteString("\033[0m")
+		}
+		if gc, err := needsGC(path); err == nil && gc {
+			branches.WriteString(" \033[33mneeds gc\033[0m")
+		}
+	}
+
+	// padded before it is linked, as the link is not shown
+	name := repoLink(path, fmt.Sprintf("%"+strconv.Itoa(-width)+"s", relPath))
+	return fmt.Sprintf("\033[1m%s\033[0m%s", name, branches.String())
+}
+
+// longestRelPath returns the length of the longest repository path relative to root.
+func longestRelPath(root string, repos []string) int {
+	longestName := 0
+	for _, repo := range repos {
+		if l := len(relativePath(root, repo)); l > longestName {
+			longestName = l
+		}
+	}
+	return longestName
+}
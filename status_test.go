@@ -0,0 +1,90 @@
+package main
+
+import (
+	"os/exec"
+	"reflect"
+	"testing"
+)
+
+func TestParseStatusV2(t *testing.T) {
+	tests := []struct {
+		name string
+		out  string
+		want repoStatus
+	}{
+		{
+			"clean, in sync",
+			"# branch.oid 1234\n# branch.head main\n# branch.upstream origin/main\n# branch.ab +0 -0\n",
+			repoStatus{Branch: "main", Sync: SyncRemote, Upstream: upstreamTracking},
+		},
+		{
+			"ahead",
+			"# branch.head main\n# branch.upstream origin/main\n# branch.ab +2 -0\n",
+			repoStatus{Branch: "main", Sync: AheadRemote, Ahead: 2, Upstream: upstreamTracking},
+		},
+		{
+			"behind",
+			"# branch.head main\n# branch.upstream origin/main\n# branch.ab +0 -3\n",
+			repoStatus{Branch: "main", Sync: BehindRemote, Behind: 3, Upstream: upstreamTracking},
+		},
+		{
+			"diverged",
+			"# branch.head main\n# branch.upstream origin/main\n# branch.ab +2 -3\n",
+			repoStatus{Branch: "main", Sync: BehindRemote, Ahead: 2, Behind: 3, Upstream: upstreamTracking},
+		},
+		{
+			"upstream gone",
+			"# branch.head topic\n# branch.upstream origin/topic\n",
+			repoStatus{Branch: "topic", Sync: SyncRemote, Upstream: upstreamGone},
+		},
+		{
+			"no upstream",
+			"# branch.head topic\n",
+			repoStatus{Branch: "topic", Sync: SyncRemote, Upstream: upstreamNone},
+		},
+		{
+			"detached",
+			"# branch.oid 1234\n# branch.head (detached)\n",
+			repoStatus{Branch: "HEAD", Sync: SyncRemote, Upstream: upstreamNone},
+		},
+		{
+			"modified",
+			"# branch.head main\n1 .M N... 100644 100644 100644 1234 1234 main.go\n",
+			repoStatus{Branch: "main", Dirty: true, Sync: SyncRemote, Upstream: upstreamNone},
+		},
+		{
+			"untracked",
+			"# branch.head main\n? notes.txt\n",
+			repoStatus{Branch: "main", Dirty: true, Sync: SyncRemote, Upstream: upstreamNone},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := repoStatus{Sync: SyncRemote, Upstream: upstreamNone}
+			parseStatusV2(tt.out, &st)
+			if !reflect.DeepEqual(st, tt.want) {
+				t.Errorf("got %+v, want %+v", st, tt.want)
+			}
+		})
+	}
+}
+
+func BenchmarkCollectStatus(b *testing.B) {
+	b.Setenv("GIT_AUTHOR_NAME", "Test")
+	b.Setenv("GIT_AUTHOR_EMAIL", "test@example.com")
+	b.Setenv("GIT_COMMITTER_NAME", "Test")
+	b.Setenv("GIT_COMMITTER_EMAIL", "test@example.com")
+	stateDirOverride = b.TempDir()
+	b.Cleanup(func() { stateDirOverride = "" })
+	repo := b.TempDir()
+	for _, args := range [][]string{{"init", "-q"}, {"commit", "-q", "--allow-empty", "-m", "initial"}, {"branch", "topic"}} {
+		if out, err := exec.Command("git", append([]string{"-C", repo}, args...)...).CombinedOutput(); err != nil {
+			b.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		collectStatus(repo)
+	}
+}
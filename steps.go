@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// stepResult is how one step of a command run in several steps went in a
+// repository, such as an attempt of -retries or the merge of gits land.
+type stepResult struct {
+	Name       string `json:"name"`
+	ExitCode   int    `json:"exit_code"`
+	DurationMS int64  `json:"duration_ms"`
+}
+
+// firstFailingStep returns the name of the first of the steps that failed,
+// or the empty string if none did.
+func firstFailingStep(steps []stepResult) string {
+	for _, s := range steps {
+		if s.ExitCode != 0 {
+			return s.Name
+		}
+	}
+	return ""
+}
+
+// stepMatrix collects the steps run in each repository, to show which step
+// failed where after the results.
+type stepMatrix struct {
+	mu sync.Mutex
+	// names are the names of the steps in the order they were first run
+	names []string
+	// steps are the steps of each repository by relative path
+	steps map[string][]stepResult
+}
+
+// add records a step run in the repository.
+func (m *stepMatrix) add(relPath string, s stepResult) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.steps == nil {
+		m.steps = make(map[string][]stepResult)
+	}
+	if !slices.Contains(m.names, s.Name) {
+		m.names = append(m.names, s.Name)
+	}
+	m.steps[relPath] = append(m.steps[relPath], s)
+}
+
+// step runs fn as the named step of the repository and records how it went.
+func (m *stepMatrix) step(relPath string, name string, fn func() error) error {
+	start := time.Now()
+	err := fn()
+	s := stepResult{Name: name, DurationMS: time.Since(start).Milliseconds()}
+	if err != nil {
+		s.ExitCode = 1
+	}
+	m.add(relPath, s)
+	return err
+}
+
+// action runs the action of a repository as the named step and records how
+// it went.
+func (m *stepMatrix) action(relPath string, name string, action func() (string, int)) (string, int) {
+	start := time.Now()
+	result, exitCode := action()
+	m.add(relPath, stepResult{Name: name, ExitCode: exitCode, DurationMS: time.Since(start).Milliseconds()})
+	return result, exitCode
+}
+
+// failed reports whether a step failed in any repository.
+func (m *stepMatrix) failed() bool {
+	if m == nil {
+		return false
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, steps := range m.steps {
+		if firstFailingStep(steps) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// write prints the matrix with a row for each repository and a column for
+// each step, showing how long the steps that passed took, the exit code of
+// those that failed and a dot for those not run, followed by the first
+// step that failed.
+func (m *stepMatrix) write(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	repos := sortedKeys(m.steps)
+	sortNames(repos)
+	header := append([]string{tr("Steps")}, m.names...)
+	header = append(header, tr("First failure"))
+	rows := [][]string{header}
+	for _, relPath := range repos {
+		row := []string{relPath}
+		for _, name := range m.names {
+			cell := "·"
+			// a step repeated in the repository shows its last run
+			for _, s := range m.steps[relPath] {
+				if s.Name != name {
+					continue
+				}
+				if s.ExitCode == 0 {
+					cell = "✓ " + (time.Duration(s.DurationMS) * time.Millisecond).String()
+				} else {
+					cell = "✗ " + tr("exit code %d", s.ExitCode)
+				}
+			}
+			row = append(row, cell)
+		}
+		row = append(row, firstFailingStep(m.steps[relPath]))
+		rows = append(rows, row)
+	}
+
+	widths := make([]int, len(header))
+	for _, row := range rows {
+		for i, cell := range row {
+			widths[i] = max(widths[i], len([]rune(cell)))
+		}
+	}
+	for _, row := range rows {
+		var line strings.Builder
+		for i, cell := range row {
+			fmt.Fprintf(&line, "  %s%s", cell, strings.Repeat(" ", widths[i]-len([]rune(cell))))
+		}
+		fmt.Fprintln(w, strings.TrimRight(line.String(), " "))
+	}
+}
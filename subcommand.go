@@ -0,0 +1,134 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// workspace is the set of repositories matched by the global options that a
+// subcommand operates on.
+type workspace struct {
+	root     string
+	repos    []string
+	parallel int
+	config   *config
+	// profile is the selected configuration profile, or nil
+	profile *profile
+
+	noLock      bool
+	lockTimeout time.Duration
+	// readOnly refuses to run subcommands that modify the repositories
+	readOnly bool
+	// forceConfirmed runs forced commands without asking, with
+	// -i-know-what-i-am-doing
+	forceConfirmed bool
+	// snapshot snapshots the repositories before write and forced commands
+	snapshot bool
+
+	// cmdOpts controls how subcommands run external commands
+	cmdOpts commandOptions
+
+	// discovery are the options used to find the repositories
+	discovery discoveryOptions
+}
+
+// rediscover searches for the matching repositories again, for long running
+// subcommands where repositories may come and go.
+func (ws *workspace) rediscover() ([]string, error) {
+	found, err := findRepos(ws.discovery)
+	if err != nil {
+		return nil, err
+	}
+	return found.repos, nil
+}
+
+// lock takes the workspace lock for a subcommand that modifies the
+// repositories, printing an error if it cannot be taken. The returned
+// function releases it.
+func (ws *workspace) lock(description string) (func(), bool) {
+	// every subcommand that modifies the repositories takes the lock
+	if ws.readOnly {
+		fmt.Printf("Refusing to run %s in -read-only mode\n", description)
+		return nil, false
+	}
+	if ws.noLock {
+		return func() {}, true
+	}
+	l, err := acquireWorkspaceLock(ws.root, ws.lockTimeout, description)
+	if err != nil {
+		fmt.Println("Error:", err)
+		fmt.Println("Use -lock-timeout to wait for it or -no-lock to run anyway")
+		return nil, false
+	}
+	return atExit(l.release), true
+}
+
+// subcommand is a built-in command that is run instead of an external command
+// when its name is the first argument.
+type subcommand struct {
+	name    string
+	summary string
+	run     func(ws *workspace, args []string) int
+	// standalone subcommands do not operate on the matched repositories, so
+	// the repositories are not searched for before they are run
+	standalone bool
+}
+
+var subcommands = map[string]*subcommand{}
+
+func registerSubcommand(s *subcommand) {
+	subcommands[s.name] = s
+}
+
+// newSubcommandFlags creates the flag set used to parse a subcommand's arguments.
+func newSubcommandFlags(name string, args string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Printf("Usage: gits [options] %s [subcommand options]%s\n", name, args)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+func printSubcommands() {
+	printCommandList("Subcommands:", subcommands)
+}
+
+func printCommandList(title string, commands map[string]*subcommand) {
+	names := make([]string, 0, len(commands))
+	width := 0
+	for name := range commands {
+		names = append(names, name)
+		width = max(width, len(name))
+	}
+	sort.Strings(names)
+
+	fmt.Println(title)
+	for _, name := range names {
+		fmt.Printf("  %-*s  %s\n", width, name, commands[name].summary)
+	}
+}
+
+// repoContaining returns the matched repository containing the path,
+// relative to the workspace root, and the path within it.
+func (ws *workspace) repoContaining(relPath string) (string, string, bool) {
+	relPath = filepath.Clean(relPath)
+	best, within := "", ""
+	for _, repo := range ws.repos {
+		r := relativePath(ws.root, repo)
+		switch {
+		case relPath == r && len(repo) > len(best):
+			best, within = repo, ""
+		case strings.HasPrefix(relPath, r+"/") && len(repo) > len(best):
+			best, within = repo, strings.TrimPrefix(relPath, r+"/")
+		case r == "." && best == "":
+			// the root is a repository containing everything
+			best, within = repo, relPath
+		}
+	}
+	return best, within, best != ""
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+func init() {
+	registerSubcommand(&subcommand{
+		name:    "sync",
+		summary: "clone the repositories of the workspace manifest that are missing and fast forward the clean ones on their default branch",
+		run:     runSync,
+
+		standalone: true,
+	})
+}
+
+func runSync(ws *workspace, args []string) int {
+	fs := newSubcommandFlags("sync", "")
+	partial := fs.String("partial", "", "partial clone kind for every missing repository: blobless, treeless or none (default: from the manifest)")
+	refOption := fs.String("reference-dir", "", "borrow objects from the reference repositories maintained by `gits cache update` in this directory (default: reference_dir from the configuration)")
+	fs.Parse(args)
+
+	if _, ok := partialFilters[*partial]; *partial != "" && *partial != "none" && !ok {
+		fmt.Println("Invalid -partial: expected blobless, treeless or none")
+		return 1
+	}
+
+	m, err := findManifest(ws.root)
+	if err != nil {
+		fmt.Println("Error reading manifest:", err)
+		return 1
+	}
+	if m == nil {
+		fmt.Printf("No %s found in %s or above\n", manifestName, ws.root)
+		return 1
+	}
+
+	release, ok := ws.lock("sync")
+	if !ok {
+		return 1
+	}
+	defer release()
+
+	var present []string
+	repos := make(map[string]manifestRepo)
+	for _, r := range m.Repos {
+		path := m.absPath(r)
+		if _, err := os.Stat(path); err == nil && r.Retired == "" {
+			present = append(present, path)
+			repos[path] = r
+		}
+	}
+
+	steps := &stepMatrix{}
+	cloned, cloneExitCode := cloneMissing(ws, m, *partial, referenceDir(ws, *refOption), steps)
+	printResults(cloned)
+
+	results, exitCode := forEachRepo(present, ws.parallel, func(path string) (string, int) {
+		relPath := relativePath(ws.root, path)
+		return steps.action(relPath, "fast forward", func() (string, int) {
+			return fastForwardRepo(path, repos[path], relPath)
+		})
+	})
+	printResults(results)
+	// which of the clones and fast forwards failed
+	if steps.failed() {
+		steps.write(os.Stdout)
+	}
+	return max(exitCode, cloneExitCode)
+}
+
+// fastForwardRepo fast forwards the repository from its upstream if it is
+// clean and on the default branch of origin, or the branch of the manifest.
+func fastForwardRepo(path string, r manifestRepo, relPath string) (string, int) {
+	if !isGitRepo(path) {
+		return fmt.Sprintf("\033[1m⚠️ %s:\033[0m not a repository", relPath), 1
+	}
+	if detectBridge(path) == bridgeSVN {
+		return fmt.Sprintf("\033[1m⏭️ %s:\033[0m a git-svn repository, gits pull runs git svn rebase", relPath), 0
+	}
+	branch := r.Branch
+	if branch == "" {
+		branch = strings.TrimPrefix(getSymbolicRef(path, "refs/remotes/origin/HEAD"), "refs/remotes/origin/")
+	}
+	if branch == "" {
+		return fmt.Sprintf("\033[1m❌ %s:\033[0m origin/HEAD is not set, run git remote set-head origin -a", relPath), 1
+	}
+	current, err := getCurrentBranch(path)
+	if err != nil {
+		return fmt.Sprintf("\033[1m❌ %s:\033[0m %v", relPath, err), 1
+	}
+	if current != branch {
+		return fmt.Sprintf("\033[1m⏭️ %s:\033[0m on %s, not %s", relPath, current, branch), 0
+	}
+	if changed, err := hasTrackedChanges(path); err != nil || changed {
+		return fmt.Sprintf("\033[1m⏭️ %s:\033[0m has uncommitted changes", relPath), 0
+	}
+
+	before, err := resolveCommit(path, "HEAD")
+	if err != nil {
+		return fmt.Sprintf("\033[1m❌ %s:\033[0m %v", relPath, err), 1
+	}
+	if _, err := runGit(path, "pull", "-q", "--ff-only"); err != nil {
+		return fmt.Sprintf("\033[1m❌ %s:\033[0m %v", relPath, err), 1
+	}
+	after, err := resolveCommit(path, "HEAD")
+	if err != nil {
+		return fmt.Sprintf("\033[1m❌ %s:\033[0m %v", relPath, err), 1
+	}
+	if before == after {
+		return "", 0
+	}
+	return fmt.Sprintf("\033[1m✅️ %s:\033[0m fast forwarded %s %s..%s", relPath, branch, commitLink(path, before), commitLink(path, after)), 0
+}
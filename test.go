@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+func init() {
+	registerSubcommand(&subcommand{
+		name:    "test",
+		summary: "run the tests of each detected ecosystem, retrying flaky failures and keeping a history of the results",
+		run:     runTest,
+	})
+}
+
+// testHistoryLength is how many results of each repository are kept.
+const testHistoryLength = 50
+
+// testHistory is the results of `gits test` in each repository, by absolute
+// path, oldest first.
+type testHistory struct {
+	Repos map[string][]testRecord `json:"repos"`
+}
+
+type testRecord struct {
+	Time   time.Time `json:"time"`
+	Passed bool      `json:"passed"`
+	// Attempts is how many runs it took to pass, or were made before failing
+	Attempts int           `json:"attempts"`
+	Duration time.Duration `json:"duration"`
+}
+
+func runTest(ws *workspace, args []string) int {
+	fs := newSubcommandFlags("test", "")
+	retries := fs.Int("retries", 2, "how many times to rerun the tests of a repository after they fail")
+	var quarantine []string
+	fs.Var((*stringList)(&quarantine), "quarantine", "glob of repository paths whose failures are reported but do not fail the run (may be repeated)")
+	history := fs.Bool("history", false, "show the recorded results instead of running the tests")
+	fs.Parse(args)
+
+	var state testHistory
+	if err := readState("test-history.json", &state); err != nil {
+		fmt.Println("Error reading test history:", err)
+		return 1
+	}
+	if *history {
+		printTestHistory(ws, state)
+		return 0
+	}
+
+	var mu sync.Mutex
+	records := make(map[string]testRecord)
+	results, exitCode := forEachRepo(ws.repos, ws.parallel, func(repo string) (string, int) {
+		relPath := relativePath(ws.root, repo)
+		start := time.Now()
+		var names []string
+		var output string
+		failed := true
+		attempts := 0
+		for failed && attempts <= *retries {
+			attempts++
+			names, output, failed = runToolchains(ws, repo, func(t toolchain) []string { return t.test })
+		}
+		if len(names) == 0 {
+			return toolchainResult(relPath, names, output), 0
+		}
+
+		mu.Lock()
+		records[repo] = testRecord{Time: start, Passed: !failed, Attempts: attempts, Duration: time.Since(start)}
+		mu.Unlock()
+
+		switch {
+		case failed && matchesAnyGlob(quarantine, relPath):
+			return fmt.Sprintf("\033[1m⚠️ %s:\033[0m failed %d times, quarantined\n  %s", relPath, attempts, strings.ReplaceAll(output, "\n", "\n  ")), 0
+		case failed:
+			return fmt.Sprintf("\033[1m❌ %s:\033[0m failed %d times\n  %s", relPath, attempts, strings.ReplaceAll(output, "\n", "\n  ")), 1
+		case attempts > 1:
+			return fmt.Sprintf("\033[1m⚠️ %s:\033[0m %s passed on attempt %d, flaky", relPath, strings.Join(names, ", "), attempts), 0
+		}
+		return fmt.Sprintf("\033[1m✅️ %s:\033[0m %s", relPath, strings.Join(names, ", ")), 0
+	})
+	printResults(results)
+
+	if state.Repos == nil {
+		state.Repos = make(map[string][]testRecord)
+	}
+	for repo, record := range records {
+		h := append(state.Repos[repo], record)
+		state.Repos[repo] = h[max(0, len(h)-testHistoryLength):]
+	}
+	if err := writeState("test-history.json", state); err != nil {
+		fmt.Println("Error saving test history:", err)
+		return 1
+	}
+	return exitCode
+}
+
+// printTestHistory shows the recorded results of each repository, newest
+// last, with its pass rate and how often it only passed on a retry.
+func printTestHistory(ws *workspace, state testHistory) {
+	width := longestRelPath(ws.root, ws.repos)
+	// with -a11y the runs are read as words rather than marks
+	mark := func(color string, symbol string, word string) string {
+		if a11yOutput {
+			return word
+		}
+		return "\033[" + color + "m" + symbol + "\033[0m"
+	}
+	for _, repo := range ws.repos {
+		records := state.Repos[repo]
+		if len(records) == 0 {
+			continue
+		}
+		var timeline []string
+		passed, flaky := 0, 0
+		for _, r := range records {
+			switch {
+			case !r.Passed:
+				timeline = append(timeline, mark("31", "✗", "failed"))
+			case r.Attempts > 1:
+				timeline = append(timeline, mark("33", "✓", "flaky"))
+				passed++
+				flaky++
+			default:
+				timeline = append(timeline, mark("32", "✓", "passed"))
+				passed++
+			}
+		}
+		separator, runs := "", "  "
+		if a11yOutput {
+			separator, runs = ", ", ": "
+		}
+		fmt.Println(plain(fmt.Sprintf("\033[1m%-*s\033[0m %*d%% passed, %d flaky, %d runs%s%s", padding(width), relativePath(ws.root, repo), padding(3), 100*passed/len(records), flaky, len(records), runs, strings.Join(timeline, separator))))
+	}
+}
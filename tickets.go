@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ticketConfig is a pattern of the ticket IDs in branch names, such as
+// OPS-12 in OPS-12-fix-retries, and the tracker page of the tickets.
+type ticketConfig struct {
+	// Pattern is a regular expression matching the ticket ID in the branch
+	// name, or its first group if it has one
+	Pattern string `yaml:"pattern"`
+	// URL is the page of the ticket, where {ticket} is its ID and the
+	// repository placeholders such as {name} are expanded
+	URL string `yaml:"url"`
+
+	re *regexp.Regexp
+}
+
+// compileTickets checks and compiles the ticket patterns.
+func (c *config) compileTickets() error {
+	for i := range c.Tickets {
+		t := &c.Tickets[i]
+		if t.Pattern == "" || t.URL == "" {
+			return fmt.Errorf("tickets need a pattern and a url")
+		}
+		re, err := regexp.Compile(t.Pattern)
+		if err != nil {
+			return fmt.Errorf("ticket pattern `%s`: %v", t.Pattern, err)
+		}
+		t.re = re
+	}
+	return nil
+}
+
+// ticket returns the ID and URL of the ticket of the branch of the
+// repository, from the first of the patterns that matches it.
+func (c *config) ticket(root string, path string, branch string) (string, string) {
+	for _, t := range c.Tickets {
+		m := t.re.FindStringSubmatch(branch)
+		if m == nil {
+			continue
+		}
+		id := m[0]
+		if len(m) > 1 {
+			id = m[1]
+		}
+		url := newRepoInfo(root, path).expand([]string{t.URL})[0]
+		return id, strings.ReplaceAll(url, "{ticket}", id)
+	}
+	return "", ""
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+func init() {
+	registerSubcommand(&subcommand{
+		name:    "fmt",
+		summary: "format the repositories with the formatter of each detected ecosystem, such as gofmt, prettier or black",
+		run:     runFmt,
+	})
+	registerSubcommand(&subcommand{
+		name:    "lint",
+		summary: "lint the repositories with the linter of each detected ecosystem, such as go vet, eslint or ruff",
+		run:     runLint,
+	})
+}
+
+// toolchain is how an ecosystem is formatted, linted and tested.
+type toolchain struct {
+	name string
+	// markers are files at the root of repositories of the ecosystem
+	markers []string
+	// format rewrites the files, check only reports whether they need it
+	format []string
+	check  []string
+	lint   []string
+	test   []string
+}
+
+// toolchains are the ecosystems gits fmt, gits lint and gits test know.
+var toolchains = []toolchain{
+	{
+		name:    "go",
+		markers: []string{"go.mod"},
+		format:  []string{"gofmt", "-l", "-w", "."},
+		check:   []string{"sh", "-c", `out=$(gofmt -l .) && [ -z "$out" ] || { echo "$out"; exit 1; }`},
+		lint:    []string{"go", "vet", "./..."},
+		test:    []string{"go", "test", "./..."},
+	},
+	{
+		name:    "javascript",
+		markers: []string{"package.json"},
+		format:  []string{"npx", "--no-install", "prettier", "--write", "--log-level", "warn", "."},
+		check:   []string{"npx", "--no-install", "prettier", "--check", "."},
+		lint:    []string{"npx", "--no-install", "eslint", "."},
+		test:    []string{"npm", "test", "--silent"},
+	},
+	{
+		name:    "python",
+		markers: []string{"pyproject.toml", "setup.py", "requirements.txt"},
+		format:  []string{"black", "--quiet", "."},
+		check:   []string{"black", "--check", "."},
+		lint:    []string{"ruff", "check", "."},
+		test:    []string{"pytest", "-q"},
+	},
+	{
+		name:    "rust",
+		markers: []string{"Cargo.toml"},
+		format:  []string{"cargo", "fmt"},
+		check:   []string{"cargo", "fmt", "--check"},
+		lint:    []string{"cargo", "clippy", "--quiet", "--", "-D", "warnings"},
+		test:    []string{"cargo", "test", "--quiet"},
+	},
+}
+
+// detectToolchains returns the toolchains of the ecosystems in the repository.
+func detectToolchains(repo string) []toolchain {
+	var found []toolchain
+	for _, t := range toolchains {
+		for _, marker := range t.markers {
+			if _, err := os.Stat(filepath.Join(repo, marker)); err == nil {
+				found = append(found, t)
+				break
+			}
+		}
+	}
+	return found
+}
+
+// runToolchains runs the command each toolchain of the repository picks in
+// turn, returning the names of those run, their combined output and whether
+// any failed. Toolchains whose tool is not installed are reported in the
+// output rather than failing.
+func runToolchains(ws *workspace, repo string, pick func(toolchain) []string) ([]string, string, bool) {
+	var names []string
+	var output strings.Builder
+	failed := false
+	info := newRepoInfo(ws.root, repo)
+	for _, t := range detectToolchains(repo) {
+		command := pick(t)
+		if _, err := exec.LookPath(command[0]); err != nil {
+			fmt.Fprintf(&output, "%s: %v\n", t.name, err)
+			continue
+		}
+		names = append(names, t.name)
+		out, exitCode := runCommand(repo, ws.cmdOpts.limits.wrap(command), ws.cmdOpts.env.build(info.environ()))
+		if exitCode != 0 {
+			failed = true
+		}
+		if out = strings.TrimRight(out, "\n"); out != "" {
+			fmt.Fprintf(&output, "%s: %s\n", t.name, out)
+		}
+	}
+	return names, strings.TrimRight(output.String(), "\n"), failed
+}
+
+func runFmt(ws *workspace, args []string) int {
+	fs := newSubcommandFlags("fmt", "")
+	check := fs.Bool("check", false, "only report the repositories that need formatting, without changing them")
+	commit := fs.Bool("commit", false, "commit the formatting changes in repositories that were clean")
+	message := fs.String("message", "Format code", "with -commit, commit message")
+	fs.Parse(args)
+
+	if !*check {
+		release, ok := ws.lock("fmt")
+		if !ok {
+			return 1
+		}
+		defer release()
+	}
+
+	policy, err := ws.commitPolicy()
+	if err != nil {
+		fmt.Println("Error reading manifest:", err)
+		return 1
+	}
+
+	results, exitCode := forEachRepo(ws.repos, ws.parallel, func(repo string) (string, int) {
+		relPath := relativePath(ws.root, repo)
+		if *check {
+			names, output, failed := runToolchains(ws, repo, func(t toolchain) []string { return t.check })
+			if failed {
+				return fmt.Sprintf("\033[1m⚠️ %s:\033[0m needs formatting\n  %s", relPath, strings.ReplaceAll(output, "\n", "\n  ")), 1
+			}
+			return toolchainResult(relPath, names, output), 0
+		}
+
+		before, err := getDirtyFiles(repo)
+		if err != nil {
+			return fmt.Sprintf("\033[1m❌ %s:\033[0m %v", relPath, err), 1
+		}
+		names, output, failed := runToolchains(ws, repo, func(t toolchain) []string { return t.format })
+		if failed {
+			return fmt.Sprintf("\033[1m❌ %s:\033[0m\n  %s", relPath, strings.ReplaceAll(output, "\n", "\n  ")), 1
+		}
+		after, err := getDirtyFiles(repo)
+		if err != nil {
+			return fmt.Sprintf("\033[1m❌ %s:\033[0m %v", relPath, err), 1
+		}
+		var changed []string
+		for _, f := range after {
+			if !slices.Contains(before, f) {
+				changed = append(changed, f)
+			}
+		}
+		if len(changed) == 0 {
+			return toolchainResult(relPath, names, output), 0
+		}
+
+		result := fmt.Sprintf("\033[1m⚠️ %s:\033[0m reformatted %d files\n  %s", relPath, len(changed), strings.Join(changed, "\n  "))
+		if !*commit {
+			return result, 0
+		}
+		if len(before) > 0 {
+			return result + "\n  not committed as the worktree was already dirty", 1
+		}
+		if err := commitFiles(repo, changed, *message, policy); err != nil {
+			return fmt.Sprintf("\033[1m❌ %s:\033[0m %v", relPath, err), 1
+		}
+		return fmt.Sprintf("\033[1m✅️ %s:\033[0m committed %d reformatted files", relPath, len(changed)), 0
+	})
+	printResults(results)
+	return exitCode
+}
+
+func runLint(ws *workspace, args []string) int {
+	fs := newSubcommandFlags("lint", "")
+	fs.Parse(args)
+
+	results, exitCode := forEachRepo(ws.repos, ws.parallel, func(repo string) (string, int) {
+		relPath := relativePath(ws.root, repo)
+		names, output, failed := runToolchains(ws, repo, func(t toolchain) []string { return t.lint })
+		if failed {
+			return fmt.Sprintf("\033[1m❌ %s:\033[0m\n  %s", relPath, strings.ReplaceAll(output, "\n", "\n  ")), 1
+		}
+		return toolchainResult(relPath, names, output), 0
+	})
+	printResults(results)
+	return exitCode
+}
+
+// toolchainResult reports the toolchains run in a repository where all
+// succeeded, nothing for repositories of no known ecosystem.
+func toolchainResult(relPath string, names []string, output string) string {
+	if len(names) == 0 && output == "" {
+		return ""
+	}
+	result := fmt.Sprintf("\033[1m✅️ %s:\033[0m %s", relPath, strings.Join(names, ", "))
+	if output != "" {
+		result += "\n  " + strings.ReplaceAll(output, "\n", "\n  ")
+	}
+	return result
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+func init() {
+	registerSubcommand(&subcommand{
+		name:    "transplant",
+		summary: "move a directory from one repository to another with its history, on new branches in both",
+		run:     runTransplant,
+	})
+}
+
+func runTransplant(ws *workspace, args []string) int {
+	fs := newSubcommandFlags("transplant", "")
+	from := fs.String("from", "", "repository to move the directory out of, relative to the workspace root")
+	path := fs.String("path", "", "directory to move, relative to the -from repository")
+	to := fs.String("to", "", "where to move it, a path relative to the workspace root inside the destination repository")
+	branch := fs.String("branch", "", "branch to create in both repositories (default: transplant/<directory name>)")
+	fs.Parse(args)
+
+	if *from == "" || *path == "" || *to == "" {
+		fmt.Println("-from, -path and -to are required")
+		fs.Usage()
+		return 1
+	}
+	source, within, ok := ws.repoContaining(*from)
+	if !ok || within != "" {
+		fmt.Printf("%s is not a matched repository\n", *from)
+		return 1
+	}
+	dest, prefix, ok := ws.repoContaining(*to)
+	if !ok || prefix == "" {
+		fmt.Printf("%s is not a path inside a matched repository\n", *to)
+		return 1
+	}
+	if dest == source {
+		fmt.Println("-to is in the -from repository, use git mv")
+		return 1
+	}
+	dir := filepath.ToSlash(filepath.Clean(*path))
+	if *branch == "" {
+		*branch = "transplant/" + filepath.Base(dir)
+	}
+
+	release, ok := ws.lock("transplant")
+	if !ok {
+		return 1
+	}
+	defer release()
+
+	policy, err := ws.commitPolicy()
+	if err != nil {
+		fmt.Println("Error reading manifest:", err)
+		return 1
+	}
+
+	for _, repo := range []string{source, dest} {
+		if changed, err := hasTrackedChanges(repo); err != nil || changed {
+			fmt.Printf("%s has uncommitted changes\n", relativePath(ws.root, repo))
+			return 1
+		}
+	}
+	if _, err := runGit(source, "cat-file", "-e", "HEAD:"+dir); err != nil {
+		fmt.Printf("%s has no %s\n", relativePath(ws.root, source), dir)
+		return 1
+	}
+	if _, err := runGit(dest, "cat-file", "-e", "HEAD:"+prefix); err == nil {
+		fmt.Printf("%s already has %s\n", relativePath(ws.root, dest), prefix)
+		return 1
+	}
+
+	fmt.Printf("Splitting the history of %s out of %s\n", dir, relativePath(ws.root, source))
+	split, err := splitSubtree(source, dir)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return 1
+	}
+
+	message := fmt.Sprintf("Move %s from %s", dir, relativePath(ws.root, source))
+	if err := addSubtree(dest, *branch, prefix, source, split, message, policy); err != nil {
+		fmt.Printf("Error adding %s to %s: %v\n", prefix, relativePath(ws.root, dest), err)
+		return 1
+	}
+	fmt.Printf("\033[1m✅️ %s:\033[0m added %s with its history on %s\n", relativePath(ws.root, dest), prefix, *branch)
+
+	if err := removeOnBranch(source, *branch, dir, fmt.Sprintf("Move %s to %s", dir, filepath.ToSlash(*to)), policy); err != nil {
+		fmt.Printf("Error removing %s from %s: %v\n", dir, relativePath(ws.root, source), err)
+		return 1
+	}
+	fmt.Printf("\033[1m✅️ %s:\033[0m removed %s on %s\n", relativePath(ws.root, source), dir, *branch)
+	return 0
+}
+
+// splitSubtree returns a commit whose history is that of the directory of
+// the repository's HEAD, with the directory as its root.
+func splitSubtree(repo string, dir string) (string, error) {
+	out, err := runGit(repo, "subtree", "split", "-q", "--prefix="+dir, "HEAD")
+	if err != nil {
+		return "", err
+	}
+	lines := strings.Split(out, "\n")
+	return strings.TrimSpace(lines[len(lines)-1]), nil
+}
+
+// fetchCommit fetches a commit of the other repository into FETCH_HEAD.
+func fetchCommit(repo string, other string, commit string) error {
+	// the commit may only be reachable from a temporary ref of the other repository
+	ref := "refs/gits/fetch"
+	if _, err := runGit(other, "update-ref", ref, commit); err != nil {
+		return err
+	}
+	defer runGit(other, "update-ref", "-d", ref)
+	_, err := runGit(repo, "fetch", "-q", other, ref)
+	return err
+}
+
+// addSubtree checks out a new branch in the repository and merges the
+// commit of the other repository into it under the prefix, following the
+// commit policy.
+func addSubtree(repo string, branch string, prefix string, other string, commit string, message string, policy *commitPolicy) error {
+	if err := fetchCommit(repo, other, commit); err != nil {
+		return err
+	}
+	if _, err := runGit(repo, "checkout", "-q", "-b", branch); err != nil {
+		return err
+	}
+	message, err := policy.message(repo, message)
+	if err != nil {
+		return err
+	}
+	if _, err := runGit(repo, "subtree", "add", "-q", "--prefix="+prefix, "-m", message, "FETCH_HEAD"); err != nil {
+		return err
+	}
+	if sign := policy.signArgs(); sign != nil {
+		// git subtree cannot sign the merge itself
+		_, err = runGit(repo, append([]string{"commit", "-q", "--amend", "--no-edit"}, sign...)...)
+	}
+	return err
+}
+
+// removeOnBranch checks out a new branch in the repository and commits the
+// removal of the directory on it.
+func removeOnBranch(repo string, branch string, dir string, message string, policy *commitPolicy) error {
+	if _, err := runGit(repo, "checkout", "-q", "-b", branch); err != nil {
+		return err
+	}
+	if _, err := runGit(repo, "rm", "-r", "-q", "--", dir); err != nil {
+		return err
+	}
+	return policy.commit(repo, message)
+}
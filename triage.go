@@ -0,0 +1,153 @@
+//go:build !noforge
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"time"
+)
+
+func init() {
+	registerSubcommand(&subcommand{
+		name:    "triage",
+		summary: "show the open issue and pull request counts of the repositories on their forges",
+		run:     runTriage,
+	})
+}
+
+// openCounts are the open issues and pull requests of a repository.
+type openCounts struct {
+	relPath string
+	repo    forgeRepo
+	issues  int
+	pulls   int
+}
+
+// triageFilter restricts what is counted.
+type triageFilter struct {
+	label string
+	// before only counts what was opened before this time, if it is set
+	before time.Time
+}
+
+func runTriage(ws *workspace, args []string) int {
+	fs := newSubcommandFlags("triage", "")
+	label := fs.String("label", "", "only count issues and pull requests with this label")
+	olderThan := fs.String("older-than", "", "only count issues and pull requests opened longer ago than this, e.g. 30d")
+	sortBy := fs.String("sort", "total", "order of the repositories: total, issues, pulls or name")
+	fs.Parse(args)
+
+	if !slices.Contains([]string{"total", "issues", "pulls", "name"}, *sortBy) {
+		fmt.Println("Invalid -sort: expected total, issues, pulls or name")
+		return 1
+	}
+	filter := triageFilter{label: *label}
+	if *olderThan != "" {
+		age, err := parseAge(*olderThan)
+		if err != nil {
+			fmt.Println("Invalid -older-than:", err)
+			return 1
+		}
+		filter.before = time.Now().Add(-age)
+	}
+
+	counts := make([]*openCounts, len(ws.repos))
+	results, exitCode := forEachRepo(ws.repos, ws.parallel, func(repo string) (string, int) {
+		relPath := relativePath(ws.root, repo)
+		client, fr, err := forgeClientFor(ws, repo)
+		if err != nil {
+			return fmt.Sprintf("\033[1m⏭️ %s:\033[0m %v", relPath, err), 0
+		}
+		c := &openCounts{relPath: relPath, repo: fr}
+		if c.issues, c.pulls, err = client.openCounts(fr, filter); err != nil {
+			return fmt.Sprintf("\033[1m❌ %s:\033[0m %s: %v", relPath, fr, err), 1
+		}
+		counts[slices.Index(ws.repos, repo)] = c
+		return "", 0
+	})
+	printResults(results)
+
+	counts = slices.DeleteFunc(counts, func(c *openCounts) bool { return c == nil })
+	slices.SortStableFunc(counts, func(a, b *openCounts) int {
+		switch *sortBy {
+		case "issues":
+			return b.issues - a.issues
+		case "pulls":
+			return b.pulls - a.pulls
+		case "name":
+			return compareNames(a.relPath, b.relPath)
+		}
+		return (b.issues + b.pulls) - (a.issues + a.pulls)
+	})
+
+	width := len("Repository")
+	for _, c := range counts {
+		width = max(width, len(c.relPath))
+	}
+	if len(counts) > 0 {
+		fmt.Printf("\033[1m%-*s %6s %6s\033[0m\n", width, "Repository", "Issues", "PRs")
+	}
+	for _, c := range counts {
+		fmt.Printf("%-*s %6d %6d\n", width, c.relPath, c.issues, c.pulls)
+	}
+	return exitCode
+}
+
+// openCounts counts the open issues and pull (or merge) requests of the repository.
+func (c *forgeClient) openCounts(r forgeRepo, filter triageFilter) (int, int, error) {
+	if c.kind == forgeGitLab {
+		query := url.Values{"state": {"opened"}, "per_page": {"1"}}
+		if filter.label != "" {
+			query.Set("labels", filter.label)
+		}
+		if !filter.before.IsZero() {
+			query.Set("created_before", filter.before.UTC().Format(time.RFC3339))
+		}
+		issues, err := c.gitlabTotal(c.repoPath(r) + "/issues?" + query.Encode())
+		if err != nil {
+			return 0, 0, err
+		}
+		pulls, err := c.gitlabTotal(c.repoPath(r) + "/merge_requests?" + query.Encode())
+		return issues, pulls, err
+	}
+
+	// GitHub's search counts without listing everything
+	q := "repo:" + r.path + " state:open"
+	if filter.label != "" {
+		q += fmt.Sprintf(" label:%q", filter.label)
+	}
+	if !filter.before.IsZero() {
+		q += " created:<" + filter.before.UTC().Format("2006-01-02T15:04:05Z")
+	}
+	count := func(kind string) (int, error) {
+		var result struct {
+			TotalCount int `json:"total_count"`
+		}
+		err := c.get("/search/issues?"+url.Values{"q": {q + " type:" + kind}, "per_page": {"1"}}.Encode(), &result)
+		return result.TotalCount, err
+	}
+	issues, err := count("issue")
+	if err != nil {
+		return 0, 0, err
+	}
+	pulls, err := count("pr")
+	return issues, pulls, err
+}
+
+// gitlabTotal returns the total number of results of a GitLab list request.
+func (c *forgeClient) gitlabTotal(path string) (int, error) {
+	resp, err := c.request(http.MethodGet, path, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	total, err := strconv.Atoi(resp.Header.Get("X-Total"))
+	if err != nil {
+		return 0, fmt.Errorf("no total in the response")
+	}
+	return total, nil
+}
@@ -0,0 +1,359 @@
+//go:build !notui
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+)
+
+func init() {
+	builtFeatures["tui"] = true
+}
+
+// tuiStatus is the status of a repository collected in the background.
+type tuiStatus struct {
+	path   string
+	status repoStatus
+}
+
+// tui is the state of the interactive mode of -tui.
+type tui struct {
+	ws *workspace
+	fd int
+	// statuses are the statuses collected so far, by path
+	statuses map[string]repoStatus
+	updates  chan tuiStatus
+	selected map[string]bool
+
+	filter string
+	// filtering is set while typing the filter, running while typing the command
+	filtering bool
+	running   bool
+	command   string
+	cursor    int
+	offset    int
+	message   string
+}
+
+// runTUI shows the status of the repositories as it comes in, and lets the
+// user fuzzy filter them, select some and run a command in those.
+func runTUI(ws *workspace) int {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) || !isTerminal(os.Stdout) {
+		fmt.Println("Invalid -tui: needs a terminal")
+		return 1
+	}
+	t := &tui{
+		ws:       ws,
+		fd:       fd,
+		statuses: make(map[string]repoStatus),
+		updates:  make(chan tuiStatus, len(ws.repos)),
+		selected: make(map[string]bool),
+	}
+
+	// keys are only read when asked for, so commands that are run get the input
+	keys := make(chan []byte)
+	wantKey := make(chan struct{})
+	go func() {
+		buf := make([]byte, 64)
+		for range wantKey {
+			n, err := os.Stdin.Read(buf)
+			if err != nil {
+				close(keys)
+				return
+			}
+			keys <- slices.Clone(buf[:n])
+		}
+	}()
+
+	restore, err := t.enter()
+	if err != nil {
+		fmt.Println("Error:", err)
+		return 1
+	}
+	defer func() { restore() }()
+
+	t.refresh(ws.repos)
+	wantKey <- struct{}{}
+	for {
+		t.render()
+		select {
+		case u := <-t.updates:
+			t.statuses[u.path] = u.status
+		case key, ok := <-keys:
+			if !ok {
+				return 0
+			}
+			line, quit := t.handleKey(key)
+			if quit {
+				return 0
+			}
+			if line != "" {
+				restore()
+				repos := t.targets()
+				t.execute(line, repos)
+				if restore, err = t.enter(); err != nil {
+					fmt.Println("Error:", err)
+					return 1
+				}
+				t.refresh(repos)
+			}
+			wantKey <- struct{}{}
+		}
+	}
+}
+
+// enter switches to the alternate screen in raw mode, returning how to
+// switch back.
+func (t *tui) enter() (func(), error) {
+	state, err := term.MakeRaw(t.fd)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Print("\033[?1049h\033[?25l")
+	return func() {
+		fmt.Print("\033[?25h\033[?1049l")
+		term.Restore(t.fd, state)
+	}, nil
+}
+
+// refresh collects the status of the repositories in the background.
+func (t *tui) refresh(repos []string) {
+	for _, path := range repos {
+		delete(t.statuses, path)
+	}
+	go forEachRepoWith(repos, t.ws.parallel, false, func(path string) (string, int) {
+		t.updates <- tuiStatus{path: path, status: collectStatus(path)}
+		return "", 0
+	})
+}
+
+// visible returns the repositories matching the filter.
+func (t *tui) visible() []string {
+	if t.filter == "" {
+		return t.ws.repos
+	}
+	var repos []string
+	for _, path := range t.ws.repos {
+		if fuzzyMatch(t.filter, relativePath(t.ws.root, path)+" "+t.statuses[path].Branch) {
+			repos = append(repos, path)
+		}
+	}
+	return repos
+}
+
+// targets returns the selected repositories, or the one under the cursor.
+func (t *tui) targets() []string {
+	var repos []string
+	for _, path := range t.ws.repos {
+		if t.selected[path] {
+			repos = append(repos, path)
+		}
+	}
+	if len(repos) == 0 {
+		if visible := t.visible(); t.cursor < len(visible) {
+			repos = []string{visible[t.cursor]}
+		}
+	}
+	return repos
+}
+
+// handleKey updates the state for a key press, returning a command line to
+// run or whether to quit.
+func (t *tui) handleKey(key []byte) (string, bool) {
+	t.message = ""
+	visible := t.visible()
+	s := string(key)
+
+	if t.filtering || t.running {
+		text := &t.filter
+		if t.running {
+			text = &t.command
+		}
+		switch {
+		case s == "\r" && t.running:
+			t.running = false
+			line := strings.TrimSpace(t.command)
+			t.command = ""
+			if line != "" && len(t.targets()) == 0 {
+				t.message = "No repository to run it in"
+				return "", false
+			}
+			return line, false
+		case s == "\r" || s == "\033":
+			if s == "\033" && t.running {
+				t.command = ""
+			}
+			t.filtering, t.running = false, false
+		case s == "\x7f" || s == "\b":
+			if _, size := utf8.DecodeLastRuneInString(*text); size > 0 {
+				*text = (*text)[:len(*text)-size]
+			}
+		case s == "\x03":
+			return "", true
+		default:
+			for _, r := range s {
+				if unicode.IsPrint(r) {
+					*text += string(r)
+				}
+			}
+		}
+		if t.filtering {
+			t.cursor = 0
+		}
+		return "", false
+	}
+
+	switch s {
+	case "q", "\x03":
+		return "", true
+	case "\033[A", "k":
+		t.cursor--
+	case "\033[B", "j":
+		t.cursor++
+	case "\033[5~":
+		t.cursor -= t.rows()
+	case "\033[6~":
+		t.cursor += t.rows()
+	case " ":
+		if t.cursor < len(visible) {
+			path := visible[t.cursor]
+			t.selected[path] = !t.selected[path]
+			t.cursor++
+		}
+	case "a":
+		// select all the visible repositories, or none if they already are
+		all := !slices.ContainsFunc(visible, func(path string) bool { return !t.selected[path] })
+		for _, path := range visible {
+			t.selected[path] = !all
+		}
+	case "/":
+		t.filtering = true
+	case "\r", ":":
+		t.running = true
+	case "r":
+		t.refresh(t.ws.repos)
+	}
+	t.cursor = max(0, min(t.cursor, len(visible)-1))
+	return "", false
+}
+
+// execute runs the command line like gits shell in the repositories, and
+// waits for enter before returning to the table.
+func (t *tui) execute(line string, repos []string) {
+	fmt.Printf("\033[1m%s\033[0m in %d repositories\n", line, len(repos))
+	sh := &gitsShell{ws: t.ws, all: repos, statuses: make(map[string]repoStatus)}
+	sh.execute(line)
+	fmt.Print("Press enter to return")
+	bufio.NewReader(os.Stdin).ReadString('\n')
+}
+
+// rows is how many repositories fit on the screen.
+func (t *tui) rows() int {
+	_, height, err := term.GetSize(t.fd)
+	if err != nil || height <= 0 {
+		height = 24
+	}
+	// the header, the filter and the help lines
+	return max(1, height-3)
+}
+
+func (t *tui) render() {
+	width, _, err := term.GetSize(t.fd)
+	if err != nil || width <= 0 {
+		width = 80
+	}
+	visible := t.visible()
+	rows := t.rows()
+	if t.cursor < t.offset {
+		t.offset = t.cursor
+	}
+	if t.cursor >= t.offset+rows {
+		t.offset = t.cursor - rows + 1
+	}
+	t.offset = max(0, min(t.offset, len(visible)-rows))
+
+	selected := 0
+	for _, ok := range t.selected {
+		if ok {
+			selected++
+		}
+	}
+	var b strings.Builder
+	b.WriteString("\033[H\033[2J")
+	header := fmt.Sprintf("\033[1mgits\033[0m %d repositories, %d shown, %d selected", len(t.ws.repos), len(visible), selected)
+	if len(t.statuses) < len(t.ws.repos) {
+		header += fmt.Sprintf(", ⚡️ %d/%d", len(t.statuses), len(t.ws.repos))
+	}
+	b.WriteString(fitWidth(header, width) + "\r\n")
+	filter := "Filter: " + t.filter
+	if t.filtering {
+		filter += "█"
+	}
+	b.WriteString(fitWidth(filter, width) + "\r\n")
+
+	nameWidth := longestRelPath(t.ws.root, t.ws.repos)
+	for i := t.offset; i < len(visible) && i < t.offset+rows; i++ {
+		path := visible[i]
+		mark := "[ ] "
+		if t.selected[path] {
+			mark = "[x] "
+		}
+		row := relativePath(t.ws.root, path) + " …"
+		if st, ok := t.statuses[path]; ok {
+			row = formatStatus(path, t.ws.root, nameWidth, false, st)
+		}
+		row = fitWidth(mark+row, width)
+		if i == t.cursor {
+			// the reverse video is reset by the colors of the row, so plain
+			row = "\033[7m" + fitWidth(ansiEscape.ReplaceAllString(row, ""), width) + "\033[0m"
+		}
+		b.WriteString(row + "\r\n")
+	}
+	for i := len(visible) - t.offset; i < rows; i++ {
+		b.WriteString("\r\n")
+	}
+
+	switch {
+	case t.running:
+		b.WriteString(fitWidth(fmt.Sprintf("Run in %d repositories: %s█", len(t.targets()), t.command), width))
+	case t.message != "":
+		b.WriteString(fitWidth(t.message, width))
+	default:
+		b.WriteString(fitWidth("↑↓ move  space select  a all  / filter  enter run a command  r refresh  q quit", width))
+	}
+	fmt.Print(b.String())
+}
+
+// fitWidth cuts a line with colors down to the width of the terminal,
+// dropping the colors if it has to.
+func fitWidth(line string, width int) string {
+	plain := ansiEscape.ReplaceAllString(line, "")
+	if utf8.RuneCountInString(plain) < width {
+		return line
+	}
+	runes := []rune(plain)
+	return string(runes[:max(0, width-1)])
+}
+
+// fuzzyMatch reports whether the characters of the pattern are in the text
+// in order, ignoring case.
+func fuzzyMatch(pattern string, text string) bool {
+	text = strings.ToLower(text)
+	for _, r := range strings.ToLower(pattern) {
+		i := strings.IndexRune(text, r)
+		if i < 0 {
+			return false
+		}
+		text = text[i+utf8.RuneLen(r):]
+	}
+	return true
+}
@@ -0,0 +1,11 @@
+//go:build notui
+
+package main
+
+import "fmt"
+
+// runTUI explains that this build has no interactive mode.
+func runTUI(ws *workspace) int {
+	fmt.Println("Error:", errFeatureMissing("tui"))
+	return 1
+}
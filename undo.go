@@ -0,0 +1,307 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+func init() {
+	registerSubcommand(&subcommand{
+		name:    "undo",
+		summary: "restore the repositories to their snapshot before a run with -snapshot, or list the snapshots",
+		run:     runUndo,
+
+		standalone: true,
+	})
+}
+
+// snapshotsFile is the state file of the snapshots taken with -snapshot.
+const snapshotsFile = "snapshots.json"
+
+// maxSnapshots is how many snapshots are kept, the oldest are dropped.
+const maxSnapshots = 20
+
+// snapshotRun is the state of the repositories before a run.
+type snapshotRun struct {
+	ID      string    `json:"id"`
+	Time    time.Time `json:"time"`
+	Command []string  `json:"command"`
+	Root    string    `json:"root"`
+	// Repos are the snapshots by absolute path
+	Repos map[string]repoSnapshot `json:"repos"`
+}
+
+// repoSnapshot is the state of a repository, with its index and worktree
+// saved as commits under refs/gits/undo/<id>/ so they are not collected.
+type repoSnapshot struct {
+	// Head is the commit checked out, empty without any commits
+	Head string `json:"head,omitempty"`
+	// Branch is the branch checked out, empty if detached
+	Branch string `json:"branch,omitempty"`
+	// Branches are the tips of the local branches by ref
+	Branches map[string]string `json:"branches"`
+	// Index is the commit of the tree of the index
+	Index string `json:"index"`
+	// Worktree is the commit of the tree of the tracked and untracked
+	// files, apart from the ignored ones
+	Worktree string `json:"worktree"`
+}
+
+type snapshotState struct {
+	Runs []snapshotRun `json:"runs"`
+}
+
+// snapshotBefore snapshots the repositories before a command that is forced
+// or matches a write pattern runs in them, with -snapshot, returning the ID
+// of the snapshot or the empty string if none was taken.
+func (ws *workspace) snapshotBefore(command []string, repos []string) (string, error) {
+	if _, forced := forceArgument(command); !ws.snapshot || (!forced && !ws.config.isWriteCommand(command)) {
+		return "", nil
+	}
+	return snapshotRepos(ws.root, command, repos, ws.parallel)
+}
+
+// snapshotRepos takes a snapshot of each of the repositories and records
+// it under a new id for gits undo.
+func snapshotRepos(root string, command []string, repos []string, parallel int) (string, error) {
+	run := snapshotRun{ID: newRunID(), Time: time.Now(), Command: command, Root: root, Repos: make(map[string]repoSnapshot)}
+	var mu sync.Mutex
+	results, exitCode := forEachRepoWith(repos, parallel, false, func(path string) (string, int) {
+		s, err := snapshotRepo(path, run.ID)
+		if err != nil {
+			return fmt.Sprintf("%s: %v", relativePath(root, path), err), 1
+		}
+		mu.Lock()
+		run.Repos[path] = s
+		mu.Unlock()
+		return "", 0
+	})
+	if exitCode != 0 {
+		dropSnapshotRefs(run)
+		return "", fmt.Errorf("%s", strings.Join(slices.DeleteFunc(results, func(r string) bool { return r == "" }), "\n  "))
+	}
+
+	var state snapshotState
+	if err := readState(snapshotsFile, &state); err != nil {
+		return "", err
+	}
+	state.Runs = append(state.Runs, run)
+	if len(state.Runs) > maxSnapshots {
+		for _, old := range state.Runs[:len(state.Runs)-maxSnapshots] {
+			dropSnapshotRefs(old)
+		}
+		state.Runs = slices.Clone(state.Runs[len(state.Runs)-maxSnapshots:])
+	}
+	return run.ID, writeState(snapshotsFile, state)
+}
+
+// snapshotRepo saves the index and worktree of the repository as commits
+// and records where its HEAD and branches are.
+func snapshotRepo(path string, id string) (repoSnapshot, error) {
+	var s repoSnapshot
+	s.Head, _ = resolveCommit(path, "HEAD")
+	s.Branch = getSymbolicRef(path, "HEAD")
+	if s.Head == "" {
+		// an unborn branch has nothing to return to
+		s.Branch = ""
+	}
+	var err error
+	if s.Branches, err = getRefTips(path, "refs/heads/"); err != nil {
+		return s, err
+	}
+
+	indexTree, err := runGit(path, "write-tree")
+	if err != nil {
+		return s, err
+	}
+	worktreeTree, err := writeWorktreeTree(path)
+	if err != nil {
+		return s, err
+	}
+
+	message := "gits snapshot " + id
+	args := []string{"commit-tree", "-m", message}
+	if s.Head != "" {
+		args = append(args, "-p", s.Head)
+	}
+	if s.Index, err = runGit(path, append(args, indexTree)...); err != nil {
+		return s, err
+	}
+	if s.Worktree, err = runGit(path, append(args, worktreeTree)...); err != nil {
+		return s, err
+	}
+	for name, hash := range map[string]string{"index": s.Index, "worktree": s.Worktree} {
+		if _, err := runGit(path, "update-ref", "refs/gits/undo/"+id+"/"+name, hash); err != nil {
+			return s, err
+		}
+	}
+	return s, nil
+}
+
+// writeWorktreeTree writes the tree of the worktree, including the
+// untracked files but not the ignored ones, using a copy of the index so
+// the index itself is left alone.
+func writeWorktreeTree(path string) (string, error) {
+	index, err := runGit(path, "rev-parse", "--path-format=absolute", "--git-path", "index")
+	if err != nil {
+		return "", err
+	}
+	dir, err := tempDir()
+	if err != nil {
+		return "", err
+	}
+	tmp, err := os.CreateTemp(dir, "index-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	if data, err := os.ReadFile(index); err == nil {
+		tmp.Write(data)
+	}
+	tmp.Close()
+
+	env := append(os.Environ(), "GIT_INDEX_FILE="+tmp.Name())
+	for _, args := range [][]string{{"add", "-A"}, {"write-tree"}} {
+		cmd := exec.Command("git", append([]string{"-C", path}, args...)...)
+		cmd.Env = env
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			return "", fmt.Errorf("git %s: %s", args[0], strings.TrimSpace(string(out)))
+		}
+		if args[0] == "write-tree" {
+			return strings.TrimSpace(string(out)), nil
+		}
+	}
+	return "", nil
+}
+
+// dropSnapshotRefs deletes the refs keeping the commits of the snapshot.
+func dropSnapshotRefs(run snapshotRun) {
+	for path := range run.Repos {
+		runGit(path, "update-ref", "-d", "refs/gits/undo/"+run.ID+"/index")
+		runGit(path, "update-ref", "-d", "refs/gits/undo/"+run.ID+"/worktree")
+	}
+}
+
+func runUndo(ws *workspace, args []string) int {
+	fs := newSubcommandFlags("undo", " [run id]")
+	fs.Parse(args)
+
+	var state snapshotState
+	if err := readState(snapshotsFile, &state); err != nil {
+		fmt.Println("Error reading snapshots:", err)
+		return 1
+	}
+
+	if fs.NArg() == 0 {
+		if len(state.Runs) == 0 {
+			fmt.Println("No snapshots, run destructive commands with -snapshot to take them")
+			return 0
+		}
+		for i := len(state.Runs) - 1; i >= 0; i-- {
+			run := state.Runs[i]
+			fmt.Printf("%s  %s  %d repositories  %s\n", run.ID, run.Time.Format("2006-01-02 15:04"), len(run.Repos), strings.Join(run.Command, " "))
+		}
+		return 0
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 1
+	}
+	i := slices.IndexFunc(state.Runs, func(r snapshotRun) bool { return r.ID == fs.Arg(0) })
+	if i < 0 {
+		fmt.Printf("No snapshot %s, gits undo lists them\n", fs.Arg(0))
+		return 1
+	}
+	run := state.Runs[i]
+
+	release, ok := ws.lock("undo")
+	if !ok {
+		return 1
+	}
+	defer release()
+
+	var paths, present []string
+	for path := range run.Repos {
+		paths = append(paths, path)
+		if isGitRepo(path) {
+			present = append(present, path)
+		}
+	}
+	slices.Sort(paths)
+	slices.Sort(present)
+
+	// undoing is destructive too, so it can be undone in turn
+	id, err := snapshotRepos(run.Root, []string{"gits", "undo", run.ID}, present, ws.parallel)
+	if err != nil {
+		fmt.Printf("Error taking a snapshot before undoing:\n  %v\n", err)
+		return 1
+	}
+
+	results, exitCode := forEachRepo(paths, ws.parallel, func(path string) (string, int) {
+		relPath := relativePath(run.Root, path)
+		if err := restoreSnapshot(path, run.Repos[path]); err != nil {
+			return fmt.Sprintf("\033[1m❌ %s:\033[0m %v", relPath, err), 1
+		}
+		return fmt.Sprintf("\033[1m✅️ %s:\033[0m restored", relPath), 0
+	})
+	printResults(results)
+	fmt.Printf("Restored the repositories as they were before %s, gits undo %s reverts this\n", strings.Join(run.Command, " "), id)
+	return exitCode
+}
+
+// restoreSnapshot returns the branches, HEAD, worktree and index of the
+// repository to the snapshot.
+func restoreSnapshot(path string, s repoSnapshot) error {
+	if !isGitRepo(path) {
+		return fmt.Errorf("no longer a repository")
+	}
+	current, err := getRefTips(path, "refs/heads/")
+	if err != nil {
+		return err
+	}
+	for ref, hash := range s.Branches {
+		if current[ref] != hash {
+			if _, err := runGit(path, "update-ref", ref, hash); err != nil {
+				return err
+			}
+		}
+	}
+	for ref := range current {
+		if _, ok := s.Branches[ref]; !ok {
+			if _, err := runGit(path, "update-ref", "-d", ref); err != nil {
+				return err
+			}
+		}
+	}
+
+	switch {
+	case s.Branch != "":
+		_, err = runGit(path, "symbolic-ref", "HEAD", s.Branch)
+	case s.Head != "":
+		_, err = runGit(path, "update-ref", "--no-deref", "HEAD", s.Head)
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, err := runGit(path, "read-tree", "--reset", "-u", s.Worktree+"^{tree}"); err != nil {
+		return err
+	}
+	_, err = runGit(path, "read-tree", s.Index+"^{tree}")
+	return err
+}
+
+// newRunID returns a random identifier for a run.
+func newRunID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
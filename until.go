@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// untilCondition is what a command repeated with -until-ok or -until-output
+// waits for in each repository, such as a tag being replicated to a mirror.
+type untilCondition struct {
+	// ok waits for the command to succeed
+	ok bool
+	// pattern, if set, waits for the output of the command to match it
+	pattern *regexp.Regexp
+	// interval is how long to wait between runs of the command
+	interval time.Duration
+	// timeout is how long to repeat the command before giving up
+	timeout time.Duration
+}
+
+// met reports whether a run of the command meets the condition.
+func (u *untilCondition) met(output string, exitCode int) bool {
+	if u.ok && exitCode != 0 {
+		return false
+	}
+	return u.pattern == nil || u.pattern.MatchString(output)
+}
+
+// poll repeats the command until a run of it meets the condition, starting
+// with the run given, or the timeout passes, when the last run is reported
+// as failed.
+func (u *untilCondition) poll(run func() (string, int, resourceUsage), output string, exitCode int, usage resourceUsage) (string, int, resourceUsage) {
+	start := time.Now()
+	runs := 1
+	for !u.met(output, exitCode) {
+		if time.Since(start)+u.interval > u.timeout {
+			note := fmt.Sprintf("condition not met after %d runs in %s", runs, time.Since(start).Round(time.Second))
+			if output != "" && !strings.HasSuffix(output, "\n") {
+				note = "\n" + note
+			}
+			return output + note, max(exitCode, 1), usage
+		}
+		time.Sleep(u.interval)
+		output, exitCode, usage = retryLockContention(run)
+		runs++
+	}
+	// the output of a command failing until the pattern matches is all that matters
+	if !u.ok {
+		exitCode = 0
+	}
+	return output, exitCode, usage
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// resourceUsage is what a command used in a repository, shown by -timings
+// and in the records of -format json and ndjson.
+type resourceUsage struct {
+	DurationMS int64 `json:"duration_ms"`
+	// CPUMS is the user and system CPU time of the command and the
+	// processes it waited for
+	CPUMS int64 `json:"cpu_ms"`
+	// MaxRSSKB is the peak resident set size of the command or the largest
+	// of the processes it waited for, where the platform reports it
+	MaxRSSKB int64 `json:"max_rss_kb,omitempty"`
+	// BytesReceived and BytesSent are from the transfer stats git prints
+	// with its progress, such as with git fetch --progress
+	BytesReceived int64 `json:"bytes_received,omitempty"`
+	BytesSent     int64 `json:"bytes_sent,omitempty"`
+}
+
+// transferStats matches the final progress lines of git transfers, such as
+// "Receiving objects: 100% (12/12), 1.20 MiB | 3.00 MiB/s, done."
+var transferStats = regexp.MustCompile(`(Receiving|Writing) objects: 100% \(\d+/\d+\), ([0-9.]+) (bytes|KiB|MiB|GiB)`)
+
+var byteUnits = map[string]float64{"bytes": 1, "KiB": 1 << 10, "MiB": 1 << 20, "GiB": 1 << 30}
+
+// measureUsage collects the usage of a command that has exited.
+func measureUsage(state *os.ProcessState, elapsed time.Duration, output string) resourceUsage {
+	u := resourceUsage{DurationMS: elapsed.Milliseconds()}
+	if state != nil {
+		u.CPUMS = (state.UserTime() + state.SystemTime()).Milliseconds()
+		u.MaxRSSKB = maxRSSKB(state)
+	}
+	for _, m := range transferStats.FindAllStringSubmatch(output, -1) {
+		v, _ := strconv.ParseFloat(m[2], 64)
+		n := int64(v * byteUnits[m[3]])
+		if m[1] == "Receiving" {
+			u.BytesReceived += n
+		} else {
+			u.BytesSent += n
+		}
+	}
+	return u
+}
+
+// writeTimings prints the usage of each repository, slowest first, and the
+// totals.
+func writeTimings(w io.Writer, runs []repoRun) {
+	runs = slices.DeleteFunc(slices.Clone(runs), func(r repoRun) bool { return r.Skipped != "" })
+	slices.SortStableFunc(runs, func(a, b repoRun) int { return int(b.Usage.DurationMS - a.Usage.DurationMS) })
+	width := len("total")
+	for _, run := range runs {
+		width = max(width, len(run.RelPath))
+	}
+
+	var total resourceUsage
+	fmt.Fprintln(w, "Timings:")
+	for _, run := range runs {
+		fmt.Fprintf(w, "  %-*s  %s\n", width, run.RelPath, formatUsage(run.Usage))
+		total.DurationMS += run.Usage.DurationMS
+		total.CPUMS += run.Usage.CPUMS
+		total.MaxRSSKB = max(total.MaxRSSKB, run.Usage.MaxRSSKB)
+		total.BytesReceived += run.Usage.BytesReceived
+		total.BytesSent += run.Usage.BytesSent
+	}
+	fmt.Fprintf(w, "  %-*s  %s\n", width, "total", formatUsage(total))
+}
+
+// formatUsage renders the usage on one line.
+func formatUsage(u resourceUsage) string {
+	s := fmt.Sprintf("%8s  cpu %8s", (time.Duration(u.DurationMS) * time.Millisecond).String(), (time.Duration(u.CPUMS) * time.Millisecond).String())
+	if u.MaxRSSKB > 0 {
+		s += "  rss " + formatBytes(u.MaxRSSKB*1024)
+	}
+	if u.BytesReceived > 0 || u.BytesSent > 0 {
+		s += fmt.Sprintf("  received %s  sent %s", formatBytes(u.BytesReceived), formatBytes(u.BytesSent))
+	}
+	return s
+}
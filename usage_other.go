@@ -0,0 +1,10 @@
+//go:build !unix
+
+package main
+
+import "os"
+
+// The peak resident set size is only reported on unix.
+func maxRSSKB(state *os.ProcessState) int64 {
+	return 0
+}
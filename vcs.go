@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// vcsKind is a version control system whose repositories gits recognizes.
+type vcsKind struct {
+	name string
+	// marker is the directory at the root of its repositories
+	marker string
+	// binary is the command that works with its repositories, which the
+	// {vcs} placeholder expands to
+	binary string
+	// status collects the status of a repository, nil for git itself
+	status func(path string) repoStatus
+}
+
+var (
+	vcsGit     = &vcsKind{name: "git", marker: ".git", binary: "git"}
+	vcsJJ      = &vcsKind{name: "jj", marker: ".jj", binary: "jj", status: collectJJStatus}
+	vcsSapling = &vcsKind{name: "sl", marker: ".sl", binary: "sl", status: collectSaplingStatus}
+)
+
+// vcsKinds are in the order they are detected in, jj and Sapling before git
+// as their repositories colocated with git also have a .git.
+var vcsKinds = []*vcsKind{vcsJJ, vcsSapling, vcsGit}
+
+// detectVCS returns the version control system of the repository at path,
+// git for bare repositories and anything else.
+func detectVCS(path string) *vcsKind {
+	for _, k := range vcsKinds {
+		if k == vcsGit {
+			break
+		}
+		if info, err := os.Stat(filepath.Join(path, k.marker)); err == nil && info.IsDir() {
+			return k
+		}
+	}
+	return vcsGit
+}
+
+// isRepo reports whether path is a repository of any of the version control
+// systems, or a bare git repository if bare is set.
+func isRepo(path string, bare bool) bool {
+	return isGitRepo(path) || detectVCS(path) != vcsGit || (bare && isBareRepo(path))
+}
+
+// runVCS runs the command of the version control system in the repository,
+// returning its trimmed output.
+func runVCS(binary string, path string, args ...string) (string, error) {
+	cmd := exec.Command(binary, args...)
+	cmd.Dir = path
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("%s %s: %s", binary, args[0], errorReason(err))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// collectJJStatus collects the status of a Jujutsu repository. The current
+// branch is the bookmark of the working copy commit or its parent, and it
+// is dirty if the working copy commit has changes.
+func collectJJStatus(path string) repoStatus {
+	st := repoStatus{VCS: "jj", DefaultBranch: "main", Sync: SyncRemote, Upstream: upstreamNone}
+	out, err := runVCS("jj", path, "log", "--no-graph", "-r", "@ | @-", "-T",
+		`change_id.shortest() ++ "\t" ++ empty ++ "\t" ++ local_bookmarks.map(|b| b.name()).join(" ") ++ "\n"`)
+	if err != nil {
+		st.Branch = "!" + err.Error()
+		return st
+	}
+	// the working copy commit comes first
+	for i, line := range strings.Split(out, "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) != 3 {
+			continue
+		}
+		if i == 0 {
+			st.Dirty = fields[1] == "false"
+			st.Branch = "@" + fields[0]
+		}
+		if bookmarks := strings.Fields(fields[2]); len(bookmarks) > 0 {
+			st.Branch = bookmarks[0]
+			break
+		}
+	}
+
+	if out, err := runVCS("jj", path, "bookmark", "list", "-T", `name ++ "\n"`); err == nil {
+		st.Branches = otherBranches(out, st.Branch)
+	}
+	return st
+}
+
+// collectSaplingStatus collects the status of a Sapling repository. The
+// current branch is the active bookmark.
+func collectSaplingStatus(path string) repoStatus {
+	st := repoStatus{VCS: "sl", DefaultBranch: "main", Sync: SyncRemote, Upstream: upstreamNone}
+	out, err := runVCS("sl", path, "log", "-r", ".", "-T", "{node|short}\t{activebookmark}")
+	if err != nil {
+		st.Branch = "!" + err.Error()
+		return st
+	}
+	node, bookmark, _ := strings.Cut(out, "\t")
+	st.Branch = bookmark
+	if st.Branch == "" {
+		st.Branch = node
+	}
+
+	changes, err := runVCS("sl", path, "status")
+	st.Dirty = err != nil || changes != ""
+
+	if out, err := runVCS("sl", path, "bookmarks", "-T", "{bookmark}\n"); err == nil {
+		st.Branches = otherBranches(out, st.Branch)
+	}
+	return st
+}
+
+// otherBranches returns the names, one per line, apart from the current one.
+func otherBranches(out string, current string) []string {
+	var branches []string
+	for _, name := range strings.Fields(out) {
+		if name != current {
+			branches = append(branches, name)
+		}
+	}
+	sortNames(branches)
+	return branches
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+func init() {
+	registerSubcommand(&subcommand{
+		name:    "version",
+		summary: "print the version and build of gits, and check for a newer release",
+		run:     runVersion,
+
+		standalone: true,
+	})
+}
+
+// The build, set by release builds with
+//
+//	go build -ldflags "-X main.version=v1.2.3 -X main.commit=$(git rev-parse HEAD) -X main.date=$(date -u +%FT%TZ)"
+//
+// and otherwise taken from the build info Go embeds, as for go install.
+var (
+	version = ""
+	commit  = ""
+	date    = ""
+)
+
+// releasesRepo is the GitHub repository gits is released from.
+const releasesRepo = "stephenc/gits"
+
+// buildInfo is the output of `gits version -json`.
+type buildInfo struct {
+	Version string `json:"version"`
+	Commit  string `json:"commit,omitempty"`
+	Date    string `json:"date,omitempty"`
+	// Modified is set for builds of a worktree with uncommitted changes
+	Modified  bool   `json:"modified,omitempty"`
+	GoVersion string `json:"go_version"`
+	Platform  string `json:"platform"`
+	// Latest is the latest release, with -check
+	Latest string `json:"latest,omitempty"`
+	// UpToDate is whether this is the latest release, with -check
+	UpToDate *bool `json:"up_to_date,omitempty"`
+	// Features are the optional features in the build
+	Features []string `json:"features"`
+}
+
+// gitsVersion returns the version of this gits, read once.
+var gitsVersion = sync.OnceValue(func() string {
+	return currentBuild().Version
+})
+
+// currentBuild returns the build of this gits.
+func currentBuild() buildInfo {
+	b := buildInfo{Version: version, Commit: commit, Date: date, GoVersion: runtime.Version(), Platform: runtime.GOOS + "/" + runtime.GOARCH}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		if b.Version == "" && info.Main.Version != "(devel)" {
+			b.Version = info.Main.Version
+		}
+		for _, s := range info.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				if b.Commit == "" {
+					b.Commit = s.Value
+				}
+			case "vcs.time":
+				if b.Date == "" {
+					b.Date = s.Value
+				}
+			case "vcs.modified":
+				b.Modified = s.Value == "true"
+			}
+		}
+	}
+	if b.Version == "" {
+		b.Version = "devel"
+	}
+	b.Features = featureNames()
+	return b
+}
+
+func (b buildInfo) String() string {
+	var details []string
+	if b.Commit != "" {
+		c := fmt.Sprintf("commit %.12s", b.Commit)
+		if b.Modified {
+			c += " with uncommitted changes"
+		}
+		details = append(details, c)
+	}
+	if b.Date != "" {
+		details = append(details, "built "+b.Date)
+	}
+	details = append(details, b.GoVersion, b.Platform)
+	return fmt.Sprintf("gits %s (%s)", b.Version, strings.Join(details, ", "))
+}
+
+func runVersion(ws *workspace, args []string) int {
+	fs := newSubcommandFlags("version", "")
+	check := fs.Bool("check", false, "compare with the latest release, exiting with 1 if there is a newer one")
+	asJSON := fs.Bool("json", false, "print the build as JSON")
+	features := fs.Bool("features", false, "list the optional features and whether this build has them")
+	fs.Parse(args)
+
+	if *features && !*asJSON {
+		fmt.Println(currentBuild())
+		printFeatures()
+		return 0
+	}
+
+	b := currentBuild()
+	exitCode := 0
+	if *check {
+		latest, err := latestRelease(ws)
+		if err != nil {
+			fmt.Println("Error finding the latest release:", err)
+			return 1
+		}
+		b.Latest = latest
+		upToDate := compareVersions(b.Version, b.Latest) >= 0
+		b.UpToDate = &upToDate
+		if !upToDate {
+			exitCode = 1
+		}
+	}
+
+	if *asJSON {
+		data, _ := json.MarshalIndent(b, "", "  ")
+		fmt.Println(string(data))
+		return exitCode
+	}
+	fmt.Println(b)
+	switch {
+	case b.UpToDate == nil:
+	case *b.UpToDate:
+		fmt.Printf("%s is the latest release\n", b.Latest)
+	default:
+		fmt.Printf("%s is available, upgrade with:\n  %s\n", b.Latest, upgradeCommand())
+	}
+	return exitCode
+}
+
+// upgradeCommand returns how to upgrade gits, from where it was installed.
+func upgradeCommand() string {
+	exe, err := os.Executable()
+	if err == nil {
+		exe, _ = filepath.EvalSymlinks(exe)
+	}
+	exe = filepath.ToSlash(exe)
+	switch {
+	case strings.Contains(exe, "/Cellar/") || strings.Contains(exe, "/homebrew/") || strings.Contains(exe, "/linuxbrew/"):
+		return "brew upgrade gits"
+	case strings.Contains(strings.ToLower(exe), "/scoop/"):
+		return "scoop update gits"
+	default:
+		return "go install github.com/" + releasesRepo + "@latest"
+	}
+}
+
+// compareVersions compares two versions such as v1.2.3, returning -1, 0 or
+// 1 as a is older than, the same as or newer than b. Development builds are
+// older than any release, and pre-releases older than their release.
+func compareVersions(a string, b string) int {
+	parse := func(v string) ([3]int, string, bool) {
+		var parts [3]int
+		v, pre, _ := strings.Cut(strings.TrimPrefix(v, "v"), "-")
+		v, _, _ = strings.Cut(v, "+")
+		fields := strings.Split(v, ".")
+		if len(fields) > 3 {
+			return parts, "", false
+		}
+		for i, f := range fields {
+			n, err := strconv.Atoi(f)
+			if err != nil {
+				return parts, "", false
+			}
+			parts[i] = n
+		}
+		return parts, pre, true
+	}
+	pa, preA, okA := parse(a)
+	pb, preB, okB := parse(b)
+	switch {
+	case !okA && !okB:
+		return 0
+	case !okA:
+		return -1
+	case !okB:
+		return 1
+	}
+	for i := range pa {
+		if pa[i] != pb[i] {
+			if pa[i] < pb[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	switch {
+	case preA == preB:
+		return 0
+	case preA == "":
+		return 1
+	case preB == "":
+		return -1
+	}
+	return strings.Compare(preA, preB)
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// walkOptions control how the roots are searched for repositories.
+type walkOptions struct {
+	// exclude are glob patterns of paths, relative to the root being searched, that are skipped
+	exclude []string
+	// bare also finds bare repositories
+	bare bool
+	// maxDepth is how many directories below the root are searched, 0 for all
+	maxDepth int
+	// followSymlinks descends into symbolic links to directories
+	followSymlinks bool
+}
+
+// walkRepos searches the root for repositories, reading the directories
+// concurrently, and returns them in no particular order. It does not
+// descend into the repositories it finds.
+func walkRepos(root string, opts walkOptions) ([]string, error) {
+	var (
+		mu       sync.Mutex
+		repos    []string
+		firstErr error
+		wg       sync.WaitGroup
+		// directory reads are mostly waiting on the filesystem
+		slots = make(chan struct{}, 4*runtime.NumCPU())
+	)
+	fail := func(err error) {
+		mu.Lock()
+		if firstErr == nil {
+			firstErr = err
+		}
+		mu.Unlock()
+	}
+
+	// ancestors are the real paths of the directories above, with
+	// followSymlinks, to stop at links back up to them
+	var visit func(path string, depth int, ancestors []string)
+	visit = func(path string, depth int, ancestors []string) {
+		defer wg.Done()
+		if len(opts.exclude) > 0 && matchesAnyGlob(opts.exclude, filepath.ToSlash(relativePath(root, path))) {
+			return
+		}
+		if opts.followSymlinks {
+			real, err := filepath.EvalSymlinks(path)
+			if err != nil {
+				fail(err)
+				return
+			}
+			if slices.Contains(ancestors, real) {
+				return
+			}
+			ancestors = append(slices.Clip(ancestors), real)
+		}
+		if isRepo(path, opts.bare) {
+			mu.Lock()
+			repos = append(repos, path)
+			mu.Unlock()
+			return
+		}
+		if opts.maxDepth > 0 && depth >= opts.maxDepth {
+			return
+		}
+
+		slots <- struct{}{}
+		entries, err := os.ReadDir(path)
+		<-slots
+		if err != nil {
+			fail(err)
+			return
+		}
+		for _, e := range entries {
+			child := filepath.Join(path, e.Name())
+			isDir := e.IsDir()
+			if !isDir && opts.followSymlinks && e.Type()&os.ModeSymlink != 0 {
+				info, err := os.Stat(child)
+				isDir = err == nil && info.IsDir()
+			}
+			if isDir {
+				wg.Add(1)
+				go visit(child, depth+1, ancestors)
+			}
+		}
+	}
+
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", root)
+	}
+	wg.Add(1)
+	visit(root, 0, nil)
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	if opts.followSymlinks {
+		// a repository reached through several links is only matched once,
+		// at the first of its paths
+		slices.Sort(repos)
+		seen := make(map[string]bool)
+		repos = slices.DeleteFunc(repos, func(path string) bool {
+			real, _ := filepath.EvalSymlinks(path)
+			dup := seen[real]
+			seen[real] = true
+			return dup
+		})
+	}
+	return repos, nil
+}
+
+// discoveryCacheFile is the cache of the repositories found under each
+// root, used with -discovery-cache.
+const discoveryCacheFile = "repos.json"
+
+// discoveryCache holds the repositories found by searching each root with
+// the same walk options, by cacheKey.
+type discoveryCache struct {
+	Roots map[string]cachedWalk `json:"roots"`
+}
+
+type cachedWalk struct {
+	Time  time.Time `json:"time"`
+	Repos []string  `json:"repos"`
+}
+
+// cacheKey identifies a search of the root with the options.
+func (opts walkOptions) cacheKey(root string) string {
+	return fmt.Sprintf("%s|depth=%d|symlinks=%t|bare=%t|exclude=%s", root, opts.maxDepth, opts.followSymlinks, opts.bare, strings.Join(opts.exclude, ","))
+}
+
+func discoveryCachePath() (string, error) {
+	dir, err := cacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, discoveryCacheFile), nil
+}
+
+func readDiscoveryCache() discoveryCache {
+	var cache discoveryCache
+	if path, err := discoveryCachePath(); err == nil {
+		if data, err := os.ReadFile(path); err == nil {
+			// a corrupt cache is searched again
+			json.Unmarshal(data, &cache)
+		}
+	}
+	if cache.Roots == nil {
+		cache.Roots = make(map[string]cachedWalk)
+	}
+	return cache
+}
+
+func writeDiscoveryCache(cache discoveryCache) error {
+	path, err := discoveryCachePath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(cache, "", "  ")
+	if err != nil {
+		return err
+	}
+	// renamed into place so concurrent runs never read half of it
+	tmp := fmt.Sprintf("%s.%d", path, os.Getpid())
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// walkReposCached returns the repositories under the root from the cache,
+// dropping those that are gone, or searches the root if it is not cached
+// or refresh is set and caches what it finds.
+func walkReposCached(root string, opts walkOptions, refresh bool) ([]string, error) {
+	cache := readDiscoveryCache()
+	key := opts.cacheKey(root)
+	if cached, ok := cache.Roots[key]; ok && !refresh {
+		return slices.DeleteFunc(cached.Repos, func(path string) bool {
+			return !isRepo(path, opts.bare)
+		}), nil
+	}
+
+	repos, err := walkRepos(root, opts)
+	if err != nil {
+		return nil, err
+	}
+	sortNames(repos)
+	cache.Roots[key] = cachedWalk{Time: time.Now(), Repos: repos}
+	if err := writeDiscoveryCache(cache); err != nil && !errors.Is(err, os.ErrPermission) {
+		return nil, fmt.Errorf("writing the discovery cache: %w", err)
+	}
+	return repos, nil
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"time"
+)
+
+func init() {
+	registerSubcommand(&subcommand{
+		name:    "watch-run",
+		summary: "re-run a command in each repository whenever its files change",
+		run:     runWatchRun,
+	})
+}
+
+// watchedRepo is the state of one repository being watched.
+type watchedRepo struct {
+	path        string
+	fingerprint string
+	// pending is set when the worktree changed and the command has not yet run
+	pending   bool
+	changedAt time.Time
+	running   bool
+
+	ran      bool
+	ranAt    time.Time
+	duration time.Duration
+	output   string
+	exitCode int
+}
+
+type watchResult struct {
+	path     string
+	output   string
+	exitCode int
+	duration time.Duration
+}
+
+func runWatchRun(ws *workspace, args []string) int {
+	fs := newSubcommandFlags("watch-run", " command [args...]")
+	interval := fs.Duration("interval", time.Second, "how often to check the worktrees for changes")
+	debounce := fs.Duration("debounce", 500*time.Millisecond, "how long a worktree must be unchanged before the command runs")
+	lines := fs.Int("lines", 10, "number of output lines to show for each repository, 0 for all")
+	initial := fs.Bool("initial", true, "run the command in every repository when starting")
+	noClear := fs.Bool("no-clear", false, "append results instead of redrawing the screen")
+	fs.Parse(args)
+
+	command := fs.Args()
+	if len(command) == 0 {
+		fmt.Println("No command provided")
+		fs.Usage()
+		return 1
+	}
+	if len(ws.repos) == 0 {
+		fmt.Println("No repositories to watch")
+		return 1
+	}
+
+	// -read-only refuses the commands matching the write patterns, while
+	// ws.lock would refuse any command
+	if err := ws.checkWrite(command); err != nil {
+		fmt.Println("Error:", err)
+		return 1
+	}
+	if !ws.noLock && !isReadOnlyCommand(command) {
+		lock, err := acquireWorkspaceLock(ws.root, ws.lockTimeout, "watch-run "+strings.Join(command, " "))
+		if err != nil {
+			fmt.Println("Error:", err)
+			fmt.Println("Use -lock-timeout to wait for it or -no-lock to run anyway")
+			return 1
+		}
+		defer lock.release()
+	}
+
+	repos := make(map[string]*watchedRepo, len(ws.repos))
+	for _, path := range ws.repos {
+		w := &watchedRepo{path: path, pending: *initial}
+		w.fingerprint, _ = worktreeFingerprint(path)
+		repos[path] = w
+	}
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+
+	results := make(chan watchResult)
+	slots := newLimiter(ws.parallel)
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+
+	fmt.Printf("Watching %d repositories, press Ctrl-C to stop\n", len(repos))
+
+	for {
+		select {
+		case <-interrupt:
+			fmt.Println()
+			return 0
+
+		case r := <-results:
+			w := repos[r.path]
+			w.running = false
+			w.ran = true
+			w.ranAt = time.Now()
+			w.output, w.exitCode, w.duration = r.output, r.exitCode, r.duration
+			// Changes made by the command itself do not trigger another run
+			w.fingerprint, _ = worktreeFingerprint(r.path)
+			printWatchResults(ws, repos, w, *lines, *noClear)
+
+		case now := <-ticker.C:
+			detectWatchChanges(ws, repos)
+			for _, w := range repos {
+				if !w.pending || w.running || now.Sub(w.changedAt) < *debounce {
+					continue
+				}
+				w.pending = false
+				w.running = true
+				go func(path string) {
+					slots.acquire()
+					start := time.Now()
+					info := newRepoInfo(ws.root, path)
+					output, exitCode := runCommand(path, ws.cmdOpts.limits.wrap(info.expand(command)), ws.cmdOpts.env.build(info.environ()))
+					slots.release(time.Since(start), exitCode != 0)
+					results <- watchResult{path: path, output: output, exitCode: exitCode, duration: time.Since(start)}
+				}(w.path)
+			}
+		}
+	}
+}
+
+// detectWatchChanges marks the repositories whose fingerprint has changed as pending.
+func detectWatchChanges(ws *workspace, repos map[string]*watchedRepo) {
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	sem := make(chan struct{}, max(ws.parallel, 1))
+	now := time.Now()
+
+	for _, w := range repos {
+		if w.running {
+			continue
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(w *watchedRepo) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			fp, err := worktreeFingerprint(w.path)
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if fp != w.fingerprint {
+				w.fingerprint = fp
+				w.pending = true
+				w.changedAt = now
+			}
+		}(w)
+	}
+	wg.Wait()
+}
+
+// printWatchResults redraws a pane with the latest output of each repository
+// that has run, or just prints the latest result with noClear.
+func printWatchResults(ws *workspace, repos map[string]*watchedRepo, latest *watchedRepo, lines int, noClear bool) {
+	if noClear {
+		fmt.Println(formatWatchPane(ws.root, latest, lines))
+		return
+	}
+
+	fmt.Print("\033[H\033[2J")
+	for _, path := range ws.repos {
+		if w := repos[path]; w.ran {
+			fmt.Println(formatWatchPane(ws.root, w, lines))
+		}
+	}
+}
+
+func formatWatchPane(root string, w *watchedRepo, lines int) string {
+	status := "✅️"
+	if w.exitCode != 0 {
+		status = "❌"
+	}
+
+	output := strings.Split(strings.TrimRight(w.output, "\n"), "\n")
+	if lines > 0 && len(output) > lines {
+		output = append([]string{fmt.Sprintf("\033[2m... %d lines\033[0m", len(output)-lines)}, output[len(output)-lines:]...)
+	}
+
+	return fmt.Sprintf("\033[1m%s %s:\033[0m \033[2m%s, %s\033[0m\n  %s",
+		status, relativePath(root, w.path), w.ranAt.Format(time.TimeOnly), w.duration.Round(time.Millisecond), strings.Join(output, "\n  "))
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"sync/atomic"
+)
+
+func init() {
+	registerSubcommand(&subcommand{
+		name:    "which",
+		summary: "find the repositories containing a file path or glob",
+		run:     runWhich,
+	})
+}
+
+// matchFile reports whether the file matches the pattern. Patterns without
+// a slash match the file name in any directory, like in .gitignore.
+func matchFile(pattern string, file string) bool {
+	if !strings.Contains(pattern, "/") {
+		return matchGlob(pattern, path.Base(file))
+	}
+	return matchGlob(strings.TrimPrefix(pattern, "/"), file)
+}
+
+func runWhich(ws *workspace, args []string) int {
+	fs := newSubcommandFlags("which", " <path or glob>")
+	history := fs.Bool("history", false, "also match files that only exist in the history of any branch")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 1
+	}
+	pattern := fs.Arg(0)
+
+	var found atomic.Bool
+	results, exitCode := forEachRepo(ws.repos, ws.parallel, func(repo string) (string, int) {
+		relPath := relativePath(ws.root, repo)
+
+		tracked, err := getTrackedFiles(repo)
+		if err != nil {
+			return fmt.Sprintf("\033[1m❌ %s:\033[0m %v", relPath, errorReason(err)), 1
+		}
+
+		var lines []string
+		current := make(map[string]bool)
+		for _, file := range tracked {
+			if matchFile(pattern, file) {
+				lines = append(lines, "  "+file)
+				current[file] = true
+			}
+		}
+
+		if *history {
+			files, err := getHistoricalFiles(repo)
+			if err != nil {
+				return fmt.Sprintf("\033[1m❌ %s:\033[0m %v", relPath, errorReason(err)), 1
+			}
+			for _, file := range files {
+				if !current[file] && matchFile(pattern, file) {
+					lines = append(lines, "  "+file+" \033[2m(history)\033[0m")
+				}
+			}
+		}
+
+		if len(lines) == 0 {
+			return "", 0
+		}
+		found.Store(true)
+		return fmt.Sprintf("\033[1m%s:\033[0m\n%s", relPath, strings.Join(lines, "\n")), 0
+	})
+	printResults(results)
+
+	if exitCode == 0 && !found.Load() {
+		// like grep, finding nothing is a failure
+		return 1
+	}
+	return exitCode
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func init() {
+	registerSubcommand(&subcommand{
+		name:    "worktree",
+		summary: "check out a branch of each repository in a parallel workspace of linked worktrees, see `gits worktree add` and `gits worktree remove`",
+		run:     runWorktree,
+	})
+}
+
+func runWorktree(ws *workspace, args []string) int {
+	if len(args) > 0 {
+		switch args[0] {
+		case "add":
+			return runWorktreeAdd(ws, args[1:])
+		case "remove":
+			return runWorktreeRemove(ws, args[1:])
+		}
+	}
+	fmt.Println("Usage: gits [options] worktree add <path> <branch>")
+	fmt.Println("       gits [options] worktree remove [-force] <path>")
+	return 1
+}
+
+// worktreeTargets returns where the worktree of each repository goes in the
+// parallel workspace at dir, at the same path relative to it as the
+// repository is to the workspace root. Linked worktrees of a repository
+// that is already matched, and repositories within dir, are left out.
+func worktreeTargets(ws *workspace, dir string) map[string]string {
+	targets := make(map[string]string)
+	seen := make(map[string]bool)
+	for _, repo := range ws.repos {
+		if repo == dir || strings.HasPrefix(repo, dir+string(filepath.Separator)) {
+			continue
+		}
+		if _, common, ok := findGitDir(repo); ok {
+			if seen[common] {
+				continue
+			}
+			seen[common] = true
+		}
+		targets[repo] = filepath.Join(dir, relativePath(ws.root, repo))
+	}
+	return targets
+}
+
+func runWorktreeAdd(ws *workspace, args []string) int {
+	fs := newSubcommandFlags("worktree add", " <path> <branch>")
+	fs.Parse(args)
+
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return 1
+	}
+	dir, err := filepath.Abs(fs.Arg(0))
+	if err != nil {
+		fmt.Println("Invalid path:", err)
+		return 1
+	}
+	branch := fs.Arg(1)
+
+	release, ok := ws.lock("worktree add")
+	if !ok {
+		return 1
+	}
+	defer release()
+
+	targets := worktreeTargets(ws, dir)
+	results, exitCode := forEachRepo(ws.repos, ws.parallel, func(repo string) (string, int) {
+		relPath := relativePath(ws.root, repo)
+		target, ok := targets[repo]
+		if !ok {
+			return "", 0
+		}
+		shown := relativePath(ws.root, target)
+		if _, err := os.Stat(target); err == nil {
+			return fmt.Sprintf("\033[1m⏭️ %s:\033[0m %s already exists", relPath, shown), 0
+		}
+
+		var gitArgs []string
+		switch {
+		case refExists(repo, "refs/heads/"+branch):
+			gitArgs = []string{"worktree", "add", target, branch}
+		case refExists(repo, "refs/remotes/origin/"+branch):
+			gitArgs = []string{"worktree", "add", "--track", "-b", branch, target, "origin/" + branch}
+		default:
+			return fmt.Sprintf("\033[1m⏭️ %s:\033[0m no branch %s", relPath, branch), 0
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			return fmt.Sprintf("\033[1m❌ %s:\033[0m %v", relPath, err), 1
+		}
+		if _, err := runGit(repo, gitArgs...); err != nil {
+			removeEmptyParents(filepath.Dir(target), dir)
+			return fmt.Sprintf("\033[1m❌ %s:\033[0m %v", relPath, err), 1
+		}
+		return fmt.Sprintf("\033[1m✅️ %s:\033[0m %s on %s", relPath, shown, branch), 0
+	})
+	printResults(results)
+	// nothing is left behind where no worktree could be added
+	os.Remove(dir)
+	return exitCode
+}
+
+func runWorktreeRemove(ws *workspace, args []string) int {
+	fs := newSubcommandFlags("worktree remove", " <path>")
+	force := fs.Bool("force", false, "remove worktrees with uncommitted changes or untracked files too")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 1
+	}
+	dir, err := filepath.Abs(fs.Arg(0))
+	if err != nil {
+		fmt.Println("Invalid path:", err)
+		return 1
+	}
+
+	release, ok := ws.lock("worktree remove")
+	if !ok {
+		return 1
+	}
+	defer release()
+
+	targets := worktreeTargets(ws, dir)
+	results, exitCode := forEachRepo(ws.repos, ws.parallel, func(repo string) (string, int) {
+		relPath := relativePath(ws.root, repo)
+		target, ok := targets[repo]
+		if !ok {
+			return "", 0
+		}
+		if _, err := os.Stat(target); err != nil {
+			return "", 0
+		}
+		gitArgs := []string{"worktree", "remove", target}
+		if *force {
+			gitArgs = append(gitArgs, "--force")
+		}
+		if _, err := runGit(repo, gitArgs...); err != nil {
+			return fmt.Sprintf("\033[1m❌ %s:\033[0m %v", relPath, err), 1
+		}
+		removeEmptyParents(filepath.Dir(target), dir)
+		return fmt.Sprintf("\033[1m✅️ %s:\033[0m removed %s", relPath, relativePath(ws.root, target)), 0
+	})
+	printResults(results)
+	// the parallel workspace goes once all its worktrees have
+	os.Remove(dir)
+	return exitCode
+}
+
+// removeEmptyParents removes dir and the directories above it up to and
+// excluding top for as long as they are empty.
+func removeEmptyParents(dir string, top string) {
+	for dir != top && strings.HasPrefix(dir, top+string(filepath.Separator)) {
+		if os.Remove(dir) != nil {
+			return
+		}
+		dir = filepath.Dir(dir)
+	}
+}
+
+// refExists returns whether the full ref, such as refs/heads/main, exists.
+func refExists(repo string, ref string) bool {
+	_, err := runGit(repo, "show-ref", "--verify", "--quiet", ref)
+	return err == nil
+}